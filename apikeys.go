@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/dbservices"
+	"os"
+	"strings"
+)
+
+const apiKeysUsage = `usage:
+  coupons apikeys issue -tenant <tenant> -name <name> -scopes <scope,scope,...>
+  coupons apikeys revoke <key id>`
+
+// runAPIKeysCommand issues and revokes API keys, e.g. `coupons apikeys issue -name checkout -scopes coupons:read`
+func runAPIKeysCommand(db *sql.DB, args []string) error {
+	apiKeyService := dbservices.APIKeyService{DB: db}
+
+	if len(args) == 0 {
+		return errors.New(apiKeysUsage)
+	}
+
+	switch args[0] {
+	case "issue":
+		flags := flag.NewFlagSet("apikeys issue", flag.ContinueOnError)
+		tenant := flags.String("tenant", "", "the tenant whose coupons the key can access")
+		name := flags.String("name", "", "who the key is for")
+		scopesString := flags.String("scopes", auth.ScopeCouponsRead, "comma separated scopes to grant")
+
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+
+		if *tenant == "" {
+			return errors.New("-tenant is required")
+		}
+
+		if *name == "" {
+			return errors.New("-name is required")
+		}
+
+		scopes := strings.Split(*scopesString, ",")
+		for _, scope := range scopes {
+			if !auth.ValidScope(scope) {
+				return fmt.Errorf("unknown scope %q, expected one of %s", scope, strings.Join(auth.Scopes, ", "))
+			}
+		}
+
+		plaintextKey, apiKey, err := apiKeyService.CreateAPIKey(context.Background(), *tenant, *name, scopes)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stdout, "id:     %s\ntenant: %s\nscopes: %s\nkey:    %s\n", apiKey.ID, apiKey.TenantID, strings.Join(apiKey.Scopes, ","), plaintextKey)
+		fmt.Fprintln(os.Stderr, "The key is only shown once, so store it somewhere safe now.")
+
+		return nil
+	case "revoke":
+		if len(args) != 2 {
+			return errors.New(apiKeysUsage)
+		}
+
+		err := apiKeyService.RevokeAPIKey(context.Background(), args[1])
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no active API key with id %s", args[1])
+		}
+
+		return err
+	default:
+		return errors.New(apiKeysUsage)
+	}
+}
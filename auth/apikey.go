@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"net/http"
+	"time"
+)
+
+const (
+	APIKeyHeader = "X-API-Key"
+	apiKeyPrefix = "cpn_"
+)
+
+type APIKey struct {
+	ID        string
+	Name      string
+	TenantID  string
+	Scopes    []string
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
+
+//go:generate counterfeiter . APIKeyStore
+type APIKeyStore interface {
+	FindAPIKey(ctx context.Context, keyHash []byte) (*APIKey, error)
+}
+
+// GenerateAPIKey returns a new random key; only its hash is ever stored, so it can't be shown again
+func GenerateAPIKey() (string, error) {
+	randomBytes := make([]byte, 32)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(randomBytes), nil
+}
+
+// HashAPIKey doesn't need a slow, salted hash because keys are random rather than chosen by people
+func HashAPIKey(apiKey string) []byte {
+	hash := sha256.Sum256([]byte(apiKey))
+	return hash[:]
+}
+
+var (
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	ErrRevokedAPIKey = errors.New("API key has been revoked")
+)
+
+// AuthenticateAPIKey returns ctx as the key's principal, acting for its tenant, or ErrInvalidAPIKey or
+// ErrRevokedAPIKey if it can't be used
+func AuthenticateAPIKey(ctx context.Context, store APIKeyStore, apiKey string) (context.Context, error) {
+	storedKey, err := store.FindAPIKey(ctx, HashAPIKey(apiKey))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if storedKey.RevokedAt != nil {
+		return nil, ErrRevokedAPIKey
+	}
+
+	ctx = WithPrincipal(ctx, &Principal{
+		ID:       storedKey.ID,
+		Name:     storedKey.Name,
+		TenantID: storedKey.TenantID,
+		Scopes:   storedKey.Scopes,
+	})
+	ctx = requestcontext.WithActor(ctx, "api-key:"+storedKey.Name)
+	ctx = requestcontext.WithTenant(ctx, storedKey.TenantID)
+
+	return ctx, nil
+}
+
+// APIKeyMiddleware rejects any request without a valid, unrevoked X-API-Key, unless it was already authenticated
+// by a bearer token
+func APIKeyMiddleware(store APIKeyStore) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if _, ok := PrincipalFrom(req.Context()); ok {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			apiKey := req.Header.Get(APIKeyHeader)
+			if apiKey == "" {
+				http.Error(w, "missing "+APIKeyHeader+" header", http.StatusUnauthorized)
+				return
+			}
+
+			ctx, err := AuthenticateAPIKey(req.Context(), store, apiKey)
+			if err == ErrInvalidAPIKey || err == ErrRevokedAPIKey {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
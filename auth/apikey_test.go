@@ -0,0 +1,126 @@
+package auth_test
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/auth/authfakes"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+var _ = Describe("API keys", func() {
+	It("generates random, prefixed keys", func() {
+		firstKey, err := auth.GenerateAPIKey()
+		Expect(err).NotTo(HaveOccurred())
+		secondKey, err := auth.GenerateAPIKey()
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(strings.HasPrefix(firstKey, "cpn_")).To(BeTrue())
+		Expect(firstKey).NotTo(Equal(secondKey))
+	})
+
+	It("hashes keys consistently", func() {
+		Expect(auth.HashAPIKey("cpn_abc")).To(Equal(auth.HashAPIKey("cpn_abc")))
+		Expect(auth.HashAPIKey("cpn_abc")).NotTo(Equal(auth.HashAPIKey("cpn_abd")))
+	})
+
+	Describe("APIKeyMiddleware", func() {
+		var (
+			fakeStore          *authfakes.FakeAPIKeyStore
+			handler            http.Handler
+			request            *http.Request
+			recorder           *httptest.ResponseRecorder
+			capturedPrincipal  *auth.Principal
+			capturedActor      string
+			capturedTenant     string
+			nextHandlerInvoked bool
+		)
+
+		BeforeEach(func() {
+			var err error
+
+			fakeStore = &authfakes.FakeAPIKeyStore{}
+			fakeStore.FindAPIKeyReturns(&auth.APIKey{ID: "1", Name: "checkout", TenantID: "boots", Scopes: []string{auth.ScopeCouponsRead}}, nil)
+
+			nextHandlerInvoked = false
+			handler = auth.APIKeyMiddleware(fakeStore)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				nextHandlerInvoked = true
+				capturedPrincipal, _ = auth.PrincipalFrom(req.Context())
+				capturedActor = requestcontext.Actor(req.Context())
+				capturedTenant = requestcontext.Tenant(req.Context())
+			}))
+
+			request, err = http.NewRequest(http.MethodGet, "/coupons", nil)
+			Expect(err).NotTo(HaveOccurred())
+			request.Header.Set("X-API-Key", "cpn_abc")
+
+			recorder = httptest.NewRecorder()
+		})
+
+		It("authenticates the request as the key's owner", func() {
+			handler.ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(fakeStore.FindAPIKeyCallCount()).To(Equal(1))
+			_, keyHash := fakeStore.FindAPIKeyArgsForCall(0)
+			Expect(keyHash).To(Equal(auth.HashAPIKey("cpn_abc")))
+
+			Expect(capturedPrincipal).To(Equal(&auth.Principal{ID: "1", Name: "checkout", TenantID: "boots", Scopes: []string{auth.ScopeCouponsRead}}))
+			Expect(capturedActor).To(Equal("api-key:checkout"))
+			Expect(capturedTenant).To(Equal("boots"))
+		})
+
+		It("lets through requests already authenticated by a bearer token", func() {
+			request.Header.Del("X-API-Key")
+			principal := &auth.Principal{Name: "checkout-service"}
+			request = request.WithContext(auth.WithPrincipal(request.Context(), principal))
+
+			handler.ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(capturedPrincipal).To(Equal(principal))
+			Expect(fakeStore.FindAPIKeyCallCount()).To(Equal(0))
+		})
+
+		It("rejects requests without a key", func() {
+			request.Header.Del("X-API-Key")
+
+			handler.ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(nextHandlerInvoked).To(BeFalse())
+			Expect(fakeStore.FindAPIKeyCallCount()).To(Equal(0))
+		})
+
+		It("rejects unknown keys", func() {
+			fakeStore.FindAPIKeyReturns(nil, sql.ErrNoRows)
+
+			handler.ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Body.String()).To(ContainSubstring("invalid API key"))
+			Expect(nextHandlerInvoked).To(BeFalse())
+		})
+
+		It("rejects revoked keys", func() {
+			revokedAt := time.Now()
+			fakeStore.FindAPIKeyReturns(&auth.APIKey{ID: "1", Name: "checkout", RevokedAt: &revokedAt}, nil)
+
+			handler.ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Body.String()).To(ContainSubstring("API key has been revoked"))
+			Expect(nextHandlerInvoked).To(BeFalse())
+		})
+
+		It("propagates the error if the key can't be looked up", func() {
+			fakeStore.FindAPIKeyReturns(nil, errors.New("connection refused"))
+
+			handler.ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+			Expect(nextHandlerInvoked).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,123 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package authfakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/madeleinesmith/coupons/auth"
+)
+
+type FakeAPIKeyStore struct {
+	FindAPIKeyStub        func(context.Context, []byte) (*auth.APIKey, error)
+	findAPIKeyMutex       sync.RWMutex
+	findAPIKeyArgsForCall []struct {
+		arg1 context.Context
+		arg2 []byte
+	}
+	findAPIKeyReturns struct {
+		result1 *auth.APIKey
+		result2 error
+	}
+	findAPIKeyReturnsOnCall map[int]struct {
+		result1 *auth.APIKey
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeAPIKeyStore) FindAPIKey(arg1 context.Context, arg2 []byte) (*auth.APIKey, error) {
+	var arg2Copy []byte
+	if arg2 != nil {
+		arg2Copy = make([]byte, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.findAPIKeyMutex.Lock()
+	ret, specificReturn := fake.findAPIKeyReturnsOnCall[len(fake.findAPIKeyArgsForCall)]
+	fake.findAPIKeyArgsForCall = append(fake.findAPIKeyArgsForCall, struct {
+		arg1 context.Context
+		arg2 []byte
+	}{arg1, arg2Copy})
+	fake.recordInvocation("FindAPIKey", []interface{}{arg1, arg2Copy})
+	fake.findAPIKeyMutex.Unlock()
+	if fake.FindAPIKeyStub != nil {
+		return fake.FindAPIKeyStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.findAPIKeyReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeAPIKeyStore) FindAPIKeyCallCount() int {
+	fake.findAPIKeyMutex.RLock()
+	defer fake.findAPIKeyMutex.RUnlock()
+	return len(fake.findAPIKeyArgsForCall)
+}
+
+func (fake *FakeAPIKeyStore) FindAPIKeyCalls(stub func(context.Context, []byte) (*auth.APIKey, error)) {
+	fake.findAPIKeyMutex.Lock()
+	defer fake.findAPIKeyMutex.Unlock()
+	fake.FindAPIKeyStub = stub
+}
+
+func (fake *FakeAPIKeyStore) FindAPIKeyArgsForCall(i int) (context.Context, []byte) {
+	fake.findAPIKeyMutex.RLock()
+	defer fake.findAPIKeyMutex.RUnlock()
+	argsForCall := fake.findAPIKeyArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeAPIKeyStore) FindAPIKeyReturns(result1 *auth.APIKey, result2 error) {
+	fake.findAPIKeyMutex.Lock()
+	defer fake.findAPIKeyMutex.Unlock()
+	fake.FindAPIKeyStub = nil
+	fake.findAPIKeyReturns = struct {
+		result1 *auth.APIKey
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPIKeyStore) FindAPIKeyReturnsOnCall(i int, result1 *auth.APIKey, result2 error) {
+	fake.findAPIKeyMutex.Lock()
+	defer fake.findAPIKeyMutex.Unlock()
+	fake.FindAPIKeyStub = nil
+	if fake.findAPIKeyReturnsOnCall == nil {
+		fake.findAPIKeyReturnsOnCall = make(map[int]struct {
+			result1 *auth.APIKey
+			result2 error
+		})
+	}
+	fake.findAPIKeyReturnsOnCall[i] = struct {
+		result1 *auth.APIKey
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAPIKeyStore) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.findAPIKeyMutex.RLock()
+	defer fake.findAPIKeyMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeAPIKeyStore) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ auth.APIKeyStore = new(FakeAPIKeyStore)
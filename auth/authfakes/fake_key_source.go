@@ -0,0 +1,119 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package authfakes
+
+import (
+	"context"
+	"sync"
+
+	jose "github.com/go-jose/go-jose/v4"
+	"github.com/madeleinesmith/coupons/auth"
+)
+
+type FakeKeySource struct {
+	KeyStub        func(context.Context, string) (*jose.JSONWebKey, error)
+	keyMutex       sync.RWMutex
+	keyArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	keyReturns struct {
+		result1 *jose.JSONWebKey
+		result2 error
+	}
+	keyReturnsOnCall map[int]struct {
+		result1 *jose.JSONWebKey
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeKeySource) Key(arg1 context.Context, arg2 string) (*jose.JSONWebKey, error) {
+	fake.keyMutex.Lock()
+	ret, specificReturn := fake.keyReturnsOnCall[len(fake.keyArgsForCall)]
+	fake.keyArgsForCall = append(fake.keyArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("Key", []interface{}{arg1, arg2})
+	fake.keyMutex.Unlock()
+	if fake.KeyStub != nil {
+		return fake.KeyStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.keyReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeKeySource) KeyCallCount() int {
+	fake.keyMutex.RLock()
+	defer fake.keyMutex.RUnlock()
+	return len(fake.keyArgsForCall)
+}
+
+func (fake *FakeKeySource) KeyCalls(stub func(context.Context, string) (*jose.JSONWebKey, error)) {
+	fake.keyMutex.Lock()
+	defer fake.keyMutex.Unlock()
+	fake.KeyStub = stub
+}
+
+func (fake *FakeKeySource) KeyArgsForCall(i int) (context.Context, string) {
+	fake.keyMutex.RLock()
+	defer fake.keyMutex.RUnlock()
+	argsForCall := fake.keyArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeKeySource) KeyReturns(result1 *jose.JSONWebKey, result2 error) {
+	fake.keyMutex.Lock()
+	defer fake.keyMutex.Unlock()
+	fake.KeyStub = nil
+	fake.keyReturns = struct {
+		result1 *jose.JSONWebKey
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeKeySource) KeyReturnsOnCall(i int, result1 *jose.JSONWebKey, result2 error) {
+	fake.keyMutex.Lock()
+	defer fake.keyMutex.Unlock()
+	fake.KeyStub = nil
+	if fake.keyReturnsOnCall == nil {
+		fake.keyReturnsOnCall = make(map[int]struct {
+			result1 *jose.JSONWebKey
+			result2 error
+		})
+	}
+	fake.keyReturnsOnCall[i] = struct {
+		result1 *jose.JSONWebKey
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeKeySource) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.keyMutex.RLock()
+	defer fake.keyMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeKeySource) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ auth.KeySource = new(FakeKeySource)
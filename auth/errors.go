@@ -0,0 +1,14 @@
+package auth
+
+import (
+	"fmt"
+)
+
+// PermissionDeniedError is returned when an authenticated principal isn't allowed to do something
+type PermissionDeniedError struct {
+	Permission string
+}
+
+func (e PermissionDeniedError) Error() string {
+	return fmt.Sprintf("permission denied: %s", e.Permission)
+}
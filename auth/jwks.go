@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-jose/go-jose/v4"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// a token signed with a key we haven't seen shouldn't let anyone make us refetch the JWKS on every request
+const minJWKSRefreshInterval = time.Minute
+
+var ErrUnknownKey = errors.New("no key in the JWKS matches the token's kid")
+
+//go:generate counterfeiter . KeySource
+type KeySource interface {
+	Key(ctx context.Context, keyId string) (*jose.JSONWebKey, error)
+}
+
+type StaticKeySet struct {
+	Keys jose.JSONWebKeySet
+}
+
+func LoadKeySetFile(path string) (*StaticKeySet, error) {
+	fileBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keySet StaticKeySet
+	err = json.Unmarshal(fileBytes, &keySet.Keys)
+	if err != nil {
+		return nil, fmt.Errorf("reading JWKS from %s: %v", path, err)
+	}
+
+	return &keySet, nil
+}
+
+func (s *StaticKeySet) Key(ctx context.Context, keyId string) (*jose.JSONWebKey, error) {
+	return findKey(s.Keys, keyId)
+}
+
+// RemoteKeySet fetches the JWKS lazily, and again whenever a token turns up signed with a key it doesn't know,
+// which is how key rotation is picked up
+type RemoteKeySet struct {
+	URL    string
+	Client *http.Client
+
+	mutex       sync.Mutex
+	keys        jose.JSONWebKeySet
+	refreshedAt time.Time
+}
+
+func (s *RemoteKeySet) Key(ctx context.Context, keyId string) (*jose.JSONWebKey, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, err := findKey(s.keys, keyId)
+	if err != ErrUnknownKey || time.Since(s.refreshedAt) < minJWKSRefreshInterval {
+		return key, err
+	}
+
+	// noted before fetching, so a JWKS that can't be fetched is only tried once per interval too
+	s.refreshedAt = time.Now()
+
+	err = s.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return findKey(s.keys, keyId)
+}
+
+func (s *RemoteKeySet) fetch(ctx context.Context) error {
+	req, err := http.NewRequest(http.MethodGet, s.URL, nil)
+	if err != nil {
+		return err
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching JWKS from %s: %s", s.URL, resp.Status)
+	}
+
+	var keys jose.JSONWebKeySet
+	err = json.NewDecoder(resp.Body).Decode(&keys)
+	if err != nil {
+		return fmt.Errorf("fetching JWKS from %s: %v", s.URL, err)
+	}
+
+	s.keys = keys
+
+	return nil
+}
+
+func findKey(keySet jose.JSONWebKeySet, keyId string) (*jose.JSONWebKey, error) {
+	keys := keySet.Key(keyId)
+	if len(keys) == 0 {
+		return nil, ErrUnknownKey
+	}
+
+	return &keys[0], nil
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultRolesClaim  = "roles"
+	DefaultTenantClaim = "tenant_id"
+)
+
+// only asymmetric algorithms, so a token can't be forged by anyone holding the public keys
+var allowedAlgorithms = []jose.SignatureAlgorithm{jose.RS256, jose.ES256, jose.EdDSA}
+
+type JWTValidator struct {
+	Keys        KeySource
+	Issuer      string
+	Audience    string
+	RolesClaim  string
+	TenantClaim string
+	Leeway      time.Duration
+	Now         func() time.Time
+}
+
+func (v JWTValidator) Validate(ctx context.Context, rawToken string) (*Principal, error) {
+	token, err := jwt.ParseSigned(rawToken, allowedAlgorithms)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := v.Keys.Key(ctx, token.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims jwt.Claims
+	var otherClaims map[string]interface{}
+
+	err = token.Claims(key.Public(), &claims, &otherClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Expiry == nil {
+		return nil, errors.New("token has no expiry")
+	}
+
+	expected := jwt.Expected{
+		Issuer:      v.Issuer,
+		AnyAudience: jwt.Audience{v.Audience},
+	}
+	if v.Now != nil {
+		expected = expected.WithTime(v.Now())
+	}
+
+	err = claims.ValidateWithLeeway(expected, v.Leeway)
+	if err != nil {
+		return nil, err
+	}
+
+	rolesClaim := v.RolesClaim
+	if rolesClaim == "" {
+		rolesClaim = DefaultRolesClaim
+	}
+
+	roles, err := stringsClaim(otherClaims[rolesClaim])
+	if err != nil {
+		return nil, fmt.Errorf("%s claim: %v", rolesClaim, err)
+	}
+
+	tenantClaim := v.TenantClaim
+	if tenantClaim == "" {
+		tenantClaim = DefaultTenantClaim
+	}
+
+	// every coupon belongs to a tenant, so a token without one can't be used for anything
+	tenant, _ := otherClaims[tenantClaim].(string)
+	if tenant == "" {
+		return nil, fmt.Errorf("token has no %s claim", tenantClaim)
+	}
+
+	return &Principal{
+		ID:       claims.Subject,
+		Name:     claims.Subject,
+		TenantID: tenant,
+		Roles:    roles,
+		Scopes:   ScopesForRoles(roles),
+	}, nil
+}
+
+// stringsClaim accepts either a JSON array of strings or a single space separated string, as OAuth scopes are sent
+func stringsClaim(claim interface{}) ([]string, error) {
+	switch value := claim.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.Fields(value), nil
+	case []interface{}:
+		values := make([]string, len(value))
+		for i, v := range value {
+			s, ok := v.(string)
+			if !ok {
+				return nil, errors.New("must only contain strings")
+			}
+			values[i] = s
+		}
+		return values, nil
+	default:
+		return nil, errors.New("must be a string or an array of strings")
+	}
+}
+
+// JWTMiddleware authenticates requests carrying an `Authorization: Bearer` token, leaving any others for the
+// API key middleware to deal with
+func JWTMiddleware(validator JWTValidator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			authorization := req.Header.Get("Authorization")
+			if !strings.HasPrefix(authorization, "Bearer ") {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			principal, err := validator.Validate(req.Context(), strings.TrimPrefix(authorization, "Bearer "))
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, "invalid bearer token: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			ctx := WithPrincipal(req.Context(), principal)
+			ctx = requestcontext.WithActor(ctx, "jwt:"+principal.Name)
+			ctx = requestcontext.WithTenant(ctx, principal.TenantID)
+
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package auth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type signingKey struct {
+	id        string
+	algorithm jose.SignatureAlgorithm
+	private   crypto.Signer
+}
+
+func (k signingKey) jwk() jose.JSONWebKey {
+	return jose.JSONWebKey{Key: k.private.Public(), KeyID: k.id, Algorithm: string(k.algorithm), Use: "sig"}
+}
+
+func (k signingKey) sign(claims interface{}) string {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: k.algorithm, Key: jose.JSONWebKey{Key: k.private, KeyID: k.id}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	Expect(err).NotTo(HaveOccurred())
+
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	Expect(err).NotTo(HaveOccurred())
+
+	return token
+}
+
+var _ = Describe("JWT validation", func() {
+	var (
+		now       time.Time
+		rsaKey    signingKey
+		ecdsaKey  signingKey
+		eddsaKey  signingKey
+		keySet    *auth.StaticKeySet
+		validator auth.JWTValidator
+		claims    map[string]interface{}
+		ctx       context.Context
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+		ctx = context.Background()
+
+		rsaPrivateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+		rsaKey = signingKey{id: "rsa-1", algorithm: jose.RS256, private: rsaPrivateKey}
+
+		ecdsaPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		ecdsaKey = signingKey{id: "ec-1", algorithm: jose.ES256, private: ecdsaPrivateKey}
+
+		_, eddsaPrivateKey, err := ed25519.GenerateKey(rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+		eddsaKey = signingKey{id: "ed-1", algorithm: jose.EdDSA, private: eddsaPrivateKey}
+
+		keySet = &auth.StaticKeySet{Keys: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{rsaKey.jwk(), ecdsaKey.jwk(), eddsaKey.jwk()}}}
+
+		validator = auth.JWTValidator{
+			Keys:     keySet,
+			Issuer:   "https://login.example.com",
+			Audience: "coupons",
+			Now:      func() time.Time { return now },
+		}
+
+		claims = map[string]interface{}{
+			"iss":       "https://login.example.com",
+			"aud":       "coupons",
+			"sub":       "checkout-service",
+			"exp":       now.Add(time.Hour).Unix(),
+			"iat":       now.Add(-time.Minute).Unix(),
+			"roles":     []string{"marketer"},
+			"tenant_id": "boots",
+		}
+	})
+
+	It("accepts RS256, ES256 and EdDSA tokens and maps their roles to scopes", func() {
+		for _, key := range []signingKey{rsaKey, ecdsaKey, eddsaKey} {
+			principal, err := validator.Validate(ctx, key.sign(claims))
+			Expect(err).NotTo(HaveOccurred(), string(key.algorithm))
+
+			Expect(principal.Name).To(Equal("checkout-service"))
+			Expect(principal.TenantID).To(Equal("boots"))
+			Expect(principal.Roles).To(Equal([]string{auth.RoleMarketer}))
+			Expect(principal.Scopes).To(ConsistOf(auth.ScopeCouponsRead, auth.ScopeCouponsWrite))
+		}
+	})
+
+	It("reads roles from the configured claim, including space separated strings", func() {
+		validator.RolesClaim = "https://example.com/roles"
+		claims["https://example.com/roles"] = "viewer finance"
+
+		principal, err := validator.Validate(ctx, rsaKey.sign(claims))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.Roles).To(Equal([]string{auth.RoleViewer, auth.RoleFinance}))
+		Expect(principal.Scopes).To(ConsistOf(auth.ScopeCouponsRead, auth.ScopeCouponsWrite, auth.ScopeRedemptionsRead, auth.ScopeRedemptionsWrite))
+	})
+
+	It("grants no scopes for unknown roles", func() {
+		claims["roles"] = []string{"superuser"}
+
+		principal, err := validator.Validate(ctx, rsaKey.sign(claims))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.Scopes).To(BeEmpty())
+	})
+
+	It("reads the tenant from the configured claim", func() {
+		validator.TenantClaim = "https://example.com/merchant"
+		claims["https://example.com/merchant"] = "superdrug"
+
+		principal, err := validator.Validate(ctx, rsaKey.sign(claims))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(principal.TenantID).To(Equal("superdrug"))
+	})
+
+	It("rejects tokens without a tenant", func() {
+		delete(claims, "tenant_id")
+
+		_, err := validator.Validate(ctx, rsaKey.sign(claims))
+		Expect(err).To(MatchError("token has no tenant_id claim"))
+	})
+
+	It("rejects expired tokens", func() {
+		claims["exp"] = now.Add(-time.Minute).Unix()
+
+		_, err := validator.Validate(ctx, rsaKey.sign(claims))
+		Expect(err).To(MatchError(jwt.ErrExpired))
+	})
+
+	It("rejects tokens without an expiry", func() {
+		delete(claims, "exp")
+
+		_, err := validator.Validate(ctx, rsaKey.sign(claims))
+		Expect(err).To(MatchError("token has no expiry"))
+	})
+
+	It("rejects tokens from another issuer", func() {
+		claims["iss"] = "https://evil.example.com"
+
+		_, err := validator.Validate(ctx, rsaKey.sign(claims))
+		Expect(err).To(MatchError(jwt.ErrInvalidIssuer))
+	})
+
+	It("rejects tokens for another audience", func() {
+		claims["aud"] = []string{"payments"}
+
+		_, err := validator.Validate(ctx, rsaKey.sign(claims))
+		Expect(err).To(MatchError(jwt.ErrInvalidAudience))
+	})
+
+	It("rejects tokens signed by a key that isn't in the JWKS", func() {
+		otherPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = validator.Validate(ctx, signingKey{id: "ec-2", algorithm: jose.ES256, private: otherPrivateKey}.sign(claims))
+		Expect(err).To(MatchError(auth.ErrUnknownKey))
+	})
+
+	It("rejects tokens signed by another key claiming a known kid", func() {
+		otherPrivateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = validator.Validate(ctx, signingKey{id: "ec-1", algorithm: jose.ES256, private: otherPrivateKey}.sign(claims))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects HS256 tokens", func() {
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","kid":"rsa-1","typ":"JWT"}`))
+		payloadBytes, err := json.Marshal(claims)
+		Expect(err).NotTo(HaveOccurred())
+		payload := base64.RawURLEncoding.EncodeToString(payloadBytes)
+
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(header + "." + payload))
+		signature := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+		_, err = validator.Validate(ctx, header+"."+payload+"."+signature)
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("key sets", func() {
+		It("loads a JWKS file", func() {
+			directory, err := ioutil.TempDir("", "jwks")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(directory)
+
+			jwksBytes, err := json.Marshal(keySet.Keys)
+			Expect(err).NotTo(HaveOccurred())
+
+			path := filepath.Join(directory, "jwks.json")
+			Expect(ioutil.WriteFile(path, jwksBytes, 0600)).To(Succeed())
+
+			validator.Keys, err = auth.LoadKeySetFile(path)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = validator.Validate(ctx, eddsaKey.sign(claims))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("fetches a remote JWKS, and again when it meets a new key", func() {
+			servedKeys := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{rsaKey.jwk()}}
+			fetches := 0
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				fetches++
+				json.NewEncoder(w).Encode(servedKeys)
+			}))
+			defer server.Close()
+
+			validator.Keys = &auth.RemoteKeySet{URL: server.URL}
+
+			_, err := validator.Validate(ctx, rsaKey.sign(claims))
+			Expect(err).NotTo(HaveOccurred())
+			_, err = validator.Validate(ctx, rsaKey.sign(claims))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetches).To(Equal(1))
+
+			// recently fetched, so an unknown key doesn't trigger another fetch straight away
+			servedKeys.Keys = append(servedKeys.Keys, ecdsaKey.jwk())
+			_, err = validator.Validate(ctx, ecdsaKey.sign(claims))
+			Expect(err).To(MatchError(auth.ErrUnknownKey))
+			Expect(fetches).To(Equal(1))
+		})
+
+		It("propagates the error if the remote JWKS can't be fetched", func() {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+			defer server.Close()
+
+			validator.Keys = &auth.RemoteKeySet{URL: server.URL}
+
+			_, err := validator.Validate(ctx, rsaKey.sign(claims))
+			Expect(err).To(MatchError(ContainSubstring("502 Bad Gateway")))
+		})
+
+		It("doesn't retry a failed fetch straight away", func() {
+			fetches := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				fetches++
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+			defer server.Close()
+
+			validator.Keys = &auth.RemoteKeySet{URL: server.URL}
+
+			_, err := validator.Validate(ctx, rsaKey.sign(claims))
+			Expect(err).To(MatchError(ContainSubstring("502 Bad Gateway")))
+			_, err = validator.Validate(ctx, rsaKey.sign(claims))
+			Expect(err).To(MatchError(auth.ErrUnknownKey))
+			Expect(fetches).To(Equal(1))
+		})
+	})
+
+	Describe("JWTMiddleware", func() {
+		var (
+			handler           http.Handler
+			recorder          *httptest.ResponseRecorder
+			request           *http.Request
+			capturedPrincipal *auth.Principal
+			capturedActor     string
+			capturedTenant    string
+		)
+
+		BeforeEach(func() {
+			var err error
+
+			capturedPrincipal = nil
+			handler = auth.JWTMiddleware(validator)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				capturedPrincipal, _ = auth.PrincipalFrom(req.Context())
+				capturedActor = requestcontext.Actor(req.Context())
+				capturedTenant = requestcontext.Tenant(req.Context())
+			}))
+
+			recorder = httptest.NewRecorder()
+			request, err = http.NewRequest(http.MethodGet, "/coupons", nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("authenticates requests with a valid bearer token", func() {
+			request.Header.Set("Authorization", "Bearer "+ecdsaKey.sign(claims))
+
+			handler.ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(capturedPrincipal.Name).To(Equal("checkout-service"))
+			Expect(capturedActor).To(Equal("jwt:checkout-service"))
+			Expect(capturedTenant).To(Equal("boots"))
+		})
+
+		It("rejects requests with an invalid bearer token", func() {
+			claims["exp"] = now.Add(-time.Hour).Unix()
+			request.Header.Set("Authorization", "Bearer "+ecdsaKey.sign(claims))
+
+			handler.ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+			Expect(recorder.Header().Get("WWW-Authenticate")).To(Equal(`Bearer error="invalid_token"`))
+			Expect(capturedPrincipal).To(BeNil())
+		})
+
+		It("leaves requests without a bearer token to the API key middleware", func() {
+			request.Header.Set("X-API-Key", "cpn_abc")
+
+			handler.ServeHTTP(recorder, request)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(capturedPrincipal).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+)
+
+const (
+	ScopeCouponsRead      = "coupons:read"
+	ScopeCouponsWrite     = "coupons:write"
+	ScopeRedemptionsRead  = "redemptions:read"
+	ScopeRedemptionsWrite = "redemptions:write"
+)
+
+var Scopes = []string{ScopeCouponsRead, ScopeCouponsWrite, ScopeRedemptionsRead, ScopeRedemptionsWrite}
+
+// Principal is whoever a request has been authenticated as
+type Principal struct {
+	ID       string
+	Name     string
+	TenantID string
+	Roles    []string
+	Scopes   []string
+}
+
+func (p Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+type key int
+
+const principalKey key = iota
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(*Principal)
+	return principal, ok && principal != nil
+}
+
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,32 @@
+package auth_test
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/auth"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Principal", func() {
+	It("has only the scopes it was granted", func() {
+		principal := auth.Principal{Scopes: []string{auth.ScopeCouponsRead}}
+
+		Expect(principal.HasScope(auth.ScopeCouponsRead)).To(BeTrue())
+		Expect(principal.HasScope(auth.ScopeCouponsWrite)).To(BeFalse())
+	})
+
+	It("is carried on the context", func() {
+		_, ok := auth.PrincipalFrom(context.Background())
+		Expect(ok).To(BeFalse())
+
+		principal := &auth.Principal{Name: "checkout"}
+		storedPrincipal, ok := auth.PrincipalFrom(auth.WithPrincipal(context.Background(), principal))
+		Expect(ok).To(BeTrue())
+		Expect(storedPrincipal).To(Equal(principal))
+	})
+
+	It("knows which scopes exist", func() {
+		Expect(auth.ValidScope("redemptions:write")).To(BeTrue())
+		Expect(auth.ValidScope("coupons:everything")).To(BeFalse())
+	})
+})
@@ -0,0 +1,32 @@
+package auth
+
+const (
+	RoleViewer   = "viewer"
+	RoleMarketer = "marketer"
+	RoleFinance  = "finance"
+	RoleAdmin    = "admin"
+)
+
+// roleScopes only decide which routes a role can reach; the policy then decides what it can do there, so finance
+// needs coupons:write to change values even though it can't otherwise edit coupons
+var roleScopes = map[string][]string{
+	RoleViewer:   {ScopeCouponsRead},
+	RoleMarketer: {ScopeCouponsRead, ScopeCouponsWrite},
+	RoleFinance:  {ScopeCouponsRead, ScopeCouponsWrite, ScopeRedemptionsRead, ScopeRedemptionsWrite},
+	RoleAdmin:    {ScopeCouponsRead, ScopeCouponsWrite, ScopeRedemptionsRead, ScopeRedemptionsWrite},
+}
+
+// ScopesForRoles grants the union of every known role's scopes; unknown roles grant nothing
+func ScopesForRoles(roles []string) []string {
+	var scopes []string
+
+	for _, role := range roles {
+		for _, scope := range roleScopes[role] {
+			if !(Principal{Scopes: scopes}).HasScope(scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+
+	return scopes
+}
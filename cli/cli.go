@@ -0,0 +1,365 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"github.com/madeleinesmith/coupons/validators"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const Usage = `usage:
+  coupons coupon create -name <name> -brand <brand> -value <value> [-dry-run]
+  coupons coupon find [<coupon id>] [-name <name>] [-brand <brand>] [-value <value>]
+  coupons coupon expire <coupon id> [-dry-run]
+  coupons coupon export [-name <name>] [-brand <brand>] [-value <value>]
+
+Each command takes -output table, json or csv. Coupons are read and written in the database for -tenant, unless
+-api-url (or COUPONS_API_URL) is given, when they go through the API with -api-key (or COUPONS_API_KEY).`
+
+//go:generate counterfeiter . Backend
+
+// Backend is where coupons are read and written: dbservices.CouponService, or the API through client.Client
+type Backend interface {
+	CreateCoupon(ctx context.Context, couponInstance coupon.Coupon) (*coupon.Coupon, error)
+	GetCoupons(ctx context.Context, filters handlers.Filters) ([]*coupon.Coupon, error)
+	StreamCoupons(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error
+	GetCouponById(ctx context.Context, couponId string) (*coupon.Coupon, error)
+	UpdateCoupon(ctx context.Context, couponInstance coupon.Coupon) error
+}
+
+// Options pick the backend: the API if APIURL is set, otherwise the database, for Tenant
+type Options struct {
+	APIURL string
+	APIKey string
+	Tenant string
+}
+
+// CLI is `coupons coupon`, for ops staff to manage coupons without writing JSON:API by hand. Coupons are written to
+// Out, and anything else for the reader to Err.
+type CLI struct {
+	Out     io.Writer
+	Err     io.Writer
+	Getenv  func(string) string
+	Connect func(Options) (Backend, error)
+	// Actor is who changes are audited as, when writing to the database directly
+	Actor string
+	Now   func() time.Time
+}
+
+func (c CLI) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+
+	return time.Now()
+}
+
+type commonFlags struct {
+	options Options
+	output  string
+}
+
+type filterFlags struct {
+	name  string
+	brand string
+	value string
+}
+
+func (c CLI) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(Usage)
+	}
+
+	switch args[0] {
+	case "create":
+		return c.create(ctx, args[1:])
+	case "find":
+		return c.find(ctx, args[1:])
+	case "expire":
+		return c.expire(ctx, args[1:])
+	case "export":
+		return c.export(ctx, args[1:])
+	default:
+		return errors.New(Usage)
+	}
+}
+
+func (c CLI) create(ctx context.Context, args []string) error {
+	flags, common := c.newFlagSet("coupon create", "table")
+	name := flags.String("name", "", "the coupon's name")
+	brand := flags.String("brand", "", "the brand the coupon is for")
+	// value is a string, so leaving it out is caught by the validator rather than creating a coupon worth 0
+	valueString := flags.String("value", "", "the coupon's value")
+	dryRun := flags.Bool("dry-run", false, "check the coupon is valid without creating it")
+
+	_, err := parse(flags, args, 0)
+	if err != nil {
+		return err
+	}
+
+	couponInstance := coupon.Coupon{Name: name, Brand: brand}
+	if *valueString != "" {
+		value, err := strconv.Atoi(*valueString)
+		if err != nil {
+			return fmt.Errorf("-value must be a whole number, got %q", *valueString)
+		}
+
+		couponInstance.Value = &value
+	}
+
+	err = validators.CouponValidator{}.Validate(couponInstance)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		fmt.Fprintln(c.Err, "dry run, so the coupon wasn't created")
+		return writeCoupons(c.Out, common.output, []*coupon.Coupon{&couponInstance})
+	}
+
+	ctx, backend, err := c.connect(ctx, common.options)
+	if err != nil {
+		return err
+	}
+
+	createdCoupon, err := backend.CreateCoupon(ctx, couponInstance)
+	if err != nil {
+		return err
+	}
+
+	return writeCoupons(c.Out, common.output, []*coupon.Coupon{createdCoupon})
+}
+
+// find looks up one coupon by id, or every coupon matching the filters
+func (c CLI) find(ctx context.Context, args []string) error {
+	flags, common := c.newFlagSet("coupon find", "table")
+	filterValues := addFilterFlags(flags)
+
+	couponIds, err := parse(flags, args, -1)
+	if err != nil {
+		return err
+	}
+
+	if len(couponIds) > 1 {
+		return errors.New("find takes at most one coupon id")
+	}
+
+	filters, err := filterValues.filters()
+	if err != nil {
+		return err
+	}
+
+	ctx, backend, err := c.connect(ctx, common.options)
+	if err != nil {
+		return err
+	}
+
+	var coupons []*coupon.Coupon
+	if len(couponIds) == 1 {
+		var couponInstance *coupon.Coupon
+		couponInstance, err = backend.GetCouponById(ctx, couponIds[0])
+		coupons = []*coupon.Coupon{couponInstance}
+		if notFound(err) {
+			return fmt.Errorf("coupon %s not found", couponIds[0])
+		}
+	} else {
+		coupons, err = backend.GetCoupons(ctx, filters)
+		if notFound(err) {
+			coupons, err = nil, nil
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return writeCoupons(c.Out, common.output, coupons)
+}
+
+// expire sets the coupon's expiry to now, and shows the expired coupon. Coupons that have already expired are left
+// alone, rather than having their expiry pushed back to now.
+func (c CLI) expire(ctx context.Context, args []string) error {
+	flags, common := c.newFlagSet("coupon expire", "table")
+	dryRun := flags.Bool("dry-run", false, "show the coupon without expiring it")
+
+	couponIds, err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	ctx, backend, err := c.connect(ctx, common.options)
+	if err != nil {
+		return err
+	}
+
+	couponInstance, err := backend.GetCouponById(ctx, couponIds[0])
+	if notFound(err) {
+		return fmt.Errorf("coupon %s not found", couponIds[0])
+	}
+
+	if err != nil {
+		return err
+	}
+
+	expiry := c.now()
+	if couponInstance.Expiry != nil && !couponInstance.Expiry.After(expiry) {
+		return fmt.Errorf("coupon %s already expired at %s", couponIds[0], couponInstance.Expiry.Format(time.RFC3339))
+	}
+
+	couponInstance.Expiry = &expiry
+
+	if *dryRun {
+		fmt.Fprintln(c.Err, "dry run, so the coupon wasn't expired")
+	} else {
+		err = backend.UpdateCoupon(ctx, coupon.Coupon{ID: couponIds[0], Expiry: &expiry})
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeCoupons(c.Out, common.output, []*coupon.Coupon{couponInstance})
+}
+
+// export streams coupons out as they're read, so exporting every coupon doesn't hold them all in memory
+func (c CLI) export(ctx context.Context, args []string) error {
+	flags, common := c.newFlagSet("coupon export", "csv")
+	filterValues := addFilterFlags(flags)
+
+	_, err := parse(flags, args, 0)
+	if err != nil {
+		return err
+	}
+
+	filters, err := filterValues.filters()
+	if err != nil {
+		return err
+	}
+
+	writer, err := newWriter(common.output, c.Out)
+	if err != nil {
+		return err
+	}
+
+	ctx, backend, err := c.connect(ctx, common.options)
+	if err != nil {
+		return err
+	}
+
+	err = backend.StreamCoupons(ctx, filters, writer.Write)
+	if err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+func (c CLI) newFlagSet(name string, defaultOutput string) (*flag.FlagSet, *commonFlags) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.Err)
+
+	common := &commonFlags{}
+	flags.StringVar(&common.output, "output", defaultOutput, "how to show coupons: "+strings.Join(Outputs, ", "))
+	flags.StringVar(&common.options.APIURL, "api-url", c.Getenv("COUPONS_API_URL"), "the API to go through, rather than the database (or COUPONS_API_URL)")
+	flags.StringVar(&common.options.APIKey, "api-key", c.Getenv("COUPONS_API_KEY"), "the API key to use with -api-url (or COUPONS_API_KEY)")
+	flags.StringVar(&common.options.Tenant, "tenant", "", "the tenant whose coupons to manage, without -api-url")
+
+	return flags, common
+}
+
+// connect puts the tenant and actor on the context for the database; the API takes both from the API key instead
+func (c CLI) connect(ctx context.Context, options Options) (context.Context, Backend, error) {
+	if options.APIURL == "" && options.Tenant == "" {
+		return nil, nil, errors.New("-tenant is required, unless going through the API with -api-url")
+	}
+
+	if options.APIURL != "" && options.APIKey == "" {
+		return nil, nil, errors.New("-api-key is required with -api-url")
+	}
+
+	backend, err := c.Connect(options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx = requestcontext.WithActor(ctx, c.Actor)
+	ctx = requestcontext.WithTenant(ctx, options.Tenant)
+
+	return ctx, backend, nil
+}
+
+func addFilterFlags(flags *flag.FlagSet) *filterFlags {
+	values := &filterFlags{}
+	flags.StringVar(&values.name, "name", "", "only coupons with this name")
+	flags.StringVar(&values.brand, "brand", "", "only coupons for this brand")
+	flags.StringVar(&values.value, "value", "", "only coupons with this value")
+
+	return values
+}
+
+func (f filterFlags) filters() (handlers.Filters, error) {
+	var filters handlers.Filters
+
+	if f.name != "" {
+		filters.Name = &f.name
+	}
+
+	if f.brand != "" {
+		filters.Brand = &f.brand
+	}
+
+	if f.value != "" {
+		value, err := strconv.Atoi(f.value)
+		if err != nil {
+			return handlers.Filters{}, fmt.Errorf("-value must be a whole number, got %q", f.value)
+		}
+
+		filters.Value = &value
+	}
+
+	return filters, nil
+}
+
+// parse lets flags come before or after the command's arguments, as in `find <coupon id> -output json`, and checks
+// there are count arguments, unless count is -1
+func parse(flags *flag.FlagSet, args []string, count int) ([]string, error) {
+	var positional []string
+
+	for {
+		err := flags.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+
+		if flags.NArg() == 0 {
+			break
+		}
+
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	if count == 1 && len(positional) != 1 {
+		return nil, fmt.Errorf("%s takes a coupon id", flags.Name())
+	}
+
+	if count == 0 && len(positional) > 0 {
+		return nil, fmt.Errorf("%s takes no arguments, got %s", flags.Name(), strings.Join(positional, " "))
+	}
+
+	return positional, nil
+}
+
+// notFound is true for sql.ErrNoRows, which the API client's 404s match, and for the copy of it that GetCoupons
+// returns when no coupons match
+func notFound(err error) bool {
+	return errors.Is(err, sql.ErrNoRows) || err != nil && err.Error() == sql.ErrNoRows.Error()
+}
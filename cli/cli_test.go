@@ -0,0 +1,258 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/cli"
+	"github.com/madeleinesmith/coupons/cli/clifakes"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("CLI", func() {
+	var (
+		backend   *clifakes.FakeBackend
+		out       *bytes.Buffer
+		errOut    *bytes.Buffer
+		env       map[string]string
+		connected []cli.Options
+		couponCLI cli.CLI
+		boots     *coupon.Coupon
+		ctx       context.Context
+	)
+
+	BeforeEach(func() {
+		backend = new(clifakes.FakeBackend)
+		out = &bytes.Buffer{}
+		errOut = &bytes.Buffer{}
+		env = map[string]string{}
+		connected = nil
+		ctx = context.Background()
+
+		couponCLI = cli.CLI{
+			Out:    out,
+			Err:    errOut,
+			Getenv: func(name string) string { return env[name] },
+			Connect: func(options cli.Options) (cli.Backend, error) {
+				connected = append(connected, options)
+				return backend, nil
+			},
+			Actor: "cli:madeleine",
+		}
+
+		name, brand, value, version := "10% off", "boots", 10, 1
+		boots = &coupon.Coupon{ID: "123", Name: &name, Brand: &brand, Value: &value, Version: &version}
+	})
+
+	It("shows usage without a command", func() {
+		Expect(couponCLI.Run(ctx, nil)).To(MatchError(cli.Usage))
+		Expect(couponCLI.Run(ctx, []string{"redeem"})).To(MatchError(cli.Usage))
+	})
+
+	Describe("create", func() {
+		It("creates the coupon in the tenant's database, as the CLI's actor", func() {
+			backend.CreateCouponReturns(boots, nil)
+
+			err := couponCLI.Run(ctx, []string{"create", "-name", "10% off", "-brand", "boots", "-value", "10", "-tenant", "boots"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(connected).To(Equal([]cli.Options{{Tenant: "boots"}}))
+			createCtx, created := backend.CreateCouponArgsForCall(0)
+			Expect(*created.Name).To(Equal("10% off"))
+			Expect(*created.Value).To(Equal(10))
+			Expect(requestcontext.Tenant(createCtx)).To(Equal("boots"))
+			Expect(requestcontext.Actor(createCtx)).To(Equal("cli:madeleine"))
+
+			Expect(out.String()).To(MatchRegexp(`ID\s+NAME\s+BRAND\s+VALUE\s+VERSION\s+EXPIRY\n123\s+10% off\s+boots\s+10\s+1\s*\n`))
+		})
+
+		It("only validates the coupon on a dry run", func() {
+			err := couponCLI.Run(ctx, []string{"create", "-name", "10% off", "-brand", "boots", "-value", "10", "-dry-run", "-output", "json"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(connected).To(BeEmpty())
+			Expect(backend.CreateCouponCallCount()).To(Equal(0))
+			Expect(out.String()).To(MatchJSON(`[{"name": "10% off", "brand": "boots", "value": 10}]`))
+			Expect(errOut.String()).To(ContainSubstring("dry run"))
+		})
+
+		It("rejects invalid coupons before connecting", func() {
+			err := couponCLI.Run(ctx, []string{"create", "-brand", "boots", "-tenant", "boots"})
+			Expect(err).To(MatchError("name field is required"))
+			Expect(connected).To(BeEmpty())
+		})
+
+		It("rejects coupons without a value, rather than creating them worth 0", func() {
+			err := couponCLI.Run(ctx, []string{"create", "-name", "10% off", "-brand", "boots", "-tenant", "boots"})
+			Expect(err).To(MatchError("value field is required"))
+			Expect(connected).To(BeEmpty())
+		})
+
+		It("rejects a value that isn't a number", func() {
+			err := couponCLI.Run(ctx, []string{"create", "-name", "10% off", "-brand", "boots", "-value", "ten"})
+			Expect(err).To(MatchError(`-value must be a whole number, got "ten"`))
+			Expect(connected).To(BeEmpty())
+		})
+	})
+
+	Describe("find", func() {
+		It("looks up a coupon by id, with flags after it", func() {
+			backend.GetCouponByIdReturns(boots, nil)
+
+			err := couponCLI.Run(ctx, []string{"find", "123", "-tenant", "boots", "-output", "csv"})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, couponId := backend.GetCouponByIdArgsForCall(0)
+			Expect(couponId).To(Equal("123"))
+			Expect(out.String()).To(Equal("id,name,brand,value,expiry\n123,10% off,boots,10,\n"))
+		})
+
+		It("reports a coupon that doesn't exist", func() {
+			backend.GetCouponByIdReturns(nil, sql.ErrNoRows)
+
+			err := couponCLI.Run(ctx, []string{"find", "404", "-tenant", "boots"})
+			Expect(err).To(MatchError("coupon 404 not found"))
+		})
+
+		It("finds coupons matching the filters", func() {
+			backend.GetCouponsReturns([]*coupon.Coupon{boots, boots}, nil)
+
+			err := couponCLI.Run(ctx, []string{"find", "-brand", "boots", "-value", "10", "-tenant", "boots", "-output", "json"})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, filters := backend.GetCouponsArgsForCall(0)
+			Expect(*filters.Brand).To(Equal("boots"))
+			Expect(*filters.Value).To(Equal(10))
+			Expect(filters.Name).To(BeNil())
+
+			Expect(out.String()).To(MatchJSON(`[
+				{"id": "123", "name": "10% off", "brand": "boots", "value": 10, "version": 1},
+				{"id": "123", "name": "10% off", "brand": "boots", "value": 10, "version": 1}
+			]`))
+		})
+
+		It("shows nothing found as an empty list", func() {
+			backend.GetCouponsReturns(nil, sql.ErrNoRows)
+
+			Expect(couponCLI.Run(ctx, []string{"find", "-tenant", "boots", "-output", "json"})).To(Succeed())
+			Expect(out.String()).To(MatchJSON(`[]`))
+		})
+
+		It("rejects a value that isn't a number", func() {
+			err := couponCLI.Run(ctx, []string{"find", "-value", "ten", "-tenant", "boots"})
+			Expect(err).To(MatchError(`-value must be a whole number, got "ten"`))
+		})
+	})
+
+	Describe("expire", func() {
+		var now time.Time
+
+		BeforeEach(func() {
+			backend.GetCouponByIdReturns(boots, nil)
+
+			now = time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+			couponCLI.Now = func() time.Time { return now }
+		})
+
+		It("sets the coupon's expiry to now and shows the expired coupon", func() {
+			Expect(couponCLI.Run(ctx, []string{"expire", "123", "-tenant", "boots", "-output", "json"})).To(Succeed())
+
+			Expect(backend.UpdateCouponCallCount()).To(Equal(1))
+			updateCtx, updated := backend.UpdateCouponArgsForCall(0)
+			Expect(updated.ID).To(Equal("123"))
+			Expect(*updated.Expiry).To(Equal(now))
+			Expect(updated.Name).To(BeNil())
+			Expect(updated.Value).To(BeNil())
+			Expect(requestcontext.Actor(updateCtx)).To(Equal("cli:madeleine"))
+
+			Expect(out.String()).To(MatchJSON(`[
+				{"id": "123", "name": "10% off", "brand": "boots", "value": 10, "version": 1, "expiry": "2026-10-19T09:30:00Z"}
+			]`))
+		})
+
+		It("shows the expiry in the table", func() {
+			Expect(couponCLI.Run(ctx, []string{"expire", "123", "-tenant", "boots"})).To(Succeed())
+			Expect(out.String()).To(MatchRegexp(`EXPIRY\n123\s+10% off\s+boots\s+10\s+1\s+2026-10-19T09:30:00Z\n`))
+		})
+
+		It("leaves coupons that have already expired alone", func() {
+			expired := now.Add(-time.Hour)
+			alreadyExpired := *boots
+			alreadyExpired.Expiry = &expired
+			backend.GetCouponByIdReturns(&alreadyExpired, nil)
+
+			err := couponCLI.Run(ctx, []string{"expire", "123", "-tenant", "boots"})
+			Expect(err).To(MatchError("coupon 123 already expired at 2026-10-19T08:30:00Z"))
+			Expect(backend.UpdateCouponCallCount()).To(Equal(0))
+		})
+
+		It("only shows the coupon on a dry run", func() {
+			Expect(couponCLI.Run(ctx, []string{"expire", "-dry-run", "123", "-tenant", "boots", "-output", "json"})).To(Succeed())
+
+			Expect(backend.UpdateCouponCallCount()).To(Equal(0))
+			Expect(out.String()).To(ContainSubstring(`"expiry": "2026-10-19T09:30:00Z"`))
+			Expect(errOut.String()).To(ContainSubstring("dry run"))
+		})
+
+		It("reports a coupon that doesn't exist", func() {
+			backend.GetCouponByIdReturns(nil, sql.ErrNoRows)
+
+			err := couponCLI.Run(ctx, []string{"expire", "404", "-tenant", "boots"})
+			Expect(err).To(MatchError("coupon 404 not found"))
+			Expect(backend.UpdateCouponCallCount()).To(Equal(0))
+		})
+
+		It("needs a coupon id", func() {
+			Expect(couponCLI.Run(ctx, []string{"expire", "-tenant", "boots"})).To(MatchError("coupon expire takes a coupon id"))
+		})
+	})
+
+	Describe("export", func() {
+		It("streams every matching coupon out as CSV by default", func() {
+			backend.StreamCouponsStub = func(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error {
+				Expect(*filters.Name).To(Equal("10% off"))
+				return fn(boots)
+			}
+
+			Expect(couponCLI.Run(ctx, []string{"export", "-name", "10% off", "-tenant", "boots"})).To(Succeed())
+			Expect(out.String()).To(Equal("id,name,brand,value,expiry\n123,10% off,boots,10,\n"))
+		})
+
+		It("returns errors from the backend", func() {
+			backend.StreamCouponsReturns(errors.New("connection refused"))
+
+			Expect(couponCLI.Run(ctx, []string{"export", "-tenant", "boots"})).To(MatchError("connection refused"))
+		})
+
+		It("rejects an unknown output before connecting", func() {
+			Expect(couponCLI.Run(ctx, []string{"export", "-tenant", "boots", "-output", "xml"})).To(MatchError(`unsupported output "xml"`))
+			Expect(connected).To(BeEmpty())
+		})
+	})
+
+	Describe("choosing a backend", func() {
+		It("needs a tenant to go to the database", func() {
+			err := couponCLI.Run(ctx, []string{"find", "123"})
+			Expect(err).To(MatchError("-tenant is required, unless going through the API with -api-url"))
+		})
+
+		It("goes through the API when given its URL and a key, from flags or the environment", func() {
+			env["COUPONS_API_URL"] = "https://coupons.example.com"
+			env["COUPONS_API_KEY"] = "from-env"
+
+			Expect(couponCLI.Run(ctx, []string{"find", "-api-key", "from-flag"})).To(Succeed())
+			Expect(connected).To(Equal([]cli.Options{{APIURL: "https://coupons.example.com", APIKey: "from-flag"}}))
+		})
+
+		It("needs an API key to go through the API", func() {
+			err := couponCLI.Run(ctx, []string{"find", "-api-url", "https://coupons.example.com"})
+			Expect(err).To(MatchError("-api-key is required with -api-url"))
+		})
+	})
+})
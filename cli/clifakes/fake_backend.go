@@ -0,0 +1,432 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package clifakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/madeleinesmith/coupons/cli"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/coupon"
+)
+
+type FakeBackend struct {
+	CreateCouponStub        func(context.Context, coupon.Coupon) (*coupon.Coupon, error)
+	createCouponMutex       sync.RWMutex
+	createCouponArgsForCall []struct {
+		arg1 context.Context
+		arg2 coupon.Coupon
+	}
+	createCouponReturns struct {
+		result1 *coupon.Coupon
+		result2 error
+	}
+	createCouponReturnsOnCall map[int]struct {
+		result1 *coupon.Coupon
+		result2 error
+	}
+	GetCouponByIdStub        func(context.Context, string) (*coupon.Coupon, error)
+	getCouponByIdMutex       sync.RWMutex
+	getCouponByIdArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getCouponByIdReturns struct {
+		result1 *coupon.Coupon
+		result2 error
+	}
+	getCouponByIdReturnsOnCall map[int]struct {
+		result1 *coupon.Coupon
+		result2 error
+	}
+	GetCouponsStub        func(context.Context, handlers.Filters) ([]*coupon.Coupon, error)
+	getCouponsMutex       sync.RWMutex
+	getCouponsArgsForCall []struct {
+		arg1 context.Context
+		arg2 handlers.Filters
+	}
+	getCouponsReturns struct {
+		result1 []*coupon.Coupon
+		result2 error
+	}
+	getCouponsReturnsOnCall map[int]struct {
+		result1 []*coupon.Coupon
+		result2 error
+	}
+	StreamCouponsStub        func(context.Context, handlers.Filters, func(*coupon.Coupon) error) error
+	streamCouponsMutex       sync.RWMutex
+	streamCouponsArgsForCall []struct {
+		arg1 context.Context
+		arg2 handlers.Filters
+		arg3 func(*coupon.Coupon) error
+	}
+	streamCouponsReturns struct {
+		result1 error
+	}
+	streamCouponsReturnsOnCall map[int]struct {
+		result1 error
+	}
+	UpdateCouponStub        func(context.Context, coupon.Coupon) error
+	updateCouponMutex       sync.RWMutex
+	updateCouponArgsForCall []struct {
+		arg1 context.Context
+		arg2 coupon.Coupon
+	}
+	updateCouponReturns struct {
+		result1 error
+	}
+	updateCouponReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeBackend) CreateCoupon(arg1 context.Context, arg2 coupon.Coupon) (*coupon.Coupon, error) {
+	fake.createCouponMutex.Lock()
+	ret, specificReturn := fake.createCouponReturnsOnCall[len(fake.createCouponArgsForCall)]
+	fake.createCouponArgsForCall = append(fake.createCouponArgsForCall, struct {
+		arg1 context.Context
+		arg2 coupon.Coupon
+	}{arg1, arg2})
+	fake.recordInvocation("CreateCoupon", []interface{}{arg1, arg2})
+	fake.createCouponMutex.Unlock()
+	if fake.CreateCouponStub != nil {
+		return fake.CreateCouponStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.createCouponReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeBackend) CreateCouponCallCount() int {
+	fake.createCouponMutex.RLock()
+	defer fake.createCouponMutex.RUnlock()
+	return len(fake.createCouponArgsForCall)
+}
+
+func (fake *FakeBackend) CreateCouponCalls(stub func(context.Context, coupon.Coupon) (*coupon.Coupon, error)) {
+	fake.createCouponMutex.Lock()
+	defer fake.createCouponMutex.Unlock()
+	fake.CreateCouponStub = stub
+}
+
+func (fake *FakeBackend) CreateCouponArgsForCall(i int) (context.Context, coupon.Coupon) {
+	fake.createCouponMutex.RLock()
+	defer fake.createCouponMutex.RUnlock()
+	argsForCall := fake.createCouponArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeBackend) CreateCouponReturns(result1 *coupon.Coupon, result2 error) {
+	fake.createCouponMutex.Lock()
+	defer fake.createCouponMutex.Unlock()
+	fake.CreateCouponStub = nil
+	fake.createCouponReturns = struct {
+		result1 *coupon.Coupon
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBackend) CreateCouponReturnsOnCall(i int, result1 *coupon.Coupon, result2 error) {
+	fake.createCouponMutex.Lock()
+	defer fake.createCouponMutex.Unlock()
+	fake.CreateCouponStub = nil
+	if fake.createCouponReturnsOnCall == nil {
+		fake.createCouponReturnsOnCall = make(map[int]struct {
+			result1 *coupon.Coupon
+			result2 error
+		})
+	}
+	fake.createCouponReturnsOnCall[i] = struct {
+		result1 *coupon.Coupon
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBackend) GetCouponById(arg1 context.Context, arg2 string) (*coupon.Coupon, error) {
+	fake.getCouponByIdMutex.Lock()
+	ret, specificReturn := fake.getCouponByIdReturnsOnCall[len(fake.getCouponByIdArgsForCall)]
+	fake.getCouponByIdArgsForCall = append(fake.getCouponByIdArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("GetCouponById", []interface{}{arg1, arg2})
+	fake.getCouponByIdMutex.Unlock()
+	if fake.GetCouponByIdStub != nil {
+		return fake.GetCouponByIdStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.getCouponByIdReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeBackend) GetCouponByIdCallCount() int {
+	fake.getCouponByIdMutex.RLock()
+	defer fake.getCouponByIdMutex.RUnlock()
+	return len(fake.getCouponByIdArgsForCall)
+}
+
+func (fake *FakeBackend) GetCouponByIdCalls(stub func(context.Context, string) (*coupon.Coupon, error)) {
+	fake.getCouponByIdMutex.Lock()
+	defer fake.getCouponByIdMutex.Unlock()
+	fake.GetCouponByIdStub = stub
+}
+
+func (fake *FakeBackend) GetCouponByIdArgsForCall(i int) (context.Context, string) {
+	fake.getCouponByIdMutex.RLock()
+	defer fake.getCouponByIdMutex.RUnlock()
+	argsForCall := fake.getCouponByIdArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeBackend) GetCouponByIdReturns(result1 *coupon.Coupon, result2 error) {
+	fake.getCouponByIdMutex.Lock()
+	defer fake.getCouponByIdMutex.Unlock()
+	fake.GetCouponByIdStub = nil
+	fake.getCouponByIdReturns = struct {
+		result1 *coupon.Coupon
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBackend) GetCouponByIdReturnsOnCall(i int, result1 *coupon.Coupon, result2 error) {
+	fake.getCouponByIdMutex.Lock()
+	defer fake.getCouponByIdMutex.Unlock()
+	fake.GetCouponByIdStub = nil
+	if fake.getCouponByIdReturnsOnCall == nil {
+		fake.getCouponByIdReturnsOnCall = make(map[int]struct {
+			result1 *coupon.Coupon
+			result2 error
+		})
+	}
+	fake.getCouponByIdReturnsOnCall[i] = struct {
+		result1 *coupon.Coupon
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBackend) GetCoupons(arg1 context.Context, arg2 handlers.Filters) ([]*coupon.Coupon, error) {
+	fake.getCouponsMutex.Lock()
+	ret, specificReturn := fake.getCouponsReturnsOnCall[len(fake.getCouponsArgsForCall)]
+	fake.getCouponsArgsForCall = append(fake.getCouponsArgsForCall, struct {
+		arg1 context.Context
+		arg2 handlers.Filters
+	}{arg1, arg2})
+	fake.recordInvocation("GetCoupons", []interface{}{arg1, arg2})
+	fake.getCouponsMutex.Unlock()
+	if fake.GetCouponsStub != nil {
+		return fake.GetCouponsStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.getCouponsReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeBackend) GetCouponsCallCount() int {
+	fake.getCouponsMutex.RLock()
+	defer fake.getCouponsMutex.RUnlock()
+	return len(fake.getCouponsArgsForCall)
+}
+
+func (fake *FakeBackend) GetCouponsCalls(stub func(context.Context, handlers.Filters) ([]*coupon.Coupon, error)) {
+	fake.getCouponsMutex.Lock()
+	defer fake.getCouponsMutex.Unlock()
+	fake.GetCouponsStub = stub
+}
+
+func (fake *FakeBackend) GetCouponsArgsForCall(i int) (context.Context, handlers.Filters) {
+	fake.getCouponsMutex.RLock()
+	defer fake.getCouponsMutex.RUnlock()
+	argsForCall := fake.getCouponsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeBackend) GetCouponsReturns(result1 []*coupon.Coupon, result2 error) {
+	fake.getCouponsMutex.Lock()
+	defer fake.getCouponsMutex.Unlock()
+	fake.GetCouponsStub = nil
+	fake.getCouponsReturns = struct {
+		result1 []*coupon.Coupon
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBackend) GetCouponsReturnsOnCall(i int, result1 []*coupon.Coupon, result2 error) {
+	fake.getCouponsMutex.Lock()
+	defer fake.getCouponsMutex.Unlock()
+	fake.GetCouponsStub = nil
+	if fake.getCouponsReturnsOnCall == nil {
+		fake.getCouponsReturnsOnCall = make(map[int]struct {
+			result1 []*coupon.Coupon
+			result2 error
+		})
+	}
+	fake.getCouponsReturnsOnCall[i] = struct {
+		result1 []*coupon.Coupon
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBackend) StreamCoupons(arg1 context.Context, arg2 handlers.Filters, arg3 func(*coupon.Coupon) error) error {
+	fake.streamCouponsMutex.Lock()
+	ret, specificReturn := fake.streamCouponsReturnsOnCall[len(fake.streamCouponsArgsForCall)]
+	fake.streamCouponsArgsForCall = append(fake.streamCouponsArgsForCall, struct {
+		arg1 context.Context
+		arg2 handlers.Filters
+		arg3 func(*coupon.Coupon) error
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("StreamCoupons", []interface{}{arg1, arg2, arg3})
+	fake.streamCouponsMutex.Unlock()
+	if fake.StreamCouponsStub != nil {
+		return fake.StreamCouponsStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.streamCouponsReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeBackend) StreamCouponsCallCount() int {
+	fake.streamCouponsMutex.RLock()
+	defer fake.streamCouponsMutex.RUnlock()
+	return len(fake.streamCouponsArgsForCall)
+}
+
+func (fake *FakeBackend) StreamCouponsCalls(stub func(context.Context, handlers.Filters, func(*coupon.Coupon) error) error) {
+	fake.streamCouponsMutex.Lock()
+	defer fake.streamCouponsMutex.Unlock()
+	fake.StreamCouponsStub = stub
+}
+
+func (fake *FakeBackend) StreamCouponsArgsForCall(i int) (context.Context, handlers.Filters, func(*coupon.Coupon) error) {
+	fake.streamCouponsMutex.RLock()
+	defer fake.streamCouponsMutex.RUnlock()
+	argsForCall := fake.streamCouponsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeBackend) StreamCouponsReturns(result1 error) {
+	fake.streamCouponsMutex.Lock()
+	defer fake.streamCouponsMutex.Unlock()
+	fake.StreamCouponsStub = nil
+	fake.streamCouponsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBackend) StreamCouponsReturnsOnCall(i int, result1 error) {
+	fake.streamCouponsMutex.Lock()
+	defer fake.streamCouponsMutex.Unlock()
+	fake.StreamCouponsStub = nil
+	if fake.streamCouponsReturnsOnCall == nil {
+		fake.streamCouponsReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.streamCouponsReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBackend) UpdateCoupon(arg1 context.Context, arg2 coupon.Coupon) error {
+	fake.updateCouponMutex.Lock()
+	ret, specificReturn := fake.updateCouponReturnsOnCall[len(fake.updateCouponArgsForCall)]
+	fake.updateCouponArgsForCall = append(fake.updateCouponArgsForCall, struct {
+		arg1 context.Context
+		arg2 coupon.Coupon
+	}{arg1, arg2})
+	fake.recordInvocation("UpdateCoupon", []interface{}{arg1, arg2})
+	fake.updateCouponMutex.Unlock()
+	if fake.UpdateCouponStub != nil {
+		return fake.UpdateCouponStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.updateCouponReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeBackend) UpdateCouponCallCount() int {
+	fake.updateCouponMutex.RLock()
+	defer fake.updateCouponMutex.RUnlock()
+	return len(fake.updateCouponArgsForCall)
+}
+
+func (fake *FakeBackend) UpdateCouponCalls(stub func(context.Context, coupon.Coupon) error) {
+	fake.updateCouponMutex.Lock()
+	defer fake.updateCouponMutex.Unlock()
+	fake.UpdateCouponStub = stub
+}
+
+func (fake *FakeBackend) UpdateCouponArgsForCall(i int) (context.Context, coupon.Coupon) {
+	fake.updateCouponMutex.RLock()
+	defer fake.updateCouponMutex.RUnlock()
+	argsForCall := fake.updateCouponArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeBackend) UpdateCouponReturns(result1 error) {
+	fake.updateCouponMutex.Lock()
+	defer fake.updateCouponMutex.Unlock()
+	fake.UpdateCouponStub = nil
+	fake.updateCouponReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBackend) UpdateCouponReturnsOnCall(i int, result1 error) {
+	fake.updateCouponMutex.Lock()
+	defer fake.updateCouponMutex.Unlock()
+	fake.UpdateCouponStub = nil
+	if fake.updateCouponReturnsOnCall == nil {
+		fake.updateCouponReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.updateCouponReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBackend) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.createCouponMutex.RLock()
+	defer fake.createCouponMutex.RUnlock()
+	fake.getCouponByIdMutex.RLock()
+	defer fake.getCouponByIdMutex.RUnlock()
+	fake.getCouponsMutex.RLock()
+	defer fake.getCouponsMutex.RUnlock()
+	fake.streamCouponsMutex.RLock()
+	defer fake.streamCouponsMutex.RUnlock()
+	fake.updateCouponMutex.RLock()
+	defer fake.updateCouponMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeBackend) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ cli.Backend = new(FakeBackend)
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/madeleinesmith/coupons/exporters"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// Outputs are the values accepted for -output
+var Outputs = []string{"table", "json", "csv"}
+
+type couponWriter interface {
+	Write(couponInstance *coupon.Coupon) error
+	Close() error
+}
+
+func newWriter(output string, w io.Writer) (couponWriter, error) {
+	switch output {
+	case "table":
+		return newTableWriter(w), nil
+	case "json":
+		return &jsonWriter{w: w}, nil
+	case "csv":
+		return exporters.NewCSVWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported output %q", output)
+	}
+}
+
+func writeCoupons(w io.Writer, output string, coupons []*coupon.Coupon) error {
+	writer, err := newWriter(output, w)
+	if err != nil {
+		return err
+	}
+
+	for _, couponInstance := range coupons {
+		err := writer.Write(couponInstance)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+type tableWriter struct {
+	writer *tabwriter.Writer
+}
+
+func newTableWriter(w io.Writer) *tableWriter {
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tNAME\tBRAND\tVALUE\tVERSION\tEXPIRY")
+
+	return &tableWriter{writer: writer}
+}
+
+func (w *tableWriter) Write(couponInstance *coupon.Coupon) error {
+	_, err := fmt.Fprintf(w.writer, "%s\t%s\t%s\t%s\t%s\t%s\n", couponInstance.ID, stringOrBlank(couponInstance.Name),
+		stringOrBlank(couponInstance.Brand), intOrBlank(couponInstance.Value), intOrBlank(couponInstance.Version),
+		timeOrBlank(couponInstance.Expiry))
+
+	return err
+}
+
+// Close writes the table, which is held back until then so its columns can be lined up
+func (w *tableWriter) Close() error {
+	return w.writer.Flush()
+}
+
+type jsonCoupon struct {
+	ID      string     `json:"id,omitempty"`
+	Name    *string    `json:"name"`
+	Brand   *string    `json:"brand"`
+	Value   *int       `json:"value"`
+	Version *int       `json:"version,omitempty"`
+	Expiry  *time.Time `json:"expiry,omitempty"`
+}
+
+// jsonWriter writes an array of plain objects, rather than a JSON:API document, so it's easy to use with jq
+type jsonWriter struct {
+	w       io.Writer
+	written int
+}
+
+func (w *jsonWriter) Write(couponInstance *coupon.Coupon) error {
+	encoded, err := json.MarshalIndent(jsonCoupon(*couponInstance), "  ", "  ")
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n  "
+	if w.written == 0 {
+		separator = "[\n  "
+	}
+	w.written++
+
+	_, err = fmt.Fprintf(w.w, "%s%s", separator, encoded)
+	return err
+}
+
+func (w *jsonWriter) Close() error {
+	if w.written == 0 {
+		_, err := fmt.Fprintln(w.w, "[]")
+		return err
+	}
+
+	_, err := fmt.Fprintln(w.w, "\n]")
+	return err
+}
+
+func stringOrBlank(value *string) string {
+	if value == nil {
+		return ""
+	}
+
+	return *value
+}
+
+func intOrBlank(value *int) string {
+	if value == nil {
+		return ""
+	}
+
+	return strconv.Itoa(*value)
+}
+
+func timeOrBlank(value *time.Time) string {
+	if value == nil {
+		return ""
+	}
+
+	return value.Format(time.RFC3339)
+}
@@ -0,0 +1,441 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/jsonapi"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/idempotency"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"io"
+	mathrand "math/rand/v2"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxAttempts = 3
+	DefaultBackoff     = 200 * time.Millisecond
+	DefaultMaxBackoff  = 5 * time.Second
+)
+
+type key int
+
+const idempotencyKeyKey key = iota
+
+// WithIdempotencyKey makes the writes made with ctx use key, so a caller retrying an operation of its own after the
+// client has given up doesn't repeat it. Otherwise each call gets a new key, kept across the client's own retries.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyKey, key)
+}
+
+// Client calls the coupons API. Its methods mirror handlers.CouponService, so it can stand in for the service
+// backed by the database. Reads, and writes sent with an idempotency key, are retried when the connection fails or
+// the API is rate limiting or unavailable, waiting Backoff and doubling up to MaxBackoff, or as long as the API's
+// Retry-After asks. Updates and reverts are never retried, as the API can't tell a retry from a second request.
+type Client struct {
+	URL         string
+	APIKey      string
+	HTTPClient  *http.Client
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+}
+
+func New(apiURL string, apiKey string) *Client {
+	return &Client{
+		URL:         apiURL,
+		APIKey:      apiKey,
+		HTTPClient:  http.DefaultClient,
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     DefaultBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+	}
+}
+
+func (c *Client) CreateCoupon(ctx context.Context, couponInstance coupon.Coupon) (*coupon.Coupon, error) {
+	body, err := coupon.Serializer{}.SerializeCoupon(&couponInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.do(ctx, http.MethodPost, "/coupons", nil, body, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	return decodeCoupon(response)
+}
+
+// CreateCoupons creates every coupon or none, as atomic operations in one transaction
+func (c *Client) CreateCoupons(ctx context.Context, coupons []coupon.Coupon) error {
+	operations := make([]atomicOperation, len(coupons))
+	for i := range coupons {
+		document, err := coupon.Serializer{}.SerializeCoupon(&coupons[i])
+		if err != nil {
+			return err
+		}
+
+		var resource struct {
+			Data json.RawMessage `json:"data"`
+		}
+		err = json.Unmarshal(document, &resource)
+		if err != nil {
+			return err
+		}
+
+		operations[i] = atomicOperation{Op: "add", Data: resource.Data}
+	}
+
+	return c.runOperations(ctx, operations)
+}
+
+// UpdateCoupon changes the fields set on couponInstance, which must have an ID
+func (c *Client) UpdateCoupon(ctx context.Context, couponInstance coupon.Coupon) error {
+	if couponInstance.ID == "" {
+		return errors.New("updating a coupon needs its ID")
+	}
+
+	body, err := coupon.Serializer{}.SerializeCoupon(&couponInstance)
+	if err != nil {
+		return err
+	}
+
+	response, err := c.do(ctx, http.MethodPatch, "/coupons", nil, body, "application/json")
+	if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
+
+// GetCoupons returns an error matching sql.ErrNoRows when no coupons match, as handlers.CouponService does. Coupons
+// pages through them instead, for when there may be too many to hold at once.
+func (c *Client) GetCoupons(ctx context.Context, filters handlers.Filters) ([]*coupon.Coupon, error) {
+	response, err := c.do(ctx, http.MethodGet, "/coupons", filterQuery(filters), nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	resources, err := jsonapi.UnmarshalManyPayload(response.Body, reflect.TypeOf(new(coupon.Coupon)))
+	if err != nil {
+		return nil, err
+	}
+
+	coupons := make([]*coupon.Coupon, len(resources))
+	for i, resource := range resources {
+		coupons[i] = resource.(*coupon.Coupon)
+	}
+
+	return coupons, nil
+}
+
+// GetCouponPage returns the page of coupons matching filters after filters.After, of filters.Limit coupons or
+// handlers.DefaultPageSize. next is the After for the page following it, or empty if this is the last page.
+func (c *Client) GetCouponPage(ctx context.Context, filters handlers.Filters) (coupons []*coupon.Coupon, next string, err error) {
+	if filters.Limit == nil {
+		pageSize := handlers.DefaultPageSize
+		filters.Limit = &pageSize
+	}
+
+	response, err := c.do(ctx, http.MethodGet, "/coupons", filterQuery(filters), nil, "")
+	if err != nil {
+		return nil, "", err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	resources, err := jsonapi.UnmarshalManyPayload(bytes.NewReader(body), reflect.TypeOf(new(coupon.Coupon)))
+	if err != nil {
+		return nil, "", err
+	}
+
+	coupons = make([]*coupon.Coupon, len(resources))
+	for i, resource := range resources {
+		coupons[i] = resource.(*coupon.Coupon)
+	}
+
+	var document struct {
+		Links struct {
+			Next string `json:"next"`
+		} `json:"links"`
+	}
+
+	err = json.Unmarshal(body, &document)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if document.Links.Next == "" {
+		return coupons, "", nil
+	}
+
+	nextURL, err := url.Parse(document.Links.Next)
+	if err != nil {
+		return nil, "", fmt.Errorf("decoding GET /coupons: next link %q: %v", document.Links.Next, err)
+	}
+
+	return coupons, nextURL.Query().Get("page[after]"), nil
+}
+
+type exportedCoupon struct {
+	ID     string     `json:"id"`
+	Name   *string    `json:"name"`
+	Brand  *string    `json:"brand"`
+	Value  *int       `json:"value"`
+	Expiry *time.Time `json:"expiry"`
+}
+
+// StreamCoupons calls fn with each coupon as it's read, stopping at the first error fn returns. Rather than paging,
+// it reads the export as it streams in, which never holds every coupon either.
+func (c *Client) StreamCoupons(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error {
+	query := filterQuery(filters)
+	query.Set("format", "ndjson")
+
+	response, err := c.do(ctx, http.MethodGet, "/coupons/export", query, nil, "")
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var exported exportedCoupon
+		err = decoder.Decode(&exported)
+		// the API cuts the connection if the export fails part way through, which reads as an unexpected EOF rather
+		// than the end
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		err = fn(&coupon.Coupon{ID: exported.ID, Name: exported.Name, Brand: exported.Brand, Value: exported.Value, Expiry: exported.Expiry})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) GetCouponById(ctx context.Context, couponId string) (*coupon.Coupon, error) {
+	return c.getCoupon(ctx, "/coupon/"+url.PathEscape(couponId), nil)
+}
+
+func (c *Client) GetCouponAsOf(ctx context.Context, couponId string, asOf time.Time) (*coupon.Coupon, error) {
+	return c.getCoupon(ctx, "/coupon/"+url.PathEscape(couponId), url.Values{"as_of": {asOf.Format(time.RFC3339)}})
+}
+
+func (c *Client) GetCouponVersion(ctx context.Context, couponId string, version int) (*coupon.Coupon, error) {
+	return c.getCoupon(ctx, "/coupon/"+url.PathEscape(couponId)+"/versions/"+strconv.Itoa(version), nil)
+}
+
+func (c *Client) RevertCoupon(ctx context.Context, couponId string, version int) (*coupon.Coupon, error) {
+	body, err := json.Marshal(map[string]interface{}{"meta": map[string]int{"revertToVersion": version}})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := c.do(ctx, http.MethodPatch, "/coupon/"+url.PathEscape(couponId), nil, body, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	return decodeCoupon(response)
+}
+
+// DeleteCoupon removes the coupon with an atomic operation, as there's no DELETE route
+func (c *Client) DeleteCoupon(ctx context.Context, couponId string) error {
+	return c.runOperations(ctx, []atomicOperation{{Op: "remove", Ref: &atomicRef{Type: "coupons", ID: couponId}}})
+}
+
+type atomicRef struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type atomicOperation struct {
+	Op   string          `json:"op"`
+	Ref  *atomicRef      `json:"ref,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+func (c *Client) runOperations(ctx context.Context, operations []atomicOperation) error {
+	body, err := json.Marshal(map[string][]atomicOperation{"atomic:operations": operations})
+	if err != nil {
+		return err
+	}
+
+	response, err := c.do(ctx, http.MethodPost, "/operations", nil, body, handlers.AtomicContentType)
+	if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
+
+func (c *Client) getCoupon(ctx context.Context, path string, query url.Values) (*coupon.Coupon, error) {
+	response, err := c.do(ctx, http.MethodGet, path, query, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	return decodeCoupon(response)
+}
+
+// do sends the request, retrying it if it's safe to, and returns the response if it succeeded or an *Error if the
+// API refused it. The caller closes the response's body.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body []byte, contentType string) (*http.Response, error) {
+	requestURL := strings.TrimSuffix(c.URL, "/") + path
+	if len(query) > 0 {
+		requestURL += "?" + query.Encode()
+	}
+
+	headers := http.Header{}
+	headers.Set("X-API-Key", c.APIKey)
+	if contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+
+	retryable := method == http.MethodGet
+	if method == http.MethodPost {
+		headers.Set(idempotency.Header, idempotencyKey(ctx))
+		retryable = true
+	}
+
+	backoff := c.Backoff
+	for attempt := 1; ; attempt++ {
+		request, err := http.NewRequestWithContext(ctx, method, requestURL, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		request.Header = headers.Clone()
+
+		var retryAfter time.Duration
+		response, err := c.httpClient().Do(request)
+		if err == nil {
+			if response.StatusCode < http.StatusBadRequest {
+				return response, nil
+			}
+
+			apiErr := decodeError(response)
+			retryAfter = apiErr.RetryAfter
+			err = apiErr
+		}
+
+		if !retryable || attempt >= c.MaxAttempts || !shouldRetry(ctx, err) {
+			return nil, err
+		}
+
+		// jittered, so clients failing together don't retry together
+		wait := backoff/2 + mathrand.N(backoff/2+1)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(wait):
+		}
+
+		backoff *= 2
+		if backoff > c.MaxBackoff {
+			backoff = c.MaxBackoff
+		}
+	}
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+
+	return c.HTTPClient
+}
+
+// shouldRetry is true for failures that may pass: lost connections, rate limits, an idempotent request still in
+// flight, and the API or a proxy in front of it being unavailable
+func shouldRetry(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		return true
+	}
+
+	switch apiErr.StatusCode {
+	case http.StatusConflict, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+func idempotencyKey(ctx context.Context) string {
+	if key, ok := ctx.Value(idempotencyKeyKey).(string); ok && key != "" {
+		return key
+	}
+
+	key := make([]byte, 16)
+	rand.Read(key)
+
+	return hex.EncodeToString(key)
+}
+
+func decodeCoupon(response *http.Response) (*coupon.Coupon, error) {
+	couponInstance := new(coupon.Coupon)
+
+	err := jsonapi.UnmarshalPayload(response.Body, couponInstance)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s %s: %v", response.Request.Method, response.Request.URL.Path, err)
+	}
+
+	return couponInstance, nil
+}
+
+func filterQuery(filters handlers.Filters) url.Values {
+	query := url.Values{}
+
+	if filters.Name != nil {
+		query.Set("name", *filters.Name)
+	}
+
+	if filters.Brand != nil {
+		query.Set("brand", *filters.Brand)
+	}
+
+	if filters.Value != nil {
+		query.Set("value", strconv.Itoa(*filters.Value))
+	}
+
+	if filters.After != nil {
+		query.Set("page[after]", *filters.After)
+	}
+
+	if filters.Limit != nil {
+		query.Set("page[size]", strconv.Itoa(*filters.Limit))
+	}
+
+	return query
+}
@@ -0,0 +1,102 @@
+package client_test
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"github.com/madeleinesmith/coupons/validators"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Client Suite")
+}
+
+// api serves the real handlers over couponService, and can be made to fail the next requests before they reach them
+type api struct {
+	server        *httptest.Server
+	couponService *handlersfakes.FakeCouponService
+
+	mutex    sync.Mutex
+	failures []int
+	requests []*http.Request
+}
+
+func newAPI() *api {
+	a := &api{couponService: new(handlersfakes.FakeCouponService)}
+
+	transactor := new(handlersfakes.FakeCouponTransactor)
+	transactor.WithinTransactionStub = func(ctx context.Context, fn func(handlers.CouponService) error) error {
+		return fn(a.couponService)
+	}
+
+	serializer := coupon.Serializer{}
+	validator := validators.CouponValidator{}
+	router := mux.NewRouter()
+	router.Path("/coupons").Handler(handlers.CouponHandler{CouponService: a.couponService, Serializer: serializer, CouponValidator: validator})
+	router.Path("/coupons/export").Handler(handlers.ExportHandler{CouponService: a.couponService})
+	router.Path("/coupon/{couponId}").Handler(handlers.CouponDetailsHandler{CouponService: a.couponService, Serializer: serializer})
+	router.Path("/coupon/{couponId}/versions/{version}").Handler(handlers.CouponVersionHandler{CouponService: a.couponService, Serializer: serializer})
+	router.Path("/operations").Handler(handlers.OperationsHandler{CouponTransactor: transactor, Serializer: serializer, CouponValidator: validator})
+	router.Use(requestcontext.Middleware)
+	router.Use(a.record)
+
+	a.server = httptest.NewServer(router)
+	return a
+}
+
+func (a *api) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		a.mutex.Lock()
+		a.requests = append(a.requests, req)
+		var failure int
+		if len(a.failures) > 0 {
+			failure, a.failures = a.failures[0], a.failures[1:]
+		}
+		a.mutex.Unlock()
+
+		if failure != 0 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, "try again", failure)
+			return
+		}
+
+		if req.Header.Get("X-API-Key") != "secret" {
+			http.Error(w, "invalid API key", http.StatusUnauthorized)
+			return
+		}
+
+		principal := &auth.Principal{Name: "checkout", Scopes: []string{auth.ScopeCouponsRead, auth.ScopeCouponsWrite}}
+		next.ServeHTTP(w, req.WithContext(auth.WithPrincipal(req.Context(), principal)))
+	})
+}
+
+// fail makes the next requests fail with the statuses given, one each
+func (a *api) fail(statusCodes ...int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.failures = append(a.failures, statusCodes...)
+}
+
+func (a *api) received() []*http.Request {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return append([]*http.Request(nil), a.requests...)
+}
+
+func (a *api) close() {
+	a.server.Close()
+}
@@ -0,0 +1,280 @@
+package client_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/client"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/idempotency"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"time"
+)
+
+var _ handlers.CouponService = (*client.Client)(nil)
+
+var _ = Describe("Client", func() {
+	var (
+		server      *api
+		couponsAPI  *client.Client
+		ctx         context.Context
+		boots       *coupon.Coupon
+		name, brand string
+		value       int
+	)
+
+	BeforeEach(func() {
+		server = newAPI()
+		couponsAPI = client.New(server.server.URL+"/", "secret")
+		couponsAPI.HTTPClient = server.server.Client()
+		couponsAPI.Backoff = time.Millisecond
+		ctx = context.Background()
+
+		name, brand, value = "10% off", "boots", 10
+		version := 1
+		boots = &coupon.Coupon{ID: "123", Name: &name, Brand: &brand, Value: &value, Version: &version}
+	})
+
+	AfterEach(func() {
+		server.close()
+	})
+
+	Describe("CreateCoupon", func() {
+		It("creates the coupon with an idempotency key", func() {
+			server.couponService.CreateCouponReturns(boots, nil)
+
+			created, err := couponsAPI.CreateCoupon(ctx, coupon.Coupon{Name: &name, Brand: &brand, Value: &value})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(created).To(Equal(boots))
+
+			_, sent := server.couponService.CreateCouponArgsForCall(0)
+			Expect(*sent.Name).To(Equal("10% off"))
+			Expect(server.received()[0].Header.Get(idempotency.Header)).To(HaveLen(32))
+		})
+
+		It("retries with the same idempotency key when the API is unavailable", func() {
+			server.fail(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+			server.couponService.CreateCouponReturns(boots, nil)
+
+			_, err := couponsAPI.CreateCoupon(ctx, coupon.Coupon{Name: &name, Brand: &brand, Value: &value})
+			Expect(err).NotTo(HaveOccurred())
+
+			requests := server.received()
+			Expect(requests).To(HaveLen(3))
+			key := requests[0].Header.Get(idempotency.Header)
+			Expect(requests[1].Header.Get(idempotency.Header)).To(Equal(key))
+			Expect(requests[2].Header.Get(idempotency.Header)).To(Equal(key))
+			Expect(server.couponService.CreateCouponCallCount()).To(Equal(1))
+		})
+
+		It("uses the idempotency key on the context", func() {
+			server.couponService.CreateCouponReturns(boots, nil)
+
+			_, err := couponsAPI.CreateCoupon(client.WithIdempotencyKey(ctx, "order-42"), coupon.Coupon{Name: &name, Brand: &brand, Value: &value})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.received()[0].Header.Get(idempotency.Header)).To(Equal("order-42"))
+		})
+
+		It("gives up after MaxAttempts, returning the last error", func() {
+			server.fail(http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusBadGateway)
+
+			_, err := couponsAPI.CreateCoupon(ctx, coupon.Coupon{Name: &name, Brand: &brand, Value: &value})
+			var apiErr *client.Error
+			Expect(errors.As(err, &apiErr)).To(BeTrue())
+			Expect(apiErr.StatusCode).To(Equal(http.StatusBadGateway))
+			Expect(server.received()).To(HaveLen(3))
+		})
+
+		It("doesn't retry a request the API rejected", func() {
+			_, err := couponsAPI.CreateCoupon(ctx, coupon.Coupon{Name: &name, Brand: &brand})
+			Expect(errors.Is(err, client.ErrBadRequest)).To(BeTrue())
+			Expect(server.received()).To(HaveLen(1))
+			Expect(server.couponService.CreateCouponCallCount()).To(Equal(0))
+		})
+
+		It("stops retrying when the context is done", func() {
+			server.fail(http.StatusServiceUnavailable)
+			couponsAPI.Backoff = time.Hour
+			couponsAPI.MaxBackoff = time.Hour
+
+			ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+
+			_, err := couponsAPI.CreateCoupon(ctx, coupon.Coupon{Name: &name, Brand: &brand, Value: &value})
+			Expect(errors.Is(err, client.ErrUnavailable)).To(BeTrue())
+			Expect(server.received()).To(HaveLen(1))
+		})
+	})
+
+	Describe("CreateCoupons", func() {
+		It("creates every coupon in one atomic request", func() {
+			server.couponService.CreateCouponReturns(boots, nil)
+			server.couponService.GetCouponByIdReturns(boots, nil)
+
+			err := couponsAPI.CreateCoupons(ctx, []coupon.Coupon{{Name: &name, Brand: &brand, Value: &value}, {Name: &name, Brand: &brand, Value: &value}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.couponService.CreateCouponCallCount()).To(Equal(2))
+			Expect(server.received()).To(HaveLen(1))
+		})
+	})
+
+	Describe("UpdateCoupon", func() {
+		It("sends the fields to change", func() {
+			err := couponsAPI.UpdateCoupon(ctx, coupon.Coupon{ID: "123", Value: &value})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, sent := server.couponService.UpdateCouponArgsForCall(0)
+			Expect(sent.ID).To(Equal("123"))
+			Expect(*sent.Value).To(Equal(10))
+		})
+
+		It("sends a new expiry, to expire the coupon", func() {
+			expiry := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+			Expect(couponsAPI.UpdateCoupon(ctx, coupon.Coupon{ID: "123", Expiry: &expiry})).To(Succeed())
+
+			_, sent := server.couponService.UpdateCouponArgsForCall(0)
+			Expect(*sent.Expiry).To(BeTemporally("==", expiry))
+			Expect(sent.Value).To(BeNil())
+		})
+
+		It("isn't retried, as the API can't tell a retry from a second update", func() {
+			server.fail(http.StatusServiceUnavailable)
+
+			err := couponsAPI.UpdateCoupon(ctx, coupon.Coupon{ID: "123", Value: &value})
+			Expect(errors.Is(err, client.ErrUnavailable)).To(BeTrue())
+			Expect(server.received()).To(HaveLen(1))
+		})
+
+		It("needs the coupon's ID", func() {
+			err := couponsAPI.UpdateCoupon(ctx, coupon.Coupon{Value: &value})
+			Expect(err).To(MatchError("updating a coupon needs its ID"))
+			Expect(server.received()).To(BeEmpty())
+		})
+	})
+
+	Describe("GetCoupons", func() {
+		It("passes the filters on", func() {
+			server.couponService.GetCouponsReturns([]*coupon.Coupon{boots}, nil)
+
+			coupons, err := couponsAPI.GetCoupons(ctx, handlers.Filters{Brand: &brand, Value: &value})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coupons).To(HaveLen(1))
+			Expect(coupons[0].ID).To(Equal("123"))
+
+			_, filters := server.couponService.GetCouponsArgsForCall(0)
+			Expect(*filters.Brand).To(Equal("boots"))
+			Expect(*filters.Value).To(Equal(10))
+			Expect(filters.Name).To(BeNil())
+		})
+
+		It("retries when rate limited", func() {
+			server.fail(http.StatusTooManyRequests)
+			server.couponService.GetCouponsReturns([]*coupon.Coupon{boots}, nil)
+
+			_, err := couponsAPI.GetCoupons(ctx, handlers.Filters{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(server.received()).To(HaveLen(2))
+		})
+
+		It("matches sql.ErrNoRows when no coupons match, as the service does", func() {
+			server.couponService.GetCouponsReturns(nil, errors.New("sql: no rows in result set"))
+
+			_, err := couponsAPI.GetCoupons(ctx, handlers.Filters{})
+			Expect(errors.Is(err, sql.ErrNoRows)).To(BeTrue())
+			Expect(errors.Is(err, client.ErrNotFound)).To(BeTrue())
+		})
+	})
+
+	Describe("GetCouponById", func() {
+		It("returns the coupon", func() {
+			server.couponService.GetCouponByIdReturns(boots, nil)
+
+			found, err := couponsAPI.GetCouponById(ctx, "123")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(Equal(boots))
+
+			_, couponId := server.couponService.GetCouponByIdArgsForCall(0)
+			Expect(couponId).To(Equal("123"))
+		})
+
+		It("decodes the API's error", func() {
+			server.couponService.GetCouponByIdReturns(nil, sql.ErrNoRows)
+
+			_, err := couponsAPI.GetCouponById(ctx, "123")
+			Expect(errors.Is(err, sql.ErrNoRows)).To(BeTrue())
+
+			var apiErr *client.Error
+			Expect(errors.As(err, &apiErr)).To(BeTrue())
+			Expect(apiErr.Method).To(Equal(http.MethodGet))
+			Expect(apiErr.Path).To(Equal("/coupon/123"))
+			Expect(apiErr.StatusCode).To(Equal(http.StatusNotFound))
+			Expect(apiErr.Message).To(Equal("sql: no rows in result set"))
+			Expect(apiErr.RequestID).To(HaveLen(32))
+			Expect(err.Error()).To(Equal("GET /coupon/123: 404 Not Found: sql: no rows in result set (request " + apiErr.RequestID + ")"))
+		})
+
+		It("matches ErrUnauthorized when the API key is wrong", func() {
+			couponsAPI.APIKey = "wrong"
+
+			_, err := couponsAPI.GetCouponById(ctx, "123")
+			Expect(errors.Is(err, client.ErrUnauthorized)).To(BeTrue())
+			Expect(errors.Is(err, client.ErrNotFound)).To(BeFalse())
+		})
+	})
+
+	Describe("GetCouponAsOf", func() {
+		It("asks for the coupon as it was", func() {
+			server.couponService.GetCouponAsOfReturns(boots, nil)
+			asOf := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+			found, err := couponsAPI.GetCouponAsOf(ctx, "123", asOf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(Equal(boots))
+
+			_, couponId, sentAsOf := server.couponService.GetCouponAsOfArgsForCall(0)
+			Expect(couponId).To(Equal("123"))
+			Expect(sentAsOf).To(BeTemporally("==", asOf))
+		})
+	})
+
+	Describe("GetCouponVersion", func() {
+		It("asks for the version", func() {
+			server.couponService.GetCouponVersionReturns(boots, nil)
+
+			found, err := couponsAPI.GetCouponVersion(ctx, "123", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(found).To(Equal(boots))
+
+			_, couponId, version := server.couponService.GetCouponVersionArgsForCall(0)
+			Expect(couponId).To(Equal("123"))
+			Expect(version).To(Equal(1))
+		})
+	})
+
+	Describe("RevertCoupon", func() {
+		It("returns the reverted coupon", func() {
+			server.couponService.RevertCouponReturns(boots, nil)
+
+			reverted, err := couponsAPI.RevertCoupon(ctx, "123", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reverted).To(Equal(boots))
+
+			_, couponId, version := server.couponService.RevertCouponArgsForCall(0)
+			Expect(couponId).To(Equal("123"))
+			Expect(version).To(Equal(1))
+		})
+	})
+
+	Describe("DeleteCoupon", func() {
+		It("removes the coupon", func() {
+			err := couponsAPI.DeleteCoupon(ctx, "123")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, couponId := server.couponService.DeleteCouponArgsForCall(0)
+			Expect(couponId).To(Equal("123"))
+		})
+	})
+})
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors each match an Error with the status code they're named for, through errors.Is
+var (
+	ErrBadRequest   = errors.New("bad request")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrNotFound     = errors.New("not found")
+	ErrConflict     = errors.New("conflict")
+	ErrRateLimited  = errors.New("rate limited")
+	ErrUnavailable  = errors.New("unavailable")
+)
+
+var statusErrors = map[error]int{
+	ErrBadRequest:   http.StatusBadRequest,
+	ErrUnauthorized: http.StatusUnauthorized,
+	ErrForbidden:    http.StatusForbidden,
+	ErrNotFound:     http.StatusNotFound,
+	ErrConflict:     http.StatusConflict,
+	ErrRateLimited:  http.StatusTooManyRequests,
+	ErrUnavailable:  http.StatusServiceUnavailable,
+	// so code written against handlers.CouponService checks for missing coupons the same way with either
+	sql.ErrNoRows: http.StatusNotFound,
+}
+
+// Error is a response the API refused, with the message it gave. RequestID can be quoted when asking about it.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+	RequestID  string
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	message := fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+
+	if e.RequestID != "" {
+		message += " (request " + e.RequestID + ")"
+	}
+
+	return message
+}
+
+func (e *Error) Is(target error) bool {
+	statusCode, ok := statusErrors[target]
+	return ok && e.StatusCode == statusCode
+}
+
+// maxErrorMessageLength keeps an unexpected response, such as a proxy's HTML error page, from filling the error
+const maxErrorMessageLength = 4096
+
+func decodeError(response *http.Response) *Error {
+	defer response.Body.Close()
+
+	message, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxErrorMessageLength))
+
+	apiErr := &Error{
+		Method:     response.Request.Method,
+		Path:       response.Request.URL.Path,
+		StatusCode: response.StatusCode,
+		Message:    strings.TrimSpace(string(message)),
+		RequestID:  response.Header.Get("X-Request-ID"),
+	}
+
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return apiErr
+}
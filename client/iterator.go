@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/coupon"
+)
+
+// CouponIterator walks through coupons one at a time, like sql.Rows, fetching a page of them from the API whenever
+// it runs out. Each coupon's ID is the cursor to carry on from, as filters.After, if the walk is cut short.
+type CouponIterator struct {
+	client  *Client
+	ctx     context.Context
+	filters handlers.Filters
+	page    []*coupon.Coupon
+	current *coupon.Coupon
+	done    bool
+	err     error
+}
+
+// Coupons iterates over every coupon matching filters, in id order, starting after filters.After and fetching
+// filters.Limit coupons at a time, or handlers.DefaultPageSize. Close it once done, if Next hasn't already returned
+// false.
+func (c *Client) Coupons(ctx context.Context, filters handlers.Filters) *CouponIterator {
+	if filters.Limit == nil {
+		pageSize := handlers.DefaultPageSize
+		filters.Limit = &pageSize
+	}
+
+	return &CouponIterator{client: c, ctx: ctx, filters: filters}
+}
+
+// Next moves on to the next coupon, returning false at the end or on an error, which Err returns
+func (it *CouponIterator) Next() bool {
+	if len(it.page) == 0 && !it.done && it.err == nil {
+		it.fetch()
+	}
+
+	if len(it.page) == 0 {
+		it.current = nil
+		return false
+	}
+
+	it.current, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+func (it *CouponIterator) fetch() {
+	coupons, next, err := it.client.GetCouponPage(it.ctx, it.filters)
+	if errors.Is(err, ErrNotFound) {
+		coupons, err = nil, nil
+	}
+
+	if err != nil {
+		it.err = err
+		return
+	}
+
+	it.page = coupons
+	it.done = next == ""
+	it.filters.After = &next
+}
+
+func (it *CouponIterator) Coupon() *coupon.Coupon {
+	return it.current
+}
+
+func (it *CouponIterator) Err() error {
+	return it.err
+}
+
+// Close stops the iterator fetching any more pages
+func (it *CouponIterator) Close() error {
+	it.page = nil
+	it.done = true
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"github.com/madeleinesmith/coupons/client"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var _ = Describe("CouponIterator", func() {
+	var (
+		server     *api
+		couponsAPI *client.Client
+		ctx        context.Context
+		brand      string
+		ids        []string
+	)
+
+	BeforeEach(func() {
+		server = newAPI()
+		couponsAPI = client.New(server.server.URL, "secret")
+		couponsAPI.HTTPClient = server.server.Client()
+		couponsAPI.Backoff = time.Millisecond
+		ctx = context.Background()
+		brand = "boots"
+		ids = []string{
+			"00000000-0000-0000-0000-000000000001",
+			"00000000-0000-0000-0000-000000000002",
+			"00000000-0000-0000-0000-000000000003",
+		}
+
+		// pages through ids as the database does, returning no rows as an error
+		server.couponService.GetCouponsStub = func(ctx context.Context, filters handlers.Filters) ([]*coupon.Coupon, error) {
+			var coupons []*coupon.Coupon
+			for i, id := range ids {
+				if filters.After != nil && id <= *filters.After || len(coupons) == *filters.Limit {
+					continue
+				}
+
+				name, value := strconv.Itoa((i+1)*10)+"% off", (i+1)*10
+				coupons = append(coupons, &coupon.Coupon{ID: id, Name: &name, Brand: filters.Brand, Value: &value})
+			}
+
+			if len(coupons) == 0 {
+				return nil, errors.New("sql: no rows in result set")
+			}
+
+			return coupons, nil
+		}
+
+		server.couponService.StreamCouponsStub = func(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error {
+			for i := 1; i <= 3; i++ {
+				name, value := strconv.Itoa(i*10)+"% off", i*10
+				err := fn(&coupon.Coupon{ID: strconv.Itoa(i), Name: &name, Brand: filters.Brand, Value: &value})
+				if err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+	})
+
+	AfterEach(func() {
+		server.close()
+	})
+
+	walk := func(iterator *client.CouponIterator) []string {
+		defer iterator.Close()
+
+		var walked []string
+		for iterator.Next() {
+			walked = append(walked, iterator.Coupon().ID)
+		}
+
+		return walked
+	}
+
+	It("walks through every coupon matching the filters, a page at a time", func() {
+		pageSize := 2
+		iterator := couponsAPI.Coupons(ctx, handlers.Filters{Brand: &brand, Limit: &pageSize})
+
+		Expect(walk(iterator)).To(Equal(ids))
+		Expect(iterator.Err()).NotTo(HaveOccurred())
+
+		requests := server.received()
+		Expect(requests).To(HaveLen(2))
+		Expect(requests[0].URL.Query().Get("brand")).To(Equal("boots"))
+		Expect(requests[0].URL.Query().Get("page[size]")).To(Equal("2"))
+		Expect(requests[0].URL.Query().Get("page[after]")).To(BeEmpty())
+		Expect(requests[1].URL.Query().Get("brand")).To(Equal("boots"))
+		Expect(requests[1].URL.Query().Get("page[after]")).To(Equal(ids[1]))
+	})
+
+	It("pages by the API's default page size unless given one", func() {
+		Expect(walk(couponsAPI.Coupons(ctx, handlers.Filters{}))).To(Equal(ids))
+
+		Expect(server.received()).To(HaveLen(1))
+		Expect(server.received()[0].URL.Query().Get("page[size]")).To(Equal(strconv.Itoa(handlers.DefaultPageSize)))
+	})
+
+	It("carries on from the coupon it was cut short at", func() {
+		pageSize := 1
+		iterator := couponsAPI.Coupons(ctx, handlers.Filters{Limit: &pageSize})
+		Expect(iterator.Next()).To(BeTrue())
+		cursor := iterator.Coupon().ID
+		iterator.Close()
+
+		Expect(iterator.Next()).To(BeFalse())
+		Expect(walk(couponsAPI.Coupons(ctx, handlers.Filters{After: &cursor, Limit: &pageSize}))).To(Equal(ids[1:]))
+	})
+
+	It("stops at the end when there are no coupons", func() {
+		ids = nil
+
+		iterator := couponsAPI.Coupons(ctx, handlers.Filters{})
+		Expect(iterator.Next()).To(BeFalse())
+		Expect(iterator.Err()).NotTo(HaveOccurred())
+	})
+
+	It("returns the API's error from Err", func() {
+		server.fail(http.StatusForbidden)
+
+		iterator := couponsAPI.Coupons(ctx, handlers.Filters{})
+		Expect(iterator.Next()).To(BeFalse())
+		Expect(errors.Is(iterator.Err(), client.ErrForbidden)).To(BeTrue())
+	})
+
+	Describe("GetCouponPage", func() {
+		It("returns where the next page starts, until the last page", func() {
+			pageSize := 2
+
+			coupons, next, err := couponsAPI.GetCouponPage(ctx, handlers.Filters{Limit: &pageSize})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coupons).To(HaveLen(2))
+			Expect(next).To(Equal(ids[1]))
+
+			coupons, next, err = couponsAPI.GetCouponPage(ctx, handlers.Filters{After: &next, Limit: &pageSize})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coupons).To(HaveLen(1))
+			Expect(coupons[0].ID).To(Equal(ids[2]))
+			Expect(next).To(BeEmpty())
+		})
+	})
+
+	Describe("StreamCoupons", func() {
+		It("calls fn with each coupon from the export", func() {
+			var streamed []string
+			err := couponsAPI.StreamCoupons(ctx, handlers.Filters{Brand: &brand}, func(couponInstance *coupon.Coupon) error {
+				Expect(*couponInstance.Brand).To(Equal("boots"))
+				streamed = append(streamed, couponInstance.ID)
+				return nil
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(streamed).To(Equal([]string{"1", "2", "3"}))
+
+			_, filters, _ := server.couponService.StreamCouponsArgsForCall(0)
+			Expect(*filters.Brand).To(Equal("boots"))
+			Expect(server.received()[0].URL.Query().Get("format")).To(Equal("ndjson"))
+		})
+
+		It("stops at fn's first error", func() {
+			var streamed []string
+			err := couponsAPI.StreamCoupons(ctx, handlers.Filters{}, func(couponInstance *coupon.Coupon) error {
+				streamed = append(streamed, couponInstance.ID)
+				return errors.New("disk full")
+			})
+
+			Expect(err).To(MatchError("disk full"))
+			Expect(streamed).To(Equal([]string{"1"}))
+		})
+
+		It("reports an export cut off part way through, rather than ending early", func() {
+			server.couponService.StreamCouponsStub = func(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error {
+				name, value := "10% off", 10
+
+				// past the response's buffer, so the API has started sending the export before it fails
+				for i := 0; i < 1000; i++ {
+					err := fn(&coupon.Coupon{ID: strconv.Itoa(i), Name: &name, Brand: &brand, Value: &value})
+					if err != nil {
+						return err
+					}
+				}
+
+				return errors.New("connection reset")
+			}
+
+			count := 0
+			err := couponsAPI.StreamCoupons(ctx, handlers.Filters{}, func(couponInstance *coupon.Coupon) error {
+				count++
+				return nil
+			})
+
+			Expect(count).To(BeNumerically(">", 0))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,301 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/madeleinesmith/coupons/logging"
+	"github.com/madeleinesmith/coupons/model"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"github.com/madeleinesmith/coupons/tracing"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DefaultFile = "./config.json"
+
+// SSLModes are the sslmode values Postgres understands
+var SSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Defaults keep the service's old behaviour of listening on 6584 and connecting to a local database without SSL
+func Defaults() model.Config {
+	var config model.Config
+
+	config.Server.Port = 6584
+	// there's no default readTimeout, as it would cut off imports still streaming in; slow clients are held to the
+	// readHeaderTimeout, and the writeTimeout covers the rest of the request
+	config.Server.ReadHeaderTimeout = "5s"
+	config.Server.WriteTimeout = "5m"
+	config.Server.IdleTimeout = "2m"
+	config.Server.ShutdownTimeout = "30s"
+	config.Database.Host = "localhost"
+	config.Database.Port = 5432
+	config.Database.DBName = "coupons"
+	config.Database.SSLMode = "disable"
+	config.Database.ConnectTimeout = "5s"
+	config.Database.MaxOpenConns = 20
+	config.Database.MaxIdleConns = 5
+	config.Database.ConnMaxLifetime = "30m"
+	config.Database.StartupTimeout = "30s"
+	config.Database.QueryTimeout = "10s"
+	// importing and exporting every coupon takes far longer than any other request
+	config.Database.RouteQueryTimeouts = map[string]string{"coupons-import": "2m", "coupons-export": "5m"}
+	// looking coupons up and redeeming them are where ids get guessed, so they're limited even without a config file
+	config.RateLimiting.Routes = map[string]model.RateLimit{
+		"coupon":        {RequestsPerMinute: 600, Burst: 100, Key: "api-key"},
+		"coupon-redeem": {RequestsPerMinute: 120, Burst: 20, Key: "api-key"},
+	}
+	config.Health.Timeout = "2s"
+	config.Health.LatencyBudget = "250ms"
+	config.Tracing.Exporter = tracing.ExporterNone
+	config.Tracing.ServiceName = "coupons"
+	config.Tracing.SampleRatio = 1
+	config.Logging.Level = "info"
+
+	return config
+}
+
+// setting can be given as an environment variable, a flag or both. Settings not listed here can only be set in the
+// config file.
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	apply func(config *model.Config, value string) error
+}
+
+var settings = []setting{
+	{"COUPONS_PORT", "port", "port to listen on", intSetting(func(c *model.Config) *int { return &c.Server.Port })},
+	{"COUPONS_ADMIN_PORT", "admin-port", "port to serve health checks on, if not the main port", intSetting(func(c *model.Config) *int { return &c.Server.AdminPort })},
+	{"COUPONS_GRPC_PORT", "grpc-port", "port to serve the gRPC API on, if any", intSetting(func(c *model.Config) *int { return &c.Server.GRPCPort })},
+	{"COUPONS_SHUTDOWN_TIMEOUT", "shutdown-timeout", "how long to wait for requests in flight when shutting down", stringSetting(func(c *model.Config) *string { return &c.Server.ShutdownTimeout })},
+	{"COUPONS_LOG_LEVEL", "log-level", "least severe logs to write: " + strings.Join(logging.Levels, ", "), stringSetting(func(c *model.Config) *string { return &c.Logging.Level })},
+	{"COUPONS_TRACING_EXPORTER", "tracing-exporter", "where to send traces: " + strings.Join(tracing.Exporters, ", "), stringSetting(func(c *model.Config) *string { return &c.Tracing.Exporter })},
+	{"COUPONS_TRACING_FILE", "tracing-file", "file to write traces to, with the file exporter", stringSetting(func(c *model.Config) *string { return &c.Tracing.File })},
+	{"COUPONS_TRACING_ENDPOINT", "tracing-endpoint", "collector URL, with the otlp exporter", stringSetting(func(c *model.Config) *string { return &c.Tracing.Endpoint })},
+	{"COUPONS_DB_HOST", "db-host", "database host", stringSetting(func(c *model.Config) *string { return &c.Database.Host })},
+	{"COUPONS_DB_PORT", "db-port", "database port", intSetting(func(c *model.Config) *int { return &c.Database.Port })},
+	{"COUPONS_DB_USER", "db-user", "database user", stringSetting(func(c *model.Config) *string { return &c.Database.User })},
+	// the password is deliberately not a flag, as flags can be seen by anyone who can list processes
+	{"COUPONS_DB_PASSWORD", "", "", stringSetting(func(c *model.Config) *string { return &c.Database.Password })},
+	{"COUPONS_DB_NAME", "db-name", "database name", stringSetting(func(c *model.Config) *string { return &c.Database.DBName })},
+	{"COUPONS_DB_SSLMODE", "db-sslmode", "database sslmode", stringSetting(func(c *model.Config) *string { return &c.Database.SSLMode })},
+	{"COUPONS_DB_CONNECT_TIMEOUT", "db-connect-timeout", "how long to wait to connect to the database", stringSetting(func(c *model.Config) *string { return &c.Database.ConnectTimeout })},
+	{"COUPONS_DB_MAX_OPEN_CONNS", "db-max-open-conns", "most database connections to open", intSetting(func(c *model.Config) *int { return &c.Database.MaxOpenConns })},
+	{"COUPONS_DB_MAX_IDLE_CONNS", "db-max-idle-conns", "most idle database connections to keep", intSetting(func(c *model.Config) *int { return &c.Database.MaxIdleConns })},
+	{"COUPONS_DB_CONN_MAX_LIFETIME", "db-conn-max-lifetime", "how long to reuse a database connection for", stringSetting(func(c *model.Config) *string { return &c.Database.ConnMaxLifetime })},
+	{"COUPONS_DB_QUERY_TIMEOUT", "db-query-timeout", "how long a request's queries can take", stringSetting(func(c *model.Config) *string { return &c.Database.QueryTimeout })},
+	{"COUPONS_DB_STARTUP_TIMEOUT", "db-startup-timeout", "how long to wait for the database on startup", stringSetting(func(c *model.Config) *string { return &c.Database.StartupTimeout })},
+}
+
+func stringSetting(field func(*model.Config) *string) func(*model.Config, string) error {
+	return func(config *model.Config, value string) error {
+		*field(config) = value
+		return nil
+	}
+}
+
+func intSetting(field func(*model.Config) *int) func(*model.Config, string) error {
+	return func(config *model.Config, value string) error {
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be a whole number, got %q", value)
+		}
+
+		*field(config) = number
+		return nil
+	}
+}
+
+// Load layers the defaults, the config file, environment variables and then flags, each overriding the last. The
+// file is -config, or COUPONS_CONFIG, or ./config.json if it exists. It returns the arguments left after the flags,
+// so subcommands can follow them.
+func Load(args []string, getenv func(string) string) (model.Config, []string, error) {
+	flags := flag.NewFlagSet("coupons", flag.ContinueOnError)
+	configFile := flags.String("config", "", "JSON or YAML config file (default "+DefaultFile+")")
+
+	flagValues := map[string]*string{}
+	for _, setting := range settings {
+		if setting.flag != "" {
+			flagValues[setting.flag] = flags.String(setting.flag, "", setting.usage+" (or "+setting.env+")")
+		}
+	}
+
+	err := flags.Parse(args)
+	if err != nil {
+		return model.Config{}, nil, err
+	}
+
+	config := Defaults()
+
+	path := *configFile
+	if path == "" {
+		path = getenv("COUPONS_CONFIG")
+	}
+
+	if path != "" {
+		err = readFile(path, &config)
+	} else if _, statErr := os.Stat(DefaultFile); statErr == nil {
+		err = readFile(DefaultFile, &config)
+	}
+
+	if err != nil {
+		return model.Config{}, nil, err
+	}
+
+	for _, setting := range settings {
+		value := getenv(setting.env)
+		if value == "" {
+			continue
+		}
+
+		err := setting.apply(&config, value)
+		if err != nil {
+			return model.Config{}, nil, fmt.Errorf("%s %v", setting.env, err)
+		}
+	}
+
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		for _, setting := range settings {
+			if setting.flag == f.Name && flagErr == nil {
+				err := setting.apply(&config, *flagValues[f.Name])
+				if err != nil {
+					flagErr = fmt.Errorf("-%s %v", f.Name, err)
+				}
+			}
+		}
+	})
+
+	if flagErr != nil {
+		return model.Config{}, nil, flagErr
+	}
+
+	err = Validate(config)
+	if err != nil {
+		return model.Config{}, nil, err
+	}
+
+	return config, flags.Args(), nil
+}
+
+// readFile rejects fields it doesn't know, so typos aren't silently ignored
+func readFile(path string, config *model.Config) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		// checked strictly on its own first, as UnmarshalStrict would also reject setting a route that has a default
+		err = yaml.UnmarshalStrict(contents, &model.Config{})
+		if err == nil {
+			err = yaml.Unmarshal(contents, config)
+		}
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(contents))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(config)
+	}
+
+	if err != nil {
+		return fmt.Errorf("reading config %s: %v", path, err)
+	}
+
+	return nil
+}
+
+// Validate reports every problem with the config at once, rather than just the first
+func Validate(config model.Config) error {
+	var problems []string
+
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	checkDuration := func(name string, value string) {
+		if value == "" {
+			return
+		}
+
+		duration, err := time.ParseDuration(value)
+		check(err == nil && duration > 0, "%s must be a positive duration such as 30s, got %q", name, value)
+	}
+
+	check(validPort(config.Server.Port), "server.port must be between 1 and 65535, got %d", config.Server.Port)
+	check(config.Server.AdminPort == 0 || validPort(config.Server.AdminPort), "server.adminPort must be between 1 and 65535, got %d", config.Server.AdminPort)
+	check(config.Server.AdminPort == 0 || config.Server.AdminPort != config.Server.Port, "server.adminPort must be different to server.port")
+	check(config.Server.GRPCPort == 0 || validPort(config.Server.GRPCPort), "server.grpcPort must be between 1 and 65535, got %d", config.Server.GRPCPort)
+	check(config.Server.GRPCPort == 0 || config.Server.GRPCPort != config.Server.Port && config.Server.GRPCPort != config.Server.AdminPort,
+		"server.grpcPort must be different to server.port and server.adminPort")
+	checkDuration("server.readHeaderTimeout", config.Server.ReadHeaderTimeout)
+	checkDuration("server.readTimeout", config.Server.ReadTimeout)
+	checkDuration("server.writeTimeout", config.Server.WriteTimeout)
+	checkDuration("server.idleTimeout", config.Server.IdleTimeout)
+	checkDuration("server.shutdownTimeout", config.Server.ShutdownTimeout)
+	_, err := requestcontext.ParseTrustedProxies(config.Server.TrustedProxies)
+	check(err == nil, "server.trustedProxies: %v", err)
+
+	database := config.Database
+	check(database.Host != "", "database.host is required")
+	check(validPort(database.Port), "database.port must be between 1 and 65535, got %d", database.Port)
+	check(database.User != "", "database.user is required")
+	check(database.DBName != "", "database.dbName is required")
+	check(contains(SSLModes, database.SSLMode), "database.sslMode must be one of %s, got %q", strings.Join(SSLModes, ", "), database.SSLMode)
+	check(database.MaxOpenConns >= 0, "database.maxOpenConns can't be negative")
+	check(database.MaxIdleConns >= 0, "database.maxIdleConns can't be negative")
+	check(database.MaxOpenConns == 0 || database.MaxIdleConns <= database.MaxOpenConns,
+		"database.maxIdleConns can't be more than database.maxOpenConns")
+	checkDuration("database.connectTimeout", database.ConnectTimeout)
+	checkDuration("database.connMaxLifetime", database.ConnMaxLifetime)
+	checkDuration("database.startupTimeout", database.StartupTimeout)
+	checkDuration("database.queryTimeout", database.QueryTimeout)
+	for routeName, timeout := range database.RouteQueryTimeouts {
+		checkDuration("database.routeQueryTimeouts."+routeName, timeout)
+	}
+
+	checkDuration("health.timeout", config.Health.Timeout)
+	checkDuration("health.latencyBudget", config.Health.LatencyBudget)
+	tracingConfiguration := config.Tracing
+	check(contains(tracing.Exporters, tracingConfiguration.Exporter), "tracing.exporter must be one of %s, got %q", strings.Join(tracing.Exporters, ", "), tracingConfiguration.Exporter)
+	check(tracingConfiguration.Exporter != tracing.ExporterFile || tracingConfiguration.File != "", "tracing.file is required with the file exporter")
+	check(tracingConfiguration.SampleRatio >= 0 && tracingConfiguration.SampleRatio <= 1, "tracing.sampleRatio must be between 0 and 1, got %v", tracingConfiguration.SampleRatio)
+
+	check(contains(logging.Levels, strings.ToLower(config.Logging.Level)), "logging.level must be one of %s, got %q", strings.Join(logging.Levels, ", "), config.Logging.Level)
+
+	checkDuration("idempotency.ttl", config.Idempotency.TTL)
+	checkDuration("idempotency.lease", config.Idempotency.Lease)
+	checkDuration("lockout.window", config.Lockout.Window)
+	checkDuration("lockout.lockoutDuration", config.Lockout.LockoutDuration)
+	checkDuration("lockout.maxLockoutDuration", config.Lockout.MaxLockoutDuration)
+
+	if len(problems) > 0 {
+		return errors.New("invalid config:\n  " + strings.Join(problems, "\n  "))
+	}
+
+	return nil
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,213 @@
+package config_test
+
+import (
+	"github.com/madeleinesmith/coupons/config"
+	"github.com/madeleinesmith/coupons/model"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var _ = Describe("Config", func() {
+	var (
+		dir string
+		env map[string]string
+	)
+
+	getenv := func(name string) string {
+		return env[name]
+	}
+
+	writeFile := func(name string, contents string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		dir, err = ioutil.TempDir("", "config")
+		Expect(err).NotTo(HaveOccurred())
+
+		env = map[string]string{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("Load", func() {
+		It("reads a JSON file over the defaults", func() {
+			path := writeFile("config.json", `{"database": {"user": "coupons", "password": "secret", "dbName": "coupons_prod"}}`)
+
+			loaded, args, err := config.Load([]string{"-config", path}, getenv)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(args).To(BeEmpty())
+
+			expected := config.Defaults()
+			expected.Database.User = "coupons"
+			expected.Database.Password = "secret"
+			expected.Database.DBName = "coupons_prod"
+			Expect(loaded).To(Equal(expected))
+		})
+
+		It("gives imports and exports longer for their queries, without a config file", func() {
+			env["COUPONS_DB_USER"] = "coupons"
+
+			loaded, _, err := config.Load(nil, getenv)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded.Database.QueryTimeout).To(Equal("10s"))
+			Expect(loaded.Database.RouteQueryTimeouts).To(Equal(map[string]string{"coupons-import": "2m", "coupons-export": "5m"}))
+		})
+
+		It("rate limits looking coupons up and redeeming them, without a config file", func() {
+			env["COUPONS_DB_USER"] = "coupons"
+
+			loaded, _, err := config.Load(nil, getenv)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded.RateLimiting.Routes).To(HaveKey("coupon"))
+			Expect(loaded.RateLimiting.Routes).To(HaveKey("coupon-redeem"))
+		})
+
+		It("doesn't time out reading request bodies, so imports can stream in for as long as they take", func() {
+			env["COUPONS_DB_USER"] = "coupons"
+
+			loaded, _, err := config.Load(nil, getenv)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded.Server.ReadTimeout).To(BeEmpty())
+			Expect(loaded.Server.ReadHeaderTimeout).To(Equal("5s"))
+		})
+
+		It("keeps the default route query timeouts the file doesn't override", func() {
+			path := writeFile("config.json", `{"database": {"user": "coupons", "routeQueryTimeouts": {"coupons-export": "10m", "coupon": "1s"}}}`)
+
+			loaded, _, err := config.Load([]string{"-config", path}, getenv)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded.Database.RouteQueryTimeouts).To(Equal(map[string]string{"coupons-import": "2m", "coupons-export": "10m", "coupon": "1s"}))
+		})
+
+		It("reads the example config", func() {
+			_, _, err := config.Load([]string{"-config", "../example_config.json"}, getenv)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reads a YAML file", func() {
+			path := writeFile("config.yaml", `
+server:
+  port: 8080
+database:
+  user: coupons
+  host: db.internal
+  sslMode: verify-full
+rateLimiting:
+  routes:
+    coupon: {requestsPerMinute: 60, key: ip}
+`)
+			env["COUPONS_CONFIG"] = path
+
+			loaded, _, err := config.Load(nil, getenv)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded.Server.Port).To(Equal(8080))
+			Expect(loaded.Database.Host).To(Equal("db.internal"))
+			Expect(loaded.Database.SSLMode).To(Equal("verify-full"))
+			Expect(loaded.Database.Port).To(Equal(5432))
+			Expect(loaded.RateLimiting.Routes).To(Equal(map[string]model.RateLimit{
+				"coupon":        {RequestsPerMinute: 60, Key: "ip"},
+				"coupon-redeem": {RequestsPerMinute: 120, Burst: 20, Key: "api-key"},
+			}))
+		})
+
+		It("lets environment variables override the file, and flags override both", func() {
+			path := writeFile("config.json", `{"database": {"user": "coupons", "host": "file-host", "port": 5433}}`)
+			env["COUPONS_DB_PASSWORD"] = "from-env"
+			env["COUPONS_DB_HOST"] = "env-host"
+			env["COUPONS_DB_PORT"] = "5434"
+
+			loaded, _, err := config.Load([]string{"-config", path, "-db-host", "flag-host", "-port", "9000"}, getenv)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(loaded.Database.Password).To(Equal("from-env"))
+			Expect(loaded.Database.Host).To(Equal("flag-host"))
+			Expect(loaded.Database.Port).To(Equal(5434))
+			Expect(loaded.Server.Port).To(Equal(9000))
+		})
+
+		It("returns the arguments after the flags", func() {
+			env["COUPONS_DB_USER"] = "coupons"
+
+			_, args, err := config.Load([]string{"-port", "9000", "apikeys", "revoke", "key-1"}, getenv)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(args).To(Equal([]string{"apikeys", "revoke", "key-1"}))
+		})
+
+		It("fails on a missing file", func() {
+			_, _, err := config.Load([]string{"-config", filepath.Join(dir, "missing.json")}, getenv)
+			Expect(err).To(MatchError(ContainSubstring("reading config")))
+		})
+
+		It("fails on fields it doesn't know", func() {
+			path := writeFile("config.json", `{"database": {"user": "coupons", "pasword": "secret"}}`)
+
+			_, _, err := config.Load([]string{"-config", path}, getenv)
+			Expect(err).To(MatchError(ContainSubstring(`unknown field "pasword"`)))
+		})
+
+		It("fails on numbers that aren't numbers", func() {
+			env["COUPONS_DB_USER"] = "coupons"
+			env["COUPONS_DB_PORT"] = "postgres"
+
+			_, _, err := config.Load(nil, getenv)
+			Expect(err).To(MatchError(`COUPONS_DB_PORT must be a whole number, got "postgres"`))
+
+			delete(env, "COUPONS_DB_PORT")
+
+			_, _, err = config.Load([]string{"-db-max-open-conns", "lots"}, getenv)
+			Expect(err).To(MatchError(`-db-max-open-conns must be a whole number, got "lots"`))
+		})
+	})
+
+	Describe("Validate", func() {
+		var validConfig model.Config
+
+		BeforeEach(func() {
+			validConfig = config.Defaults()
+			validConfig.Database.User = "coupons"
+		})
+
+		It("accepts the defaults with a user", func() {
+			Expect(config.Validate(validConfig)).To(Succeed())
+		})
+
+		It("reports every problem at once", func() {
+			invalidConfig := validConfig
+			invalidConfig.Server.Port = 0
+			invalidConfig.Server.AdminPort = 70000
+			invalidConfig.Server.GRPCPort = 70000
+			invalidConfig.Server.TrustedProxies = []string{"10.0.0.1"}
+			invalidConfig.Database.User = ""
+			invalidConfig.Database.SSLMode = "on"
+			invalidConfig.Database.MaxOpenConns = 2
+			invalidConfig.Database.ConnectTimeout = "5"
+			invalidConfig.Lockout.Window = "-1m"
+			invalidConfig.Tracing.Exporter = "file"
+			invalidConfig.Logging.Level = "verbose"
+
+			Expect(config.Validate(invalidConfig)).To(MatchError("invalid config:\n" +
+				"  server.port must be between 1 and 65535, got 0\n" +
+				"  server.adminPort must be between 1 and 65535, got 70000\n" +
+				"  server.grpcPort must be between 1 and 65535, got 70000\n" +
+				"  server.grpcPort must be different to server.port and server.adminPort\n" +
+				"  server.trustedProxies: \"10.0.0.1\" is not a CIDR such as 10.0.0.0/8\n" +
+				"  database.user is required\n" +
+				"  database.sslMode must be one of disable, allow, prefer, require, verify-ca, verify-full, got \"on\"\n" +
+				"  database.maxIdleConns can't be more than database.maxOpenConns\n" +
+				"  database.connectTimeout must be a positive duration such as 30s, got \"5\"\n" +
+				"  tracing.file is required with the file exporter\n" +
+				"  logging.level must be one of debug, info, warn, error, got \"verbose\"\n" +
+				"  lockout.window must be a positive duration such as 30s, got \"-1m\""))
+		})
+	})
+})
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/cli"
+	"github.com/madeleinesmith/coupons/client"
+	"github.com/madeleinesmith/coupons/config"
+	"github.com/madeleinesmith/coupons/dbservices"
+	"net/http"
+	"os"
+	"os/user"
+	"time"
+)
+
+// runCouponCommand manages coupons for ops staff, e.g. `coupons coupon find -brand boots -tenant boots`
+func runCouponCommand(args []string) error {
+	actor := "cli"
+	if currentUser, err := user.Current(); err == nil {
+		actor = "cli:" + currentUser.Username
+	}
+
+	couponCLI := cli.CLI{
+		Out:     os.Stdout,
+		Err:     os.Stderr,
+		Getenv:  os.Getenv,
+		Connect: connectCouponBackend,
+		Actor:   actor,
+	}
+
+	return couponCLI.Run(context.Background(), args)
+}
+
+// connectCouponBackend only loads the config when going to the database, so the API can be used without one
+func connectCouponBackend(options cli.Options) (cli.Backend, error) {
+	if options.APIURL != "" {
+		apiClient := client.New(options.APIURL, options.APIKey)
+		apiClient.HTTPClient = &http.Client{Timeout: 5 * time.Minute}
+
+		return apiClient, nil
+	}
+
+	applicationConfiguration, _, err := config.Load(nil, os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := initializeDb(applicationConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkDatabaseReady(db, applicationConfiguration)
+	if err != nil {
+		return nil, err
+	}
+
+	return dbservices.CouponService{DB: db}, nil
+}
@@ -0,0 +1,8 @@
+package db
+
+import "embed"
+
+// Migrations are built into the binary, so `coupons migrate` needs nothing but a database to run against
+//
+//go:embed migrations/*.sql
+var Migrations embed.FS
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS coupon_audit;
+DROP FUNCTION IF EXISTS coupon_audit_append_only();
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS coupon_audit (
+  id BIGSERIAL PRIMARY KEY,
+  coupon_id uuid NOT NULL,
+  action VARCHAR NOT NULL,
+  actor VARCHAR NOT NULL,
+  request_id VARCHAR,
+  changes JSONB NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS coupon_audit_coupon_id_idx ON coupon_audit (coupon_id, created_at);
+
+-- the audit trail is append-only, so refuse to change or remove rows once written
+CREATE OR REPLACE FUNCTION coupon_audit_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'coupon_audit is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS coupon_audit_append_only ON coupon_audit;
+CREATE TRIGGER coupon_audit_append_only
+  BEFORE UPDATE OR DELETE ON coupon_audit
+  FOR EACH ROW EXECUTE PROCEDURE coupon_audit_append_only();
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS coupon_versions;
+DROP FUNCTION IF EXISTS coupon_versions_immutable();
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS coupon_versions (
+  coupon_id uuid NOT NULL,
+  version INT NOT NULL,
+  name VARCHAR,
+  brand VARCHAR,
+  value INT,
+  deleted BOOLEAN NOT NULL DEFAULT false,
+  valid_from TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp,
+  PRIMARY KEY (coupon_id, version)
+);
+
+CREATE INDEX IF NOT EXISTS coupon_versions_valid_from_idx ON coupon_versions (coupon_id, valid_from);
+
+-- coupons that existed before versioning start out at version 1
+INSERT INTO coupon_versions (coupon_id, version, name, brand, value, valid_from)
+  SELECT id, 1, name, brand, value, COALESCE(created_at, current_timestamp) FROM coupons
+  ON CONFLICT DO NOTHING;
+
+CREATE OR REPLACE FUNCTION coupon_versions_immutable() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'coupon_versions are immutable';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS coupon_versions_immutable ON coupon_versions;
+CREATE TRIGGER coupon_versions_immutable
+  BEFORE UPDATE OR DELETE ON coupon_versions
+  FOR EACH ROW EXECUTE PROCEDURE coupon_versions_immutable();
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+  id uuid DEFAULT uuid_generate_v1mc() PRIMARY KEY,
+  name VARCHAR NOT NULL,
+  key_hash BYTEA NOT NULL UNIQUE,
+  scopes VARCHAR[] NOT NULL DEFAULT '{}',
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp,
+  revoked_at TIMESTAMP WITH TIME ZONE
+);
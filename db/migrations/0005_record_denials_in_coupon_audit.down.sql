@@ -0,0 +1 @@
+ALTER TABLE coupon_audit DROP COLUMN IF EXISTS permission;
@@ -0,0 +1,3 @@
+-- denied attempts to create coupons are audited too, and they have no coupon
+ALTER TABLE coupon_audit ALTER COLUMN coupon_id DROP NOT NULL;
+ALTER TABLE coupon_audit ADD COLUMN IF NOT EXISTS permission VARCHAR;
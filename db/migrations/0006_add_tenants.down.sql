@@ -0,0 +1,17 @@
+DROP POLICY IF EXISTS coupon_versions_tenant_isolation ON coupon_versions;
+ALTER TABLE coupon_versions NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE coupon_versions DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS coupon_audit_tenant_isolation ON coupon_audit;
+ALTER TABLE coupon_audit NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE coupon_audit DISABLE ROW LEVEL SECURITY;
+
+DROP POLICY IF EXISTS coupons_tenant_isolation ON coupons;
+ALTER TABLE coupons NO FORCE ROW LEVEL SECURITY;
+ALTER TABLE coupons DISABLE ROW LEVEL SECURITY;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE coupon_versions DROP COLUMN IF EXISTS tenant_id;
+ALTER TABLE coupon_audit DROP COLUMN IF EXISTS tenant_id;
+DROP INDEX IF EXISTS coupons_tenant_id_idx;
+ALTER TABLE coupons DROP COLUMN IF EXISTS tenant_id;
@@ -0,0 +1,37 @@
+-- coupons that existed before multi-tenancy belong to the 'default' tenant
+ALTER TABLE coupons ADD COLUMN IF NOT EXISTS tenant_id VARCHAR NOT NULL DEFAULT 'default';
+ALTER TABLE coupons ALTER COLUMN tenant_id DROP DEFAULT;
+CREATE INDEX IF NOT EXISTS coupons_tenant_id_idx ON coupons (tenant_id);
+
+ALTER TABLE coupon_audit ADD COLUMN IF NOT EXISTS tenant_id VARCHAR NOT NULL DEFAULT 'default';
+ALTER TABLE coupon_audit ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE coupon_versions ADD COLUMN IF NOT EXISTS tenant_id VARCHAR NOT NULL DEFAULT 'default';
+ALTER TABLE coupon_versions ALTER COLUMN tenant_id DROP DEFAULT;
+
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS tenant_id VARCHAR NOT NULL DEFAULT 'default';
+ALTER TABLE api_keys ALTER COLUMN tenant_id DROP DEFAULT;
+
+-- the service filters every query by tenant itself; row-level security catches anything that slips through.
+-- app.tenant_id is set at the start of each transaction. Superusers and BYPASSRLS roles skip these policies,
+-- so the service must not connect as one.
+ALTER TABLE coupons ENABLE ROW LEVEL SECURITY;
+ALTER TABLE coupons FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS coupons_tenant_isolation ON coupons;
+CREATE POLICY coupons_tenant_isolation ON coupons
+  USING (tenant_id = current_setting('app.tenant_id', true))
+  WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE coupon_audit ENABLE ROW LEVEL SECURITY;
+ALTER TABLE coupon_audit FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS coupon_audit_tenant_isolation ON coupon_audit;
+CREATE POLICY coupon_audit_tenant_isolation ON coupon_audit
+  USING (tenant_id = current_setting('app.tenant_id', true))
+  WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
+
+ALTER TABLE coupon_versions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE coupon_versions FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS coupon_versions_tenant_isolation ON coupon_versions;
+CREATE POLICY coupon_versions_tenant_isolation ON coupon_versions
+  USING (tenant_id = current_setting('app.tenant_id', true))
+  WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
@@ -0,0 +1,15 @@
+-- keys are scoped to a tenant, so two merchants can't collide on (or replay) each other's keys. The tenant is
+-- always filtered on in queries, but there's no row-level security here: expired keys are deleted across tenants.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+  tenant_id VARCHAR NOT NULL,
+  key VARCHAR(255) NOT NULL,
+  fingerprint BYTEA NOT NULL,
+  status_code INTEGER,
+  headers JSONB,
+  body BYTEA,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  PRIMARY KEY (tenant_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -0,0 +1,9 @@
+-- token buckets shared by every instance of the service, for the postgres rate limiting backend
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+  key VARCHAR PRIMARY KEY,
+  tokens DOUBLE PRECISION NOT NULL,
+  allowed BOOLEAN NOT NULL,
+  updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS rate_limit_buckets_updated_at_idx ON rate_limit_buckets (updated_at);
@@ -0,0 +1,18 @@
+-- failed coupon lookups, kept long enough to be counted over the lockout policy's sliding window
+CREATE TABLE IF NOT EXISTS lookup_failures (
+  id BIGSERIAL PRIMARY KEY,
+  actor VARCHAR NOT NULL,
+  identifier VARCHAR NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS lookup_failures_actor_idx ON lookup_failures (actor, created_at);
+
+CREATE TABLE IF NOT EXISTS lockouts (
+  id BIGSERIAL PRIMARY KEY,
+  actor VARCHAR NOT NULL,
+  locked_until TIMESTAMP WITH TIME ZONE NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS lockouts_actor_idx ON lockouts (actor, created_at);
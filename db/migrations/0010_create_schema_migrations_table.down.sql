@@ -0,0 +1 @@
+-- schema_migrations is kept, as `coupons migrate` records every migration in it, including reverting this one
@@ -0,0 +1,8 @@
+-- the service refuses to start unless the latest migration is recorded here
+CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INTEGER PRIMARY KEY,
+  applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp
+);
+
+-- migrations are applied in order, so a database reaching this one already has every migration before it
+INSERT INTO schema_migrations (version) SELECT generate_series(0, 10) ON CONFLICT DO NOTHING;
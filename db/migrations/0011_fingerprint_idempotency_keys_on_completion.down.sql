@@ -0,0 +1,3 @@
+-- keys still in progress have no fingerprint to keep, so they're dropped and their requests can be retried
+DELETE FROM idempotency_keys WHERE fingerprint IS NULL;
+ALTER TABLE idempotency_keys ALTER COLUMN fingerprint SET NOT NULL;
@@ -0,0 +1,2 @@
+-- requests are fingerprinted as their body is read, so a key has no fingerprint until its request has finished
+ALTER TABLE idempotency_keys ALTER COLUMN fingerprint DROP NOT NULL;
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS redemptions (
+  id uuid DEFAULT uuid_generate_v1mc() PRIMARY KEY,
+  coupon_id uuid NOT NULL REFERENCES coupons (id) ON DELETE CASCADE,
+  tenant_id VARCHAR NOT NULL,
+  actor VARCHAR NOT NULL,
+  request_id VARCHAR,
+  redeemed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp
+);
+
+CREATE INDEX IF NOT EXISTS redemptions_coupon_id_idx ON redemptions (coupon_id, redeemed_at);
+
+ALTER TABLE redemptions ENABLE ROW LEVEL SECURITY;
+ALTER TABLE redemptions FORCE ROW LEVEL SECURITY;
+DROP POLICY IF EXISTS redemptions_tenant_isolation ON redemptions;
+CREATE POLICY redemptions_tenant_isolation ON redemptions
+  USING (tenant_id = current_setting('app.tenant_id', true))
+  WITH CHECK (tenant_id = current_setting('app.tenant_id', true));
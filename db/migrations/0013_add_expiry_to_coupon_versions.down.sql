@@ -0,0 +1 @@
+ALTER TABLE coupon_versions DROP COLUMN IF EXISTS expiry;
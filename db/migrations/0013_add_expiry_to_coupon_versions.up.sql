@@ -0,0 +1,2 @@
+-- versions are immutable, so those written before expiries were versioned don't record one
+ALTER TABLE coupon_versions ADD COLUMN IF NOT EXISTS expiry TIMESTAMP WITH TIME ZONE;
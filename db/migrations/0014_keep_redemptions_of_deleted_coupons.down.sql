@@ -0,0 +1,4 @@
+-- NOT VALID, as redemptions of coupons deleted since can't be checked against them
+ALTER TABLE redemptions DROP CONSTRAINT IF EXISTS redemptions_coupon_id_fkey;
+ALTER TABLE redemptions ADD CONSTRAINT redemptions_coupon_id_fkey
+  FOREIGN KEY (coupon_id) REFERENCES coupons (id) ON DELETE CASCADE NOT VALID;
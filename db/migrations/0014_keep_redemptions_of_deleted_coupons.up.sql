@@ -0,0 +1,2 @@
+-- like the audit trail, redemptions are history, so they outlive the coupon rather than being deleted along with it
+ALTER TABLE redemptions DROP CONSTRAINT IF EXISTS redemptions_coupon_id_fkey;
@@ -0,0 +1,104 @@
+package dbservices
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"github.com/madeleinesmith/coupons/auth"
+)
+
+type APIKeyService struct {
+	DB *sql.DB
+}
+
+// CreateAPIKey returns the plaintext key alongside the stored record, as this is the only time it's available
+func (s APIKeyService) CreateAPIKey(ctx context.Context, tenant string, name string, scopes []string) (string, *auth.APIKey, error) {
+	plaintextKey, err := auth.GenerateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Insert("api_keys").
+		Columns("name", "key_hash", "scopes", "tenant_id").
+		Values(name, auth.HashAPIKey(plaintextKey), pq.Array(scopes), tenant).
+		Suffix("RETURNING id, created_at").
+		ToSql()
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	apiKey := auth.APIKey{Name: name, TenantID: tenant, Scopes: scopes}
+
+	err = traced(s.DB).QueryRowContext(ctx, dbQuery, args...).Scan(&apiKey.ID, &apiKey.CreatedAt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return plaintextKey, &apiKey, nil
+}
+
+// RevokeAPIKey returns sql.ErrNoRows if there's no such key, or it was already revoked
+func (s APIKeyService) RevokeAPIKey(ctx context.Context, keyId string) error {
+	if !isUUID(keyId) {
+		return sql.ErrNoRows
+	}
+
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Update("api_keys").
+		Set("revoked_at", squirrel.Expr("current_timestamp")).
+		Where(squirrel.Eq{"id": keyId, "revoked_at": nil}).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	result, err := traced(s.DB).ExecContext(ctx, dbQuery, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (s APIKeyService) FindAPIKey(ctx context.Context, keyHash []byte) (*auth.APIKey, error) {
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Select("id", "name", "tenant_id", "scopes", "created_at", "revoked_at").
+		From("api_keys").
+		Where(squirrel.Eq{"key_hash": keyHash}).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var apiKey auth.APIKey
+	var revokedAt pq.NullTime
+
+	err = traced(s.DB).QueryRowContext(ctx, dbQuery, args...).
+		Scan(&apiKey.ID, &apiKey.Name, &apiKey.TenantID, pq.Array(&apiKey.Scopes), &apiKey.CreatedAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		apiKey.RevokedAt = &revokedAt.Time
+	}
+
+	return &apiKey, nil
+}
@@ -0,0 +1,127 @@
+package dbservices_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/dbservices"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"time"
+)
+
+var _ = Describe("API Key Service", func() {
+	var (
+		mockedService dbservices.APIKeyService
+		dbMock        sqlmock.Sqlmock
+		realService   dbservices.APIKeyService
+		ctx           context.Context
+	)
+
+	BeforeEach(func() {
+		var db *sql.DB
+		var err error
+
+		db, dbMock, err = sqlmock.New()
+		Expect(err).NotTo(HaveOccurred())
+
+		mockedService = dbservices.APIKeyService{
+			DB: db,
+		}
+
+		realService = dbservices.APIKeyService{
+			DB: realDB,
+		}
+
+		ctx = context.Background()
+	})
+
+	It("finds issued keys by their hash until they are revoked", func() {
+		plaintextKey, issuedKey, err := realService.CreateAPIKey(ctx, "boots", "checkout", []string{auth.ScopeCouponsRead})
+		Expect(err).NotTo(HaveOccurred())
+
+		foundKey, err := realService.FindAPIKey(ctx, auth.HashAPIKey(plaintextKey))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(foundKey.ID).To(Equal(issuedKey.ID))
+		Expect(foundKey.TenantID).To(Equal("boots"))
+		Expect(foundKey.Scopes).To(Equal([]string{auth.ScopeCouponsRead}))
+		Expect(foundKey.RevokedAt).To(BeNil())
+
+		Expect(realService.RevokeAPIKey(ctx, issuedKey.ID)).To(Succeed())
+
+		foundKey, err = realService.FindAPIKey(ctx, auth.HashAPIKey(plaintextKey))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(foundKey.RevokedAt).NotTo(BeNil())
+
+		Expect(realService.RevokeAPIKey(ctx, issuedKey.ID)).To(MatchError(sql.ErrNoRows))
+	})
+
+	Describe("CreateAPIKey", func() {
+		It("stores only the hash of the key", func() {
+			dbMock.ExpectQuery(`INSERT INTO api_keys \(name,key_hash,scopes,tenant_id\) VALUES \(\$1,\$2,\$3,\$4\) RETURNING id, created_at`).
+				WithArgs("checkout", sqlmock.AnyArg(), sqlmock.AnyArg(), "boots").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow("1", time.Now()))
+
+			plaintextKey, apiKey, err := mockedService.CreateAPIKey(ctx, "boots", "checkout", []string{auth.ScopeCouponsWrite})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(plaintextKey).NotTo(BeEmpty())
+			Expect(apiKey.ID).To(Equal("1"))
+			Expect(apiKey.Scopes).To(Equal([]string{auth.ScopeCouponsWrite}))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("propagates the error", func() {
+			dbMock.ExpectQuery(`INSERT INTO api_keys .*`).WillReturnError(errors.New("relation does not exist"))
+
+			_, _, err := mockedService.CreateAPIKey(ctx, "boots", "checkout", nil)
+			Expect(err).To(MatchError("relation does not exist"))
+		})
+	})
+
+	Describe("RevokeAPIKey", func() {
+		It("returns sql.ErrNoRows if the key is unknown or already revoked", func() {
+			dbMock.ExpectExec(`UPDATE api_keys SET revoked_at = current_timestamp WHERE id = \$1 AND revoked_at IS NULL`).
+				WithArgs("0b5a3c1e-7d2f-4e8a-9c6b-1f2e3d4c5b6a").
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			Expect(mockedService.RevokeAPIKey(ctx, "0b5a3c1e-7d2f-4e8a-9c6b-1f2e3d4c5b6a")).To(MatchError(sql.ErrNoRows))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns sql.ErrNoRows without querying if the id isn't a UUID", func() {
+			Expect(mockedService.RevokeAPIKey(ctx, "checkout")).To(MatchError(sql.ErrNoRows))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Describe("FindAPIKey", func() {
+		It("scans the key", func() {
+			createdAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			dbMock.ExpectQuery(`SELECT id, name, tenant_id, scopes, created_at, revoked_at FROM api_keys WHERE key_hash = \$1`).
+				WithArgs([]byte("hash")).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "tenant_id", "scopes", "created_at", "revoked_at"}).
+					AddRow("1", "checkout", "boots", "{coupons:read,coupons:write}", createdAt, nil))
+
+			apiKey, err := mockedService.FindAPIKey(ctx, []byte("hash"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(apiKey).To(Equal(&auth.APIKey{
+				ID:        "1",
+				Name:      "checkout",
+				TenantID:  "boots",
+				Scopes:    []string{auth.ScopeCouponsRead, auth.ScopeCouponsWrite},
+				CreatedAt: createdAt,
+			}))
+		})
+
+		It("returns sql.ErrNoRows for unknown keys", func() {
+			dbMock.ExpectQuery(`SELECT .* FROM api_keys`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "tenant_id", "scopes", "created_at", "revoked_at"}))
+
+			_, err := mockedService.FindAPIKey(ctx, []byte("hash"))
+			Expect(err).To(MatchError(sql.ErrNoRows))
+		})
+	})
+})
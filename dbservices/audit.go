@@ -0,0 +1,166 @@
+package dbservices
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/Masterminds/squirrel"
+	"github.com/madeleinesmith/coupons/model/audit"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/requestcontext"
+)
+
+type CouponAuditService struct {
+	DB *sql.DB
+}
+
+func newAuditEntry(ctx context.Context, action string, couponId string, before *coupon.Coupon, after *coupon.Coupon) audit.Entry {
+	return audit.Entry{
+		CouponID:  couponId,
+		Action:    action,
+		Actor:     requestcontext.Actor(ctx),
+		RequestID: requestcontext.RequestID(ctx),
+		Changes:   audit.Diff(before, after),
+	}
+}
+
+// insertAuditEntries takes the executor of the change being audited, so both are committed or rolled back together
+func insertAuditEntries(ctx context.Context, exec executor, tenant string, entries ...audit.Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	insertStatement := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Insert("coupon_audit").
+		Columns("coupon_id", "action", "actor", "request_id", "changes", "tenant_id")
+
+	for _, entry := range entries {
+		changes, err := json.Marshal(entry.Changes)
+		if err != nil {
+			return err
+		}
+
+		var requestId *string
+		if entry.RequestID != "" {
+			requestId = &entry.RequestID
+		}
+
+		insertStatement = insertStatement.Values(entry.CouponID, entry.Action, entry.Actor, requestId, changes, tenant)
+	}
+
+	dbQuery, args, err := insertStatement.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = traced(exec).ExecContext(ctx, dbQuery, args...)
+
+	return err
+}
+
+// RecordDenial writes straight to the DB rather than joining any transaction, so the entry survives the rollback
+// the denial causes. couponId is empty for denied creates, and isn't recorded if it can't be a coupon's id, as
+// coupon_id is a uuid.
+func (s CouponAuditService) RecordDenial(ctx context.Context, permission string, couponId string) error {
+	var nullableCouponId *string
+	if isUUID(couponId) {
+		nullableCouponId = &couponId
+	}
+
+	var requestId *string
+	if id := requestcontext.RequestID(ctx); id != "" {
+		requestId = &id
+	}
+
+	tx, tenant, err := beginTenantTransaction(ctx, s.DB)
+	if err != nil {
+		return err
+	}
+
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Insert("coupon_audit").
+		Columns("coupon_id", "action", "actor", "request_id", "permission", "tenant_id").
+		Values(nullableCouponId, audit.ActionDenied, requestcontext.Actor(ctx), requestId, permission, tenant).
+		ToSql()
+
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = traced(tx).ExecContext(ctx, dbQuery, args...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s CouponAuditService) GetCouponHistory(ctx context.Context, couponId string) ([]*audit.Entry, error) {
+	if !isUUID(couponId) {
+		return []*audit.Entry{}, nil
+	}
+
+	tx, tenant, err := beginTenantTransaction(ctx, s.DB)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	entries, err := getCouponHistory(ctx, tx, tenant, couponId)
+	if err != nil {
+		tx.Rollback()
+		return nil, contextError(ctx, err)
+	}
+
+	return entries, contextError(ctx, tx.Commit())
+}
+
+func getCouponHistory(ctx context.Context, tx *sql.Tx, tenant string, couponId string) ([]*audit.Entry, error) {
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Select("id", "coupon_id", "action", "actor", "request_id", "permission", "changes", "created_at").
+		From("coupon_audit").
+		Where(squirrel.Eq{"coupon_id": couponId, "tenant_id": tenant}).
+		OrderBy("created_at", "id").
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := traced(tx).QueryContext(ctx, dbQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []*audit.Entry{}
+
+	for rows.Next() {
+		entry := new(audit.Entry)
+
+		var requestId sql.NullString
+		var permission sql.NullString
+		var changes []byte
+
+		err := rows.Scan(&entry.ID, &entry.CouponID, &entry.Action, &entry.Actor, &requestId, &permission, &changes, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.RequestID = requestId.String
+		entry.Permission = permission.String
+
+		err = json.Unmarshal(changes, &entry.Changes)
+		if err != nil {
+			return nil, err
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
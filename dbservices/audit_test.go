@@ -0,0 +1,192 @@
+package dbservices_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/dbservices"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/audit"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"time"
+)
+
+var _ = Describe("Coupon Audit Service", func() {
+	var (
+		mockedService dbservices.CouponAuditService
+		dbMock        sqlmock.Sqlmock
+		realService   dbservices.CouponAuditService
+		ctx           context.Context
+	)
+
+	BeforeEach(func() {
+		var db *sql.DB
+		var err error
+
+		db, dbMock, err = sqlmock.New()
+		Expect(err).NotTo(HaveOccurred())
+
+		mockedService = dbservices.CouponAuditService{
+			DB: db,
+		}
+
+		realService = dbservices.CouponAuditService{
+			DB: realDB,
+		}
+
+		ctx = requestcontext.WithActor(context.Background(), "madeleine")
+		ctx = requestcontext.WithTenant(ctx, testTenant)
+	})
+
+	Describe("GetCouponHistory", func() {
+		It("returns every change made through the coupon service, oldest first", func() {
+			couponService := dbservices.CouponService{DB: realDB}
+
+			name := "Save £5 at Boots"
+			brand := "Boots"
+			value := 5
+
+			createdCoupon, err := couponService.CreateCoupon(ctx, coupon.Coupon{Name: &name, Brand: &brand, Value: &value})
+			Expect(err).NotTo(HaveOccurred())
+
+			newValue := 50
+			Expect(couponService.UpdateCoupon(requestcontext.WithRequestID(ctx, "req-456"), coupon.Coupon{ID: createdCoupon.ID, Value: &newValue})).To(Succeed())
+			Expect(couponService.DeleteCoupon(ctx, createdCoupon.ID)).To(Succeed())
+
+			entries, err := realService.GetCouponHistory(ctx, createdCoupon.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(3))
+
+			Expect(entries[0].Action).To(Equal(audit.ActionCreate))
+			Expect(entries[0].Actor).To(Equal("madeleine"))
+			Expect(entries[0].Changes).To(HaveKey("name"))
+
+			Expect(entries[1].Action).To(Equal(audit.ActionUpdate))
+			Expect(entries[1].RequestID).To(Equal("req-456"))
+			Expect(entries[1].Changes).To(Equal(map[string]audit.Change{
+				"value": {Before: float64(5), After: float64(50)},
+			}))
+
+			Expect(entries[2].Action).To(Equal(audit.ActionDelete))
+			Expect(entries[2].Changes["value"]).To(Equal(audit.Change{Before: float64(50), After: nil}))
+		})
+
+		It("refuses to rewrite history", func() {
+			name := "Save £5 at Boots"
+			brand := "Boots"
+			value := 5
+
+			createdCoupon, err := dbservices.CouponService{DB: realDB}.CreateCoupon(ctx, coupon.Coupon{Name: &name, Brand: &brand, Value: &value})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = realDB.Exec("UPDATE coupon_audit SET actor = 'someone else' WHERE coupon_id = $1", createdCoupon.ID)
+			Expect(err).To(MatchError(ContainSubstring("coupon_audit is append-only")))
+		})
+
+		It("scans the audit rows", func() {
+			createdAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT id, coupon_id, action, actor, request_id, permission, changes, created_at FROM coupon_audit WHERE coupon_id = \$1 AND tenant_id = \$2 ORDER BY created_at, id`).
+				WithArgs("0faec7ea-239f-11e9-9e44-d770694a0159", testTenant).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "coupon_id", "action", "actor", "request_id", "permission", "changes", "created_at"}).
+					AddRow(1, "0faec7ea-239f-11e9-9e44-d770694a0159", "update", "madeleine", nil, nil, []byte(`{"brand":{"before":"Asda","after":"Tesco"}}`), createdAt))
+			dbMock.ExpectCommit()
+
+			entries, err := mockedService.GetCouponHistory(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(Equal([]*audit.Entry{{
+				ID:        "1",
+				CouponID:  "0faec7ea-239f-11e9-9e44-d770694a0159",
+				Action:    "update",
+				Actor:     "madeleine",
+				Changes:   map[string]audit.Change{"brand": {Before: "Asda", After: "Tesco"}},
+				CreatedAt: createdAt,
+			}}))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns an empty history for a coupon that was never changed", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT .* FROM coupon_audit`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "coupon_id", "action", "actor", "request_id", "permission", "changes", "created_at"}))
+			dbMock.ExpectCommit()
+
+			entries, err := mockedService.GetCouponHistory(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+
+		It("propagates the error", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT .* FROM coupon_audit`).WillReturnError(errors.New("permission denied"))
+			dbMock.ExpectRollback()
+
+			_, err := mockedService.GetCouponHistory(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159")
+			Expect(err).To(MatchError("permission denied"))
+		})
+	})
+
+	Describe("RecordDenial", func() {
+		It("shows up in the coupon's history even though the change was rolled back", func() {
+			couponService := dbservices.CouponService{DB: realDB}
+
+			name := "Save £5 at Boots"
+			brand := "Boots"
+			value := 5
+
+			createdCoupon, err := couponService.CreateCoupon(ctx, coupon.Coupon{Name: &name, Brand: &brand, Value: &value})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = couponService.WithinTransaction(ctx, func(handlers.CouponService) error {
+				Expect(realService.RecordDenial(ctx, "delete-coupons", createdCoupon.ID)).To(Succeed())
+				return auth.PermissionDeniedError{Permission: "delete-coupons"}
+			})
+			Expect(err).To(MatchError("permission denied: delete-coupons"))
+
+			entries, err := realService.GetCouponHistory(ctx, createdCoupon.ID)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[1].Action).To(Equal(audit.ActionDenied))
+			Expect(entries[1].Permission).To(Equal("delete-coupons"))
+		})
+
+		It("records who was refused what", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectExec(`INSERT INTO coupon_audit \(coupon_id,action,actor,request_id,permission,tenant_id\) VALUES \(\$1,\$2,\$3,\$4,\$5,\$6\)`).
+				WithArgs("0faec7ea-239f-11e9-9e44-d770694a0159", "denied", "madeleine", "req-123", "change-value", testTenant).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			dbMock.ExpectCommit()
+
+			Expect(mockedService.RecordDenial(requestcontext.WithRequestID(ctx, "req-123"), "change-value", "0faec7ea-239f-11e9-9e44-d770694a0159")).To(Succeed())
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("records denials for ids that can't be a coupon's without a coupon, rather than failing", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectExec(`INSERT INTO coupon_audit .*`).
+				WithArgs(nil, "denied", "madeleine", nil, "view-coupons", testTenant).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			dbMock.ExpectCommit()
+
+			Expect(mockedService.RecordDenial(ctx, "view-coupons", "BOOTS-SAVE5")).To(Succeed())
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("records denied creates without a coupon", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectExec(`INSERT INTO coupon_audit .*`).
+				WithArgs(nil, "denied", "madeleine", nil, "create-coupons", testTenant).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			dbMock.ExpectCommit()
+
+			Expect(mockedService.RecordDenial(ctx, "create-coupons", "")).To(Succeed())
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
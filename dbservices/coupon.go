@@ -1,74 +1,200 @@
 package dbservices
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
 	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/audit"
 	"github.com/madeleinesmith/coupons/model/coupon"
+	"strings"
 )
 
+const exportFetchSize = 500
+
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// CouponService runs everything in a transaction scoped to the request's tenant, and every query it builds is
+// filtered by that tenant
 type CouponService struct {
-	DB *sql.DB
+	DB     *sql.DB
+	tx     *sql.Tx
+	tenant string
 }
 
-func (s CouponService) CreateCoupon(couponInstance coupon.Coupon) (*coupon.Coupon, error) {
-	query, args, err := squirrel.StatementBuilder.
-		PlaceholderFormat(squirrel.Dollar).
-		Insert("coupons").
-		Columns("name", "brand", "value").
-		Values(*couponInstance.Name, *couponInstance.Brand, *couponInstance.Value).
-		Suffix("RETURNING id").
-		ToSql()
+func (s CouponService) WithinTransaction(ctx context.Context, fn func(handlers.CouponService) error) error {
+	return s.inTransaction(ctx, func(txService CouponService) error {
+		return fn(txService)
+	})
+}
 
+// inTransaction joins the service's transaction if it already has one, otherwise it starts a new one
+func (s CouponService) inTransaction(ctx context.Context, fn func(CouponService) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+
+	tx, tenant, err := beginTenantTransaction(ctx, s.DB)
 	if err != nil {
-		return nil, err
+		return contextError(ctx, err)
 	}
 
-	err = s.DB.QueryRow(query, args...).Scan(&couponInstance.ID)
+	err = fn(CouponService{DB: s.DB, tx: tx, tenant: tenant})
 	if err != nil {
-		return nil, err
+		tx.Rollback()
+		return contextError(ctx, err)
 	}
 
-	return &couponInstance, nil
+	return contextError(ctx, tx.Commit())
 }
 
-func (s CouponService) UpdateCoupon(coupon coupon.Coupon) error {
-	updateStatement := squirrel.StatementBuilder.
-		PlaceholderFormat(squirrel.Dollar).
-		Update("coupons").
-		Where(squirrel.Eq{"id": coupon.ID})
+func (s CouponService) CreateCoupon(ctx context.Context, couponInstance coupon.Coupon) (*coupon.Coupon, error) {
+	err := s.inTransaction(ctx, func(txService CouponService) error {
+		query, args, err := squirrel.StatementBuilder.
+			PlaceholderFormat(squirrel.Dollar).
+			Insert("coupons").
+			Columns("name", "brand", "value", "tenant_id").
+			Values(*couponInstance.Name, *couponInstance.Brand, *couponInstance.Value, txService.tenant).
+			Suffix("RETURNING id").
+			ToSql()
 
-	if coupon.Name != nil {
-		updateStatement = updateStatement.Set("name", &coupon.Name)
-	}
+		if err != nil {
+			return err
+		}
 
-	if coupon.Brand != nil {
-		updateStatement = updateStatement.Set("brand", &coupon.Brand)
-	}
+		err = traced(txService.tx).QueryRowContext(ctx, query, args...).Scan(&couponInstance.ID)
+		if err != nil {
+			return err
+		}
 
-	if coupon.Value != nil {
-		updateStatement = updateStatement.Set("value", &coupon.Value)
-	}
+		return txService.recordChanges(ctx, couponChange{action: audit.ActionCreate, couponId: couponInstance.ID, after: &couponInstance})
+	})
 
-	dbQuery, args, err := updateStatement.ToSql()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = s.DB.Exec(dbQuery, args...)
-	if err != nil {
-		return err
+	return &couponInstance, nil
+}
+
+func (s CouponService) CreateCoupons(ctx context.Context, coupons []coupon.Coupon) error {
+	if len(coupons) == 0 {
+		return nil
+	}
+
+	return s.inTransaction(ctx, func(txService CouponService) error {
+		insertStatement := squirrel.StatementBuilder.
+			PlaceholderFormat(squirrel.Dollar).
+			Insert("coupons").
+			Columns("name", "brand", "value", "tenant_id").
+			Suffix("RETURNING id, name, brand, value, expiry")
+
+		for _, couponInstance := range coupons {
+			insertStatement = insertStatement.Values(*couponInstance.Name, *couponInstance.Brand, *couponInstance.Value, txService.tenant)
+		}
+
+		dbQuery, args, err := insertStatement.ToSql()
+		if err != nil {
+			return err
+		}
+
+		rows, err := traced(txService.tx).QueryContext(ctx, dbQuery, args...)
+		if err != nil {
+			return err
+		}
+
+		createdCoupons, err := scanCoupons(rows)
+		if err != nil {
+			return err
+		}
+
+		changes := make([]couponChange, len(createdCoupons))
+		for i, createdCoupon := range createdCoupons {
+			changes[i] = couponChange{action: audit.ActionCreate, couponId: createdCoupon.ID, after: createdCoupon}
+		}
+
+		return txService.recordChanges(ctx, changes...)
+	})
+}
+
+func (s CouponService) UpdateCoupon(ctx context.Context, coupon coupon.Coupon) error {
+	return s.updateCoupon(ctx, coupon, false)
+}
+
+// updateCoupon changes the fields that are set. With replaceExpiry, the expiry is set even if it's nil, clearing it.
+func (s CouponService) updateCoupon(ctx context.Context, coupon coupon.Coupon, replaceExpiry bool) error {
+	if !isUUID(coupon.ID) {
+		return sql.ErrNoRows
 	}
 
-	return nil
+	return s.inTransaction(ctx, func(txService CouponService) error {
+		updateStatement := squirrel.StatementBuilder.
+			PlaceholderFormat(squirrel.Dollar).
+			Update("coupons").
+			Where(squirrel.Eq{"id": coupon.ID, "tenant_id": txService.tenant})
+
+		if coupon.Name != nil {
+			updateStatement = updateStatement.Set("name", &coupon.Name)
+		}
+
+		if coupon.Brand != nil {
+			updateStatement = updateStatement.Set("brand", &coupon.Brand)
+		}
+
+		if coupon.Value != nil {
+			updateStatement = updateStatement.Set("value", &coupon.Value)
+		}
+
+		if coupon.Expiry != nil || replaceExpiry {
+			updateStatement = updateStatement.Set("expiry", coupon.Expiry)
+		}
+
+		dbQuery, args, err := updateStatement.ToSql()
+		if err != nil {
+			return err
+		}
+
+		before, err := txService.getCouponForUpdate(ctx, coupon.ID)
+		if err != nil {
+			return err
+		}
+
+		_, err = traced(txService.tx).ExecContext(ctx, dbQuery, args...)
+		if err != nil {
+			return err
+		}
+
+		after := *before
+		if coupon.Name != nil {
+			after.Name = coupon.Name
+		}
+		if coupon.Brand != nil {
+			after.Brand = coupon.Brand
+		}
+		if coupon.Value != nil {
+			after.Value = coupon.Value
+		}
+		if coupon.Expiry != nil || replaceExpiry {
+			after.Expiry = coupon.Expiry
+		}
+
+		return txService.recordChanges(ctx, couponChange{action: audit.ActionUpdate, couponId: coupon.ID, before: before, after: &after})
+	})
 }
 
-func (s CouponService) GetCoupons(filters handlers.Filters) ([]*coupon.Coupon, error) {
+func (s CouponService) couponsSelect(filters handlers.Filters) squirrel.SelectBuilder {
 	selectStatement := squirrel.StatementBuilder.
 		PlaceholderFormat(squirrel.Dollar).
-		Select("id, name, brand, value").
-		From("coupons")
+		Select("id, name, brand, value, expiry").
+		From("coupons").
+		Where(squirrel.Eq{"tenant_id": s.tenant})
 
 	if filters.Brand != nil {
 		selectStatement = selectStatement.Where(squirrel.Eq{"brand": *filters.Brand})
@@ -82,25 +208,63 @@ func (s CouponService) GetCoupons(filters handlers.Filters) ([]*coupon.Coupon, e
 		selectStatement = selectStatement.Where(squirrel.Eq{"name": *filters.Name})
 	}
 
-	dbQuery, args, err := selectStatement.ToSql()
+	if filters.After != nil {
+		selectStatement = selectStatement.Where(squirrel.Gt{"id": *filters.After})
+	}
+
+	// pages are in id order, so each carries on from the last id of the one before
+	if filters.After != nil || filters.Limit != nil {
+		selectStatement = selectStatement.OrderBy("id")
+	}
+
+	if filters.Limit != nil {
+		selectStatement = selectStatement.Limit(uint64(*filters.Limit))
+	}
+
+	return selectStatement
+}
+
+func (s CouponService) GetCoupons(ctx context.Context, filters handlers.Filters) ([]*coupon.Coupon, error) {
+	var couponSlice []*coupon.Coupon
+
+	err := s.inTransaction(ctx, func(txService CouponService) error {
+		dbQuery, args, err := txService.couponsSelect(filters).ToSql()
+		if err != nil {
+			return err
+		}
+
+		rows, err := traced(txService.tx).QueryContext(ctx, dbQuery, args...)
+		if err != nil {
+			return err
+		}
+
+		couponSlice, err = scanCoupons(rows)
+
+		return err
+	})
+
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := s.DB.Query(dbQuery, args...)
-	if err != nil {
+	// this strikes me as rather an inelegant solution to determining if no rows are returned
+	if len(couponSlice) == 0 {
+		err := errors.New("sql: no rows in result set")
 		return nil, err
 	}
 
-	numRows := 0
+	return couponSlice, nil
+}
+
+func scanCoupons(rows *sql.Rows) ([]*coupon.Coupon, error) {
+	defer rows.Close()
+
 	var couponSlice []*coupon.Coupon
 
 	for rows.Next() {
-		numRows++
-
 		couponInstance := new(coupon.Coupon)
 
-		err := rows.Scan(&couponInstance.ID, &couponInstance.Name, &couponInstance.Brand, &couponInstance.Value)
+		err := rows.Scan(&couponInstance.ID, &couponInstance.Name, &couponInstance.Brand, &couponInstance.Value, &couponInstance.Expiry)
 
 		if err != nil {
 			return nil, err
@@ -109,34 +273,156 @@ func (s CouponService) GetCoupons(filters handlers.Filters) ([]*coupon.Coupon, e
 		couponSlice = append(couponSlice, couponInstance)
 	}
 
-	// this strikes me as rather an inelegant solution to determining if no rows are returned
-	if numRows == 0 {
-		err := errors.New("sql: no rows in result set")
+	return couponSlice, rows.Err()
+}
+
+// StreamCoupons reads the coupons through a server-side cursor, so only exportFetchSize of them are held in memory at once
+func (s CouponService) StreamCoupons(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error {
+	return s.inTransaction(ctx, func(txService CouponService) error {
+		dbQuery, args, err := txService.couponsSelect(filters).ToSql()
+		if err != nil {
+			return err
+		}
+
+		// named afresh for each export, so nothing else on the connection can clash with it
+		cursor := "coupon_export_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+		_, err = traced(txService.tx).ExecContext(ctx, "DECLARE "+cursor+" NO SCROLL CURSOR FOR "+dbQuery, args...)
+		if err != nil {
+			return err
+		}
+
+		for {
+			numRows, err := txService.fetchCoupons(ctx, cursor, fn)
+			if err != nil {
+				return err
+			}
+
+			if numRows < exportFetchSize {
+				break
+			}
+		}
+
+		_, err = traced(txService.tx).ExecContext(ctx, "CLOSE "+cursor)
+
+		return err
+	})
+}
+
+func (s CouponService) fetchCoupons(ctx context.Context, cursor string, fn func(*coupon.Coupon) error) (int, error) {
+	rows, err := traced(s.tx).QueryContext(ctx, fmt.Sprintf("FETCH %d FROM %s", exportFetchSize, cursor))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	numRows := 0
+
+	for rows.Next() {
+		numRows++
+
+		couponInstance := new(coupon.Coupon)
+
+		err := rows.Scan(&couponInstance.ID, &couponInstance.Name, &couponInstance.Brand, &couponInstance.Value, &couponInstance.Expiry)
+		if err != nil {
+			return 0, err
+		}
+
+		err = fn(couponInstance)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return numRows, rows.Err()
+}
+
+func (s CouponService) GetCouponById(ctx context.Context, couponId string) (*coupon.Coupon, error) {
+	if !isUUID(couponId) {
+		return nil, sql.ErrNoRows
+	}
+
+	var couponInstance coupon.Coupon
+
+	err := s.inTransaction(ctx, func(txService CouponService) error {
+		sqlString, args, err := squirrel.StatementBuilder.
+			PlaceholderFormat(squirrel.Dollar).
+			Select("id", "name", "brand", "value", "expiry").
+			From("coupons").
+			Where(squirrel.Eq{"id": couponId, "tenant_id": txService.tenant}).
+			ToSql()
+
+		if err != nil {
+			return err
+		}
+
+		return traced(txService.tx).QueryRowContext(ctx, sqlString, args...).
+			Scan(&couponInstance.ID, &couponInstance.Name, &couponInstance.Brand, &couponInstance.Value, &couponInstance.Expiry)
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
-	return couponSlice, nil
+	return &couponInstance, nil
 }
 
-func (s CouponService) GetCouponById(couponId string) (*coupon.Coupon, error) {
+// getCouponForUpdate locks the coupon's row until the transaction ends, so the audited "before" can't go stale
+func (s CouponService) getCouponForUpdate(ctx context.Context, couponId string) (*coupon.Coupon, error) {
 	sqlString, args, err := squirrel.StatementBuilder.
 		PlaceholderFormat(squirrel.Dollar).
-		Select("id", "name", "brand", "value").
+		Select("id", "name", "brand", "value", "expiry").
 		From("coupons").
-		Where(squirrel.Eq{"id": couponId}).
+		Where(squirrel.Eq{"id": couponId, "tenant_id": s.tenant}).
+		Suffix("FOR UPDATE").
 		ToSql()
 
 	if err != nil {
 		return nil, err
 	}
 
-	row := s.DB.QueryRow(sqlString, args...)
-
 	var couponInstance coupon.Coupon
-	err = row.Scan(&couponInstance.ID, &couponInstance.Name, &couponInstance.Brand, &couponInstance.Value)
+	err = traced(s.tx).QueryRowContext(ctx, sqlString, args...).
+		Scan(&couponInstance.ID, &couponInstance.Name, &couponInstance.Brand, &couponInstance.Value, &couponInstance.Expiry)
 	if err != nil {
 		return nil, err
 	}
 
 	return &couponInstance, nil
 }
+
+func (s CouponService) DeleteCoupon(ctx context.Context, couponId string) error {
+	if !isUUID(couponId) {
+		return sql.ErrNoRows
+	}
+
+	return s.inTransaction(ctx, func(txService CouponService) error {
+		dbQuery, args, err := squirrel.StatementBuilder.
+			PlaceholderFormat(squirrel.Dollar).
+			Delete("coupons").
+			Where(squirrel.Eq{"id": couponId, "tenant_id": txService.tenant}).
+			Suffix("RETURNING id, name, brand, value, expiry").
+			ToSql()
+
+		if err != nil {
+			return err
+		}
+
+		var deletedCoupon coupon.Coupon
+
+		err = traced(txService.tx).QueryRowContext(ctx, dbQuery, args...).
+			Scan(&deletedCoupon.ID, &deletedCoupon.Name, &deletedCoupon.Brand, &deletedCoupon.Value, &deletedCoupon.Expiry)
+		if err != nil {
+			return err
+		}
+
+		return txService.recordChanges(ctx, couponChange{action: audit.ActionDelete, couponId: couponId, before: &deletedCoupon})
+	})
+}
+
+// isUUID is whether Postgres would accept id as a uuid, rather than failing the whole statement. Lookups of ids that
+// aren't return sql.ErrNoRows like any other missing coupon, so guessing codes is counted as failed lookups.
+func isUUID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
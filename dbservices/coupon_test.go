@@ -1,14 +1,17 @@
 package dbservices_test
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/madeleinesmith/coupons/dbservices"
 	"github.com/madeleinesmith/coupons/handlers"
 	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/requestcontext"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"time"
 )
 
 var _ = Describe("Coupon Service", func() {
@@ -16,12 +19,17 @@ var _ = Describe("Coupon Service", func() {
 		mockedService dbservices.CouponService
 		dbMock        sqlmock.Sqlmock
 		realService   dbservices.CouponService
+		ctx           context.Context
 	)
 
 	BeforeEach(func() {
 		var db *sql.DB
 		var err error
 
+		ctx = requestcontext.WithActor(context.Background(), "madeleine")
+		ctx = requestcontext.WithRequestID(ctx, "req-123")
+		ctx = requestcontext.WithTenant(ctx, testTenant)
+
 		db, dbMock, err = sqlmock.New()
 		Expect(err).NotTo(HaveOccurred())
 
@@ -52,7 +60,7 @@ var _ = Describe("Coupon Service", func() {
 		})
 
 		It("successfully creates a coupon", func() {
-			returnedCoupon, err := realService.CreateCoupon(exampleCoupon)
+			returnedCoupon, err := realService.CreateCoupon(ctx, exampleCoupon)
 			Expect(err).ToNot(HaveOccurred())
 
 			couponWithId := exampleCoupon
@@ -61,7 +69,7 @@ var _ = Describe("Coupon Service", func() {
 
 			var capturedCoupon coupon.Coupon
 
-			Expect(realDB.QueryRow("SELECT id, name, brand, value FROM coupons WHERE id=$1", returnedCoupon.ID).
+			Expect(realDB.QueryRow("SELECT id, name, brand, value, expiry FROM coupons WHERE id=$1", returnedCoupon.ID).
 				Scan(&capturedCoupon.ID, &capturedCoupon.Name, &capturedCoupon.Brand, &capturedCoupon.Value)).To(Succeed())
 
 			Expect(capturedCoupon.ID).NotTo(BeEmpty())
@@ -70,16 +78,104 @@ var _ = Describe("Coupon Service", func() {
 			Expect(*capturedCoupon.Value).To(Equal(108))
 		})
 
+		It("records the creation in the audit trail in the same transaction", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery("INSERT INTO coupons .*").
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("0faec7ea-239f-11e9-9e44-d770694a0159"))
+			dbMock.ExpectExec(`INSERT INTO coupon_audit \(coupon_id,action,actor,request_id,changes,tenant_id\) VALUES \(\$1,\$2,\$3,\$4,\$5,\$6\)`).
+				WithArgs("0faec7ea-239f-11e9-9e44-d770694a0159", "create", "madeleine", "req-123",
+					[]byte(`{"brand":{"before":null,"after":"Vue"},"name":{"before":null,"after":"Save £108 at Vue"},"value":{"before":null,"after":108}}`), testTenant).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			dbMock.ExpectExec(`INSERT INTO coupon_versions \(coupon_id,version,name,brand,value,expiry,deleted,tenant_id\) VALUES \(\$1,\(SELECT COALESCE\(MAX\(version\), 0\) \+ 1 FROM coupon_versions WHERE coupon_id = \$2\),\$3,\$4,\$5,\$6,\$7,\$8\)`).
+				WithArgs("0faec7ea-239f-11e9-9e44-d770694a0159", "0faec7ea-239f-11e9-9e44-d770694a0159", "Save £108 at Vue", "Vue", 108, nil, false, testTenant).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			dbMock.ExpectCommit()
+
+			_, err := mockedService.CreateCoupon(ctx, exampleCoupon)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("rolls back the coupon if the audit entry can't be written", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery("INSERT INTO coupons .*").
+				WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("0faec7ea-239f-11e9-9e44-d770694a0159"))
+			dbMock.ExpectExec("INSERT INTO coupon_audit .*").WillReturnError(errors.New("relation does not exist"))
+			dbMock.ExpectRollback()
+
+			_, err := mockedService.CreateCoupon(ctx, exampleCoupon)
+			Expect(err).To(MatchError("relation does not exist"))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
 		It("propagates the error", func() {
+			expectTenantTransaction(dbMock)
 			dbMock.ExpectQuery("INSERT INTO coupons .*").
 				WillReturnError(errors.New("oops I did it again 😇"))
+			dbMock.ExpectRollback()
 
-			_, err := mockedService.CreateCoupon(exampleCoupon)
+			_, err := mockedService.CreateCoupon(ctx, exampleCoupon)
 			Expect(err).To(MatchError(ContainSubstring("oops I did it again 😇")))
 			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
 		})
 	})
 
+	Describe("CreateCoupons", func() {
+		It("inserts every coupon in a single statement", func() {
+			name1, brand1, value1 := "Save £1 at Lidl", "Lidl", 1
+			name2, brand2, value2 := "Save £2 at Aldi", "Aldi", 2
+
+			Expect(realService.CreateCoupons(ctx, []coupon.Coupon{
+				{Name: &name1, Brand: &brand1, Value: &value1},
+				{Name: &name2, Brand: &brand2, Value: &value2},
+			})).To(Succeed())
+
+			var count int
+			Expect(realDB.QueryRow("SELECT COUNT(*) FROM coupons WHERE brand IN ('Lidl', 'Aldi')").Scan(&count)).To(Succeed())
+			Expect(count).To(Equal(2))
+		})
+
+		It("builds a multi-row insert", func() {
+			name1, brand1, value1 := "Save £1 at Lidl", "Lidl", 1
+			name2, brand2, value2 := "Save £2 at Aldi", "Aldi", 2
+
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`INSERT INTO coupons \(name,brand,value,tenant_id\) VALUES \(\$1,\$2,\$3,\$4\),\(\$5,\$6,\$7,\$8\) RETURNING id, name, brand, value, expiry`).
+				WithArgs(name1, brand1, value1, testTenant, name2, brand2, value2, testTenant).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}).
+					AddRow("1", name1, brand1, value1, nil).
+					AddRow("2", name2, brand2, value2, nil))
+			dbMock.ExpectExec(`INSERT INTO coupon_audit \(coupon_id,action,actor,request_id,changes,tenant_id\) VALUES \(\$1,\$2,\$3,\$4,\$5,\$6\),\(\$7,\$8,\$9,\$10,\$11,\$12\)`).
+				WillReturnResult(sqlmock.NewResult(0, 2))
+			dbMock.ExpectExec(`INSERT INTO coupon_versions .*`).
+				WithArgs("1", "1", name1, brand1, value1, nil, false, testTenant, "2", "2", name2, brand2, value2, nil, false, testTenant).
+				WillReturnResult(sqlmock.NewResult(0, 2))
+			dbMock.ExpectCommit()
+
+			Expect(mockedService.CreateCoupons(ctx, []coupon.Coupon{
+				{Name: &name1, Brand: &brand1, Value: &value1},
+				{Name: &name2, Brand: &brand2, Value: &value2},
+			})).To(Succeed())
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("does nothing when there are no coupons", func() {
+			Expect(mockedService.CreateCoupons(ctx, nil)).To(Succeed())
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("propagates the error", func() {
+			name, brand, value := "Save £1 at Lidl", "Lidl", 1
+
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery("INSERT INTO coupons .*").WillReturnError(errors.New("unique violation 🙈"))
+			dbMock.ExpectRollback()
+
+			err := mockedService.CreateCoupons(ctx, []coupon.Coupon{{Name: &name, Brand: &brand, Value: &value}})
+			Expect(err).To(MatchError("unique violation 🙈"))
+		})
+	})
+
 	Describe("UpdateCoupon", func() {
 		var (
 			expectedCoupon coupon.Coupon
@@ -98,13 +194,13 @@ var _ = Describe("Coupon Service", func() {
 				Value: &value,
 			}
 
-			updateQuery = `UPDATE coupons SET name = \$1, brand = \$2, value = \$3 WHERE id = \$4`
+			updateQuery = `UPDATE coupons SET name = \$1, brand = \$2, value = \$3 WHERE id = \$4 AND tenant_id = \$5`
 		})
 
 		It("successfully updates a coupon", func() {
 			var newlyCreatedId string
-			insertStatement := `INSERT INTO coupons (name, brand, value) VALUES ($1, $2, $3) RETURNING id`
-			Expect(realDB.QueryRow(insertStatement, "A namely coupon", "Asda", 41).Scan(&newlyCreatedId)).To(Succeed())
+			insertStatement := `INSERT INTO coupons (name, brand, value, tenant_id) VALUES ($1, $2, $3, $4) RETURNING id`
+			Expect(realDB.QueryRow(insertStatement, "A namely coupon", "Asda", 41, testTenant).Scan(&newlyCreatedId)).To(Succeed())
 
 			name := "A less namely coupon"
 			value := 41
@@ -115,7 +211,7 @@ var _ = Describe("Coupon Service", func() {
 				Value: &value,
 			}
 
-			Expect(realService.UpdateCoupon(couponToUpdate)).To(Succeed())
+			Expect(realService.UpdateCoupon(ctx, couponToUpdate)).To(Succeed())
 
 			capturedCoupon := coupon.Coupon{}
 			Expect(realDB.QueryRow("SELECT name, brand, value FROM coupons WHERE id = $1", newlyCreatedId).Scan(&capturedCoupon.Name, &capturedCoupon.Brand, &capturedCoupon.Value)).To(Succeed())
@@ -125,12 +221,72 @@ var _ = Describe("Coupon Service", func() {
 			Expect(*capturedCoupon.Value).To(Equal(*couponToUpdate.Value))
 		})
 
+		It("records the changed fields in the audit trail in the same transaction", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT id, name, brand, value, expiry FROM coupons WHERE id = \$1 AND tenant_id = \$2 FOR UPDATE`).
+				WithArgs(expectedCoupon.ID, testTenant).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}).
+					AddRow(expectedCoupon.ID, *expectedCoupon.Name, *expectedCoupon.Brand, 50, nil))
+			dbMock.ExpectExec(updateQuery).
+				WithArgs(*expectedCoupon.Name, *expectedCoupon.Brand, *expectedCoupon.Value, expectedCoupon.ID, testTenant).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			dbMock.ExpectExec(`INSERT INTO coupon_audit .*`).
+				WithArgs(expectedCoupon.ID, "update", "madeleine", "req-123", []byte(`{"value":{"before":50,"after":100}}`), testTenant).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			dbMock.ExpectExec(`INSERT INTO coupon_versions .*`).
+				WithArgs(expectedCoupon.ID, expectedCoupon.ID, *expectedCoupon.Name, *expectedCoupon.Brand, *expectedCoupon.Value, nil, false, testTenant).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			dbMock.ExpectCommit()
+
+			Expect(mockedService.UpdateCoupon(ctx, expectedCoupon)).To(Succeed())
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("sets the coupon's expiry, auditing the change", func() {
+			expiry := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+			expired := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT id, name, brand, value, expiry FROM coupons WHERE id = \$1 AND tenant_id = \$2 FOR UPDATE`).
+				WithArgs(expectedCoupon.ID, testTenant).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}).
+					AddRow(expectedCoupon.ID, *expectedCoupon.Name, *expectedCoupon.Brand, *expectedCoupon.Value, expiry))
+			dbMock.ExpectExec(`UPDATE coupons SET expiry = \$1 WHERE id = \$2 AND tenant_id = \$3`).
+				WithArgs(expired, expectedCoupon.ID, testTenant).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			dbMock.ExpectExec(`INSERT INTO coupon_audit .*`).
+				WithArgs(expectedCoupon.ID, "update", "madeleine", "req-123",
+					[]byte(`{"expiry":{"before":"2027-01-01T00:00:00Z","after":"2026-10-19T09:30:00Z"}}`), testTenant).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			dbMock.ExpectExec(`INSERT INTO coupon_versions .*`).WillReturnResult(sqlmock.NewResult(1, 1))
+			dbMock.ExpectCommit()
+
+			Expect(mockedService.UpdateCoupon(ctx, coupon.Coupon{ID: expectedCoupon.ID, Expiry: &expired})).To(Succeed())
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns sql.ErrNoRows if the coupon does not exist", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT id, name, brand, value, expiry FROM coupons WHERE id = \$1 AND tenant_id = \$2 FOR UPDATE`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}))
+			dbMock.ExpectRollback()
+
+			err := mockedService.UpdateCoupon(ctx, expectedCoupon)
+			Expect(err).To(MatchError(sql.ErrNoRows))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
 		It("propagates the error if exec fails", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT id, name, brand, value, expiry FROM coupons WHERE id = \$1 AND tenant_id = \$2 FOR UPDATE`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}).
+					AddRow(expectedCoupon.ID, *expectedCoupon.Name, *expectedCoupon.Brand, 50, nil))
 			dbMock.ExpectExec(updateQuery).
-				WithArgs(*expectedCoupon.Name, *expectedCoupon.Brand, *expectedCoupon.Value, expectedCoupon.ID).
+				WithArgs(*expectedCoupon.Name, *expectedCoupon.Brand, *expectedCoupon.Value, expectedCoupon.ID, testTenant).
 				WillReturnError(errors.New("oh dear 😭"))
+			dbMock.ExpectRollback()
 
-			err := mockedService.UpdateCoupon(expectedCoupon)
+			err := mockedService.UpdateCoupon(ctx, expectedCoupon)
 
 			Expect(err).To(MatchError(ContainSubstring("oh dear 😭")))
 			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
@@ -185,17 +341,18 @@ var _ = Describe("Coupon Service", func() {
 				&coupon3,
 			}
 
-			_, err := realDB.Exec("INSERT INTO coupons (id, name, brand, value) VALUES ($1, $2, $3, $4), ($5, $6, $7, $8), ($9, $10, $11, $12)",
+			_, err := realDB.Exec("INSERT INTO coupons (id, name, brand, value, tenant_id) VALUES ($1, $2, $3, $4, $13), ($5, $6, $7, $8, $13), ($9, $10, $11, $12, $13)",
 				expectedCoupons[0].ID, *expectedCoupons[0].Name, *expectedCoupons[0].Brand, *expectedCoupons[0].Value,
 				expectedCoupons[1].ID, *expectedCoupons[1].Name, *expectedCoupons[1].Brand, *expectedCoupons[1].Value,
-				expectedCoupons[2].ID, *expectedCoupons[2].Name, *expectedCoupons[2].Brand, *expectedCoupons[2].Value)
+				expectedCoupons[2].ID, *expectedCoupons[2].Name, *expectedCoupons[2].Brand, *expectedCoupons[2].Value,
+				testTenant)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
 		It("successfully retrieves coupons with no filter", func() {
 			queryParams := handlers.Filters{}
 
-			coupons, err := realService.GetCoupons(queryParams)
+			coupons, err := realService.GetCoupons(ctx, queryParams)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(coupons).To(Equal(expectedCoupons))
 		})
@@ -203,7 +360,7 @@ var _ = Describe("Coupon Service", func() {
 		It("successfully retrieves coupons with `brand` filter", func() {
 			expectedBrand := "Tom's"
 
-			coupons, err := realService.GetCoupons(handlers.Filters{
+			coupons, err := realService.GetCoupons(ctx, handlers.Filters{
 				Brand: &expectedBrand,
 			})
 			Expect(err).NotTo(HaveOccurred())
@@ -216,7 +373,7 @@ var _ = Describe("Coupon Service", func() {
 		It("successfully retrieves coupons with `value` filter", func() {
 			expectedValue := 30
 
-			coupons, err := realService.GetCoupons(handlers.Filters{
+			coupons, err := realService.GetCoupons(ctx, handlers.Filters{
 				Value: &expectedValue,
 			})
 			Expect(err).NotTo(HaveOccurred())
@@ -228,7 +385,7 @@ var _ = Describe("Coupon Service", func() {
 		It("successfully retrieves coupons with `name` filter", func() {
 			expectedName := "Save £30 at Tom's Supermercado"
 
-			coupons, err := realService.GetCoupons(handlers.Filters{
+			coupons, err := realService.GetCoupons(ctx, handlers.Filters{
 				Name: &expectedName,
 			})
 			Expect(err).NotTo(HaveOccurred())
@@ -241,7 +398,7 @@ var _ = Describe("Coupon Service", func() {
 			expectedBrand := "Tom's"
 			expectedValue := 30
 
-			coupons, err := realService.GetCoupons(handlers.Filters{
+			coupons, err := realService.GetCoupons(ctx, handlers.Filters{
 				Brand: &expectedBrand,
 				Value: &expectedValue,
 			})
@@ -252,11 +409,43 @@ var _ = Describe("Coupon Service", func() {
 			Expect(*coupons[0].Value).To(Equal(30))
 		})
 
+		It("pages through coupons in id order", func() {
+			limit := 2
+
+			coupons, err := realService.GetCoupons(ctx, handlers.Filters{Limit: &limit})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coupons).To(Equal(expectedCoupons[:2]))
+
+			coupons, err = realService.GetCoupons(ctx, handlers.Filters{After: &coupons[1].ID, Limit: &limit})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coupons).To(Equal(expectedCoupons[2:]))
+		})
+
+		It("asks for a page after the given id, in id order", func() {
+			after := "354403f0-1c0e-11e9-9142-134e17ba9a5f"
+			limit := 2
+
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT id, name, brand, value, expiry FROM coupons WHERE tenant_id = \$1 AND id > \$2 ORDER BY id LIMIT 2`).
+				WithArgs(testTenant, after).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value"}).
+					AddRow("c614eeaa-1c9d-11e9-8c4f-3f7c43a05026", "Save £20 at Tom's Supermercado", "Tom's", 20))
+			dbMock.ExpectCommit()
+
+			coupons, err := mockedService.GetCoupons(ctx, handlers.Filters{After: &after, Limit: &limit})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coupons).To(HaveLen(1))
+
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
 		It("propagates the error if querying the db fails", func() {
-			dbMock.ExpectQuery("SELECT id, name, brand, value FROM coupons").WillReturnError(errors.New("boo 👻"))
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery("SELECT id, name, brand, value, expiry FROM coupons").WillReturnError(errors.New("boo 👻"))
+			dbMock.ExpectRollback()
 			queryParams := handlers.Filters{}
 
-			_, err := mockedService.GetCoupons(queryParams)
+			_, err := mockedService.GetCoupons(ctx, queryParams)
 			Expect(err).To(MatchError("boo 👻"))
 
 			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
@@ -265,37 +454,115 @@ var _ = Describe("Coupon Service", func() {
 		It("propagates the error if no rows are found", func() {
 			queryParams := handlers.Filters{}
 
-			rows := sqlmock.NewRows([]string{"id", "name", "brand", "value"})
-			dbMock.ExpectQuery("SELECT id, name, brand, value FROM coupons").WillReturnRows(rows)
+			rows := sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"})
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery("SELECT id, name, brand, value, expiry FROM coupons").WillReturnRows(rows)
+			dbMock.ExpectCommit()
 
-			_, err := mockedService.GetCoupons(queryParams)
+			_, err := mockedService.GetCoupons(ctx, queryParams)
 			Expect(err).To(MatchError("sql: no rows in result set"))
 
 			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
 		})
 
 		It("propagates the error if scanning to the struct fails", func() {
-			dbMock.ExpectQuery("SELECT id, name, brand, value FROM coupons").WillReturnRows(
-				sqlmock.NewRows([]string{"id", "name", "brand", "value"}).
-					AddRow(nil, nil, nil, nil))
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery("SELECT id, name, brand, value, expiry FROM coupons").WillReturnRows(
+				sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}).
+					AddRow(nil, nil, nil, nil, nil))
+			dbMock.ExpectRollback()
 
 			queryParams := handlers.Filters{}
 
-			_, err := mockedService.GetCoupons(queryParams)
+			_, err := mockedService.GetCoupons(ctx, queryParams)
 			Expect(err).To(HaveOccurred())
 
 			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
 		})
 	})
 
+	Describe("StreamCoupons", func() {
+		It("streams every matching coupon", func() {
+			insertStatement := `INSERT INTO coupons (name, brand, value, tenant_id) VALUES ($1, $2, $3, $4)`
+			for i := 0; i < 1100; i++ {
+				_, err := realDB.Exec(insertStatement, "Bulk coupon", "Costco", i%3, testTenant)
+				Expect(err).NotTo(HaveOccurred())
+			}
+
+			expectedValue := 1
+			var streamedCoupons []*coupon.Coupon
+
+			err := realService.StreamCoupons(ctx, handlers.Filters{Value: &expectedValue}, func(couponInstance *coupon.Coupon) error {
+				streamedCoupons = append(streamedCoupons, couponInstance)
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(streamedCoupons).To(HaveLen(367))
+			for _, couponInstance := range streamedCoupons {
+				Expect(*couponInstance.Value).To(Equal(1))
+			}
+		})
+
+		It("fetches from a cursor declared with the filtered query", func() {
+			expectedBrand := "Costco"
+
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectExec(`DECLARE coupon_export_[0-9a-f]{32} NO SCROLL CURSOR FOR SELECT id, name, brand, value, expiry FROM coupons WHERE tenant_id = \$1 AND brand = \$2`).
+				WithArgs(testTenant, expectedBrand).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			dbMock.ExpectQuery(`FETCH 500 FROM coupon_export_[0-9a-f]{32}`).WillReturnRows(
+				sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}).
+					AddRow("1", "Bulk coupon", "Costco", 1, nil).
+					AddRow("2", "Bulk coupon", "Costco", 2, nil))
+			dbMock.ExpectExec(`CLOSE coupon_export_[0-9a-f]{32}`).WillReturnResult(sqlmock.NewResult(0, 0))
+			dbMock.ExpectCommit()
+
+			var streamedIds []string
+			err := mockedService.StreamCoupons(ctx, handlers.Filters{Brand: &expectedBrand}, func(couponInstance *coupon.Coupon) error {
+				streamedIds = append(streamedIds, couponInstance.ID)
+				return nil
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(streamedIds).To(Equal([]string{"1", "2"}))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("stops and rolls back if the callback fails", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectExec(`DECLARE coupon_export_[0-9a-f]{32} .*`).WillReturnResult(sqlmock.NewResult(0, 0))
+			dbMock.ExpectQuery(`FETCH 500 FROM coupon_export_[0-9a-f]{32}`).WillReturnRows(
+				sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}).
+					AddRow("1", "Bulk coupon", "Costco", 1, nil))
+			dbMock.ExpectRollback()
+
+			err := mockedService.StreamCoupons(ctx, handlers.Filters{}, func(couponInstance *coupon.Coupon) error {
+				return errors.New("client went away")
+			})
+			Expect(err).To(MatchError("client went away"))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("propagates the error if the cursor cannot be declared", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectExec(`DECLARE coupon_export_[0-9a-f]{32} .*`).WillReturnError(errors.New("syntax error 🤓"))
+			dbMock.ExpectRollback()
+
+			err := mockedService.StreamCoupons(ctx, handlers.Filters{}, func(couponInstance *coupon.Coupon) error {
+				return nil
+			})
+			Expect(err).To(MatchError("syntax error 🤓"))
+		})
+	})
+
 	Describe("GetCouponById", func() {
 		It("successfully retrieves a coupon", func() {
 			var couponId string
 
-			insertStatement := `INSERT INTO coupons (name, brand, value) VALUES ($1, $2, $3) RETURNING id`
-			Expect(realDB.QueryRow(insertStatement, "Save some money", "Accessorize", 10).Scan(&couponId)).To(Succeed())
+			insertStatement := `INSERT INTO coupons (name, brand, value, tenant_id) VALUES ($1, $2, $3, $4) RETURNING id`
+			Expect(realDB.QueryRow(insertStatement, "Save some money", "Accessorize", 10, testTenant).Scan(&couponId)).To(Succeed())
 
-			retrievedCoupon, err := realService.GetCouponById(couponId)
+			retrievedCoupon, err := realService.GetCouponById(ctx, couponId)
 			Expect(err).ToNot(HaveOccurred())
 
 			Expect(*retrievedCoupon.Name).To(Equal("Save some money"))
@@ -303,11 +570,244 @@ var _ = Describe("Coupon Service", func() {
 			Expect(*retrievedCoupon.Value).To(Equal(10))
 		})
 
+		It("retrieves when the coupon expires", func() {
+			var couponId string
+			expiry := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			insertStatement := `INSERT INTO coupons (name, brand, value, expiry, tenant_id) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+			Expect(realDB.QueryRow(insertStatement, "Save some money", "Accessorize", 10, expiry, testTenant).Scan(&couponId)).To(Succeed())
+
+			retrievedCoupon, err := realService.GetCouponById(ctx, couponId)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*retrievedCoupon.Expiry).To(BeTemporally("==", expiry))
+		})
+
 		It("propagates the error if QueryRow/ scanning fails", func() {
-			dbMock.ExpectQuery(`SELECT id, name, brand, value .*`).WillReturnError(sql.ErrNoRows)
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT id, name, brand, value, expiry .*`).WillReturnError(sql.ErrNoRows)
+			dbMock.ExpectRollback()
+
+			_, err := mockedService.GetCouponById(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159")
+			Expect(err).To(MatchError(sql.ErrNoRows))
+		})
+
+		It("reports a query cancelled by the request's deadline as the deadline", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT id, name, brand, value, expiry .*`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"})).
+				WillDelayFor(time.Second)
+
+			deadlineCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+			defer cancel()
+
+			_, err := mockedService.GetCouponById(deadlineCtx, "0faec7ea-239f-11e9-9e44-d770694a0159")
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+		})
+
+		It("returns sql.ErrNoRows for ids that can't be a coupon's, rather than failing the query", func() {
+			_, err := mockedService.GetCouponById(ctx, "BOOTS-SAVE5")
+			Expect(err).To(MatchError(sql.ErrNoRows))
+
+			_, err = realService.GetCouponById(ctx, "BOOTS-SAVE5")
+			Expect(err).To(MatchError(sql.ErrNoRows))
+
+			Expect(mockedService.UpdateCoupon(ctx, coupon.Coupon{ID: "BOOTS-SAVE5"})).To(MatchError(sql.ErrNoRows))
+			Expect(mockedService.DeleteCoupon(ctx, "BOOTS-SAVE5")).To(MatchError(sql.ErrNoRows))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Describe("DeleteCoupon", func() {
+		It("successfully deletes a coupon", func() {
+			var couponId string
 
-			_, err := mockedService.GetCouponById("123")
+			insertStatement := `INSERT INTO coupons (name, brand, value, tenant_id) VALUES ($1, $2, $3, $4) RETURNING id`
+			Expect(realDB.QueryRow(insertStatement, "Free delivery", "Ocado", 5, testTenant).Scan(&couponId)).To(Succeed())
+
+			Expect(realService.DeleteCoupon(ctx, couponId)).To(Succeed())
+
+			var count int
+			Expect(realDB.QueryRow("SELECT COUNT(*) FROM coupons WHERE id = $1", couponId).Scan(&count)).To(Succeed())
+			Expect(count).To(Equal(0))
+		})
+
+		It("returns sql.ErrNoRows if the coupon does not exist", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`DELETE FROM coupons WHERE id = \$1 AND tenant_id = \$2 RETURNING id, name, brand, value, expiry`).
+				WithArgs("0faec7ea-239f-11e9-9e44-d770694a0159", testTenant).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}))
+			dbMock.ExpectRollback()
+
+			err := mockedService.DeleteCoupon(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159")
+			Expect(err).To(MatchError(sql.ErrNoRows))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("propagates the error if exec fails", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`DELETE FROM coupons .*`).WillReturnError(errors.New("nope 🙅"))
+			dbMock.ExpectRollback()
+
+			err := mockedService.DeleteCoupon(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159")
+			Expect(err).To(MatchError("nope 🙅"))
+		})
+	})
+
+	Describe("WithinTransaction", func() {
+		It("rolls back every change if one of them fails", func() {
+			name := "Half price pizza"
+			brand := "Pizza Hut"
+			value := 50
+
+			err := realService.WithinTransaction(ctx, func(txService handlers.CouponService) error {
+				_, err := txService.CreateCoupon(ctx, coupon.Coupon{Name: &name, Brand: &brand, Value: &value})
+				Expect(err).NotTo(HaveOccurred())
+
+				return txService.DeleteCoupon(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159")
+			})
 			Expect(err).To(MatchError(sql.ErrNoRows))
+
+			var count int
+			Expect(realDB.QueryRow("SELECT COUNT(*) FROM coupons").Scan(&count)).To(Succeed())
+			Expect(count).To(Equal(0))
+		})
+
+		It("runs the callback's queries on the transaction and commits", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`DELETE FROM coupons .*`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}).AddRow("0faec7ea-239f-11e9-9e44-d770694a0159", "Half price pizza", "Pizza Hut", 50, nil))
+			dbMock.ExpectExec(`INSERT INTO coupon_audit .*`).
+				WithArgs("0faec7ea-239f-11e9-9e44-d770694a0159", "delete", "madeleine", "req-123", sqlmock.AnyArg(), testTenant).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			dbMock.ExpectExec(`INSERT INTO coupon_versions .*`).
+				WithArgs("0faec7ea-239f-11e9-9e44-d770694a0159", "0faec7ea-239f-11e9-9e44-d770694a0159", nil, nil, nil, nil, true, testTenant).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			dbMock.ExpectCommit()
+
+			err := mockedService.WithinTransaction(ctx, func(txService handlers.CouponService) error {
+				return txService.DeleteCoupon(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159")
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("rolls back if the callback fails", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectRollback()
+
+			err := mockedService.WithinTransaction(ctx, func(txService handlers.CouponService) error {
+				return errors.New("changed my mind 🤷")
+			})
+			Expect(err).To(MatchError("changed my mind 🤷"))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("propagates the error if the transaction cannot be started", func() {
+			dbMock.ExpectBegin().WillReturnError(errors.New("too many connections"))
+
+			err := mockedService.WithinTransaction(ctx, func(txService handlers.CouponService) error {
+				Fail("callback should not be called")
+				return nil
+			})
+			Expect(err).To(MatchError("too many connections"))
+		})
+	})
+
+	Describe("tenant isolation", func() {
+		It("filters every query by the tenant on the context", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT id, name, brand, value, expiry FROM coupons WHERE id = \$1 AND tenant_id = \$2`).
+				WithArgs("0faec7ea-239f-11e9-9e44-d770694a0159", testTenant).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}))
+			dbMock.ExpectRollback()
+
+			_, err := mockedService.GetCouponById(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159")
+			Expect(err).To(MatchError(sql.ErrNoRows))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("refuses to run without a tenant", func() {
+			_, err := mockedService.GetCoupons(requestcontext.WithActor(context.Background(), "madeleine"), handlers.Filters{})
+			Expect(err).To(MatchError(dbservices.ErrNoTenant))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		Context("when another tenant has coupons", func() {
+			var (
+				otherTenantCtx    context.Context
+				otherTenantCoupon *coupon.Coupon
+			)
+
+			BeforeEach(func() {
+				otherTenantCtx = requestcontext.WithTenant(ctx, "tesco")
+
+				name := "Clubcard prices"
+				brand := "Tesco"
+				value := 15
+
+				var err error
+				otherTenantCoupon, err = realService.CreateCoupon(otherTenantCtx, coupon.Coupon{Name: &name, Brand: &brand, Value: &value})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("never returns another tenant's coupons", func() {
+				_, err := realService.GetCouponById(ctx, otherTenantCoupon.ID)
+				Expect(err).To(MatchError(sql.ErrNoRows))
+
+				_, err = realService.GetCoupons(ctx, handlers.Filters{})
+				Expect(err).To(MatchError("sql: no rows in result set"))
+
+				err = realService.StreamCoupons(ctx, handlers.Filters{}, func(*coupon.Coupon) error {
+					Fail("no coupons should be streamed")
+					return nil
+				})
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = realService.GetCouponVersion(ctx, otherTenantCoupon.ID, 1)
+				Expect(err).To(MatchError(sql.ErrNoRows))
+
+				history, err := dbservices.CouponAuditService{DB: realDB}.GetCouponHistory(ctx, otherTenantCoupon.ID)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(history).To(BeEmpty())
+			})
+
+			It("never changes another tenant's coupons", func() {
+				newValue := 0
+				Expect(realService.UpdateCoupon(ctx, coupon.Coupon{ID: otherTenantCoupon.ID, Value: &newValue})).To(MatchError(sql.ErrNoRows))
+				Expect(realService.DeleteCoupon(ctx, otherTenantCoupon.ID)).To(MatchError(sql.ErrNoRows))
+
+				unchangedCoupon, err := realService.GetCouponById(otherTenantCtx, otherTenantCoupon.ID)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(unchangedCoupon).To(Equal(otherTenantCoupon))
+			})
+
+			It("is enforced by row-level security even without the service's filters", func() {
+				// superusers, like the one the tests connect as, bypass row-level security, so switch to a role that doesn't
+				_, err := realDB.Exec(`DO $$ BEGIN
+					CREATE ROLE coupons_rls_test NOLOGIN;
+				EXCEPTION WHEN duplicate_object THEN NULL;
+				END $$`)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = realDB.Exec("GRANT SELECT, INSERT ON coupons TO coupons_rls_test")
+				Expect(err).NotTo(HaveOccurred())
+
+				tx, err := realDB.Begin()
+				Expect(err).NotTo(HaveOccurred())
+				defer tx.Rollback()
+
+				_, err = tx.Exec("SET LOCAL ROLE coupons_rls_test")
+				Expect(err).NotTo(HaveOccurred())
+				_, err = tx.Exec("SELECT set_config('app.tenant_id', $1, true)", testTenant)
+				Expect(err).NotTo(HaveOccurred())
+
+				var count int
+				Expect(tx.QueryRow("SELECT COUNT(*) FROM coupons").Scan(&count)).To(Succeed())
+				Expect(count).To(Equal(0))
+
+				_, err = tx.Exec("INSERT INTO coupons (name, brand, value, tenant_id) VALUES ('Sneaky', 'Tesco', 1, 'tesco')")
+				Expect(err).To(MatchError(ContainSubstring("row-level security")))
+			})
+
 		})
 	})
 })
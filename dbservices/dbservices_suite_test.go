@@ -6,8 +6,12 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	_ "github.com/lib/pq"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
 
+// testTenant is the tenant every spec runs as, unless it's checking isolation from another one
+const testTenant = "sainsburys"
+
 var realDB *sql.DB
 
 func TestDbservices(t *testing.T) {
@@ -28,7 +32,7 @@ var _ = AfterEach(func() {
 })
 
 func cleanDB() {
-	_, err := realDB.Exec("TRUNCATE TABLE coupons")
+	_, err := realDB.Exec("TRUNCATE TABLE coupons, coupon_audit, coupon_versions, redemptions, api_keys, idempotency_keys, rate_limit_buckets, lookup_failures, lockouts")
 	Expect(err).NotTo(HaveOccurred())
 }
 
@@ -39,4 +43,10 @@ func initializeDb() *sql.DB {
 	Expect(err).NotTo(HaveOccurred())
 
 	return db
-}
\ No newline at end of file
+}
+func expectTenantTransaction(dbMock sqlmock.Sqlmock) {
+	dbMock.ExpectBegin()
+	dbMock.ExpectExec(`SELECT set_config\('app.tenant_id', \$1, true\)`).
+		WithArgs(testTenant).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
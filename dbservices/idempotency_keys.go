@@ -0,0 +1,156 @@
+package dbservices
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/Masterminds/squirrel"
+	"github.com/madeleinesmith/coupons/idempotency"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"time"
+)
+
+// IdempotencyKeyService stores each tenant's idempotency keys along with the response to their first request
+type IdempotencyKeyService struct {
+	DB *sql.DB
+}
+
+// Reserve takes over an expired key rather than failing on it, so the expiry doesn't rely on DeleteExpired running.
+// It also takes over keys left in progress for longer than lease, as their request will never finish with them.
+func (s IdempotencyKeyService) Reserve(ctx context.Context, key string, ttl time.Duration, lease time.Duration) (bool, error) {
+	tenant := requestcontext.Tenant(ctx)
+	if tenant == "" {
+		return false, ErrNoTenant
+	}
+
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Insert("idempotency_keys").
+		Columns("tenant_id", "key", "expires_at").
+		Values(tenant, key, squirrel.Expr("current_timestamp + ? * interval '1 second'", ttl.Seconds())).
+		Suffix("ON CONFLICT (tenant_id, key) DO UPDATE SET "+
+			"expires_at = EXCLUDED.expires_at, created_at = current_timestamp, "+
+			"fingerprint = NULL, status_code = NULL, headers = NULL, body = NULL "+
+			"WHERE idempotency_keys.expires_at <= current_timestamp OR (idempotency_keys.status_code IS NULL AND "+
+			"idempotency_keys.created_at <= current_timestamp - ? * interval '1 second')", lease.Seconds()).
+		ToSql()
+
+	if err != nil {
+		return false, err
+	}
+
+	result, err := traced(s.DB).ExecContext(ctx, dbQuery, args...)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rowsAffected == 1, nil
+}
+
+func (s IdempotencyKeyService) Find(ctx context.Context, key string) (*idempotency.Entry, error) {
+	tenant := requestcontext.Tenant(ctx)
+	if tenant == "" {
+		return nil, ErrNoTenant
+	}
+
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Select("fingerprint", "status_code", "headers", "body").
+		From("idempotency_keys").
+		Where(squirrel.Eq{"key": key, "tenant_id": tenant}).
+		Where("expires_at > current_timestamp").
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var entry idempotency.Entry
+	var statusCode sql.NullInt64
+	var headers []byte
+	var body []byte
+
+	err = traced(s.DB).QueryRowContext(ctx, dbQuery, args...).Scan(&entry.Fingerprint, &statusCode, &headers, &body)
+	if err != nil {
+		return nil, err
+	}
+
+	if !statusCode.Valid {
+		return &entry, nil
+	}
+
+	entry.Response = &idempotency.Response{StatusCode: int(statusCode.Int64), Body: body}
+
+	err = json.Unmarshal(headers, &entry.Response.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (s IdempotencyKeyService) Complete(ctx context.Context, key string, fingerprint []byte, response idempotency.Response) error {
+	tenant := requestcontext.Tenant(ctx)
+	if tenant == "" {
+		return ErrNoTenant
+	}
+
+	headers, err := json.Marshal(response.Header)
+	if err != nil {
+		return err
+	}
+
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Update("idempotency_keys").
+		Set("fingerprint", fingerprint).
+		Set("status_code", response.StatusCode).
+		Set("headers", headers).
+		Set("body", response.Body).
+		Where(squirrel.Eq{"key": key, "tenant_id": tenant}).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = traced(s.DB).ExecContext(ctx, dbQuery, args...)
+
+	return err
+}
+
+func (s IdempotencyKeyService) Release(ctx context.Context, key string) error {
+	tenant := requestcontext.Tenant(ctx)
+	if tenant == "" {
+		return ErrNoTenant
+	}
+
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Delete("idempotency_keys").
+		Where(squirrel.Eq{"key": key, "tenant_id": tenant}).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = traced(s.DB).ExecContext(ctx, dbQuery, args...)
+
+	return err
+}
+
+// DeleteExpired clears out the expired keys of every tenant
+func (s IdempotencyKeyService) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := traced(s.DB).ExecContext(ctx, "DELETE FROM idempotency_keys WHERE expires_at <= current_timestamp")
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
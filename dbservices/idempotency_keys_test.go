@@ -0,0 +1,157 @@
+package dbservices_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/madeleinesmith/coupons/dbservices"
+	"github.com/madeleinesmith/coupons/idempotency"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"net/http"
+	"time"
+)
+
+var _ = Describe("Idempotency Key Service", func() {
+	var (
+		mockedService dbservices.IdempotencyKeyService
+		dbMock        sqlmock.Sqlmock
+		realService   dbservices.IdempotencyKeyService
+		ctx           context.Context
+	)
+
+	BeforeEach(func() {
+		var db *sql.DB
+		var err error
+
+		db, dbMock, err = sqlmock.New()
+		Expect(err).NotTo(HaveOccurred())
+
+		mockedService = dbservices.IdempotencyKeyService{
+			DB: db,
+		}
+
+		realService = dbservices.IdempotencyKeyService{
+			DB: realDB,
+		}
+
+		ctx = requestcontext.WithTenant(context.Background(), testTenant)
+	})
+
+	It("reserves a key once, then returns its stored response", func() {
+		reserved, err := realService.Reserve(ctx, "order-123", time.Hour, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reserved).To(BeTrue())
+
+		reserved, err = realService.Reserve(ctx, "order-123", time.Hour, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reserved).To(BeFalse())
+
+		entry, err := realService.Find(ctx, "order-123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entry).To(Equal(&idempotency.Entry{}))
+
+		response := idempotency.Response{
+			StatusCode: http.StatusCreated,
+			Header:     http.Header{"Content-Type": {"application/vnd.api+json"}},
+			Body:       []byte(`{"data":{"id":"1"}}`),
+		}
+		Expect(realService.Complete(ctx, "order-123", []byte("fingerprint"), response)).To(Succeed())
+
+		entry, err = realService.Find(ctx, "order-123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entry).To(Equal(&idempotency.Entry{Fingerprint: []byte("fingerprint"), Response: &response}))
+	})
+
+	It("keeps each tenant's keys apart", func() {
+		reserved, err := realService.Reserve(ctx, "order-123", time.Hour, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reserved).To(BeTrue())
+
+		otherTenantCtx := requestcontext.WithTenant(context.Background(), "tesco")
+
+		_, err = realService.Find(otherTenantCtx, "order-123")
+		Expect(err).To(MatchError(sql.ErrNoRows))
+
+		reserved, err = realService.Reserve(otherTenantCtx, "order-123", time.Hour, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reserved).To(BeTrue())
+	})
+
+	It("lets expired keys be reused, and deletes them", func() {
+		_, err := realService.Reserve(ctx, "order-123", time.Hour, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = realDB.Exec("UPDATE idempotency_keys SET expires_at = current_timestamp - interval '1 second'")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = realService.Find(ctx, "order-123")
+		Expect(err).To(MatchError(sql.ErrNoRows))
+
+		deleted, err := realService.DeleteExpired(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleted).To(Equal(int64(1)))
+
+		_, err = realService.Reserve(ctx, "order-456", time.Hour, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(realService.Complete(ctx, "order-456", []byte("old"), idempotency.Response{StatusCode: http.StatusCreated})).To(Succeed())
+		_, err = realDB.Exec("UPDATE idempotency_keys SET expires_at = current_timestamp - interval '1 second'")
+		Expect(err).NotTo(HaveOccurred())
+
+		reserved, err := realService.Reserve(ctx, "order-456", time.Hour, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reserved).To(BeTrue())
+
+		entry, err := realService.Find(ctx, "order-456")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entry).To(Equal(&idempotency.Entry{}))
+	})
+
+	It("takes over a key left in progress once its lease has run out", func() {
+		_, err := realService.Reserve(ctx, "order-123", time.Hour, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+
+		reserved, err := realService.Reserve(ctx, "order-123", time.Hour, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reserved).To(BeFalse())
+
+		_, err = realDB.Exec("UPDATE idempotency_keys SET created_at = current_timestamp - interval '2 minutes'")
+		Expect(err).NotTo(HaveOccurred())
+
+		reserved, err = realService.Reserve(ctx, "order-123", time.Hour, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reserved).To(BeTrue())
+	})
+
+	Describe("Reserve", func() {
+		It("only takes over an existing key once it has expired, or its lease has run out", func() {
+			dbMock.ExpectExec(`INSERT INTO idempotency_keys \(tenant_id,key,expires_at\) VALUES \(\$1,\$2,current_timestamp \+ \$3 \* interval '1 second'\) ON CONFLICT \(tenant_id, key\) DO UPDATE SET .* WHERE idempotency_keys.expires_at <= current_timestamp OR \(idempotency_keys.status_code IS NULL AND idempotency_keys.created_at <= current_timestamp - \$4 \* interval '1 second'\)`).
+				WithArgs(testTenant, "order-123", float64(3600), float64(60)).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+
+			reserved, err := mockedService.Reserve(ctx, "order-123", time.Hour, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(reserved).To(BeFalse())
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("refuses to run without a tenant", func() {
+			_, err := mockedService.Reserve(context.Background(), "order-123", time.Hour, time.Minute)
+			Expect(err).To(MatchError(dbservices.ErrNoTenant))
+		})
+	})
+
+	Describe("Find", func() {
+		It("returns an entry without a response while the request is in progress", func() {
+			dbMock.ExpectQuery(`SELECT fingerprint, status_code, headers, body FROM idempotency_keys WHERE key = \$1 AND tenant_id = \$2 AND expires_at > current_timestamp`).
+				WithArgs("order-123", testTenant).
+				WillReturnRows(sqlmock.NewRows([]string{"fingerprint", "status_code", "headers", "body"}).
+					AddRow(nil, nil, nil, nil))
+
+			entry, err := mockedService.Find(ctx, "order-123")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entry).To(Equal(&idempotency.Entry{}))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,138 @@
+package dbservices
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+	"time"
+)
+
+// LockoutService records failed coupon lookups and the lockouts they lead to
+type LockoutService struct {
+	DB *sql.DB
+}
+
+func (s LockoutService) RecordFailure(ctx context.Context, actor string, identifier string) error {
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Insert("lookup_failures").
+		Columns("actor", "identifier").
+		Values(actor, identifier).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = traced(s.DB).ExecContext(ctx, dbQuery, args...)
+
+	return err
+}
+
+func (s LockoutService) FailuresSince(ctx context.Context, actor string, since time.Time) ([]string, error) {
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Select("identifier").
+		From("lookup_failures").
+		Where(squirrel.Eq{"actor": actor}).
+		Where(squirrel.GtOrEq{"created_at": since}).
+		OrderBy("created_at", "id").
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := traced(s.DB).QueryContext(ctx, dbQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var identifiers []string
+
+	for rows.Next() {
+		var identifier string
+
+		err := rows.Scan(&identifier)
+		if err != nil {
+			return nil, err
+		}
+
+		identifiers = append(identifiers, identifier)
+	}
+
+	return identifiers, rows.Err()
+}
+
+func (s LockoutService) Lock(ctx context.Context, actor string, until time.Time) error {
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Insert("lockouts").
+		Columns("actor", "locked_until").
+		Values(actor, until).
+		ToSql()
+
+	if err != nil {
+		return err
+	}
+
+	_, err = traced(s.DB).ExecContext(ctx, dbQuery, args...)
+
+	return err
+}
+
+func (s LockoutService) LockedUntil(ctx context.Context, actor string) (time.Time, error) {
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Select("MAX(locked_until)").
+		From("lockouts").
+		Where(squirrel.Eq{"actor": actor}).
+		ToSql()
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var lockedUntil pq.NullTime
+
+	err = traced(s.DB).QueryRowContext(ctx, dbQuery, args...).Scan(&lockedUntil)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return lockedUntil.Time, nil
+}
+
+func (s LockoutService) CountLockouts(ctx context.Context, actor string, since time.Time) (int, error) {
+	dbQuery, args, err := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Select("COUNT(*)").
+		From("lockouts").
+		Where(squirrel.Eq{"actor": actor}).
+		Where(squirrel.GtOrEq{"created_at": since}).
+		ToSql()
+
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = traced(s.DB).QueryRowContext(ctx, dbQuery, args...).Scan(&count)
+
+	return count, err
+}
+
+// DeleteBefore clears out failures and finished lockouts from before the given time, which should be longer ago
+// than both the lockout policy's window and the day over which lockouts escalate
+func (s LockoutService) DeleteBefore(ctx context.Context, before time.Time) error {
+	_, err := traced(s.DB).ExecContext(ctx, "DELETE FROM lookup_failures WHERE created_at < $1", before)
+	if err != nil {
+		return err
+	}
+
+	_, err = traced(s.DB).ExecContext(ctx, "DELETE FROM lockouts WHERE locked_until < $1 AND created_at < $1", before)
+
+	return err
+}
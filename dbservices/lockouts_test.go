@@ -0,0 +1,94 @@
+package dbservices_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/madeleinesmith/coupons/dbservices"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"time"
+)
+
+var _ = Describe("Lockout Service", func() {
+	var (
+		mockedService dbservices.LockoutService
+		dbMock        sqlmock.Sqlmock
+		realService   dbservices.LockoutService
+		ctx           context.Context
+	)
+
+	BeforeEach(func() {
+		var db *sql.DB
+		var err error
+
+		db, dbMock, err = sqlmock.New()
+		Expect(err).NotTo(HaveOccurred())
+
+		mockedService = dbservices.LockoutService{
+			DB: db,
+		}
+
+		realService = dbservices.LockoutService{
+			DB: realDB,
+		}
+
+		ctx = context.Background()
+	})
+
+	It("returns an actor's failures in order, and their latest lockout", func() {
+		start := time.Now().Add(-time.Minute)
+
+		Expect(realService.RecordFailure(ctx, "ip:203.0.113.7", "SAVE0001")).To(Succeed())
+		Expect(realService.RecordFailure(ctx, "ip:203.0.113.7", "SAVE0002")).To(Succeed())
+		Expect(realService.RecordFailure(ctx, "ip:198.51.100.1", "SAVE0003")).To(Succeed())
+
+		failures, err := realService.FailuresSince(ctx, "ip:203.0.113.7", start)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(failures).To(Equal([]string{"SAVE0001", "SAVE0002"}))
+
+		lockedUntil, err := realService.LockedUntil(ctx, "ip:203.0.113.7")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lockedUntil.IsZero()).To(BeTrue())
+
+		until := time.Now().Add(time.Minute).Truncate(time.Microsecond)
+		Expect(realService.Lock(ctx, "ip:203.0.113.7", until.Add(-30*time.Second))).To(Succeed())
+		Expect(realService.Lock(ctx, "ip:203.0.113.7", until)).To(Succeed())
+
+		lockedUntil, err = realService.LockedUntil(ctx, "ip:203.0.113.7")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lockedUntil.Equal(until)).To(BeTrue())
+
+		lockouts, err := realService.CountLockouts(ctx, "ip:203.0.113.7", start)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(lockouts).To(Equal(2))
+	})
+
+	Describe("FailuresSince", func() {
+		It("only returns the actor's failures since the given time", func() {
+			since := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+			dbMock.ExpectQuery(`SELECT identifier FROM lookup_failures WHERE actor = \$1 AND created_at >= \$2 ORDER BY created_at, id`).
+				WithArgs("principal:key-1", since).
+				WillReturnRows(sqlmock.NewRows([]string{"identifier"}).AddRow("SAVE0001").AddRow("SAVE0002"))
+
+			failures, err := mockedService.FailuresSince(ctx, "principal:key-1", since)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(failures).To(Equal([]string{"SAVE0001", "SAVE0002"}))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+
+	Describe("LockedUntil", func() {
+		It("returns the zero time for actors who've never been locked out", func() {
+			dbMock.ExpectQuery(`SELECT MAX\(locked_until\) FROM lockouts WHERE actor = \$1`).
+				WithArgs("principal:key-1").
+				WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(nil))
+
+			lockedUntil, err := mockedService.LockedUntil(ctx, "principal:key-1")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lockedUntil.IsZero()).To(BeTrue())
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,387 @@
+package dbservices
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationLockID keys the advisory lock held while migrating, so two instances started at once take turns. Any
+// number works, as long as nothing else locks it.
+const migrationLockID = 6584
+
+var migrationFilePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a pair of files in db/migrations, such as 0001_create_coupons_table.up.sql and its .down.sql
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+	Dirty     bool
+}
+
+// DirtyMigrationError means a migration was started but never recorded as finished or rolled back, so nothing else
+// is run until someone has looked at the database
+type DirtyMigrationError struct {
+	Version int
+}
+
+func (e DirtyMigrationError) Error() string {
+	return fmt.Sprintf("migration %04d is dirty, as a previous run stopped part way through it. Check the database, "+
+		"then record which migrations it has with `coupons migrate force <version>`", e.Version)
+}
+
+// LoadMigrations reads every migration in fsys, in version order. Each needs both an up and a down file.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	paths, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, path := range paths {
+		match := migrationFilePattern.FindStringSubmatch(path)
+		if match == nil {
+			return nil, fmt.Errorf("migration %s should be named like 0001_create_coupons_table.up.sql", path)
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrations %04d_%s and %s share a version", version, migration.Name, path)
+		}
+
+		contents, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if match[3] == "up" {
+			migration.Up = string(contents)
+		} else {
+			migration.Down = string(contents)
+		}
+	}
+
+	var migrations []Migration
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", migration)
+		}
+
+		migrations = append(migrations, *migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// Migrator applies and reverts Migrations, recording each in schema_migrations. Every migration runs in its own
+// transaction, and is marked dirty until that commits, so one interrupted part way through is noticed.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+type migrationRecord struct {
+	appliedAt time.Time
+	dirty     bool
+}
+
+func (m Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+
+	err := m.withLock(ctx, func(conn *sql.Conn, records map[int]migrationRecord) error {
+		for _, migration := range m.Migrations {
+			record, applied := records[migration.Version]
+			statuses = append(statuses, MigrationStatus{
+				Migration: migration,
+				Applied:   applied && !record.dirty,
+				AppliedAt: record.appliedAt,
+				Dirty:     record.dirty,
+			})
+		}
+
+		return nil
+	})
+
+	return statuses, err
+}
+
+// Up applies every migration not yet applied. Versions applied by a newer build are left alone.
+func (m Migrator) Up(ctx context.Context) error {
+	return m.migrate(ctx, func(records map[int]migrationRecord) ([]Migration, []Migration, error) {
+		return m.pending(records, math.MaxInt32), nil, nil
+	})
+}
+
+// Down reverts the latest steps migrations applied, or all of them if steps isn't positive
+func (m Migrator) Down(ctx context.Context, steps int) error {
+	return m.migrate(ctx, func(records map[int]migrationRecord) ([]Migration, []Migration, error) {
+		versions := appliedVersions(records)
+		if steps > 0 && steps < len(versions) {
+			versions = versions[:steps]
+		}
+
+		toRevert, err := m.find(versions)
+		return nil, toRevert, err
+	})
+}
+
+// Goto applies or reverts migrations until every one up to version, and none after it, has been applied
+func (m Migrator) Goto(ctx context.Context, version int) error {
+	if _, err := m.find([]int{version}); err != nil {
+		return err
+	}
+
+	return m.migrate(ctx, func(records map[int]migrationRecord) ([]Migration, []Migration, error) {
+		var newer []int
+		for _, applied := range appliedVersions(records) {
+			if applied > version {
+				newer = append(newer, applied)
+			}
+		}
+
+		toRevert, err := m.find(newer)
+		return m.pending(records, version), toRevert, err
+	})
+}
+
+// Force records every migration up to version as applied, and none after it, without running any. It's how a dirty
+// migration is cleared once the database has been fixed by hand; a version of -1 records none as applied.
+func (m Migrator) Force(ctx context.Context, version int) error {
+	if version != -1 {
+		if _, err := m.find([]int{version}); err != nil {
+			return err
+		}
+	}
+
+	return m.withLock(ctx, func(conn *sql.Conn, records map[int]migrationRecord) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version > $1", version)
+		if err == nil {
+			_, err = tx.ExecContext(ctx, "UPDATE schema_migrations SET dirty = false")
+		}
+
+		for _, migration := range m.pending(records, version) {
+			if err == nil {
+				_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", migration.Version)
+			}
+		}
+
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// migrate runs the migrations plan chooses, from the migrations recorded once the lock is held
+func (m Migrator) migrate(ctx context.Context, plan func(map[int]migrationRecord) ([]Migration, []Migration, error)) error {
+	return m.withLock(ctx, func(conn *sql.Conn, records map[int]migrationRecord) error {
+		for _, version := range appliedVersions(records) {
+			if records[version].dirty {
+				return DirtyMigrationError{Version: version}
+			}
+		}
+
+		toApply, toRevert, err := plan(records)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range toRevert {
+			err := run(ctx, conn, migration, false)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, migration := range toApply {
+			err := run(ctx, conn, migration, true)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(toApply) == 0 && len(toRevert) == 0 {
+			log.Printf("no migrations to run")
+		}
+
+		return nil
+	})
+}
+
+// withLock holds the advisory lock on a connection of its own, as it belongs to the session that took it
+func (m Migrator) withLock(ctx context.Context, fn func(*sql.Conn, map[int]migrationRecord) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID)
+	if err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	// the table is usually created by migration 0010, but migrating a new database has to be recorded from 0000.
+	// Databases migrated before there was a dirty flag are given one.
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+  version INTEGER PRIMARY KEY,
+  applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT current_timestamp
+);
+ALTER TABLE schema_migrations ADD COLUMN IF NOT EXISTS dirty BOOLEAN NOT NULL DEFAULT false`)
+	if err != nil {
+		return err
+	}
+
+	records, err := migrationRecords(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn, records)
+}
+
+func migrationRecords(ctx context.Context, conn *sql.Conn) (map[int]migrationRecord, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at, dirty FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := map[int]migrationRecord{}
+	for rows.Next() {
+		var version int
+		var record migrationRecord
+
+		err := rows.Scan(&version, &record.appliedAt, &record.dirty)
+		if err != nil {
+			return nil, err
+		}
+
+		records[version] = record
+	}
+
+	return records, rows.Err()
+}
+
+// run marks the migration dirty before its transaction starts, and clean once it commits. If the transaction fails
+// it's rolled back, leaving nothing behind, so the mark is undone too.
+func run(ctx context.Context, conn *sql.Conn, migration Migration, up bool) error {
+	direction, statements := "down", migration.Down
+	markDirty := "UPDATE schema_migrations SET dirty = true WHERE version = $1"
+	markDone := "DELETE FROM schema_migrations WHERE version = $1"
+	undoMark := "UPDATE schema_migrations SET dirty = false WHERE version = $1"
+	if up {
+		direction, statements = "up", migration.Up
+		markDirty = "INSERT INTO schema_migrations (version, dirty) VALUES ($1, true) ON CONFLICT (version) DO UPDATE SET dirty = true"
+		markDone = "UPDATE schema_migrations SET dirty = false, applied_at = current_timestamp WHERE version = $1"
+		undoMark = "DELETE FROM schema_migrations WHERE version = $1"
+	}
+
+	log.Printf("migrating %s %s", direction, migration)
+
+	_, err := conn.ExecContext(ctx, markDirty, migration.Version)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, statements)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, markDone, migration.Version)
+	}
+
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+
+	if err != nil {
+		_, undoErr := conn.ExecContext(context.Background(), undoMark, migration.Version)
+		if undoErr != nil {
+			return fmt.Errorf("migrating %s %s: %v (and it's left marked dirty: %v)", direction, migration, err, undoErr)
+		}
+
+		return fmt.Errorf("migrating %s %s: %v", direction, migration, err)
+	}
+
+	return nil
+}
+
+// pending are the migrations up to version not yet applied, in order
+func (m Migrator) pending(records map[int]migrationRecord, version int) []Migration {
+	var pending []Migration
+	for _, migration := range m.Migrations {
+		if _, applied := records[migration.Version]; !applied && migration.Version <= version {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending
+}
+
+// find returns the migrations with each of versions, in the same order
+func (m Migrator) find(versions []int) ([]Migration, error) {
+	var found []Migration
+	for _, version := range versions {
+		index := sort.Search(len(m.Migrations), func(i int) bool { return m.Migrations[i].Version >= version })
+		if index == len(m.Migrations) || m.Migrations[index].Version != version {
+			return nil, fmt.Errorf("there's no migration %04d in this build", version)
+		}
+
+		found = append(found, m.Migrations[index])
+	}
+
+	return found, nil
+}
+
+// appliedVersions are newest first, the order they're reverted in
+func appliedVersions(records map[int]migrationRecord) []int {
+	var versions []int
+	for version := range records {
+		versions = append(versions, version)
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+	return versions
+}
@@ -0,0 +1,244 @@
+package dbservices_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/db"
+	"github.com/madeleinesmith/coupons/dbservices"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"io/fs"
+	"regexp"
+	"testing/fstest"
+	"time"
+)
+
+var _ = Describe("Migrations", func() {
+	Describe("LoadMigrations", func() {
+		It("loads the migrations built into the binary, ending at the schema version the service checks for", func() {
+			migrationFiles, err := fs.Sub(db.Migrations, "migrations")
+			Expect(err).NotTo(HaveOccurred())
+
+			migrations, err := dbservices.LoadMigrations(migrationFiles)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(migrations[0].String()).To(Equal("0000_uuid_extension"))
+			Expect(migrations[len(migrations)-1].Version).To(Equal(dbservices.SchemaVersion))
+			for i, migration := range migrations {
+				Expect(migration.Version).To(Equal(i))
+			}
+		})
+
+		It("pairs up and down files, in version order", func() {
+			migrations, err := dbservices.LoadMigrations(fstest.MapFS{
+				"0002_add_brands.down.sql":  {Data: []byte("DROP TABLE brands;")},
+				"0002_add_brands.up.sql":    {Data: []byte("CREATE TABLE brands ();")},
+				"0001_add_coupons.up.sql":   {Data: []byte("CREATE TABLE coupons ();")},
+				"0001_add_coupons.down.sql": {Data: []byte("DROP TABLE coupons;")},
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(migrations).To(Equal([]dbservices.Migration{
+				{Version: 1, Name: "add_coupons", Up: "CREATE TABLE coupons ();", Down: "DROP TABLE coupons;"},
+				{Version: 2, Name: "add_brands", Up: "CREATE TABLE brands ();", Down: "DROP TABLE brands;"},
+			}))
+		})
+
+		It("rejects a migration without a down file", func() {
+			_, err := dbservices.LoadMigrations(fstest.MapFS{
+				"0001_add_coupons.up.sql": {Data: []byte("CREATE TABLE coupons ();")},
+			})
+			Expect(err).To(MatchError("migration 0001_add_coupons needs both an up and a down file"))
+		})
+
+		It("rejects two migrations with the same version", func() {
+			_, err := dbservices.LoadMigrations(fstest.MapFS{
+				"0001_add_coupons.up.sql":   {Data: []byte("CREATE TABLE coupons ();")},
+				"0001_add_coupons.down.sql": {Data: []byte("DROP TABLE coupons;")},
+				"0001_add_brands.up.sql":    {Data: []byte("CREATE TABLE brands ();")},
+				"0001_add_brands.down.sql":  {Data: []byte("DROP TABLE brands;")},
+			})
+			Expect(err).To(MatchError(ContainSubstring("share a version")))
+		})
+
+		It("rejects files named unlike a migration", func() {
+			_, err := dbservices.LoadMigrations(fstest.MapFS{"add_coupons.sql": {Data: []byte("CREATE TABLE coupons ();")}})
+			Expect(err).To(MatchError(ContainSubstring("should be named like")))
+		})
+	})
+
+	Describe("Migrator", func() {
+		var (
+			mockDB    *sql.DB
+			dbMock    sqlmock.Sqlmock
+			migrator  dbservices.Migrator
+			ctx       context.Context
+			appliedAt time.Time
+		)
+
+		BeforeEach(func() {
+			var err error
+
+			mockDB, dbMock, err = sqlmock.New()
+			Expect(err).NotTo(HaveOccurred())
+
+			migrator = dbservices.Migrator{DB: mockDB, Migrations: []dbservices.Migration{
+				{Version: 0, Name: "add_coupons", Up: "CREATE TABLE coupons ();", Down: "DROP TABLE coupons;"},
+				{Version: 1, Name: "add_brands", Up: "CREATE TABLE brands ();", Down: "DROP TABLE brands;"},
+				{Version: 2, Name: "add_stores", Up: "CREATE TABLE stores ();", Down: "DROP TABLE stores;"},
+			}}
+			ctx = context.Background()
+			appliedAt = time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+		})
+
+		AfterEach(func() {
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		// expectLock expects the lock to be taken and the migrations applied so far to be read, as each command starts
+		expectLock := func(rows *sqlmock.Rows) {
+			dbMock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_lock($1)")).WithArgs(6584).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+			dbMock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+			dbMock.ExpectQuery("SELECT version, applied_at, dirty FROM schema_migrations").WillReturnRows(rows)
+		}
+
+		expectUnlock := func() {
+			dbMock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_unlock($1)")).WithArgs(6584).
+				WillReturnResult(sqlmock.NewResult(0, 0))
+		}
+
+		expectUp := func(version int, statements string) {
+			dbMock.ExpectExec("INSERT INTO schema_migrations \\(version, dirty\\) VALUES \\(\\$1, true\\)").WithArgs(version).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			dbMock.ExpectBegin()
+			dbMock.ExpectExec(regexp.QuoteMeta(statements)).WillReturnResult(sqlmock.NewResult(0, 0))
+			dbMock.ExpectExec("UPDATE schema_migrations SET dirty = false, applied_at").WithArgs(version).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			dbMock.ExpectCommit()
+		}
+
+		expectDown := func(version int, statements string) {
+			dbMock.ExpectExec("UPDATE schema_migrations SET dirty = true").WithArgs(version).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			dbMock.ExpectBegin()
+			dbMock.ExpectExec(regexp.QuoteMeta(statements)).WillReturnResult(sqlmock.NewResult(0, 0))
+			dbMock.ExpectExec("DELETE FROM schema_migrations WHERE version = \\$1").WithArgs(version).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			dbMock.ExpectCommit()
+		}
+
+		migrationRows := func() *sqlmock.Rows {
+			return sqlmock.NewRows([]string{"version", "applied_at", "dirty"})
+		}
+
+		It("applies each pending migration in its own transaction, under the advisory lock", func() {
+			expectLock(migrationRows().AddRow(0, appliedAt, false))
+			expectUp(1, "CREATE TABLE brands ();")
+			expectUp(2, "CREATE TABLE stores ();")
+			expectUnlock()
+
+			Expect(migrator.Up(ctx)).To(Succeed())
+		})
+
+		It("leaves migrations applied by a newer build alone", func() {
+			expectLock(migrationRows().AddRow(0, appliedAt, false).AddRow(1, appliedAt, false).
+				AddRow(2, appliedAt, false).AddRow(3, appliedAt, false))
+			expectUnlock()
+
+			Expect(migrator.Up(ctx)).To(Succeed())
+		})
+
+		It("rolls back a failed migration and doesn't leave it marked dirty", func() {
+			expectLock(migrationRows().AddRow(0, appliedAt, false))
+			dbMock.ExpectExec("INSERT INTO schema_migrations").WithArgs(1).WillReturnResult(sqlmock.NewResult(0, 1))
+			dbMock.ExpectBegin()
+			dbMock.ExpectExec(regexp.QuoteMeta("CREATE TABLE brands ();")).WillReturnError(errors.New("syntax error"))
+			dbMock.ExpectRollback()
+			dbMock.ExpectExec("DELETE FROM schema_migrations WHERE version = \\$1").WithArgs(1).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			expectUnlock()
+
+			Expect(migrator.Up(ctx)).To(MatchError("migrating up 0001_add_brands: syntax error"))
+		})
+
+		It("refuses to run anything while a migration is dirty", func() {
+			expectLock(migrationRows().AddRow(0, appliedAt, false).AddRow(1, appliedAt, true))
+			expectUnlock()
+
+			err := migrator.Up(ctx)
+			Expect(err).To(Equal(dbservices.DirtyMigrationError{Version: 1}))
+			Expect(err).To(MatchError(ContainSubstring("coupons migrate force")))
+		})
+
+		It("reverts the latest migration", func() {
+			expectLock(migrationRows().AddRow(0, appliedAt, false).AddRow(1, appliedAt, false))
+			expectDown(1, "DROP TABLE brands;")
+			expectUnlock()
+
+			Expect(migrator.Down(ctx, 1)).To(Succeed())
+		})
+
+		It("reverts every migration, newest first", func() {
+			expectLock(migrationRows().AddRow(0, appliedAt, false).AddRow(1, appliedAt, false))
+			expectDown(1, "DROP TABLE brands;")
+			expectDown(0, "DROP TABLE coupons;")
+			expectUnlock()
+
+			Expect(migrator.Down(ctx, 0)).To(Succeed())
+		})
+
+		It("won't revert a migration this build doesn't have", func() {
+			expectLock(migrationRows().AddRow(2, appliedAt, false).AddRow(3, appliedAt, false))
+			expectUnlock()
+
+			Expect(migrator.Down(ctx, 1)).To(MatchError("there's no migration 0003 in this build"))
+		})
+
+		It("goes to a version, reverting newer migrations and applying older ones", func() {
+			expectLock(migrationRows().AddRow(2, appliedAt, false))
+			expectDown(2, "DROP TABLE stores;")
+			expectUp(0, "CREATE TABLE coupons ();")
+			expectUp(1, "CREATE TABLE brands ();")
+			expectUnlock()
+
+			Expect(migrator.Goto(ctx, 1)).To(Succeed())
+		})
+
+		It("won't go to a version it doesn't have", func() {
+			Expect(migrator.Goto(ctx, 7)).To(MatchError("there's no migration 0007 in this build"))
+		})
+
+		It("forces the recorded version without running any migrations", func() {
+			expectLock(migrationRows().AddRow(0, appliedAt, false).AddRow(2, appliedAt, true))
+			dbMock.ExpectBegin()
+			dbMock.ExpectExec("DELETE FROM schema_migrations WHERE version > \\$1").WithArgs(1).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			dbMock.ExpectExec("UPDATE schema_migrations SET dirty = false").WillReturnResult(sqlmock.NewResult(0, 1))
+			dbMock.ExpectExec("INSERT INTO schema_migrations \\(version\\) VALUES \\(\\$1\\)").WithArgs(1).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			dbMock.ExpectCommit()
+			expectUnlock()
+
+			Expect(migrator.Force(ctx, 1)).To(Succeed())
+		})
+
+		It("reports which migrations are applied, pending or dirty", func() {
+			expectLock(migrationRows().AddRow(0, appliedAt, false).AddRow(1, appliedAt, true))
+			expectUnlock()
+
+			statuses, err := migrator.Status(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(statuses).To(HaveLen(3))
+			Expect(statuses[0].Applied).To(BeTrue())
+			Expect(statuses[0].AppliedAt).To(Equal(appliedAt))
+			Expect(statuses[1].Applied).To(BeFalse())
+			Expect(statuses[1].Dirty).To(BeTrue())
+			Expect(statuses[2].Applied).To(BeFalse())
+			Expect(statuses[2].Dirty).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,46 @@
+package dbservices
+
+import (
+	"context"
+	"database/sql"
+	"github.com/madeleinesmith/coupons/ratelimit"
+	"time"
+)
+
+// refilledTokens is the bucket's tokens plus whatever it has gained since it was last used, up to the burst ($2)
+const refilledTokens = "LEAST($2::double precision, bucket.tokens + EXTRACT(EPOCH FROM current_timestamp - bucket.updated_at) * $3::double precision)"
+
+// takeToken refills the bucket, then takes a token if there's a whole one. It's one statement, so concurrent
+// requests to different instances can't both take the last token.
+const takeToken = `
+INSERT INTO rate_limit_buckets AS bucket (key, tokens, allowed, updated_at)
+VALUES ($1, $2::double precision - 1, true, current_timestamp)
+ON CONFLICT (key) DO UPDATE SET
+  tokens = CASE WHEN ` + refilledTokens + ` >= 1 THEN ` + refilledTokens + ` - 1 ELSE ` + refilledTokens + ` END,
+  allowed = ` + refilledTokens + ` >= 1,
+  updated_at = current_timestamp
+RETURNING allowed, tokens`
+
+// RateLimitService keeps token buckets in Postgres, so limits hold across every instance of the service
+type RateLimitService struct {
+	DB *sql.DB
+}
+
+func (s RateLimitService) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	var result ratelimit.Result
+
+	err := traced(s.DB).QueryRowContext(ctx, takeToken, key, float64(limit.Burst), limit.Rate).Scan(&result.Allowed, &result.Tokens)
+
+	return result, err
+}
+
+// DeleteIdleBuckets removes buckets that haven't been used for idleFor, which must be long enough for them to have
+// filled up again
+func (s RateLimitService) DeleteIdleBuckets(ctx context.Context, idleFor time.Duration) (int64, error) {
+	result, err := traced(s.DB).ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at < current_timestamp - $1 * interval '1 second'", idleFor.Seconds())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
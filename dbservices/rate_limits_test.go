@@ -0,0 +1,93 @@
+package dbservices_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/dbservices"
+	"github.com/madeleinesmith/coupons/ratelimit"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"time"
+)
+
+var _ = Describe("Rate Limit Service", func() {
+	var (
+		mockedService dbservices.RateLimitService
+		dbMock        sqlmock.Sqlmock
+		realService   dbservices.RateLimitService
+		ctx           context.Context
+	)
+
+	BeforeEach(func() {
+		var db *sql.DB
+		var err error
+
+		db, dbMock, err = sqlmock.New()
+		Expect(err).NotTo(HaveOccurred())
+
+		mockedService = dbservices.RateLimitService{
+			DB: db,
+		}
+
+		realService = dbservices.RateLimitService{
+			DB: realDB,
+		}
+
+		ctx = context.Background()
+	})
+
+	It("allows a burst and then refuses until the bucket refills", func() {
+		limit := ratelimit.Limit{Rate: 0.001, Burst: 2}
+
+		result, err := realService.Take(ctx, "coupon:ip:203.0.113.7", limit)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Allowed).To(BeTrue())
+		Expect(result.Tokens).To(BeNumerically("~", 1, 0.01))
+
+		result, err = realService.Take(ctx, "coupon:ip:203.0.113.7", limit)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Allowed).To(BeTrue())
+
+		result, err = realService.Take(ctx, "coupon:ip:203.0.113.7", limit)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Allowed).To(BeFalse())
+		Expect(result.Tokens).To(BeNumerically("<", 1))
+
+		result, err = realService.Take(ctx, "coupon:ip:198.51.100.1", limit)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Allowed).To(BeTrue())
+
+		_, err = realDB.Exec("UPDATE rate_limit_buckets SET updated_at = updated_at - interval '1 hour'")
+		Expect(err).NotTo(HaveOccurred())
+
+		result, err = realService.Take(ctx, "coupon:ip:203.0.113.7", limit)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Allowed).To(BeTrue())
+
+		deleted, err := realService.DeleteIdleBuckets(ctx, 30*time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deleted).To(Equal(int64(1)))
+	})
+
+	Describe("Take", func() {
+		It("takes a token in a single upsert", func() {
+			dbMock.ExpectQuery(`INSERT INTO rate_limit_buckets AS bucket .* ON CONFLICT \(key\) DO UPDATE SET .* RETURNING allowed, tokens`).
+				WithArgs("coupon:ip:203.0.113.7", float64(10), 0.5).
+				WillReturnRows(sqlmock.NewRows([]string{"allowed", "tokens"}).AddRow(false, 0.25))
+
+			result, err := mockedService.Take(ctx, "coupon:ip:203.0.113.7", ratelimit.Limit{Rate: 0.5, Burst: 10})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(ratelimit.Result{Allowed: false, Tokens: 0.25}))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("propagates the error", func() {
+			dbMock.ExpectQuery(`INSERT INTO rate_limit_buckets .*`).WillReturnError(errors.New("too many connections"))
+
+			_, err := mockedService.Take(ctx, "coupon:ip:203.0.113.7", ratelimit.Limit{Rate: 0.5, Burst: 10})
+			Expect(err).To(MatchError("too many connections"))
+		})
+	})
+})
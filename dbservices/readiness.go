@@ -0,0 +1,71 @@
+package dbservices
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"log"
+	"time"
+)
+
+// SchemaVersion is the latest migration in db/migrations, which must be bumped alongside each new migration. A test
+// checks it matches.
+const SchemaVersion = 14
+
+// WaitForDatabase pings db until it answers or ctx is done, doubling the wait between attempts up to maxBackoff
+func WaitForDatabase(ctx context.Context, db *sql.DB, backoff time.Duration, maxBackoff time.Duration) error {
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		log.Printf("database not ready after %d attempt(s), retrying in %s: %v", attempt, backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("database not ready after %d attempt(s): %v", attempt, err)
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// CheckSchema returns an error unless every migration up to SchemaVersion has been applied. Newer schemas are
+// allowed, so a previous build can still be rolled back to after migrating.
+func CheckSchema(ctx context.Context, db *sql.DB) error {
+	var version sql.NullInt64
+
+	err := db.QueryRowContext(ctx, "SELECT MAX(version) FROM schema_migrations").Scan(&version)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "42P01" {
+		return fmt.Errorf("database has no schema_migrations table, run `coupons migrate up` to apply migrations up to %04d", SchemaVersion)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !version.Valid || version.Int64 < SchemaVersion {
+		return fmt.Errorf("database schema is at version %d, run `coupons migrate up` to apply migrations up to %04d", version.Int64, SchemaVersion)
+	}
+
+	return nil
+}
+
+// SampleQuery reads a coupon, to catch a database that answers pings but is too slow or locked up to serve requests.
+// Without a tenant row-level security hides every coupon, which is fine as only the time taken matters.
+func SampleQuery(ctx context.Context, db *sql.DB) error {
+	var id string
+
+	err := db.QueryRowContext(ctx, "SELECT id FROM coupons LIMIT 1").Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,86 @@
+package dbservices_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/madeleinesmith/coupons/dbservices"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"time"
+)
+
+var _ = Describe("Readiness", func() {
+	var (
+		db     *sql.DB
+		dbMock sqlmock.Sqlmock
+		ctx    context.Context
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		db, dbMock, err = sqlmock.New()
+		Expect(err).NotTo(HaveOccurred())
+
+		ctx = context.Background()
+	})
+
+	It("finds the test database migrated", func() {
+		Expect(dbservices.CheckSchema(ctx, realDB)).To(Succeed())
+	})
+
+	Describe("WaitForDatabase", func() {
+		It("returns once the database answers", func() {
+			Expect(dbservices.WaitForDatabase(ctx, db, time.Millisecond, time.Millisecond)).To(Succeed())
+		})
+
+		It("keeps retrying until it gives up", func() {
+			unreachableDB, err := sql.Open("postgres", "host=127.0.0.1 port=1 user=testing dbname=coupons_test sslmode=disable")
+			Expect(err).NotTo(HaveOccurred())
+			defer unreachableDB.Close()
+
+			timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+
+			err = dbservices.WaitForDatabase(timeoutCtx, unreachableDB, time.Millisecond, 10*time.Millisecond)
+			Expect(err).To(MatchError(MatchRegexp(`database not ready after \d+ attempt\(s\)`)))
+		})
+	})
+
+	Describe("CheckSchema", func() {
+		It("accepts a schema at or past the latest migration", func() {
+			dbMock.ExpectQuery(`SELECT MAX\(version\) FROM schema_migrations`).
+				WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(dbservices.SchemaVersion + 1))
+
+			Expect(dbservices.CheckSchema(ctx, db)).To(Succeed())
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("rejects a schema missing migrations", func() {
+			dbMock.ExpectQuery(`SELECT MAX\(version\) FROM schema_migrations`).
+				WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(dbservices.SchemaVersion - 1))
+
+			Expect(dbservices.CheckSchema(ctx, db)).To(MatchError(ContainSubstring(fmt.Sprintf("database schema is at version %d", dbservices.SchemaVersion-1))))
+		})
+
+		It("rejects a database that has never been migrated", func() {
+			dbMock.ExpectQuery(`SELECT MAX\(version\) FROM schema_migrations`).
+				WillReturnError(&pq.Error{Code: "42P01"})
+
+			Expect(dbservices.CheckSchema(ctx, db)).To(MatchError(ContainSubstring("no schema_migrations table")))
+		})
+	})
+
+	Describe("SampleQuery", func() {
+		It("doesn't mind there being no coupons to read", func() {
+			dbMock.ExpectQuery(`SELECT id FROM coupons LIMIT 1`).
+				WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+			Expect(dbservices.SampleQuery(ctx, db)).To(Succeed())
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,63 @@
+package dbservices
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/madeleinesmith/coupons/model/audit"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"time"
+)
+
+// RedeemCoupon locks the coupon while it's redeemed, so it can't be expired or deleted part way through. Coupons
+// can be redeemed any number of times until they expire. Each redemption is audited along with it, though as it
+// doesn't change the coupon it isn't a new version.
+func (s CouponService) RedeemCoupon(ctx context.Context, couponId string) (*coupon.Redemption, error) {
+	if !isUUID(couponId) {
+		return nil, sql.ErrNoRows
+	}
+
+	var redemption coupon.Redemption
+
+	err := s.inTransaction(ctx, func(txService CouponService) error {
+		couponInstance, err := txService.getCouponForUpdate(ctx, couponId)
+		if err != nil {
+			return err
+		}
+
+		if couponInstance.Expiry != nil && !couponInstance.Expiry.After(time.Now()) {
+			return coupon.ErrExpired
+		}
+
+		var requestId *string
+		if id := requestcontext.RequestID(ctx); id != "" {
+			requestId = &id
+		}
+
+		dbQuery, args, err := squirrel.StatementBuilder.
+			PlaceholderFormat(squirrel.Dollar).
+			Insert("redemptions").
+			Columns("coupon_id", "tenant_id", "actor", "request_id").
+			Values(couponId, txService.tenant, requestcontext.Actor(ctx), requestId).
+			Suffix("RETURNING id, coupon_id, redeemed_at").
+			ToSql()
+
+		if err != nil {
+			return err
+		}
+
+		err = traced(txService.tx).QueryRowContext(ctx, dbQuery, args...).
+			Scan(&redemption.ID, &redemption.CouponID, &redemption.RedeemedAt)
+		if err != nil {
+			return err
+		}
+
+		return insertAuditEntries(ctx, txService.tx, txService.tenant, newAuditEntry(ctx, audit.ActionRedeem, couponId, nil, nil))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &redemption, nil
+}
@@ -0,0 +1,171 @@
+package dbservices_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/dbservices"
+	"github.com/madeleinesmith/coupons/model/audit"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"time"
+)
+
+var _ = Describe("Redemptions", func() {
+	var (
+		mockedService dbservices.CouponService
+		dbMock        sqlmock.Sqlmock
+		realService   dbservices.CouponService
+		ctx           context.Context
+		couponId      string
+		selectQuery   string
+	)
+
+	BeforeEach(func() {
+		var db *sql.DB
+		var err error
+
+		db, dbMock, err = sqlmock.New()
+		Expect(err).NotTo(HaveOccurred())
+
+		mockedService = dbservices.CouponService{
+			DB: db,
+		}
+
+		realService = dbservices.CouponService{
+			DB: realDB,
+		}
+
+		ctx = requestcontext.WithActor(context.Background(), "madeleine")
+		ctx = requestcontext.WithRequestID(ctx, "req-123")
+		ctx = requestcontext.WithTenant(ctx, testTenant)
+
+		couponId = "0faec7ea-239f-11e9-9e44-d770694a0159"
+		selectQuery = `SELECT id, name, brand, value, expiry FROM coupons WHERE id = \$1 AND tenant_id = \$2 FOR UPDATE`
+	})
+
+	Describe("RedeemCoupon", func() {
+		It("records who redeemed the coupon", func() {
+			insertStatement := `INSERT INTO coupons (name, brand, value, tenant_id) VALUES ($1, $2, $3, $4) RETURNING id`
+			Expect(realDB.QueryRow(insertStatement, "Free delivery", "Ocado", 5, testTenant).Scan(&couponId)).To(Succeed())
+
+			redemption, err := realService.RedeemCoupon(ctx, couponId)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(redemption.ID).NotTo(BeEmpty())
+			Expect(redemption.CouponID).To(Equal(couponId))
+			Expect(redemption.RedeemedAt).To(BeTemporally("~", time.Now(), time.Minute))
+
+			var actor, requestId string
+			Expect(realDB.QueryRow("SELECT actor, request_id FROM redemptions WHERE id = $1", redemption.ID).
+				Scan(&actor, &requestId)).To(Succeed())
+			Expect(actor).To(Equal("madeleine"))
+			Expect(requestId).To(Equal("req-123"))
+
+			entries, err := dbservices.CouponAuditService{DB: realDB}.GetCouponHistory(ctx, couponId)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Action).To(Equal(audit.ActionRedeem))
+			Expect(entries[0].Actor).To(Equal("madeleine"))
+		})
+
+		It("keeps the redemptions of a coupon once it's deleted", func() {
+			insertStatement := `INSERT INTO coupons (name, brand, value, tenant_id) VALUES ($1, $2, $3, $4) RETURNING id`
+			Expect(realDB.QueryRow(insertStatement, "Free delivery", "Ocado", 5, testTenant).Scan(&couponId)).To(Succeed())
+
+			redemption, err := realService.RedeemCoupon(ctx, couponId)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(realService.DeleteCoupon(ctx, couponId)).To(Succeed())
+
+			var redemptions int
+			Expect(realDB.QueryRow("SELECT count(*) FROM redemptions WHERE id = $1", redemption.ID).Scan(&redemptions)).To(Succeed())
+			Expect(redemptions).To(Equal(1))
+		})
+
+		It("inserts the redemption while the coupon is locked", func() {
+			redeemedAt := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(selectQuery).
+				WithArgs(couponId, testTenant).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}).
+					AddRow(couponId, "Free delivery", "Ocado", 5, time.Now().Add(time.Hour)))
+			dbMock.ExpectQuery(`INSERT INTO redemptions \(coupon_id,tenant_id,actor,request_id\) VALUES \(\$1,\$2,\$3,\$4\) RETURNING id, coupon_id, redeemed_at`).
+				WithArgs(couponId, testTenant, "madeleine", "req-123").
+				WillReturnRows(sqlmock.NewRows([]string{"id", "coupon_id", "redeemed_at"}).
+					AddRow("5b1c1e2a-239f-11e9-9e44-d770694a0159", couponId, redeemedAt))
+			dbMock.ExpectExec(`INSERT INTO coupon_audit \(coupon_id,action,actor,request_id,changes,tenant_id\) VALUES \(\$1,\$2,\$3,\$4,\$5,\$6\)`).
+				WithArgs(couponId, "redeem", "madeleine", "req-123", []byte(`{}`), testTenant).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			dbMock.ExpectCommit()
+
+			redemption, err := mockedService.RedeemCoupon(ctx, couponId)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(redemption).To(Equal(&coupon.Redemption{
+				ID:         "5b1c1e2a-239f-11e9-9e44-d770694a0159",
+				CouponID:   couponId,
+				RedeemedAt: redeemedAt,
+			}))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns coupon.ErrExpired once the coupon has expired", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(selectQuery).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}).
+					AddRow(couponId, "Free delivery", "Ocado", 5, time.Now().Add(-time.Hour)))
+			dbMock.ExpectRollback()
+
+			_, err := mockedService.RedeemCoupon(ctx, couponId)
+			Expect(err).To(MatchError(coupon.ErrExpired))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns sql.ErrNoRows if the coupon does not exist", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(selectQuery).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}))
+			dbMock.ExpectRollback()
+
+			_, err := mockedService.RedeemCoupon(ctx, couponId)
+			Expect(err).To(MatchError(sql.ErrNoRows))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns sql.ErrNoRows without querying for ids that aren't uuids", func() {
+			_, err := mockedService.RedeemCoupon(ctx, "BOOTS-SAVE5")
+			Expect(err).To(MatchError(sql.ErrNoRows))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("propagates the error if the insert fails", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(selectQuery).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}).
+					AddRow(couponId, "Free delivery", "Ocado", 5, nil))
+			dbMock.ExpectQuery(`INSERT INTO redemptions .*`).WillReturnError(errors.New("nope 🙅"))
+			dbMock.ExpectRollback()
+
+			_, err := mockedService.RedeemCoupon(ctx, couponId)
+			Expect(err).To(MatchError("nope 🙅"))
+		})
+
+		It("rolls back the redemption if the audit entry can't be written", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(selectQuery).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}).
+					AddRow(couponId, "Free delivery", "Ocado", 5, nil))
+			dbMock.ExpectQuery(`INSERT INTO redemptions .*`).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "coupon_id", "redeemed_at"}).
+					AddRow("5b1c1e2a-239f-11e9-9e44-d770694a0159", couponId, time.Now()))
+			dbMock.ExpectExec(`INSERT INTO coupon_audit .*`).WillReturnError(errors.New("relation does not exist"))
+			dbMock.ExpectRollback()
+
+			_, err := mockedService.RedeemCoupon(ctx, couponId)
+			Expect(err).To(MatchError("relation does not exist"))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,42 @@
+package dbservices
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/requestcontext"
+)
+
+var ErrNoTenant = errors.New("no tenant on the request context")
+
+// beginTenantTransaction sets app.tenant_id for the transaction, which the row-level security policies check
+// every row against
+func beginTenantTransaction(ctx context.Context, db *sql.DB) (*sql.Tx, string, error) {
+	tenant := requestcontext.Tenant(ctx)
+	if tenant == "" {
+		return nil, "", ErrNoTenant
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	_, err = traced(tx).ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, true)", tenant)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", err
+	}
+
+	return tx, tenant, nil
+}
+
+// contextError reports a query cancelled by the request's deadline as the deadline, rather than as whatever the
+// driver made of the cancellation, so handlers can tell a slow query from a broken one
+func contextError(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
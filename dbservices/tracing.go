@@ -0,0 +1,64 @@
+package dbservices
+
+import (
+	"context"
+	"database/sql"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+)
+
+const instrumentationName = "github.com/madeleinesmith/coupons/dbservices"
+
+// tracedExecutor records each statement as a span, with its SQL but never its arguments, which can hold API key
+// hashes and coupon details. Spans end once the statement has run, not once its rows have all been read.
+type tracedExecutor struct {
+	executor executor
+}
+
+func traced(exec executor) tracedExecutor {
+	return tracedExecutor{executor: exec}
+}
+
+func startQuerySpan(ctx context.Context, query string) (context.Context, trace.Span) {
+	operation := strings.ToUpper(strings.SplitN(strings.TrimSpace(query), " ", 2)[0])
+
+	return otel.Tracer(instrumentationName).Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBQueryText(query), semconv.DBOperationName(operation)))
+}
+
+func endQuerySpan(span trace.Span, err error) {
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+func (t tracedExecutor) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	result, err := t.executor.ExecContext(ctx, query, args...)
+	endQuerySpan(span, err)
+
+	return result, err
+}
+
+func (t tracedExecutor) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuerySpan(ctx, query)
+	rows, err := t.executor.QueryContext(ctx, query, args...)
+	endQuerySpan(span, err)
+
+	return rows, err
+}
+
+func (t tracedExecutor) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuerySpan(ctx, query)
+	row := t.executor.QueryRowContext(ctx, query, args...)
+	endQuerySpan(span, row.Err())
+
+	return row
+}
@@ -0,0 +1,68 @@
+package dbservices_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/madeleinesmith/coupons/dbservices"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var _ = Describe("Tracing", func() {
+	var (
+		mockedService dbservices.CouponService
+		dbMock        sqlmock.Sqlmock
+		spanRecorder  *tracetest.SpanRecorder
+	)
+
+	BeforeEach(func() {
+		var db *sql.DB
+		var err error
+
+		db, dbMock, err = sqlmock.New()
+		Expect(err).NotTo(HaveOccurred())
+
+		mockedService = dbservices.CouponService{DB: db}
+
+		spanRecorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+	})
+
+	AfterEach(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+
+	It("records each query as a child of the request's span, with its SQL but not its arguments", func() {
+		ctx, parent := otel.Tracer("test").Start(requestcontext.WithTenant(context.Background(), testTenant), "GET coupon")
+
+		expectTenantTransaction(dbMock)
+		dbMock.ExpectQuery(`SELECT id, name, brand, value, expiry FROM coupons`).
+			WithArgs("0faec7ea-239f-11e9-9e44-d770694a0159", testTenant).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "name", "brand", "value", "expiry"}).AddRow("0faec7ea-239f-11e9-9e44-d770694a0159", "Save £5", "Tesco", 5, nil))
+		dbMock.ExpectCommit()
+
+		_, err := mockedService.GetCouponById(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159")
+		Expect(err).NotTo(HaveOccurred())
+		parent.End()
+
+		spans := spanRecorder.Ended()
+		Expect(spans).To(HaveLen(3))
+
+		querySpan := spans[1]
+		Expect(querySpan.Name()).To(Equal("SELECT"))
+		Expect(querySpan.Parent().SpanID()).To(Equal(parent.SpanContext().SpanID()))
+		Expect(querySpan.Attributes()).To(ContainElement(attribute.String("db.query.text",
+			"SELECT id, name, brand, value, expiry FROM coupons WHERE id = $1 AND tenant_id = $2")))
+
+		for _, attribute := range querySpan.Attributes() {
+			Expect(attribute.Value.Emit()).NotTo(ContainSubstring(testTenant))
+		}
+	})
+})
@@ -0,0 +1,152 @@
+package dbservices
+
+import (
+	"context"
+	"database/sql"
+	"github.com/Masterminds/squirrel"
+	"github.com/madeleinesmith/coupons/model/audit"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"time"
+)
+
+// couponChange is a single create, update or delete, recorded both in the audit trail and as a new coupon version
+type couponChange struct {
+	action   string
+	couponId string
+	before   *coupon.Coupon
+	after    *coupon.Coupon
+}
+
+// recordChanges writes to the service's transaction, so the changes and their history commit or roll back together
+func (s CouponService) recordChanges(ctx context.Context, changes ...couponChange) error {
+	auditEntries := make([]audit.Entry, len(changes))
+	for i, change := range changes {
+		auditEntries[i] = newAuditEntry(ctx, change.action, change.couponId, change.before, change.after)
+	}
+
+	err := insertAuditEntries(ctx, s.tx, s.tenant, auditEntries...)
+	if err != nil {
+		return err
+	}
+
+	return insertCouponVersions(ctx, s.tx, s.tenant, changes...)
+}
+
+func insertCouponVersions(ctx context.Context, exec executor, tenant string, changes ...couponChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	insertStatement := squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Insert("coupon_versions").
+		Columns("coupon_id", "version", "name", "brand", "value", "expiry", "deleted", "tenant_id")
+
+	for _, change := range changes {
+		nextVersion := squirrel.Expr("(SELECT COALESCE(MAX(version), 0) + 1 FROM coupon_versions WHERE coupon_id = ?)", change.couponId)
+
+		// deletions are recorded as an empty version, so point-in-time reads after them find nothing
+		if change.after == nil {
+			insertStatement = insertStatement.Values(change.couponId, nextVersion, nil, nil, nil, nil, true, tenant)
+			continue
+		}
+
+		insertStatement = insertStatement.Values(change.couponId, nextVersion, change.after.Name, change.after.Brand,
+			change.after.Value, change.after.Expiry, false, tenant)
+	}
+
+	dbQuery, args, err := insertStatement.ToSql()
+	if err != nil {
+		return err
+	}
+
+	_, err = traced(exec).ExecContext(ctx, dbQuery, args...)
+
+	return err
+}
+
+func (s CouponService) versionsSelect(couponId string) squirrel.SelectBuilder {
+	return squirrel.StatementBuilder.
+		PlaceholderFormat(squirrel.Dollar).
+		Select("coupon_id", "name", "brand", "value", "expiry", "version", "deleted").
+		From("coupon_versions").
+		Where(squirrel.Eq{"coupon_id": couponId, "tenant_id": s.tenant})
+}
+
+// scanVersion takes a function building the query, as the tenant isn't known until the transaction has begun
+func (s CouponService) scanVersion(ctx context.Context, couponId string, buildSelect func(CouponService) squirrel.SelectBuilder) (*coupon.Coupon, error) {
+	if !isUUID(couponId) {
+		return nil, sql.ErrNoRows
+	}
+
+	var couponInstance coupon.Coupon
+	var deleted bool
+
+	err := s.inTransaction(ctx, func(txService CouponService) error {
+		dbQuery, args, err := buildSelect(txService).ToSql()
+		if err != nil {
+			return err
+		}
+
+		return traced(txService.tx).QueryRowContext(ctx, dbQuery, args...).
+			Scan(&couponInstance.ID, &couponInstance.Name, &couponInstance.Brand, &couponInstance.Value, &couponInstance.Expiry,
+				&couponInstance.Version, &deleted)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if deleted {
+		return nil, sql.ErrNoRows
+	}
+
+	return &couponInstance, nil
+}
+
+// GetCouponAsOf returns the coupon as it was at asOf, or sql.ErrNoRows if it didn't exist (or had been deleted) then
+func (s CouponService) GetCouponAsOf(ctx context.Context, couponId string, asOf time.Time) (*coupon.Coupon, error) {
+	return s.scanVersion(ctx, couponId, func(txService CouponService) squirrel.SelectBuilder {
+		return txService.versionsSelect(couponId).
+			Where(squirrel.LtOrEq{"valid_from": asOf}).
+			OrderBy("version DESC").
+			Limit(1)
+	})
+}
+
+func (s CouponService) GetCouponVersion(ctx context.Context, couponId string, version int) (*coupon.Coupon, error) {
+	return s.scanVersion(ctx, couponId, func(txService CouponService) squirrel.SelectBuilder {
+		return txService.versionsSelect(couponId).
+			Where(squirrel.Eq{"version": version})
+	})
+}
+
+// RevertCoupon makes a copy of an old version the latest one; history itself is never rewritten. The old version's
+// expiry is restored even if it had none, so reverting can undo expiring a coupon.
+func (s CouponService) RevertCoupon(ctx context.Context, couponId string, version int) (*coupon.Coupon, error) {
+	var revertedCoupon *coupon.Coupon
+
+	err := s.inTransaction(ctx, func(txService CouponService) error {
+		oldVersion, err := txService.GetCouponVersion(ctx, couponId, version)
+		if err != nil {
+			return err
+		}
+
+		oldVersion.Version = nil
+
+		err = txService.updateCoupon(ctx, *oldVersion, true)
+		if err != nil {
+			return err
+		}
+
+		revertedCoupon, err = txService.GetCouponById(ctx, couponId)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return revertedCoupon, nil
+}
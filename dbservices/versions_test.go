@@ -0,0 +1,202 @@
+package dbservices_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/dbservices"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"time"
+)
+
+var _ = Describe("Coupon versions", func() {
+	var (
+		mockedService dbservices.CouponService
+		dbMock        sqlmock.Sqlmock
+		realService   dbservices.CouponService
+		ctx           context.Context
+		versionRows   []string
+	)
+
+	BeforeEach(func() {
+		var db *sql.DB
+		var err error
+
+		db, dbMock, err = sqlmock.New()
+		Expect(err).NotTo(HaveOccurred())
+
+		mockedService = dbservices.CouponService{
+			DB: db,
+		}
+
+		realService = dbservices.CouponService{
+			DB: realDB,
+		}
+
+		ctx = requestcontext.WithActor(context.Background(), "madeleine")
+		ctx = requestcontext.WithTenant(ctx, testTenant)
+		versionRows = []string{"coupon_id", "name", "brand", "value", "expiry", "version", "deleted"}
+	})
+
+	createCoupon := func() *coupon.Coupon {
+		name := "Save £5 at Boots"
+		brand := "Boots"
+		value := 5
+
+		createdCoupon, err := realService.CreateCoupon(ctx, coupon.Coupon{Name: &name, Brand: &brand, Value: &value})
+		Expect(err).NotTo(HaveOccurred())
+
+		return createdCoupon
+	}
+
+	Describe("GetCouponAsOf", func() {
+		It("returns the coupon as it was at the given time", func() {
+			createdCoupon := createCoupon()
+
+			var beforeUpdate time.Time
+			Expect(realDB.QueryRow("SELECT current_timestamp").Scan(&beforeUpdate)).To(Succeed())
+
+			newValue := 50
+			Expect(realService.UpdateCoupon(ctx, coupon.Coupon{ID: createdCoupon.ID, Value: &newValue})).To(Succeed())
+
+			oldCoupon, err := realService.GetCouponAsOf(ctx, createdCoupon.ID, beforeUpdate)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*oldCoupon.Value).To(Equal(5))
+			Expect(*oldCoupon.Version).To(Equal(1))
+
+			currentCoupon, err := realService.GetCouponAsOf(ctx, createdCoupon.ID, time.Now().Add(time.Hour))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*currentCoupon.Value).To(Equal(50))
+			Expect(*currentCoupon.Version).To(Equal(2))
+		})
+
+		It("returns sql.ErrNoRows once the coupon has been deleted", func() {
+			createdCoupon := createCoupon()
+			Expect(realService.DeleteCoupon(ctx, createdCoupon.ID)).To(Succeed())
+
+			_, err := realService.GetCouponAsOf(ctx, createdCoupon.ID, time.Now().Add(time.Hour))
+			Expect(err).To(MatchError(sql.ErrNoRows))
+		})
+
+		It("selects the latest version from before the given time", func() {
+			asOf := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT coupon_id, name, brand, value, expiry, version, deleted FROM coupon_versions WHERE coupon_id = \$1 AND tenant_id = \$2 AND valid_from <= \$3 ORDER BY version DESC LIMIT 1`).
+				WithArgs("0faec7ea-239f-11e9-9e44-d770694a0159", testTenant, asOf).
+				WillReturnRows(sqlmock.NewRows(versionRows).AddRow("0faec7ea-239f-11e9-9e44-d770694a0159", "Save £5 at Boots", "Boots", 5, nil, 3, false))
+			dbMock.ExpectCommit()
+
+			couponInstance, err := mockedService.GetCouponAsOf(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159", asOf)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(couponInstance.ID).To(Equal("0faec7ea-239f-11e9-9e44-d770694a0159"))
+			Expect(*couponInstance.Version).To(Equal(3))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns sql.ErrNoRows if the coupon did not exist yet", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT .* FROM coupon_versions`).WillReturnRows(sqlmock.NewRows(versionRows))
+			dbMock.ExpectRollback()
+
+			_, err := mockedService.GetCouponAsOf(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159", time.Now())
+			Expect(err).To(MatchError(sql.ErrNoRows))
+		})
+	})
+
+	Describe("GetCouponVersion", func() {
+		It("returns the requested version", func() {
+			expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT coupon_id, name, brand, value, expiry, version, deleted FROM coupon_versions WHERE coupon_id = \$1 AND tenant_id = \$2 AND version = \$3`).
+				WithArgs("0faec7ea-239f-11e9-9e44-d770694a0159", testTenant, 2).
+				WillReturnRows(sqlmock.NewRows(versionRows).AddRow("0faec7ea-239f-11e9-9e44-d770694a0159", "Save £5 at Boots", "Boots", 5, expiry, 2, false))
+			dbMock.ExpectCommit()
+
+			couponInstance, err := mockedService.GetCouponVersion(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159", 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*couponInstance.Name).To(Equal("Save £5 at Boots"))
+			Expect(*couponInstance.Version).To(Equal(2))
+			Expect(*couponInstance.Expiry).To(Equal(expiry))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+
+		It("returns sql.ErrNoRows for the version recording a deletion", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT .* FROM coupon_versions`).
+				WillReturnRows(sqlmock.NewRows(versionRows).AddRow("0faec7ea-239f-11e9-9e44-d770694a0159", nil, nil, nil, nil, 4, true))
+			dbMock.ExpectCommit()
+
+			_, err := mockedService.GetCouponVersion(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159", 4)
+			Expect(err).To(MatchError(sql.ErrNoRows))
+		})
+
+		It("propagates the error", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT .* FROM coupon_versions`).WillReturnError(errors.New("connection reset"))
+			dbMock.ExpectRollback()
+
+			_, err := mockedService.GetCouponVersion(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159", 1)
+			Expect(err).To(MatchError("connection reset"))
+		})
+	})
+
+	Describe("RevertCoupon", func() {
+		It("makes an old version the latest one", func() {
+			createdCoupon := createCoupon()
+
+			newValue := 50
+			Expect(realService.UpdateCoupon(ctx, coupon.Coupon{ID: createdCoupon.ID, Value: &newValue})).To(Succeed())
+
+			revertedCoupon, err := realService.RevertCoupon(ctx, createdCoupon.ID, 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*revertedCoupon.Value).To(Equal(5))
+
+			latestVersion, err := realService.GetCouponVersion(ctx, createdCoupon.ID, 3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(*latestVersion.Value).To(Equal(5))
+		})
+
+		It("restores the old version's expiry, clearing one set since", func() {
+			createdCoupon := createCoupon()
+
+			expiry := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			Expect(realService.UpdateCoupon(ctx, coupon.Coupon{ID: createdCoupon.ID, Expiry: &expiry})).To(Succeed())
+
+			revertedCoupon, err := realService.RevertCoupon(ctx, createdCoupon.ID, 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(revertedCoupon.Expiry).To(BeNil())
+
+			coupons, err := realService.GetCoupons(ctx, handlers.Filters{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(coupons[0].Expiry).To(BeNil())
+
+			expiredVersion, err := realService.GetCouponVersion(ctx, createdCoupon.ID, 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(expiredVersion.Expiry.Equal(expiry)).To(BeTrue())
+		})
+
+		It("refuses to rewrite history", func() {
+			createdCoupon := createCoupon()
+
+			_, err := realDB.Exec("UPDATE coupon_versions SET value = 0 WHERE coupon_id = $1", createdCoupon.ID)
+			Expect(err).To(MatchError(ContainSubstring("coupon_versions are immutable")))
+		})
+
+		It("returns sql.ErrNoRows without changing anything if the version does not exist", func() {
+			expectTenantTransaction(dbMock)
+			dbMock.ExpectQuery(`SELECT .* FROM coupon_versions`).WillReturnRows(sqlmock.NewRows(versionRows))
+			dbMock.ExpectRollback()
+
+			_, err := mockedService.RevertCoupon(ctx, "0faec7ea-239f-11e9-9e44-d770694a0159", 7)
+			Expect(err).To(MatchError(sql.ErrNoRows))
+			Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,80 @@
+package exporters
+
+import (
+	"encoding/csv"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"io"
+	"strconv"
+	"time"
+)
+
+var csvHeader = []string{"id", "name", "brand", "value", "expiry"}
+
+type CSVWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{
+		writer: csv.NewWriter(w),
+	}
+}
+
+func (w *CSVWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+
+	w.headerWritten = true
+
+	return w.writer.Write(csvHeader)
+}
+
+func (w *CSVWriter) Write(couponInstance *coupon.Coupon) error {
+	err := w.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	record := []string{couponInstance.ID, "", "", "", ""}
+
+	if couponInstance.Name != nil {
+		record[1] = *couponInstance.Name
+	}
+
+	if couponInstance.Brand != nil {
+		record[2] = *couponInstance.Brand
+	}
+
+	if couponInstance.Value != nil {
+		record[3] = strconv.Itoa(*couponInstance.Value)
+	}
+
+	if couponInstance.Expiry != nil {
+		record[4] = couponInstance.Expiry.UTC().Format(time.RFC3339)
+	}
+
+	return w.writer.Write(record)
+}
+
+func (w *CSVWriter) Flush() error {
+	w.writer.Flush()
+
+	return w.writer.Error()
+}
+
+func (w *CSVWriter) Close() error {
+	err := w.writeHeader()
+	if err != nil {
+		return err
+	}
+
+	w.writer.Flush()
+
+	return w.writer.Error()
+}
+
+func (w *CSVWriter) ContentType() string {
+	return "text/csv"
+}
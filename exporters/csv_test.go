@@ -0,0 +1,52 @@
+package exporters_test
+
+import (
+	"bytes"
+	"github.com/madeleinesmith/coupons/exporters"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("CSVWriter", func() {
+	var (
+		buffer *bytes.Buffer
+		writer *exporters.CSVWriter
+	)
+
+	BeforeEach(func() {
+		buffer = &bytes.Buffer{}
+		writer = exporters.NewCSVWriter(buffer)
+	})
+
+	It("writes a header followed by one record per coupon", func() {
+		name := "Save £5, today only"
+		brand := "Tesco"
+		value := 5
+		expiry := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+		Expect(writer.Write(&coupon.Coupon{ID: "1", Name: &name, Brand: &brand, Value: &value, Expiry: &expiry})).To(Succeed())
+		Expect(writer.Write(&coupon.Coupon{ID: "2"})).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		Expect(buffer.String()).To(Equal("id,name,brand,value,expiry\n1,\"Save £5, today only\",Tesco,5,2030-01-01T12:00:00Z\n2,,,,\n"))
+	})
+
+	It("writes nothing until the first coupon", func() {
+		Expect(buffer.Len()).To(Equal(0))
+	})
+
+	It("writes out the coupons so far when flushed", func() {
+		Expect(writer.Write(&coupon.Coupon{ID: "1"})).To(Succeed())
+		Expect(buffer.Len()).To(Equal(0))
+
+		Expect(writer.Flush()).To(Succeed())
+		Expect(buffer.String()).To(Equal("id,name,brand,value,expiry\n1,,,,\n"))
+	})
+
+	It("writes just the header if there are no coupons", func() {
+		Expect(writer.Close()).To(Succeed())
+		Expect(buffer.String()).To(Equal("id,name,brand,value,expiry\n"))
+	})
+})
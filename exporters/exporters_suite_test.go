@@ -0,0 +1,13 @@
+package exporters_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestExporters(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Exporters Suite")
+}
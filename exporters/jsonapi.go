@@ -0,0 +1,73 @@
+package exporters
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/google/jsonapi"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"io"
+)
+
+// JSONAPIWriter writes the same document as coupon.Serializer.SerializeCoupons, one resource object at a time
+type JSONAPIWriter struct {
+	w       io.Writer
+	started bool
+}
+
+func NewJSONAPIWriter(w io.Writer) *JSONAPIWriter {
+	return &JSONAPIWriter{
+		w: w,
+	}
+}
+
+func (w *JSONAPIWriter) Write(couponInstance *coupon.Coupon) error {
+	payload, err := jsonapi.Marshal(couponInstance)
+	if err != nil {
+		return err
+	}
+
+	onePayload, ok := payload.(*jsonapi.OnePayload)
+	if !ok {
+		return errors.New("expected a single coupon payload")
+	}
+
+	nodeBytes, err := json.Marshal(onePayload.Data)
+	if err != nil {
+		return err
+	}
+
+	separator := ","
+	if !w.started {
+		separator = `{"data":[`
+		w.started = true
+	}
+
+	_, err = io.WriteString(w.w, separator)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.w.Write(nodeBytes)
+
+	return err
+}
+
+// Flush has nothing to do, as every coupon is written straight through
+func (w *JSONAPIWriter) Flush() error {
+	return nil
+}
+
+func (w *JSONAPIWriter) Close() error {
+	closing := "]}"
+	if !w.started {
+		closing = `{"data":[]}`
+	}
+
+	_, err := io.WriteString(w.w, closing)
+
+	return err
+}
+
+func (w *JSONAPIWriter) ContentType() string {
+	return "application/json"
+}
@@ -0,0 +1,46 @@
+package exporters_test
+
+import (
+	"bytes"
+	"github.com/madeleinesmith/coupons/exporters"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("JSONAPIWriter", func() {
+	var (
+		buffer *bytes.Buffer
+		writer *exporters.JSONAPIWriter
+	)
+
+	BeforeEach(func() {
+		buffer = &bytes.Buffer{}
+		writer = exporters.NewJSONAPIWriter(buffer)
+	})
+
+	It("writes the same document as the coupon serializer", func() {
+		name1, brand1, value1 := "Save £10 at Madeleine's Supermercado", "Madeleine's", 10
+		name2, brand2, value2 := "Save £20 at Tom's Supermercado", "Tom's", 20
+
+		coupons := []*coupon.Coupon{
+			{ID: "354403f0-1c0e-11e9-9142-134e17ba9a5f", Name: &name1, Brand: &brand1, Value: &value1},
+			{ID: "c614eeaa-1c9d-11e9-8c4f-3f7c43a05026", Name: &name2, Brand: &brand2, Value: &value2},
+		}
+
+		for _, couponInstance := range coupons {
+			Expect(writer.Write(couponInstance)).To(Succeed())
+		}
+		Expect(writer.Close()).To(Succeed())
+
+		expected, err := coupon.Serializer{}.SerializeCoupons(coupons)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(buffer.String()).To(MatchJSON(expected))
+	})
+
+	It("writes an empty data array if there are no coupons", func() {
+		Expect(writer.Close()).To(Succeed())
+		Expect(buffer.String()).To(MatchJSON(`{"data": []}`))
+	})
+})
@@ -0,0 +1,50 @@
+package exporters
+
+import (
+	"encoding/json"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"io"
+	"time"
+)
+
+type ndjsonCoupon struct {
+	ID     string     `json:"id"`
+	Name   *string    `json:"name"`
+	Brand  *string    `json:"brand"`
+	Value  *int       `json:"value"`
+	Expiry *time.Time `json:"expiry"`
+}
+
+type NDJSONWriter struct {
+	encoder *json.Encoder
+}
+
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// json.Encoder terminates every value with a newline, which is all NDJSON needs
+func (w *NDJSONWriter) Write(couponInstance *coupon.Coupon) error {
+	return w.encoder.Encode(ndjsonCoupon{
+		ID:     couponInstance.ID,
+		Name:   couponInstance.Name,
+		Brand:  couponInstance.Brand,
+		Value:  couponInstance.Value,
+		Expiry: couponInstance.Expiry,
+	})
+}
+
+// Flush has nothing to do, as every coupon is written straight through
+func (w *NDJSONWriter) Flush() error {
+	return nil
+}
+
+func (w *NDJSONWriter) Close() error {
+	return nil
+}
+
+func (w *NDJSONWriter) ContentType() string {
+	return "application/x-ndjson"
+}
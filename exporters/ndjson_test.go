@@ -0,0 +1,32 @@
+package exporters_test
+
+import (
+	"bytes"
+	"github.com/madeleinesmith/coupons/exporters"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"strings"
+	"time"
+)
+
+var _ = Describe("NDJSONWriter", func() {
+	It("writes one JSON object per line", func() {
+		buffer := &bytes.Buffer{}
+		writer := exporters.NewNDJSONWriter(buffer)
+
+		name := "Save £5 at Tesco"
+		brand := "Tesco"
+		value := 5
+		expiry := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+		Expect(writer.Write(&coupon.Coupon{ID: "1", Name: &name, Brand: &brand, Value: &value, Expiry: &expiry})).To(Succeed())
+		Expect(writer.Write(&coupon.Coupon{ID: "2"})).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(MatchJSON(`{"id": "1", "name": "Save £5 at Tesco", "brand": "Tesco", "value": 5, "expiry": "2030-01-01T12:00:00Z"}`))
+		Expect(lines[1]).To(MatchJSON(`{"id": "2", "name": null, "brand": null, "value": null, "expiry": null}`))
+	})
+})
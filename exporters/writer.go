@@ -0,0 +1,30 @@
+package exporters
+
+import (
+	"fmt"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"io"
+)
+
+// Writer streams coupons out one at a time. Nothing is written to the underlying io.Writer until the first
+// coupon (or Close), so an error before then can still be reported with a proper status code.
+type Writer interface {
+	Write(couponInstance *coupon.Coupon) error
+	// Flush writes out any coupons still buffered
+	Flush() error
+	Close() error
+	ContentType() string
+}
+
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case "csv":
+		return NewCSVWriter(w), nil
+	case "ndjson":
+		return NewNDJSONWriter(w), nil
+	case "jsonapi":
+		return NewJSONAPIWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
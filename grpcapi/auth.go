@@ -0,0 +1,105 @@
+package grpcapi
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+// APIKeyMetadata is auth.APIKeyHeader as gRPC metadata, whose keys are lower case
+const APIKeyMetadata = "x-api-key"
+
+// Authenticator authenticates calls as the REST middleware does requests: with a bearer token in the
+// authorization metadata when JWTValidator is set, or else an x-api-key. Health checks and reflection are left
+// open, like the REST API's health checks and docs.
+type Authenticator struct {
+	APIKeyStore  auth.APIKeyStore
+	JWTValidator *auth.JWTValidator
+}
+
+func (a Authenticator) Unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !requiresAuthentication(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (a Authenticator) Stream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !requiresAuthentication(info.FullMethod) {
+		return handler(srv, stream)
+	}
+
+	ctx, err := a.authenticate(stream.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, contextStream{ServerStream: stream, ctx: ctx})
+}
+
+func (a Authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	authorization := first(md.Get("authorization"))
+	if a.JWTValidator != nil && strings.HasPrefix(authorization, "Bearer ") {
+		principal, err := a.JWTValidator.Validate(ctx, strings.TrimPrefix(authorization, "Bearer "))
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "invalid bearer token: "+err.Error())
+		}
+
+		ctx = auth.WithPrincipal(ctx, principal)
+		ctx = requestcontext.WithActor(ctx, "jwt:"+principal.Name)
+		ctx = requestcontext.WithTenant(ctx, principal.TenantID)
+
+		return ctx, nil
+	}
+
+	apiKey := first(md.Get(APIKeyMetadata))
+	if apiKey == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing "+APIKeyMetadata+" metadata")
+	}
+
+	ctx, err := auth.AuthenticateAPIKey(ctx, a.APIKeyStore, apiKey)
+	if err == auth.ErrInvalidAPIKey || err == auth.ErrRevokedAPIKey {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return ctx, nil
+}
+
+func requiresAuthentication(fullMethod string) bool {
+	return !strings.HasPrefix(fullMethod, "/grpc.health.v1.") && !strings.HasPrefix(fullMethod, "/grpc.reflection.")
+}
+
+// contextStream carries the principal and anything else the interceptors put on the context to the handler, as a
+// stream's context can't otherwise be replaced
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
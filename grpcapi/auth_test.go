@@ -0,0 +1,203 @@
+package grpcapi_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/auth/authfakes"
+	"github.com/madeleinesmith/coupons/grpcapi"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+var _ = Describe("Authenticator", func() {
+	var (
+		fakeCouponService *handlersfakes.FakeCouponService
+		fakeStore         *authfakes.FakeAPIKeyStore
+		authenticator     grpcapi.Authenticator
+		conn              *grpc.ClientConn
+		client            grpcapi.CouponServiceClient
+		stop              func()
+	)
+
+	BeforeEach(func() {
+		fakeCouponService = &handlersfakes.FakeCouponService{}
+		fakeCouponService.GetCouponByIdReturns(&coupon.Coupon{ID: "1"}, nil)
+
+		fakeStore = &authfakes.FakeAPIKeyStore{}
+		fakeStore.FindAPIKeyReturns(&auth.APIKey{Name: "checkout", TenantID: "boots", Scopes: []string{auth.ScopeCouponsRead}}, nil)
+
+		authenticator = grpcapi.Authenticator{APIKeyStore: fakeStore}
+	})
+
+	JustBeforeEach(func() {
+		conn, stop = serve(&grpcapi.CouponServer{CouponService: fakeCouponService}, authenticator)
+		client = grpcapi.NewCouponServiceClient(conn)
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	It("authenticates calls by their x-api-key", func() {
+		_, err := client.GetCoupon(withAPIKey("cpn_abc"), &grpcapi.GetCouponRequest{Id: "1"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, keyHash := fakeStore.FindAPIKeyArgsForCall(0)
+		Expect(keyHash).To(Equal(auth.HashAPIKey("cpn_abc")))
+	})
+
+	It("fails with Unauthenticated without an x-api-key", func() {
+		_, err := client.GetCoupon(context.Background(), &grpcapi.GetCouponRequest{Id: "1"})
+
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+		Expect(fakeCouponService.GetCouponByIdCallCount()).To(Equal(0))
+	})
+
+	It("fails with Unauthenticated if the key doesn't exist", func() {
+		fakeStore.FindAPIKeyReturns(nil, sql.ErrNoRows)
+
+		_, err := client.GetCoupon(withAPIKey("cpn_abc"), &grpcapi.GetCouponRequest{Id: "1"})
+
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+		Expect(fakeCouponService.GetCouponByIdCallCount()).To(Equal(0))
+	})
+
+	It("fails with Unauthenticated if the key has been revoked", func() {
+		revokedAt := time.Now()
+		fakeStore.FindAPIKeyReturns(&auth.APIKey{Name: "checkout", RevokedAt: &revokedAt}, nil)
+
+		_, err := client.GetCoupon(withAPIKey("cpn_abc"), &grpcapi.GetCouponRequest{Id: "1"})
+
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+	})
+
+	It("fails with Internal if the key can't be looked up", func() {
+		fakeStore.FindAPIKeyReturns(nil, errors.New("connection refused"))
+
+		_, err := client.GetCoupon(withAPIKey("cpn_abc"), &grpcapi.GetCouponRequest{Id: "1"})
+
+		Expect(status.Code(err)).To(Equal(codes.Internal))
+	})
+
+	It("authenticates streams too", func() {
+		stream, err := client.ListCoupons(context.Background(), &grpcapi.ListCouponsRequest{})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = stream.Recv()
+		Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+		Expect(fakeCouponService.StreamCouponsCallCount()).To(Equal(0))
+	})
+
+	Context("with a JWT validator", func() {
+		var (
+			signer jose.Signer
+			claims map[string]interface{}
+		)
+
+		BeforeEach(func() {
+			privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).NotTo(HaveOccurred())
+
+			signer, err = jose.NewSigner(
+				jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: privateKey, KeyID: "ec-1"}},
+				(&jose.SignerOptions{}).WithType("JWT"),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			authenticator.JWTValidator = &auth.JWTValidator{
+				Keys: &auth.StaticKeySet{Keys: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+					{Key: privateKey.Public(), KeyID: "ec-1", Algorithm: string(jose.ES256), Use: "sig"},
+				}}},
+				Issuer:   "https://login.example.com",
+				Audience: "coupons",
+			}
+
+			claims = map[string]interface{}{
+				"iss":       "https://login.example.com",
+				"aud":       "coupons",
+				"sub":       "checkout-service",
+				"exp":       time.Now().Add(time.Hour).Unix(),
+				"roles":     []string{auth.RoleViewer},
+				"tenant_id": "boots",
+			}
+		})
+
+		bearer := func() context.Context {
+			token, err := jwt.Signed(signer).Claims(claims).Serialize()
+			Expect(err).NotTo(HaveOccurred())
+
+			return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+		}
+
+		It("authenticates calls by their bearer token", func() {
+			_, err := client.GetCoupon(bearer(), &grpcapi.GetCouponRequest{Id: "1"})
+			Expect(err).NotTo(HaveOccurred())
+
+			serviceCtx, _ := fakeCouponService.GetCouponByIdArgsForCall(0)
+			Expect(requestcontext.Actor(serviceCtx)).To(Equal("jwt:checkout-service"))
+			Expect(requestcontext.Tenant(serviceCtx)).To(Equal("boots"))
+			Expect(fakeStore.FindAPIKeyCallCount()).To(Equal(0))
+		})
+
+		It("fails with Unauthenticated if the token isn't valid", func() {
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+
+			_, err := client.GetCoupon(bearer(), &grpcapi.GetCouponRequest{Id: "1"})
+
+			Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+			Expect(fakeCouponService.GetCouponByIdCallCount()).To(Equal(0))
+		})
+
+		It("still accepts an x-api-key", func() {
+			_, err := client.GetCoupon(withAPIKey("cpn_abc"), &grpcapi.GetCouponRequest{Id: "1"})
+
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	It("leaves health checks open", func() {
+		response, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{
+			Service: grpcapi.CouponService_ServiceDesc.ServiceName,
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(response.Status).To(Equal(healthpb.HealthCheckResponse_SERVING))
+	})
+
+	It("leaves reflection open", func() {
+		stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		err = stream.Send(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		response, err := stream.Recv()
+		Expect(err).NotTo(HaveOccurred())
+
+		var services []string
+		for _, service := range response.GetListServicesResponse().Service {
+			services = append(services, service.Name)
+		}
+		Expect(services).To(ContainElement("coupons.v1.CouponService"))
+		Expect(services).To(ContainElement("grpc.health.v1.Health"))
+	})
+})
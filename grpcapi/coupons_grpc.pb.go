@@ -0,0 +1,302 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.4.0
+// - protoc             (unknown)
+// source: coupons.proto
+
+package grpcapi
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	CouponService_CreateCoupon_FullMethodName = "/coupons.v1.CouponService/CreateCoupon"
+	CouponService_GetCoupon_FullMethodName    = "/coupons.v1.CouponService/GetCoupon"
+	CouponService_ListCoupons_FullMethodName  = "/coupons.v1.CouponService/ListCoupons"
+	CouponService_UpdateCoupon_FullMethodName = "/coupons.v1.CouponService/UpdateCoupon"
+	CouponService_RedeemCoupon_FullMethodName = "/coupons.v1.CouponService/RedeemCoupon"
+)
+
+// CouponServiceClient is the client API for CouponService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// CouponService is the gRPC API, alongside the REST one. Calls are authenticated with an x-api-key, or an
+// authorization bearer token, in the metadata, and only see the coupons of that key or token's tenant.
+type CouponServiceClient interface {
+	CreateCoupon(ctx context.Context, in *CreateCouponRequest, opts ...grpc.CallOption) (*Coupon, error)
+	GetCoupon(ctx context.Context, in *GetCouponRequest, opts ...grpc.CallOption) (*Coupon, error)
+	// ListCoupons streams every coupon matching the filters, or none
+	ListCoupons(ctx context.Context, in *ListCouponsRequest, opts ...grpc.CallOption) (CouponService_ListCouponsClient, error)
+	// UpdateCoupon changes the fields that are set, and returns the coupon as it now stands
+	UpdateCoupon(ctx context.Context, in *UpdateCouponRequest, opts ...grpc.CallOption) (*Coupon, error)
+	// RedeemCoupon records the coupon being redeemed, failing with FAILED_PRECONDITION once it has expired
+	RedeemCoupon(ctx context.Context, in *RedeemCouponRequest, opts ...grpc.CallOption) (*Redemption, error)
+}
+
+type couponServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCouponServiceClient(cc grpc.ClientConnInterface) CouponServiceClient {
+	return &couponServiceClient{cc}
+}
+
+func (c *couponServiceClient) CreateCoupon(ctx context.Context, in *CreateCouponRequest, opts ...grpc.CallOption) (*Coupon, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Coupon)
+	err := c.cc.Invoke(ctx, CouponService_CreateCoupon_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *couponServiceClient) GetCoupon(ctx context.Context, in *GetCouponRequest, opts ...grpc.CallOption) (*Coupon, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Coupon)
+	err := c.cc.Invoke(ctx, CouponService_GetCoupon_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *couponServiceClient) ListCoupons(ctx context.Context, in *ListCouponsRequest, opts ...grpc.CallOption) (CouponService_ListCouponsClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CouponService_ServiceDesc.Streams[0], CouponService_ListCoupons_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &couponServiceListCouponsClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CouponService_ListCouponsClient interface {
+	Recv() (*Coupon, error)
+	grpc.ClientStream
+}
+
+type couponServiceListCouponsClient struct {
+	grpc.ClientStream
+}
+
+func (x *couponServiceListCouponsClient) Recv() (*Coupon, error) {
+	m := new(Coupon)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *couponServiceClient) UpdateCoupon(ctx context.Context, in *UpdateCouponRequest, opts ...grpc.CallOption) (*Coupon, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Coupon)
+	err := c.cc.Invoke(ctx, CouponService_UpdateCoupon_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *couponServiceClient) RedeemCoupon(ctx context.Context, in *RedeemCouponRequest, opts ...grpc.CallOption) (*Redemption, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Redemption)
+	err := c.cc.Invoke(ctx, CouponService_RedeemCoupon_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CouponServiceServer is the server API for CouponService service.
+// All implementations must embed UnimplementedCouponServiceServer
+// for forward compatibility
+//
+// CouponService is the gRPC API, alongside the REST one. Calls are authenticated with an x-api-key, or an
+// authorization bearer token, in the metadata, and only see the coupons of that key or token's tenant.
+type CouponServiceServer interface {
+	CreateCoupon(context.Context, *CreateCouponRequest) (*Coupon, error)
+	GetCoupon(context.Context, *GetCouponRequest) (*Coupon, error)
+	// ListCoupons streams every coupon matching the filters, or none
+	ListCoupons(*ListCouponsRequest, CouponService_ListCouponsServer) error
+	// UpdateCoupon changes the fields that are set, and returns the coupon as it now stands
+	UpdateCoupon(context.Context, *UpdateCouponRequest) (*Coupon, error)
+	// RedeemCoupon records the coupon being redeemed, failing with FAILED_PRECONDITION once it has expired
+	RedeemCoupon(context.Context, *RedeemCouponRequest) (*Redemption, error)
+	mustEmbedUnimplementedCouponServiceServer()
+}
+
+// UnimplementedCouponServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedCouponServiceServer struct {
+}
+
+func (UnimplementedCouponServiceServer) CreateCoupon(context.Context, *CreateCouponRequest) (*Coupon, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateCoupon not implemented")
+}
+func (UnimplementedCouponServiceServer) GetCoupon(context.Context, *GetCouponRequest) (*Coupon, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCoupon not implemented")
+}
+func (UnimplementedCouponServiceServer) ListCoupons(*ListCouponsRequest, CouponService_ListCouponsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ListCoupons not implemented")
+}
+func (UnimplementedCouponServiceServer) UpdateCoupon(context.Context, *UpdateCouponRequest) (*Coupon, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateCoupon not implemented")
+}
+func (UnimplementedCouponServiceServer) RedeemCoupon(context.Context, *RedeemCouponRequest) (*Redemption, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RedeemCoupon not implemented")
+}
+func (UnimplementedCouponServiceServer) mustEmbedUnimplementedCouponServiceServer() {}
+
+// UnsafeCouponServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CouponServiceServer will
+// result in compilation errors.
+type UnsafeCouponServiceServer interface {
+	mustEmbedUnimplementedCouponServiceServer()
+}
+
+func RegisterCouponServiceServer(s grpc.ServiceRegistrar, srv CouponServiceServer) {
+	s.RegisterService(&CouponService_ServiceDesc, srv)
+}
+
+func _CouponService_CreateCoupon_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateCouponRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CouponServiceServer).CreateCoupon(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CouponService_CreateCoupon_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CouponServiceServer).CreateCoupon(ctx, req.(*CreateCouponRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CouponService_GetCoupon_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCouponRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CouponServiceServer).GetCoupon(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CouponService_GetCoupon_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CouponServiceServer).GetCoupon(ctx, req.(*GetCouponRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CouponService_ListCoupons_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListCouponsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CouponServiceServer).ListCoupons(m, &couponServiceListCouponsServer{ServerStream: stream})
+}
+
+type CouponService_ListCouponsServer interface {
+	Send(*Coupon) error
+	grpc.ServerStream
+}
+
+type couponServiceListCouponsServer struct {
+	grpc.ServerStream
+}
+
+func (x *couponServiceListCouponsServer) Send(m *Coupon) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CouponService_UpdateCoupon_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateCouponRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CouponServiceServer).UpdateCoupon(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CouponService_UpdateCoupon_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CouponServiceServer).UpdateCoupon(ctx, req.(*UpdateCouponRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CouponService_RedeemCoupon_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RedeemCouponRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CouponServiceServer).RedeemCoupon(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CouponService_RedeemCoupon_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CouponServiceServer).RedeemCoupon(ctx, req.(*RedeemCouponRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CouponService_ServiceDesc is the grpc.ServiceDesc for CouponService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CouponService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "coupons.v1.CouponService",
+	HandlerType: (*CouponServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateCoupon",
+			Handler:    _CouponService_CreateCoupon_Handler,
+		},
+		{
+			MethodName: "GetCoupon",
+			Handler:    _CouponService_GetCoupon_Handler,
+		},
+		{
+			MethodName: "UpdateCoupon",
+			Handler:    _CouponService_UpdateCoupon_Handler,
+		},
+		{
+			MethodName: "RedeemCoupon",
+			Handler:    _CouponService_RedeemCoupon_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ListCoupons",
+			Handler:       _CouponService_ListCoupons_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "coupons.proto",
+}
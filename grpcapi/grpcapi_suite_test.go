@@ -0,0 +1,52 @@
+package grpcapi_test
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/grpcapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestGRPCAPI(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "gRPC API Suite")
+}
+
+// serve runs the server in memory, returning a connection to it and a func to stop both
+func serve(couponServer *grpcapi.CouponServer, authenticator grpcapi.Authenticator) (*grpc.ClientConn, func()) {
+	instrumentation := grpcapi.Instrumentation{Logger: slog.New(slog.NewJSONHandler(io.Discard, nil))}
+	return serveWith(couponServer, instrumentation, authenticator, grpcapi.Limits{}, grpcapi.Idempotency{})
+}
+
+func serveWith(couponServer *grpcapi.CouponServer, instrumentation grpcapi.Instrumentation, authenticator grpcapi.Authenticator, limits grpcapi.Limits, idempotent grpcapi.Idempotency) (*grpc.ClientConn, func()) {
+	listener := bufconn.Listen(1024 * 1024)
+	server, _ := grpcapi.NewServer(couponServer, instrumentation, authenticator, limits, idempotent)
+	go server.Serve(listener)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	Expect(err).NotTo(HaveOccurred())
+
+	return conn, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+// withAPIKey adds the metadata a caller authenticates with
+func withAPIKey(apiKey string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), grpcapi.APIKeyMetadata, apiKey)
+}
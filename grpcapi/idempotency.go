@@ -0,0 +1,150 @@
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"github.com/madeleinesmith/coupons/idempotency"
+	"github.com/madeleinesmith/coupons/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"log/slog"
+	"time"
+)
+
+const (
+	// IdempotencyMetadata and ReplayedMetadata are idempotency.Header and idempotency.ReplayedHeader as gRPC metadata
+	IdempotencyMetadata = "idempotency-key"
+	ReplayedMetadata    = "idempotent-replayed"
+)
+
+// idempotentMethods are the calls that can be retried with an idempotency key, with the response type of each, to
+// replay stored responses into
+var idempotentMethods = map[string]func() proto.Message{
+	CouponService_CreateCoupon_FullMethodName: func() proto.Message { return &Coupon{} },
+	CouponService_RedeemCoupon_FullMethodName: func() proto.Message { return &Redemption{} },
+}
+
+// Idempotency does for CreateCoupon and RedeemCoupon what idempotency.Middleware does for POSTs, from the same
+// Store: a call retried with the same idempotency-key and request gets the first call's response back, rather than
+// creating or redeeming again. Only successful responses are stored, so a failed call can be retried for real. It
+// goes after the Authenticator, as keys are scoped to the caller's tenant.
+type Idempotency struct {
+	Store idempotency.Store
+	TTL   time.Duration
+	Lease time.Duration
+}
+
+func (i Idempotency) Unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	key := first(md.Get(IdempotencyMetadata))
+
+	newResponse, ok := idempotentMethods[info.FullMethod]
+	if !ok || key == "" || i.Store == nil {
+		return handler(ctx, req)
+	}
+
+	if len(key) > idempotency.MaxKeyLength {
+		return nil, status.Errorf(codes.InvalidArgument, "%s must be at most %d characters", IdempotencyMetadata, idempotency.MaxKeyLength)
+	}
+
+	fingerprint, err := callFingerprint(info.FullMethod, req)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	reserved, err := i.Store.Reserve(ctx, key, i.TTL, i.Lease)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if !reserved {
+		return i.replay(ctx, key, fingerprint, newResponse())
+	}
+
+	defer func() {
+		recovered := recover()
+		if recovered == nil {
+			return
+		}
+
+		i.release(ctx, key)
+		panic(recovered)
+	}()
+
+	resp, err := handler(ctx, req)
+	if err != nil {
+		i.release(ctx, key)
+		return resp, err
+	}
+
+	body, marshalErr := proto.Marshal(resp.(proto.Message))
+	if marshalErr == nil {
+		marshalErr = i.Store.Complete(context.WithoutCancel(ctx), key, fingerprint, idempotency.Response{
+			StatusCode: int(codes.OK),
+			Body:       body,
+		})
+	}
+	if marshalErr != nil {
+		logging.FromContext(ctx).Error("storing the response for an idempotency key", slog.String("key", key), slog.Any("error", marshalErr))
+	}
+
+	return resp, nil
+}
+
+func (i Idempotency) replay(ctx context.Context, key string, fingerprint []byte, resp proto.Message) (interface{}, error) {
+	entry, err := i.Store.Find(ctx, key)
+	if err == sql.ErrNoRows || (err == nil && entry.Response == nil) {
+		// it's still in progress, or it failed and was released since we tried to reserve it
+		return nil, status.Errorf(codes.Aborted, "the call with this %s has not finished, try again", IdempotencyMetadata)
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if !bytes.Equal(entry.Fingerprint, fingerprint) {
+		return nil, status.Errorf(codes.InvalidArgument, "%s has already been used for a different request", IdempotencyMetadata)
+	}
+
+	err = proto.Unmarshal(entry.Response.Body, resp)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	grpc.SetHeader(ctx, metadata.Pairs(ReplayedMetadata, "true"))
+
+	return resp, nil
+}
+
+// release frees the key for a retry, even if the caller has gone
+func (i Idempotency) release(ctx context.Context, key string) {
+	err := i.Store.Release(context.WithoutCancel(ctx), key)
+	if err != nil {
+		logging.FromContext(ctx).Error("releasing an idempotency key", slog.String("key", key), slog.Any("error", err))
+	}
+}
+
+// callFingerprint identifies a call, as idempotency.Fingerprint does a request. Including the method keeps a key
+// from being reused across methods, or for a REST request.
+func callFingerprint(fullMethod string, req interface{}) ([]byte, error) {
+	message, ok := req.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("%s request is not a proto message", fullMethod)
+	}
+
+	body, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint := sha256.New()
+	fmt.Fprintf(fingerprint, "%s\n", fullMethod)
+	fingerprint.Write(body)
+
+	return fingerprint.Sum(nil), nil
+}
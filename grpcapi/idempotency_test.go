@@ -0,0 +1,156 @@
+package grpcapi_test
+
+import (
+	"context"
+	"database/sql"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/auth/authfakes"
+	"github.com/madeleinesmith/coupons/grpcapi"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/idempotency"
+	"github.com/madeleinesmith/coupons/idempotency/idempotencyfakes"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+var _ = Describe("Idempotency", func() {
+	var (
+		fakeRedemptions   *handlersfakes.FakeRedemptionService
+		fakeCouponService *handlersfakes.FakeCouponService
+		fakeIdempotency   *idempotencyfakes.FakeStore
+		client            grpcapi.CouponServiceClient
+		stop              func()
+		ctx               context.Context
+	)
+
+	BeforeEach(func() {
+		fakeRedemptions = &handlersfakes.FakeRedemptionService{}
+		fakeRedemptions.RedeemCouponReturns(&coupon.Redemption{ID: "9", CouponID: "1", RedeemedAt: time.Now()}, nil)
+		fakeCouponService = &handlersfakes.FakeCouponService{}
+		fakeCouponService.GetCouponByIdReturns(&coupon.Coupon{ID: "1"}, nil)
+
+		fakeIdempotency = &idempotencyfakes.FakeStore{}
+		fakeIdempotency.ReserveReturns(true, nil)
+
+		fakeStore := &authfakes.FakeAPIKeyStore{}
+		fakeStore.FindAPIKeyReturns(&auth.APIKey{
+			Name:     "checkout",
+			TenantID: "boots",
+			Scopes:   []string{auth.ScopeCouponsRead, auth.ScopeRedemptionsWrite},
+		}, nil)
+
+		conn, stopServer := serveWith(&grpcapi.CouponServer{
+			CouponService:     fakeCouponService,
+			RedemptionService: fakeRedemptions,
+		}, grpcapi.Instrumentation{Logger: slog.New(slog.NewJSONHandler(io.Discard, nil))},
+			grpcapi.Authenticator{APIKeyStore: fakeStore},
+			grpcapi.Limits{},
+			grpcapi.Idempotency{Store: fakeIdempotency, TTL: time.Hour, Lease: time.Minute})
+		client = grpcapi.NewCouponServiceClient(conn)
+		stop = stopServer
+
+		ctx = metadata.AppendToOutgoingContext(withAPIKey("cpn_abc"), grpcapi.IdempotencyMetadata, "redeem-1")
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	It("stores the response to the first call with a key", func() {
+		_, err := client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "1"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, key, ttl, lease := fakeIdempotency.ReserveArgsForCall(0)
+		Expect(key).To(Equal("redeem-1"))
+		Expect(ttl).To(Equal(time.Hour))
+		Expect(lease).To(Equal(time.Minute))
+
+		Expect(fakeIdempotency.CompleteCallCount()).To(Equal(1))
+		_, completedKey, fingerprint, response := fakeIdempotency.CompleteArgsForCall(0)
+		Expect(completedKey).To(Equal("redeem-1"))
+		Expect(fingerprint).NotTo(BeEmpty())
+		Expect(response.Body).NotTo(BeEmpty())
+	})
+
+	It("replays the stored response to a retry, without redeeming again", func() {
+		_, err := client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "1"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, fingerprint, response := fakeIdempotency.CompleteArgsForCall(0)
+		fakeIdempotency.ReserveReturns(false, nil)
+		fakeIdempotency.FindReturns(&idempotency.Entry{Fingerprint: fingerprint, Response: &response}, nil)
+
+		var header metadata.MD
+		redemption, err := client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "1"}, grpc.Header(&header))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redemption.Id).To(Equal("9"))
+		Expect(header.Get(grpcapi.ReplayedMetadata)).To(Equal([]string{"true"}))
+		Expect(fakeRedemptions.RedeemCouponCallCount()).To(Equal(1))
+	})
+
+	It("fails with InvalidArgument if the key was used for a different request", func() {
+		_, err := client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "1"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, fingerprint, response := fakeIdempotency.CompleteArgsForCall(0)
+		fakeIdempotency.ReserveReturns(false, nil)
+		fakeIdempotency.FindReturns(&idempotency.Entry{Fingerprint: fingerprint, Response: &response}, nil)
+
+		_, err = client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "2"})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		Expect(fakeRedemptions.RedeemCouponCallCount()).To(Equal(1))
+	})
+
+	It("fails with Aborted while the first call with the key is in progress", func() {
+		fakeIdempotency.ReserveReturns(false, nil)
+		fakeIdempotency.FindReturns(&idempotency.Entry{}, nil)
+
+		_, err := client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "1"})
+		Expect(status.Code(err)).To(Equal(codes.Aborted))
+
+		fakeIdempotency.FindReturns(nil, sql.ErrNoRows)
+
+		_, err = client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "1"})
+		Expect(status.Code(err)).To(Equal(codes.Aborted))
+		Expect(fakeRedemptions.RedeemCouponCallCount()).To(Equal(0))
+	})
+
+	It("releases the key of a failed call, so it can be retried", func() {
+		fakeRedemptions.RedeemCouponReturns(nil, coupon.ErrExpired)
+
+		_, err := client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "1"})
+		Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+
+		Expect(fakeIdempotency.ReleaseCallCount()).To(Equal(1))
+		_, key := fakeIdempotency.ReleaseArgsForCall(0)
+		Expect(key).To(Equal("redeem-1"))
+		Expect(fakeIdempotency.CompleteCallCount()).To(Equal(0))
+	})
+
+	It("rejects keys that are too long", func() {
+		ctx = metadata.AppendToOutgoingContext(withAPIKey("cpn_abc"), grpcapi.IdempotencyMetadata, strings.Repeat("k", idempotency.MaxKeyLength+1))
+
+		_, err := client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "1"})
+		Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		Expect(fakeIdempotency.ReserveCallCount()).To(Equal(0))
+	})
+
+	It("leaves calls without a key, and methods that change nothing, alone", func() {
+		_, err := client.RedeemCoupon(withAPIKey("cpn_abc"), &grpcapi.RedeemCouponRequest{Id: "1"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "1"})
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeIdempotency.ReserveCallCount()).To(Equal(0))
+	})
+})
@@ -0,0 +1,288 @@
+package grpcapi
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/lockout"
+	"github.com/madeleinesmith/coupons/logging"
+	"github.com/madeleinesmith/coupons/metrics"
+	"github.com/madeleinesmith/coupons/ratelimit"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"github.com/madeleinesmith/coupons/tracing"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// RequestIDMetadata is requestcontext.RequestIDHeader as gRPC metadata
+	RequestIDMetadata  = "x-request-id"
+	RetryAfterMetadata = "retry-after"
+)
+
+// RouteNames gives each method the name of the REST route it matches, so the rate limits, lockouts and query
+// timeouts configured by route name apply to both APIs. Methods without one, such as health checks, are left alone.
+var RouteNames = map[string]string{
+	CouponService_CreateCoupon_FullMethodName: "coupons",
+	CouponService_GetCoupon_FullMethodName:    "coupon",
+	CouponService_ListCoupons_FullMethodName:  "coupons-export",
+	CouponService_UpdateCoupon_FullMethodName: "coupons",
+	CouponService_RedeemCoupon_FullMethodName: "coupon-redeem",
+}
+
+// Instrumentation does for calls what the REST router's middleware does for requests: it gives each one a request
+// ID, an access log line, a span and metrics, and the route's deadline for its queries. It goes ahead of the
+// Authenticator, so calls turned away by authentication or limits are logged and counted too.
+type Instrumentation struct {
+	Logger             *slog.Logger
+	QueryTimeout       time.Duration
+	RouteQueryTimeouts map[string]time.Duration
+}
+
+func (i Instrumentation) Unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	routeName, ok := RouteNames[info.FullMethod]
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	var resp interface{}
+	err := i.instrument(ctx, info.FullMethod, routeName, func(ctx context.Context) error {
+		var err error
+		resp, err = handler(ctx, req)
+		return err
+	})
+
+	return resp, err
+}
+
+func (i Instrumentation) Stream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	routeName, ok := RouteNames[info.FullMethod]
+	if !ok {
+		return handler(srv, stream)
+	}
+
+	return i.instrument(stream.Context(), info.FullMethod, routeName, func(ctx context.Context) error {
+		return handler(srv, contextStream{ServerStream: stream, ctx: ctx})
+	})
+}
+
+func (i Instrumentation) instrument(ctx context.Context, fullMethod string, routeName string, handle func(context.Context) error) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	requestID := requestcontext.AcceptRequestID(first(md.Get(RequestIDMetadata)))
+	ctx = requestcontext.WithRequestID(ctx, requestID)
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadata, requestID))
+
+	logger := i.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	ctx, done := logging.StartAccess(ctx, logger)
+
+	service, method := splitMethod(fullMethod)
+	ctx, span := tracing.StartServerSpan(ctx, metadataCarrier(md), service+"/"+method,
+		semconv.RPCSystemGRPC, semconv.RPCService(service), semconv.RPCMethod(method))
+	defer span.End()
+
+	ctx, cancel := requestcontext.WithRouteDeadline(ctx, routeName, i.QueryTimeout, i.RouteQueryTimeouts)
+	defer cancel()
+
+	start := time.Now()
+	err := handle(ctx)
+	code := status.Code(err)
+
+	span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+	if serverError(code) {
+		span.SetStatus(otelcodes.Error, code.String())
+	}
+
+	metrics.ObserveGRPCCall(method, code.String(), time.Since(start))
+	done(
+		slog.String("method", fullMethod),
+		slog.String("route", routeName),
+		slog.String("code", code.String()),
+	)
+
+	return err
+}
+
+// Limits applies the rate limits and lockouts of the REST API's routes to the methods matching them. Given the same
+// Limiter and lockout Store, a caller can't get around either by switching API. It goes after the Authenticator, so
+// calls are limited by API key, and notes the caller for the access log.
+type Limits struct {
+	Limiter       ratelimit.Limiter
+	Rules         map[string]ratelimit.Rule
+	Guard         lockout.Guard
+	GuardedRoutes []string
+}
+
+// Unary counts every NotFound as a failed lookup of the requested coupon id, towards locking the caller out
+func (l Limits) Unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	routeName, ok := RouteNames[info.FullMethod]
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	ctx = logging.WithPrincipal(ctx)
+
+	couponId := ""
+	if idRequest, ok := req.(interface{ GetId() string }); ok {
+		couponId = idRequest.GetId()
+	}
+
+	err := l.rateLimit(ctx, routeName, couponId)
+	if err != nil {
+		countRefusedRedemption(info.FullMethod, err, metrics.ReasonRateLimited)
+		return nil, err
+	}
+
+	if !l.guards(routeName) {
+		return handler(ctx, req)
+	}
+
+	actor := lockout.ActorKey(ctx, clientIP(ctx))
+
+	lockedUntil, err := l.Guard.Store.LockedUntil(ctx, actor)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	if remaining := l.Guard.Remaining(lockedUntil); remaining > 0 {
+		grpc.SetHeader(ctx, metadata.Pairs(RetryAfterMetadata, strconv.Itoa(int(math.Ceil(remaining.Seconds())))))
+		err = status.Error(codes.ResourceExhausted, "too many failed lookups, try again later")
+		countRefusedRedemption(info.FullMethod, err, metrics.ReasonLockedOut)
+		return nil, err
+	}
+
+	resp, err := handler(ctx, req)
+	if status.Code(err) != codes.NotFound {
+		return resp, err
+	}
+
+	failureErr := l.Guard.RecordFailure(context.WithoutCancel(ctx), actor, couponId, lockedUntil)
+	if failureErr != nil {
+		logging.FromContext(ctx).Error("recording a failed lookup", slog.String("actor", actor), slog.Any("error", failureErr))
+	}
+
+	return resp, err
+}
+
+// Stream only rate limits, as no stream looks coupons up by id
+func (l Limits) Stream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	routeName, ok := RouteNames[info.FullMethod]
+	if !ok {
+		return handler(srv, stream)
+	}
+
+	ctx := logging.WithPrincipal(stream.Context())
+
+	err := l.rateLimit(ctx, routeName, "")
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, contextStream{ServerStream: stream, ctx: ctx})
+}
+
+func (l Limits) rateLimit(ctx context.Context, routeName string, couponId string) error {
+	rule, ok := l.Rules[routeName]
+	if !ok || l.Limiter == nil {
+		return nil
+	}
+
+	key := ratelimit.Key(ctx, rule.KeyBy, couponId, clientIP(ctx))
+	result, err := l.Limiter.Take(ctx, routeName+":"+key, rule.Limit)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	if !result.Allowed {
+		grpc.SetHeader(ctx, metadata.Pairs(RetryAfterMetadata, strconv.Itoa(ratelimit.RetryAfter(rule.Limit, result))))
+		return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+	}
+
+	return nil
+}
+
+// countRefusedRedemption counts redemptions turned away by the limits, as they never reach the RedemptionService
+// that counts the rest
+func countRefusedRedemption(fullMethod string, err error, reason string) {
+	if fullMethod == CouponService_RedeemCoupon_FullMethodName && status.Code(err) == codes.ResourceExhausted {
+		metrics.CountRefusedRedemption(reason)
+	}
+}
+
+func (l Limits) guards(routeName string) bool {
+	if l.Guard.Store == nil {
+		return false
+	}
+
+	for _, guardedRoute := range l.GuardedRoutes {
+		if guardedRoute == routeName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// clientIP is the address the call came from, as requestcontext.ClientIP is for requests
+func clientIP(ctx context.Context) string {
+	callPeer, ok := peer.FromContext(ctx)
+	if !ok || callPeer.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(callPeer.Addr.String())
+	if err != nil {
+		return callPeer.Addr.String()
+	}
+
+	return host
+}
+
+// splitMethod splits /coupons.v1.CouponService/GetCoupon into its service and method
+func splitMethod(fullMethod string) (string, string) {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return service, method
+}
+
+// serverError is whether code is the gRPC version of a 5xx, as the REST API's spans are marked failed for
+func serverError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.Internal, codes.Unimplemented, codes.Unavailable, codes.DataLoss, codes.DeadlineExceeded:
+		return true
+	default:
+		return false
+	}
+}
+
+// metadataCarrier reads the caller's trace context from the call's metadata
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	return first(metadata.MD(c).Get(key))
+}
+
+func (c metadataCarrier) Set(key string, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
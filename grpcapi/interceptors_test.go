@@ -0,0 +1,291 @@
+package grpcapi_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/auth/authfakes"
+	"github.com/madeleinesmith/coupons/grpcapi"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/lockout"
+	"github.com/madeleinesmith/coupons/lockout/lockoutfakes"
+	"github.com/madeleinesmith/coupons/metrics"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/ratelimit"
+	"github.com/madeleinesmith/coupons/ratelimit/ratelimitfakes"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"log/slog"
+	"time"
+)
+
+var _ = Describe("Interceptors", func() {
+	var (
+		fakeCouponService *handlersfakes.FakeCouponService
+		fakeStore         *authfakes.FakeAPIKeyStore
+		logs              *bytes.Buffer
+		instrumentation   grpcapi.Instrumentation
+		limits            grpcapi.Limits
+		client            grpcapi.CouponServiceClient
+		conn              *grpc.ClientConn
+		stop              func()
+		ctx               context.Context
+	)
+
+	BeforeEach(func() {
+		fakeCouponService = &handlersfakes.FakeCouponService{}
+		fakeCouponService.GetCouponByIdReturns(&coupon.Coupon{ID: "1"}, nil)
+
+		fakeStore = &authfakes.FakeAPIKeyStore{}
+		fakeStore.FindAPIKeyReturns(&auth.APIKey{ID: "key-1", Name: "checkout", TenantID: "boots", Scopes: []string{auth.ScopeCouponsRead}}, nil)
+
+		logs = &bytes.Buffer{}
+		instrumentation = grpcapi.Instrumentation{Logger: slog.New(slog.NewJSONHandler(logs, nil))}
+		limits = grpcapi.Limits{}
+
+		ctx = withAPIKey("cpn_abc")
+	})
+
+	JustBeforeEach(func() {
+		conn, stop = serveWith(&grpcapi.CouponServer{CouponService: fakeCouponService}, instrumentation,
+			grpcapi.Authenticator{APIKeyStore: fakeStore}, limits, grpcapi.Idempotency{})
+		client = grpcapi.NewCouponServiceClient(conn)
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	Describe("Instrumentation", func() {
+		It("passes the caller's request ID on, and echoes it back", func() {
+			var header metadata.MD
+			ctx = metadata.AppendToOutgoingContext(ctx, grpcapi.RequestIDMetadata, "abc-123")
+
+			_, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "1"}, grpc.Header(&header))
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(header.Get(grpcapi.RequestIDMetadata)).To(Equal([]string{"abc-123"}))
+			serviceCtx, _ := fakeCouponService.GetCouponByIdArgsForCall(0)
+			Expect(requestcontext.RequestID(serviceCtx)).To(Equal("abc-123"))
+		})
+
+		It("logs each call, with who made it, even when authentication turns it away", func() {
+			_, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "1"})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = client.GetCoupon(context.Background(), &grpcapi.GetCouponRequest{Id: "1"})
+			Expect(status.Code(err)).To(Equal(codes.Unauthenticated))
+
+			decoder := json.NewDecoder(logs)
+
+			var line map[string]interface{}
+			Expect(decoder.Decode(&line)).To(Succeed())
+			Expect(line).To(HaveKeyWithValue("msg", "request"))
+			Expect(line).To(HaveKeyWithValue("method", grpcapi.CouponService_GetCoupon_FullMethodName))
+			Expect(line).To(HaveKeyWithValue("route", "coupon"))
+			Expect(line).To(HaveKeyWithValue("code", "OK"))
+			Expect(line).To(HaveKeyWithValue("principal", "api-key:checkout"))
+			Expect(line).To(HaveKeyWithValue("tenant", "boots"))
+			Expect(line).To(HaveKey("request_id"))
+
+			line = nil
+			Expect(decoder.Decode(&line)).To(Succeed())
+			Expect(line).To(HaveKeyWithValue("code", "Unauthenticated"))
+			Expect(line).To(HaveKeyWithValue("principal", requestcontext.AnonymousActor))
+		})
+
+		It("gives the call's queries the route's deadline", func() {
+			instrumentation.QueryTimeout = time.Second
+			instrumentation.RouteQueryTimeouts = map[string]time.Duration{"coupon": time.Hour}
+			stop()
+			conn, stop = serveWith(&grpcapi.CouponServer{CouponService: fakeCouponService}, instrumentation,
+				grpcapi.Authenticator{APIKeyStore: fakeStore}, limits, grpcapi.Idempotency{})
+			client = grpcapi.NewCouponServiceClient(conn)
+
+			_, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "1"})
+			Expect(err).NotTo(HaveOccurred())
+
+			serviceCtx, _ := fakeCouponService.GetCouponByIdArgsForCall(0)
+			deadline, ok := serviceCtx.Deadline()
+			Expect(ok).To(BeTrue())
+			Expect(deadline).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+		})
+
+		Context("with tracing", func() {
+			var spanRecorder *tracetest.SpanRecorder
+
+			BeforeEach(func() {
+				spanRecorder = tracetest.NewSpanRecorder()
+				otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+				otel.SetTextMapPropagator(propagation.TraceContext{})
+			})
+
+			AfterEach(func() {
+				otel.SetTracerProvider(noop.NewTracerProvider())
+				otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+			})
+
+			It("records a span for each call, continuing the caller's trace", func() {
+				ctx = metadata.AppendToOutgoingContext(ctx, "traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+				fakeCouponService.GetCouponByIdReturns(nil, sql.ErrNoRows)
+
+				_, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "1"})
+				Expect(status.Code(err)).To(Equal(codes.NotFound))
+
+				spans := spanRecorder.Ended()
+				Expect(spans).To(HaveLen(1))
+				Expect(spans[0].Name()).To(Equal("coupons.v1.CouponService/GetCoupon"))
+				Expect(spans[0].SpanContext().TraceID().String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+				Expect(spans[0].Parent().SpanID().String()).To(Equal("00f067aa0ba902b7"))
+
+				attributes := map[string]string{}
+				for _, attribute := range spans[0].Attributes() {
+					attributes[string(attribute.Key)] = attribute.Value.Emit()
+				}
+				Expect(attributes).To(HaveKeyWithValue("rpc.method", "GetCoupon"))
+				Expect(attributes).To(HaveKeyWithValue("rpc.grpc.status_code", "5"))
+			})
+		})
+
+		It("leaves health checks alone", func() {
+			_, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(logs.String()).To(BeEmpty())
+		})
+	})
+
+	Describe("Limits", func() {
+		Context("with a rate limit on the route", func() {
+			var fakeLimiter *ratelimitfakes.FakeLimiter
+
+			BeforeEach(func() {
+				fakeLimiter = &ratelimitfakes.FakeLimiter{}
+				fakeLimiter.TakeReturns(ratelimit.Result{Allowed: true, Tokens: 4}, nil)
+
+				limits.Limiter = fakeLimiter
+				limits.Rules = map[string]ratelimit.Rule{
+					"coupon": {Limit: ratelimit.Limit{Rate: 1, Burst: 5}, KeyBy: ratelimit.KeyByAPIKey},
+				}
+			})
+
+			It("takes from the same bucket as the REST route", func() {
+				_, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "1"})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeLimiter.TakeCallCount()).To(Equal(1))
+				_, key, limit := fakeLimiter.TakeArgsForCall(0)
+				Expect(key).To(Equal("coupon:principal:key-1"))
+				Expect(limit).To(Equal(ratelimit.Limit{Rate: 1, Burst: 5}))
+			})
+
+			It("fails with ResourceExhausted once the limit is reached, saying when to retry", func() {
+				fakeLimiter.TakeReturns(ratelimit.Result{Allowed: false, Tokens: -1}, nil)
+
+				var header metadata.MD
+				_, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "1"}, grpc.Header(&header))
+				Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+				Expect(header.Get(grpcapi.RetryAfterMetadata)).To(Equal([]string{"2"}))
+
+				Expect(fakeCouponService.GetCouponByIdCallCount()).To(Equal(0))
+			})
+
+			It("counts rate limited redemptions as refused", func() {
+				limits.Rules["coupon-redeem"] = ratelimit.Rule{Limit: ratelimit.Limit{Rate: 1, Burst: 5}, KeyBy: ratelimit.KeyByAPIKey}
+				fakeLimiter.TakeReturns(ratelimit.Result{Allowed: false, Tokens: -1}, nil)
+				before := refusedRedemptions(metrics.ReasonRateLimited)
+
+				_, err := client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "1"})
+				Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+
+				Expect(refusedRedemptions(metrics.ReasonRateLimited)).To(Equal(before + 1))
+			})
+
+			It("leaves methods on other routes alone", func() {
+				stream, err := client.ListCoupons(ctx, &grpcapi.ListCouponsRequest{})
+				Expect(err).NotTo(HaveOccurred())
+				_, err = stream.Recv()
+				Expect(err).To(HaveOccurred())
+				Expect(status.Code(err)).NotTo(Equal(codes.ResourceExhausted))
+
+				Expect(fakeLimiter.TakeCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("with the route guarded against guessing", func() {
+			var fakeLockoutStore *lockoutfakes.FakeStore
+
+			BeforeEach(func() {
+				fakeLockoutStore = &lockoutfakes.FakeStore{}
+				limits.Guard = lockout.Guard{Store: fakeLockoutStore, Alerter: &lockoutfakes.FakeAlerter{}, Policy: lockout.DefaultPolicy}
+				limits.GuardedRoutes = []string{"coupon"}
+			})
+
+			It("counts NotFound as a failed lookup of the coupon id", func() {
+				fakeCouponService.GetCouponByIdReturns(nil, sql.ErrNoRows)
+
+				_, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "SAVE0001"})
+				Expect(status.Code(err)).To(Equal(codes.NotFound))
+
+				Expect(fakeLockoutStore.RecordFailureCallCount()).To(Equal(1))
+				_, actor, identifier := fakeLockoutStore.RecordFailureArgsForCall(0)
+				Expect(actor).To(Equal("principal:key-1"))
+				Expect(identifier).To(Equal("SAVE0001"))
+			})
+
+			It("doesn't count coupons that were found", func() {
+				_, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "1"})
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(fakeLockoutStore.RecordFailureCallCount()).To(Equal(0))
+			})
+
+			It("fails with ResourceExhausted while the caller is locked out", func() {
+				fakeLockoutStore.LockedUntilReturns(time.Now().Add(90*time.Second), nil)
+
+				var header metadata.MD
+				_, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "1"}, grpc.Header(&header))
+				Expect(status.Code(err)).To(Equal(codes.ResourceExhausted))
+				Expect(header.Get(grpcapi.RetryAfterMetadata)).To(Equal([]string{"90"}))
+
+				Expect(fakeCouponService.GetCouponByIdCallCount()).To(Equal(0))
+			})
+		})
+	})
+})
+
+func refusedRedemptions(reason string) float64 {
+	families, err := metrics.Registry.Gather()
+	Expect(err).NotTo(HaveOccurred())
+
+	for _, family := range families {
+		if family.GetName() != "coupons_redemptions_total" {
+			continue
+		}
+
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+
+			if labels["outcome"] == "refused" && labels["reason"] == reason {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+
+	return 0
+}
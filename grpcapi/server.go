@@ -0,0 +1,220 @@
+package grpcapi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative coupons.proto
+
+// CouponServer serves the gRPC API from the same services as the REST handlers, so calls go through the same
+// policy, metrics and audit trail
+type CouponServer struct {
+	UnimplementedCouponServiceServer
+
+	CouponService     handlers.CouponService
+	CouponTransactor  handlers.CouponTransactor
+	CouponValidator   handlers.CouponValidator
+	RedemptionService handlers.RedemptionService
+}
+
+// NewServer serves couponServer, along with the gRPC health and reflection services. Calls go through the same
+// instrumentation, authentication and limits as requests to the REST API, in the same order. The health server is
+// returned so it can report the service isn't serving once it's shutting down.
+func NewServer(couponServer *CouponServer, instrumentation Instrumentation, authenticator Authenticator, limits Limits, idempotent Idempotency) (*grpc.Server, *health.Server) {
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(instrumentation.Unary, authenticator.Unary, limits.Unary, idempotent.Unary),
+		grpc.ChainStreamInterceptor(instrumentation.Stream, authenticator.Stream, limits.Stream),
+	)
+
+	RegisterCouponServiceServer(server, couponServer)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(CouponService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+
+	return server, healthServer
+}
+
+func (s *CouponServer) CreateCoupon(ctx context.Context, req *CreateCouponRequest) (*Coupon, error) {
+	err := requireScope(ctx, auth.ScopeCouponsWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	value := int(req.Value)
+	couponInstance := coupon.Coupon{Name: &req.Name, Brand: &req.Brand, Value: &value}
+
+	err = s.CouponValidator.Validate(couponInstance)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	createdCoupon, err := s.CouponService.CreateCoupon(ctx, couponInstance)
+	if err != nil {
+		return nil, statusFor(err)
+	}
+
+	return toProto(createdCoupon), nil
+}
+
+func (s *CouponServer) GetCoupon(ctx context.Context, req *GetCouponRequest) (*Coupon, error) {
+	err := requireScope(ctx, auth.ScopeCouponsRead)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	couponInstance, err := s.CouponService.GetCouponById(ctx, req.Id)
+	if err != nil {
+		return nil, statusFor(err)
+	}
+
+	return toProto(couponInstance), nil
+}
+
+// ListCoupons sends each coupon as it's read, so listing every coupon doesn't hold them all in memory
+func (s *CouponServer) ListCoupons(req *ListCouponsRequest, stream CouponService_ListCouponsServer) error {
+	err := requireScope(stream.Context(), auth.ScopeCouponsRead)
+	if err != nil {
+		return err
+	}
+
+	filters := handlers.Filters{Name: req.Name, Brand: req.Brand}
+	if req.Value != nil {
+		value := int(*req.Value)
+		filters.Value = &value
+	}
+
+	err = s.CouponService.StreamCoupons(stream.Context(), filters, func(couponInstance *coupon.Coupon) error {
+		return stream.Send(toProto(couponInstance))
+	})
+	if err != nil {
+		return statusFor(err)
+	}
+
+	return nil
+}
+
+// UpdateCoupon reads the coupon back in the same transaction, as the operations handler does
+func (s *CouponServer) UpdateCoupon(ctx context.Context, req *UpdateCouponRequest) (*Coupon, error) {
+	err := requireScope(ctx, auth.ScopeCouponsWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	couponInstance := coupon.Coupon{ID: req.Id, Name: req.Name, Brand: req.Brand}
+	if req.Value != nil {
+		value := int(*req.Value)
+		couponInstance.Value = &value
+	}
+
+	var updatedCoupon *coupon.Coupon
+	err = s.CouponTransactor.WithinTransaction(ctx, func(couponService handlers.CouponService) error {
+		err := couponService.UpdateCoupon(ctx, couponInstance)
+		if err != nil {
+			return err
+		}
+
+		updatedCoupon, err = couponService.GetCouponById(ctx, req.Id)
+		return err
+	})
+	if err != nil {
+		return nil, statusFor(err)
+	}
+
+	return toProto(updatedCoupon), nil
+}
+
+func (s *CouponServer) RedeemCoupon(ctx context.Context, req *RedeemCouponRequest) (*Redemption, error) {
+	err := requireScope(ctx, auth.ScopeRedemptionsWrite)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Id == "" {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	redemption, err := s.RedemptionService.RedeemCoupon(ctx, req.Id)
+	if err != nil {
+		return nil, statusFor(err)
+	}
+
+	return &Redemption{Id: redemption.ID, CouponId: redemption.CouponID, RedeemedAt: timestamppb.New(redemption.RedeemedAt)}, nil
+}
+
+// requireScope is the gRPC version of the handlers' check, failing with PermissionDenied
+func requireScope(ctx context.Context, scope string) error {
+	principal, ok := auth.PrincipalFrom(ctx)
+	if !ok || !principal.HasScope(scope) {
+		return status.Error(codes.PermissionDenied, fmt.Sprintf("the %s scope is required", scope))
+	}
+
+	return nil
+}
+
+// statusFor gives errors from the services the code the REST API's status corresponds to
+func statusFor(err error) error {
+	var permissionDenied auth.PermissionDeniedError
+
+	switch {
+	case err == sql.ErrNoRows:
+		return status.Error(codes.NotFound, "coupon not found")
+	case err == coupon.ErrExpired:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.As(err, &permissionDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func toProto(couponInstance *coupon.Coupon) *Coupon {
+	protoCoupon := &Coupon{Id: couponInstance.ID}
+
+	if couponInstance.Name != nil {
+		protoCoupon.Name = *couponInstance.Name
+	}
+
+	if couponInstance.Brand != nil {
+		protoCoupon.Brand = *couponInstance.Brand
+	}
+
+	if couponInstance.Value != nil {
+		protoCoupon.Value = int64(*couponInstance.Value)
+	}
+
+	if couponInstance.Version != nil {
+		protoCoupon.Version = int64(*couponInstance.Version)
+	}
+
+	if couponInstance.Expiry != nil {
+		protoCoupon.Expiry = timestamppb.New(*couponInstance.Expiry)
+	}
+
+	return protoCoupon
+}
@@ -0,0 +1,307 @@
+package grpcapi_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/auth/authfakes"
+	"github.com/madeleinesmith/coupons/grpcapi"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"time"
+)
+
+var _ = Describe("CouponServer", func() {
+	var (
+		fakeCouponService   *handlersfakes.FakeCouponService
+		fakeTransactor      *handlersfakes.FakeCouponTransactor
+		fakeCouponValidator *handlersfakes.FakeCouponValidator
+		fakeRedemptions     *handlersfakes.FakeRedemptionService
+		fakeStore           *authfakes.FakeAPIKeyStore
+		client              grpcapi.CouponServiceClient
+		stop                func()
+		ctx                 context.Context
+		storedCoupon        *coupon.Coupon
+	)
+
+	BeforeEach(func() {
+		fakeCouponService = &handlersfakes.FakeCouponService{}
+		fakeTransactor = &handlersfakes.FakeCouponTransactor{}
+		fakeCouponValidator = &handlersfakes.FakeCouponValidator{}
+		fakeRedemptions = &handlersfakes.FakeRedemptionService{}
+		fakeStore = &authfakes.FakeAPIKeyStore{}
+
+		fakeTransactor.WithinTransactionStub = func(ctx context.Context, fn func(handlers.CouponService) error) error {
+			return fn(fakeCouponService)
+		}
+		fakeStore.FindAPIKeyReturns(&auth.APIKey{
+			Name:     "checkout",
+			TenantID: "boots",
+			Scopes:   []string{auth.ScopeCouponsRead, auth.ScopeCouponsWrite, auth.ScopeRedemptionsWrite},
+		}, nil)
+
+		name, brand, value, version := "Save £20 at Tesco", "Tesco", 20, 2
+		storedCoupon = &coupon.Coupon{ID: "1", Name: &name, Brand: &brand, Value: &value, Version: &version}
+
+		conn, stopServer := serve(&grpcapi.CouponServer{
+			CouponService:     fakeCouponService,
+			CouponTransactor:  fakeTransactor,
+			CouponValidator:   fakeCouponValidator,
+			RedemptionService: fakeRedemptions,
+		}, grpcapi.Authenticator{APIKeyStore: fakeStore})
+		client = grpcapi.NewCouponServiceClient(conn)
+		stop = stopServer
+
+		ctx = withAPIKey("cpn_abc")
+	})
+
+	AfterEach(func() {
+		stop()
+	})
+
+	Describe("CreateCoupon", func() {
+		It("validates and creates the coupon", func() {
+			fakeCouponService.CreateCouponReturns(storedCoupon, nil)
+
+			createdCoupon, err := client.CreateCoupon(ctx, &grpcapi.CreateCouponRequest{Name: "Save £20 at Tesco", Brand: "Tesco", Value: 20})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeCouponValidator.ValidateCallCount()).To(Equal(1))
+			Expect(fakeCouponService.CreateCouponCallCount()).To(Equal(1))
+			serviceCtx, couponInstance := fakeCouponService.CreateCouponArgsForCall(0)
+			Expect(*couponInstance.Name).To(Equal("Save £20 at Tesco"))
+			Expect(*couponInstance.Brand).To(Equal("Tesco"))
+			Expect(*couponInstance.Value).To(Equal(20))
+			Expect(requestcontext.Tenant(serviceCtx)).To(Equal("boots"))
+			Expect(requestcontext.Actor(serviceCtx)).To(Equal("api-key:checkout"))
+
+			Expect(createdCoupon.Id).To(Equal("1"))
+			Expect(createdCoupon.Version).To(Equal(int64(2)))
+		})
+
+		It("fails with InvalidArgument if the coupon isn't valid", func() {
+			fakeCouponValidator.ValidateReturns(errors.New("value must be positive"))
+
+			_, err := client.CreateCoupon(ctx, &grpcapi.CreateCouponRequest{Name: "Save £20 at Tesco", Brand: "Tesco", Value: -1})
+
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+			Expect(status.Convert(err).Message()).To(Equal("value must be positive"))
+			Expect(fakeCouponService.CreateCouponCallCount()).To(Equal(0))
+		})
+
+		It("fails with PermissionDenied without the coupons:write scope", func() {
+			fakeStore.FindAPIKeyReturns(&auth.APIKey{Name: "checkout", Scopes: []string{auth.ScopeCouponsRead}}, nil)
+
+			_, err := client.CreateCoupon(ctx, &grpcapi.CreateCouponRequest{Name: "Save £20 at Tesco", Brand: "Tesco", Value: 20})
+
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+			Expect(fakeCouponService.CreateCouponCallCount()).To(Equal(0))
+		})
+
+		It("fails with PermissionDenied if the policy denies it", func() {
+			fakeCouponService.CreateCouponReturns(nil, auth.PermissionDeniedError{Permission: "create-coupons"})
+
+			_, err := client.CreateCoupon(ctx, &grpcapi.CreateCouponRequest{Name: "Save £20 at Tesco", Brand: "Tesco", Value: 20})
+
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+		})
+
+		It("fails with Internal if the coupon can't be created", func() {
+			fakeCouponService.CreateCouponReturns(nil, errors.New("connection refused"))
+
+			_, err := client.CreateCoupon(ctx, &grpcapi.CreateCouponRequest{Name: "Save £20 at Tesco", Brand: "Tesco", Value: 20})
+
+			Expect(status.Code(err)).To(Equal(codes.Internal))
+		})
+	})
+
+	Describe("GetCoupon", func() {
+		It("gets the coupon", func() {
+			fakeCouponService.GetCouponByIdReturns(storedCoupon, nil)
+
+			gotCoupon, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "1"})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, id := fakeCouponService.GetCouponByIdArgsForCall(0)
+			Expect(id).To(Equal("1"))
+			Expect(gotCoupon.Name).To(Equal("Save £20 at Tesco"))
+			Expect(gotCoupon.Brand).To(Equal("Tesco"))
+			Expect(gotCoupon.Value).To(Equal(int64(20)))
+			Expect(gotCoupon.Expiry).To(BeNil())
+		})
+
+		It("includes the coupon's expiry", func() {
+			expiry := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+			storedCoupon.Expiry = &expiry
+			fakeCouponService.GetCouponByIdReturns(storedCoupon, nil)
+
+			gotCoupon, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "1"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gotCoupon.Expiry.AsTime()).To(Equal(expiry))
+		})
+
+		It("fails with NotFound if there's no such coupon", func() {
+			fakeCouponService.GetCouponByIdReturns(nil, sql.ErrNoRows)
+
+			_, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "2"})
+
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+		})
+
+		It("fails with InvalidArgument without an id", func() {
+			_, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{})
+
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+		})
+
+		It("fails with DeadlineExceeded if the query times out", func() {
+			fakeCouponService.GetCouponByIdReturns(nil, context.DeadlineExceeded)
+
+			_, err := client.GetCoupon(ctx, &grpcapi.GetCouponRequest{Id: "1"})
+
+			Expect(status.Code(err)).To(Equal(codes.DeadlineExceeded))
+		})
+	})
+
+	Describe("ListCoupons", func() {
+		BeforeEach(func() {
+			fakeCouponService.StreamCouponsStub = func(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error {
+				for _, id := range []string{"1", "2", "3"} {
+					err := fn(&coupon.Coupon{ID: id, Brand: filters.Brand})
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		})
+
+		It("streams every matching coupon", func() {
+			brand, value := "Tesco", int64(20)
+			stream, err := client.ListCoupons(ctx, &grpcapi.ListCouponsRequest{Brand: &brand, Value: &value})
+			Expect(err).NotTo(HaveOccurred())
+
+			var ids []string
+			for {
+				listedCoupon, err := stream.Recv()
+				if err == io.EOF {
+					break
+				}
+				Expect(err).NotTo(HaveOccurred())
+
+				ids = append(ids, listedCoupon.Id)
+				Expect(listedCoupon.Brand).To(Equal("Tesco"))
+			}
+			Expect(ids).To(Equal([]string{"1", "2", "3"}))
+
+			serviceCtx, filters, _ := fakeCouponService.StreamCouponsArgsForCall(0)
+			Expect(filters.Name).To(BeNil())
+			Expect(*filters.Brand).To(Equal("Tesco"))
+			Expect(*filters.Value).To(Equal(20))
+			Expect(requestcontext.Tenant(serviceCtx)).To(Equal("boots"))
+		})
+
+		It("fails with PermissionDenied without the coupons:read scope", func() {
+			fakeStore.FindAPIKeyReturns(&auth.APIKey{Name: "checkout", Scopes: []string{auth.ScopeCouponsWrite}}, nil)
+
+			stream, err := client.ListCoupons(ctx, &grpcapi.ListCouponsRequest{})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = stream.Recv()
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+			Expect(fakeCouponService.StreamCouponsCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("UpdateCoupon", func() {
+		It("updates the fields that are set, returning the coupon as it now stands", func() {
+			fakeCouponService.GetCouponByIdReturns(storedCoupon, nil)
+
+			value := int64(20)
+			updatedCoupon, err := client.UpdateCoupon(ctx, &grpcapi.UpdateCouponRequest{Id: "1", Value: &value})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeTransactor.WithinTransactionCallCount()).To(Equal(1))
+			_, couponInstance := fakeCouponService.UpdateCouponArgsForCall(0)
+			Expect(couponInstance.ID).To(Equal("1"))
+			Expect(couponInstance.Name).To(BeNil())
+			Expect(couponInstance.Brand).To(BeNil())
+			Expect(*couponInstance.Value).To(Equal(20))
+
+			Expect(updatedCoupon.Name).To(Equal("Save £20 at Tesco"))
+			Expect(updatedCoupon.Version).To(Equal(int64(2)))
+		})
+
+		It("fails with NotFound if there's no such coupon", func() {
+			fakeCouponService.UpdateCouponReturns(sql.ErrNoRows)
+
+			_, err := client.UpdateCoupon(ctx, &grpcapi.UpdateCouponRequest{Id: "2"})
+
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+			Expect(fakeCouponService.GetCouponByIdCallCount()).To(Equal(0))
+		})
+
+		It("fails with InvalidArgument without an id", func() {
+			_, err := client.UpdateCoupon(ctx, &grpcapi.UpdateCouponRequest{})
+
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+			Expect(fakeTransactor.WithinTransactionCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("RedeemCoupon", func() {
+		It("redeems the coupon", func() {
+			redeemedAt := time.Date(2026, 10, 19, 9, 30, 0, 0, time.UTC)
+			fakeRedemptions.RedeemCouponReturns(&coupon.Redemption{ID: "9", CouponID: "1", RedeemedAt: redeemedAt}, nil)
+
+			redemption, err := client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "1"})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(redemption.Id).To(Equal("9"))
+			Expect(redemption.CouponId).To(Equal("1"))
+			Expect(redemption.RedeemedAt.AsTime()).To(Equal(redeemedAt))
+
+			_, couponId := fakeRedemptions.RedeemCouponArgsForCall(0)
+			Expect(couponId).To(Equal("1"))
+		})
+
+		It("fails with NotFound if there's no such coupon", func() {
+			fakeRedemptions.RedeemCouponReturns(nil, sql.ErrNoRows)
+
+			_, err := client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "2"})
+
+			Expect(status.Code(err)).To(Equal(codes.NotFound))
+		})
+
+		It("fails with FailedPrecondition once the coupon has expired", func() {
+			fakeRedemptions.RedeemCouponReturns(nil, coupon.ErrExpired)
+
+			_, err := client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "1"})
+
+			Expect(status.Code(err)).To(Equal(codes.FailedPrecondition))
+		})
+
+		It("fails with InvalidArgument without an id", func() {
+			_, err := client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{})
+
+			Expect(status.Code(err)).To(Equal(codes.InvalidArgument))
+			Expect(fakeRedemptions.RedeemCouponCallCount()).To(Equal(0))
+		})
+
+		It("fails with PermissionDenied without the redemptions:write scope", func() {
+			fakeStore.FindAPIKeyReturns(&auth.APIKey{Name: "checkout", Scopes: []string{auth.ScopeCouponsWrite}}, nil)
+
+			_, err := client.RedeemCoupon(ctx, &grpcapi.RedeemCouponRequest{Id: "1"})
+
+			Expect(status.Code(err)).To(Equal(codes.PermissionDenied))
+		})
+	})
+})
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/madeleinesmith/coupons/auth"
+	"net/http"
+)
+
+// requireScope writes a 403 and returns false unless the authenticated principal has been granted scope
+func requireScope(w http.ResponseWriter, req *http.Request, scope string) bool {
+	principal, ok := auth.PrincipalFrom(req.Context())
+	if !ok || !principal.HasScope(scope) {
+		handleError(w, req, fmt.Errorf("the %s scope is required", scope), http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
@@ -2,11 +2,22 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"io/ioutil"
 	"net/http"
+	"time"
 )
 
+type revertRequest struct {
+	Meta struct {
+		RevertToVersion *int `json:"revertToVersion"`
+	} `json:"meta"`
+}
+
 type CouponDetailsHandler struct {
 	CouponService CouponService
 	Serializer    CouponSerializer
@@ -16,13 +27,19 @@ func (h CouponDetailsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request
 	switch req.Method {
 	case http.MethodGet:
 		h.handleGet(w, req)
+	case http.MethodPatch:
+		h.handlePatch(w, req)
 	default:
 		err := errors.New(`Method not allowed`)
-		handleError(w, err, http.StatusMethodNotAllowed)
+		handleError(w, req, err, http.StatusMethodNotAllowed)
 	}
 }
 
 func (h CouponDetailsHandler) handleGet(w http.ResponseWriter, req *http.Request) {
+	if !requireScope(w, req, auth.ScopeCouponsRead) {
+		return
+	}
+
 	vars := mux.Vars(req)
 
 	var couponId string
@@ -30,11 +47,26 @@ func (h CouponDetailsHandler) handleGet(w http.ResponseWriter, req *http.Request
 
 	if couponId, ok = vars["couponId"]; !ok {
 		err := errors.New("couponId URL variable not found")
-		handleError(w, err, http.StatusBadRequest)
+		handleError(w, req, err, http.StatusBadRequest)
 		return
 	}
 
-	couponInstance, err := h.CouponService.GetCouponById(couponId)
+	var couponInstance *coupon.Coupon
+	var err error
+
+	if asOfString := req.URL.Query().Get("as_of"); asOfString != "" {
+		var asOf time.Time
+		asOf, err = time.Parse(time.RFC3339, asOfString)
+		if err != nil {
+			handleError(w, req, err, http.StatusBadRequest)
+			return
+		}
+
+		couponInstance, err = h.CouponService.GetCouponAsOf(req.Context(), couponId, asOf)
+	} else {
+		couponInstance, err = h.CouponService.GetCouponById(req.Context(), couponId)
+	}
+
 	if err != nil {
 		code := http.StatusInternalServerError
 
@@ -42,13 +74,13 @@ func (h CouponDetailsHandler) handleGet(w http.ResponseWriter, req *http.Request
 			code = http.StatusNotFound
 		}
 
-		handleError(w, err, code)
+		handleError(w, req, err, code)
 		return
 	}
 
 	serializedCoupon, err := h.Serializer.SerializeCoupon(couponInstance)
 	if err != nil {
-		handleError(w, err, http.StatusInternalServerError)
+		handleError(w, req, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -56,3 +88,49 @@ func (h CouponDetailsHandler) handleGet(w http.ResponseWriter, req *http.Request
 
 	w.Write(serializedCoupon)
 }
+
+// handlePatch reverts the coupon to one of its earlier versions, given as {"meta":{"revertToVersion":n}}
+func (h CouponDetailsHandler) handlePatch(w http.ResponseWriter, req *http.Request) {
+	if !requireScope(w, req, auth.ScopeCouponsWrite) {
+		return
+	}
+
+	couponId, ok := mux.Vars(req)["couponId"]
+	if !ok {
+		handleError(w, req, errors.New("couponId URL variable not found"), http.StatusBadRequest)
+		return
+	}
+
+	bodyBytes, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	var revert revertRequest
+	err = json.Unmarshal(bodyBytes, &revert)
+	if err != nil {
+		handleError(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	if revert.Meta.RevertToVersion == nil {
+		handleError(w, req, errors.New("meta.revertToVersion is required"), http.StatusBadRequest)
+		return
+	}
+
+	revertedCoupon, err := h.CouponService.RevertCoupon(req.Context(), couponId, *revert.Meta.RevertToVersion)
+	if err != nil {
+		handleError(w, req, err, statusForLookupError(err))
+		return
+	}
+
+	serializedCoupon, err := h.Serializer.SerializeCoupon(revertedCoupon)
+	if err != nil {
+		handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(serializedCoupon)
+}
@@ -1,9 +1,11 @@
 package handlers_test
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/auth"
 	"github.com/madeleinesmith/coupons/handlers"
 	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
 	"github.com/madeleinesmith/coupons/model/coupon"
@@ -11,6 +13,8 @@ import (
 	. "github.com/onsi/gomega"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"time"
 )
 
 var _ = Describe("CouponDetailsHandler", func() {
@@ -31,6 +35,7 @@ var _ = Describe("CouponDetailsHandler", func() {
 
 				request, err = http.NewRequest(http.MethodGet, "/omg/lol", nil)
 				Expect(err).ToNot(HaveOccurred())
+				request = authenticated(request, auth.ScopeCouponsRead)
 
 				recorder = httptest.NewRecorder()
 
@@ -61,7 +66,8 @@ var _ = Describe("CouponDetailsHandler", func() {
 				Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
 
 				Expect(fakeCouponService.GetCouponByIdCallCount()).To(Equal(1))
-				Expect(fakeCouponService.GetCouponByIdArgsForCall(0)).To(Equal(couponId))
+				_, requestedId := fakeCouponService.GetCouponByIdArgsForCall(0)
+				Expect(requestedId).To(Equal(couponId))
 
 				Expect(fakeCouponSerializer.SerializeCouponCallCount()).To(Equal(1))
 				Expect(fakeCouponSerializer.SerializeCouponArgsForCall(0)).To(Equal(sampleCoupon))
@@ -106,6 +112,13 @@ var _ = Describe("CouponDetailsHandler", func() {
 				Expect(string(recorder.Body.Bytes())).To(ContainSubstring("🎷🎷🎷🎷"))
 			})
 
+			It("returns a 503 if the db service runs out of time", func() {
+				fakeCouponService.GetCouponByIdReturns(nil, context.DeadlineExceeded)
+
+				handler.ServeHTTP(recorder, request)
+				Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+			})
+
 			It("propagates the error if the coupon serializer fails", func() {
 				fakeCouponSerializer.SerializeCouponReturns([]byte(""), errors.New("shocking 👻"))
 
@@ -118,6 +131,41 @@ var _ = Describe("CouponDetailsHandler", func() {
 				Expect(string(recorder.Body.Bytes())).To(ContainSubstring("shocking 👻"))
 			})
 
+			Context("with as_of", func() {
+				BeforeEach(func() {
+					request.URL.RawQuery = "as_of=2026-01-01T00:00:00Z"
+					fakeCouponService.GetCouponAsOfReturns(sampleCoupon, nil)
+				})
+
+				It("retrieves the coupon as it was at that time", func() {
+					handler.ServeHTTP(recorder, request)
+					Expect(recorder.Code).To(Equal(http.StatusOK))
+
+					Expect(fakeCouponService.GetCouponByIdCallCount()).To(Equal(0))
+					Expect(fakeCouponService.GetCouponAsOfCallCount()).To(Equal(1))
+					_, requestedId, asOf := fakeCouponService.GetCouponAsOfArgsForCall(0)
+					Expect(requestedId).To(Equal(couponId))
+					Expect(asOf).To(Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+					Expect(fakeCouponSerializer.SerializeCouponArgsForCall(0)).To(Equal(sampleCoupon))
+				})
+
+				It("returns a 404 if the coupon did not exist then", func() {
+					fakeCouponService.GetCouponAsOfReturns(nil, sql.ErrNoRows)
+
+					handler.ServeHTTP(recorder, request)
+					Expect(recorder.Code).To(Equal(http.StatusNotFound))
+				})
+
+				It("errors if as_of is not an RFC 3339 timestamp", func() {
+					request.URL.RawQuery = "as_of=yesterday"
+
+					handler.ServeHTTP(recorder, request)
+					Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+					Expect(fakeCouponService.GetCouponAsOfCallCount()).To(Equal(0))
+				})
+			})
+
 			// probs doesn't belong in the GET context but cba to do all the setup all over again
 			It("errors if the http method is not supported", func() {
 				request.Method = http.MethodOptions
@@ -129,4 +177,78 @@ var _ = Describe("CouponDetailsHandler", func() {
 			})
 		})
 	})
-})
\ No newline at end of file
+
+	Describe("PATCH endpoint", func() {
+		var (
+			recorder             *httptest.ResponseRecorder
+			fakeCouponService    handlersfakes.FakeCouponService
+			fakeCouponSerializer handlersfakes.FakeCouponSerializer
+			handler              handlers.CouponDetailsHandler
+			revertedCoupon       *coupon.Coupon
+		)
+
+		patch := func(body string) {
+			request, err := http.NewRequest(http.MethodPatch, "/coupon/123", strings.NewReader(body))
+			Expect(err).NotTo(HaveOccurred())
+
+			request = authenticated(request, auth.ScopeCouponsWrite)
+
+			handler.ServeHTTP(recorder, mux.SetURLVars(request, map[string]string{"couponId": "123"}))
+		}
+
+		BeforeEach(func() {
+			recorder = httptest.NewRecorder()
+
+			fakeCouponService = handlersfakes.FakeCouponService{}
+			fakeCouponSerializer = handlersfakes.FakeCouponSerializer{}
+
+			revertedCoupon = &coupon.Coupon{ID: "123"}
+			fakeCouponService.RevertCouponReturns(revertedCoupon, nil)
+			fakeCouponSerializer.SerializeCouponReturns([]byte("back to the future 🚗"), nil)
+
+			handler = handlers.CouponDetailsHandler{
+				CouponService: &fakeCouponService,
+				Serializer:    &fakeCouponSerializer,
+			}
+		})
+
+		It("reverts the coupon to the requested version", func() {
+			patch(`{"meta":{"revertToVersion":2}}`)
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			Expect(fakeCouponService.RevertCouponCallCount()).To(Equal(1))
+			_, couponId, version := fakeCouponService.RevertCouponArgsForCall(0)
+			Expect(couponId).To(Equal("123"))
+			Expect(version).To(Equal(2))
+
+			Expect(fakeCouponSerializer.SerializeCouponArgsForCall(0)).To(Equal(revertedCoupon))
+			Expect(recorder.Body.String()).To(Equal("back to the future 🚗"))
+		})
+
+		It("returns a 404 if the version does not exist", func() {
+			fakeCouponService.RevertCouponReturns(nil, sql.ErrNoRows)
+
+			patch(`{"meta":{"revertToVersion":9}}`)
+			Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		})
+
+		It("errors if no version is given", func() {
+			patch(`{"meta":{}}`)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+			Expect(recorder.Body.String()).To(ContainSubstring("meta.revertToVersion is required"))
+			Expect(fakeCouponService.RevertCouponCallCount()).To(Equal(0))
+		})
+
+		It("errors if the body is not JSON", func() {
+			patch(`revert please`)
+			Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("propagates the error if the db service fails", func() {
+			fakeCouponService.RevertCouponReturns(nil, errors.New("deadlock detected"))
+
+			patch(`{"meta":{"revertToVersion":2}}`)
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+})
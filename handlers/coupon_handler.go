@@ -1,26 +1,59 @@
 package handlers
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/logging"
 	"github.com/madeleinesmith/coupons/model/coupon"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"time"
+)
+
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
 )
 
 type Filters struct {
 	Name  *string
 	Value *int
 	Brand *string
+	// After and Limit page through coupons in id order: at most Limit coupons, starting after the id After
+	After *string
+	Limit *int
 }
 
 //go:generate counterfeiter . CouponService
 type CouponService interface {
-	CreateCoupon(couponInstance coupon.Coupon) (*coupon.Coupon, error)
-	UpdateCoupon(couponInstance coupon.Coupon) error
-	GetCoupons(filters Filters) ([]*coupon.Coupon, error)
-	GetCouponById(couponId string) (*coupon.Coupon, error)
+	CreateCoupon(ctx context.Context, couponInstance coupon.Coupon) (*coupon.Coupon, error)
+	CreateCoupons(ctx context.Context, coupons []coupon.Coupon) error
+	UpdateCoupon(ctx context.Context, couponInstance coupon.Coupon) error
+	GetCoupons(ctx context.Context, filters Filters) ([]*coupon.Coupon, error)
+	StreamCoupons(ctx context.Context, filters Filters, fn func(*coupon.Coupon) error) error
+	GetCouponById(ctx context.Context, couponId string) (*coupon.Coupon, error)
+	DeleteCoupon(ctx context.Context, couponId string) error
+	GetCouponAsOf(ctx context.Context, couponId string, asOf time.Time) (*coupon.Coupon, error)
+	GetCouponVersion(ctx context.Context, couponId string, version int) (*coupon.Coupon, error)
+	RevertCoupon(ctx context.Context, couponId string, version int) (*coupon.Coupon, error)
+}
+
+// RedemptionService records coupons being redeemed. Only the gRPC API redeems coupons so far, so it's kept apart from
+// CouponService, which the REST API and client implement.
+//
+//go:generate counterfeiter . RedemptionService
+type RedemptionService interface {
+	RedeemCoupon(ctx context.Context, couponId string) (*coupon.Redemption, error)
+}
+
+//go:generate counterfeiter . CouponTransactor
+type CouponTransactor interface {
+	WithinTransaction(ctx context.Context, fn func(CouponService) error) error
 }
 
 //go:generate counterfeiter . CouponSerializer
@@ -28,6 +61,7 @@ type CouponSerializer interface {
 	DeserializeCoupon(bodyBytes []byte) (coupon.Coupon, error)
 	SerializeCoupon(coupon *coupon.Coupon) ([]byte, error)
 	SerializeCoupons([]*coupon.Coupon) ([]byte, error)
+	SerializeCouponPage(coupons []*coupon.Coupon, next string) ([]byte, error)
 }
 
 //go:generate counterfeiter . CouponValidator
@@ -50,39 +84,43 @@ func (h CouponHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	case http.MethodGet:
 		h.handleGet(w, req)
 	default:
-		handleError(w, errors.New("Method not allowed"), http.StatusMethodNotAllowed)
+		handleError(w, req, errors.New("Method not allowed"), http.StatusMethodNotAllowed)
 	}
 }
 
 func (h CouponHandler) handlePost(w http.ResponseWriter, req *http.Request) {
+	if !requireScope(w, req, auth.ScopeCouponsWrite) {
+		return
+	}
+
 	bodyBytes, err := ioutil.ReadAll(req.Body)
 	if err != nil {
-		handleError(w, err, http.StatusInternalServerError)
+		handleError(w, req, err, http.StatusInternalServerError)
 		return
 	}
 
 	couponInstance, err := h.Serializer.DeserializeCoupon(bodyBytes)
 	if err != nil {
-		handleError(w, err, http.StatusBadRequest)
+		handleError(w, req, err, http.StatusBadRequest)
 		return
 	}
 
 	err = h.CouponValidator.Validate(couponInstance)
 	if err != nil {
-		handleError(w, err, http.StatusBadRequest)
+		handleError(w, req, err, http.StatusBadRequest)
 		return
 	}
 
 	// consider sanitizing the coupon i.e. removing whitespace from fields before inserting into the db
-	createdCoupon, err := h.CouponService.CreateCoupon(couponInstance)
+	createdCoupon, err := h.CouponService.CreateCoupon(req.Context(), couponInstance)
 	if err != nil {
-		handleError(w, err, http.StatusInternalServerError)
+		handleError(w, req, err, http.StatusInternalServerError)
 		return
 	}
 
 	json, err := h.Serializer.SerializeCoupon(createdCoupon)
 	if err != nil {
-		handleError(w, err, http.StatusInternalServerError)
+		handleError(w, req, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -92,21 +130,25 @@ func (h CouponHandler) handlePost(w http.ResponseWriter, req *http.Request) {
 }
 
 func (h CouponHandler) handlePatch(w http.ResponseWriter, req *http.Request) {
+	if !requireScope(w, req, auth.ScopeCouponsWrite) {
+		return
+	}
+
 	bodyBytes, err := ioutil.ReadAll(req.Body)
 	if err != nil {
-		handleError(w, err, http.StatusInternalServerError)
+		handleError(w, req, err, http.StatusInternalServerError)
 		return
 	}
 
 	couponInstance, err := h.Serializer.DeserializeCoupon(bodyBytes)
 	if err != nil {
-		handleError(w, err, http.StatusInternalServerError)
+		handleError(w, req, err, http.StatusInternalServerError)
 		return
 	}
 
-	err = h.CouponService.UpdateCoupon(couponInstance)
+	err = h.CouponService.UpdateCoupon(req.Context(), couponInstance)
 	if err != nil {
-		handleError(w, err, http.StatusInternalServerError)
+		handleError(w, req, err, statusForLookupError(err))
 		return
 	}
 
@@ -114,9 +156,69 @@ func (h CouponHandler) handlePatch(w http.ResponseWriter, req *http.Request) {
 }
 
 func (h CouponHandler) handleGet(w http.ResponseWriter, req *http.Request) {
+	if !requireScope(w, req, auth.ScopeCouponsRead) {
+		return
+	}
+
 	var coupons []*coupon.Coupon
-	var err error
 
+	filters, err := parseFilters(req)
+	if err != nil {
+		handleError(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	pageSize, err := parsePage(req, &filters)
+	if err != nil {
+		handleError(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	coupons, err = h.CouponService.GetCoupons(req.Context(), filters)
+
+	if err != nil {
+		code := http.StatusInternalServerError
+
+		// 42703 is an undefined_column error
+		pqError, ok := err.(*pq.Error)
+		if ok {
+			if pqError.Code == "42703" {
+				code = http.StatusBadRequest
+			}
+		}
+
+		if err.Error() == "sql: no rows in result set" {
+			code = http.StatusNotFound
+		}
+
+		handleError(w, req, err, code)
+		return
+	}
+
+	var serializerCoupons []byte
+	if pageSize == 0 {
+		serializerCoupons, err = h.Serializer.SerializeCoupons(coupons)
+	} else {
+		// one more coupon than the page holds was asked for, to know whether there's a next page
+		var next string
+		if len(coupons) > pageSize {
+			coupons = coupons[:pageSize]
+			next = nextPageLink(req, coupons[pageSize-1].ID)
+		}
+
+		serializerCoupons, err = h.Serializer.SerializeCouponPage(coupons, next)
+	}
+
+	if err != nil {
+		handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(serializerCoupons)
+}
+
+func parseFilters(req *http.Request) (Filters, error) {
 	var filters Filters
 
 	for queryParamsKey, queryParamsValue := range req.URL.Query() {
@@ -129,8 +231,7 @@ func (h CouponHandler) handleGet(w http.ResponseWriter, req *http.Request) {
 
 			intValue, err := strconv.Atoi(stringValue)
 			if err != nil {
-				handleError(w, err, http.StatusBadRequest)
-				return
+				return Filters{}, err
 			}
 
 			filters.Value = &intValue
@@ -141,37 +242,63 @@ func (h CouponHandler) handleGet(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 
-	coupons, err = h.CouponService.GetCoupons(filters)
+	return filters, nil
+}
 
-	if err != nil {
-		code := http.StatusInternalServerError
+// parsePage adds page[size] and page[after] to filters, asking for one coupon more than the page size. The page size
+// is 0 when the request doesn't page.
+func parsePage(req *http.Request, filters *Filters) (int, error) {
+	query := req.URL.Query()
+	sizeParam := query.Get("page[size]")
+	after := query.Get("page[after]")
 
-		// 42703 is an undefined_column error
-		pqError, ok := err.(*pq.Error)
-		if ok {
-			if pqError.Code == "42703" {
-				code = http.StatusBadRequest
-			}
+	if sizeParam == "" && after == "" {
+		return 0, nil
+	}
+
+	pageSize := DefaultPageSize
+	if sizeParam != "" {
+		var err error
+		pageSize, err = strconv.Atoi(sizeParam)
+		if err != nil || pageSize < 1 || pageSize > MaxPageSize {
+			return 0, fmt.Errorf("page[size] must be a whole number from 1 to %d, got %q", MaxPageSize, sizeParam)
 		}
+	}
 
-		if err.Error() == "sql: no rows in result set" {
-			code = http.StatusNotFound
+	if after != "" {
+		_, err := uuid.Parse(after)
+		if err != nil {
+			return 0, fmt.Errorf("page[after] must be a coupon id, got %q", after)
 		}
 
-		handleError(w, err, code)
-		return
+		filters.After = &after
 	}
 
-	serializerCoupons, err := h.Serializer.SerializeCoupons(coupons)
-	if err != nil {
-		handleError(w, err, http.StatusInternalServerError)
-		return
-	}
+	limit := pageSize + 1
+	filters.Limit = &limit
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(serializerCoupons)
+	return pageSize, nil
+}
+
+// nextPageLink is the request's own path and query, carrying on after lastId
+func nextPageLink(req *http.Request, lastId string) string {
+	query := req.URL.Query()
+	query.Set("page[after]", lastId)
+
+	return req.URL.Path + "?" + query.Encode()
 }
 
-func handleError(w http.ResponseWriter, err error, code int) {
+func handleError(w http.ResponseWriter, req *http.Request, err error, code int) {
+	var permissionDenied auth.PermissionDeniedError
+	if errors.As(err, &permissionDenied) {
+		code = http.StatusForbidden
+	}
+
+	// the route's database deadline passed; the request may succeed if retried when the database is less busy
+	if errors.Is(err, context.DeadlineExceeded) {
+		code = http.StatusServiceUnavailable
+	}
+
+	logging.Error(req.Context(), err, code)
 	http.Error(w, err.Error(), code)
 }
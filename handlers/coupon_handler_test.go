@@ -1,11 +1,20 @@
 package handlers_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"database/sql"
 	"errors"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/lib/pq"
+	"github.com/madeleinesmith/coupons/auth"
 	"github.com/madeleinesmith/coupons/handlers"
 	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
 	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/policy"
+	"github.com/madeleinesmith/coupons/policy/policyfakes"
 	"github.com/madeleinesmith/coupons/test_utils"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -13,6 +22,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 )
 
 var _ = Describe("Coupon Handler", func() {
@@ -59,6 +69,7 @@ var _ = Describe("Coupon Handler", func() {
 			body := strings.NewReader(bodyJSON)
 			request, err = http.NewRequest("POST", "/omg/lol", body)
 			Expect(err).To(BeNil())
+			request = authenticated(request, auth.ScopeCouponsWrite)
 
 			name := "Save £99 at Tesco"
 			brand := "Tesco"
@@ -109,12 +120,31 @@ var _ = Describe("Coupon Handler", func() {
 				Expect(fakeCouponValidator.ValidateArgsForCall(0)).To(Equal(expectedCoupon))
 
 				Expect(fakeCouponService.CreateCouponCallCount()).To(Equal(1))
-				Expect(fakeCouponService.CreateCouponArgsForCall(0)).To(Equal(expectedCoupon))
+				_, couponToCreate := fakeCouponService.CreateCouponArgsForCall(0)
+				Expect(couponToCreate).To(Equal(expectedCoupon))
 
 				Expect(fakeCouponSerializer.SerializeCouponCallCount()).To(Equal(1))
 				Expect(fakeCouponSerializer.SerializeCouponArgsForCall(0)).To(Equal(&createdCoupon))
 			})
 
+			It("is forbidden without the coupons:write scope", func() {
+				request = authenticated(request, auth.ScopeCouponsRead)
+
+				handler.ServeHTTP(recorder, request)
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+				Expect(recorder.Body.String()).To(ContainSubstring("the coupons:write scope is required"))
+
+				Expect(fakeCouponService.CreateCouponCallCount()).To(Equal(0))
+			})
+
+			It("is forbidden if the coupon service's policy denies it", func() {
+				fakeCouponService.CreateCouponReturns(nil, auth.PermissionDeniedError{Permission: "create-coupons"})
+
+				handler.ServeHTTP(recorder, request)
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+				Expect(recorder.Body.String()).To(ContainSubstring("permission denied: create-coupons"))
+			})
+
 			It("propagates the error if reading the request body fails", func() {
 				request.Body = ioutil.NopCloser(test_utils.DummyReader{Message: "bad bad bad"})
 
@@ -215,6 +245,7 @@ var _ = Describe("Coupon Handler", func() {
 
 			request, err = http.NewRequest("PATCH", "/omg/lol", updateBody)
 			Expect(err).ToNot(HaveOccurred())
+			request = authenticated(request, auth.ScopeCouponsWrite)
 
 			brand := "Sainsbury's"
 
@@ -235,7 +266,17 @@ var _ = Describe("Coupon Handler", func() {
 				Expect(fakeCouponSerializer.DeserializeCouponArgsForCall(0)).To(Equal([]byte(bodyJson)))
 
 				Expect(fakeCouponService.UpdateCouponCallCount()).To(Equal(1))
-				Expect(fakeCouponService.UpdateCouponArgsForCall(0)).To(Equal(expectedCoupon))
+				_, couponToUpdate := fakeCouponService.UpdateCouponArgsForCall(0)
+				Expect(couponToUpdate).To(Equal(expectedCoupon))
+			})
+
+			It("is forbidden without the coupons:write scope", func() {
+				request = authenticated(request, auth.ScopeCouponsRead)
+
+				handler.ServeHTTP(recorder, request)
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+
+				Expect(fakeCouponService.UpdateCouponCallCount()).To(Equal(0))
 			})
 
 			It("propagates the error if reading the request body fails", func() {
@@ -258,6 +299,13 @@ var _ = Describe("Coupon Handler", func() {
 				Expect(fakeCouponService.UpdateCouponCallCount()).To(Equal(0))
 			})
 
+			It("returns a 404 if the coupon does not exist", func() {
+				fakeCouponService.UpdateCouponReturns(sql.ErrNoRows)
+
+				handler.ServeHTTP(recorder, request)
+				Expect(recorder.Code).To(Equal(http.StatusNotFound))
+			})
+
 			It("propagates the error if the db service fails", func() {
 				fakeCouponService.UpdateCouponReturns(errors.New("db service failure"))
 
@@ -268,6 +316,72 @@ var _ = Describe("Coupon Handler", func() {
 				Expect(fakeCouponService.UpdateCouponCallCount()).To(Equal(1))
 			})
 		})
+
+		Context("with a finance bearer token", func() {
+			var (
+				signer  jose.Signer
+				jwtAuth func(http.Handler) http.Handler
+			)
+
+			BeforeEach(func() {
+				privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				Expect(err).NotTo(HaveOccurred())
+
+				signer, err = jose.NewSigner(
+					jose.SigningKey{Algorithm: jose.ES256, Key: jose.JSONWebKey{Key: privateKey, KeyID: "ec-1"}},
+					(&jose.SignerOptions{}).WithType("JWT"),
+				)
+				Expect(err).NotTo(HaveOccurred())
+
+				jwtAuth = auth.JWTMiddleware(auth.JWTValidator{
+					Keys: &auth.StaticKeySet{Keys: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+						{Key: privateKey.Public(), KeyID: "ec-1", Algorithm: string(jose.ES256), Use: "sig"},
+					}}},
+					Issuer:   "https://login.example.com",
+					Audience: "coupons",
+				})
+
+				// the handler is served as it is in main, behind the policy
+				handler.CouponService = policy.CouponService{
+					Next:    fakeCouponService,
+					Policy:  policy.DefaultPolicy,
+					Denials: &policyfakes.FakeDenialRecorder{},
+				}
+
+				token, err := jwt.Signed(signer).Claims(map[string]interface{}{
+					"iss":       "https://login.example.com",
+					"aud":       "coupons",
+					"sub":       "finance-team",
+					"exp":       time.Now().Add(time.Hour).Unix(),
+					"roles":     []string{auth.RoleFinance},
+					"tenant_id": "boots",
+				}).Serialize()
+				Expect(err).NotTo(HaveOccurred())
+
+				request, err = http.NewRequest("PATCH", "/omg/lol", strings.NewReader(bodyJson))
+				Expect(err).NotTo(HaveOccurred())
+				request.Header.Set("Authorization", "Bearer "+token)
+			})
+
+			It("can change a coupon's value", func() {
+				value := 25
+				fakeCouponSerializer.DeserializeCouponReturns(coupon.Coupon{ID: "0faec7ea-239f-11e9-9e44-d770694a0159", Value: &value}, nil)
+
+				jwtAuth(handler).ServeHTTP(recorder, request)
+				Expect(recorder.Code).To(Equal(http.StatusNoContent))
+
+				Expect(fakeCouponService.UpdateCouponCallCount()).To(Equal(1))
+				_, couponToUpdate := fakeCouponService.UpdateCouponArgsForCall(0)
+				Expect(*couponToUpdate.Value).To(Equal(25))
+			})
+
+			It("is forbidden from changing anything else", func() {
+				jwtAuth(handler).ServeHTTP(recorder, request)
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+
+				Expect(fakeCouponService.UpdateCouponCallCount()).To(Equal(0))
+			})
+		})
 	})
 
 	Describe("GET endpoint", func() {
@@ -285,6 +399,7 @@ var _ = Describe("Coupon Handler", func() {
 
 			request, err = http.NewRequest(http.MethodGet, "/coupons", nil)
 			Expect(err).NotTo(HaveOccurred())
+			request = authenticated(request, auth.ScopeCouponsRead)
 
 			fakeCouponSerializer = handlersfakes.FakeCouponSerializer{}
 			fakeCouponService = handlersfakes.FakeCouponService{}
@@ -328,7 +443,8 @@ var _ = Describe("Coupon Handler", func() {
 				Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
 
 				Expect(fakeCouponService.GetCouponsCallCount()).To(Equal(1))
-				Expect(fakeCouponService.GetCouponsArgsForCall(0)).To(Equal(handlers.Filters{}))
+				_, filters := fakeCouponService.GetCouponsArgsForCall(0)
+				Expect(filters).To(Equal(handlers.Filters{}))
 
 				Expect(fakeCouponSerializer.SerializeCouponsCallCount()).To(Equal(1))
 				Expect(fakeCouponSerializer.SerializeCouponsArgsForCall(0)).To(Equal(couponsSlice))
@@ -392,7 +508,8 @@ var _ = Describe("Coupon Handler", func() {
 				expectedValue := 30
 				expectedName := "Hello world"
 
-				Expect(fakeCouponService.GetCouponsArgsForCall(0)).To(Equal(handlers.Filters{
+				_, filters := fakeCouponService.GetCouponsArgsForCall(0)
+				Expect(filters).To(Equal(handlers.Filters{
 					Brand: &expectedBrand,
 					Value: &expectedValue,
 					Name:  &expectedName,
@@ -410,5 +527,77 @@ var _ = Describe("Coupon Handler", func() {
 				Expect(fakeCouponService.GetCouponsCallCount()).To(Equal(0))
 			})
 		})
+
+		// /coupons?page[size]=1&page[after]=...
+		Context("Getting a page of coupons", func() {
+			BeforeEach(func() {
+				fakeCouponSerializer.SerializeCouponPageReturns([]byte(`📄`), nil)
+			})
+
+			It("asks for one coupon more than the page, and links to the page after it", func() {
+				request.URL.RawQuery = "brand=Vue&page%5Bsize%5D=1&page%5Bafter%5D=00000000-0000-0000-0000-000000000000"
+
+				couponHandler.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(recorder.Body.String()).To(Equal(`📄`))
+
+				_, filters := fakeCouponService.GetCouponsArgsForCall(0)
+				Expect(*filters.Brand).To(Equal("Vue"))
+				Expect(*filters.After).To(Equal("00000000-0000-0000-0000-000000000000"))
+				Expect(*filters.Limit).To(Equal(2))
+
+				Expect(fakeCouponSerializer.SerializeCouponsCallCount()).To(Equal(0))
+				coupons, next := fakeCouponSerializer.SerializeCouponPageArgsForCall(0)
+				Expect(coupons).To(Equal(couponsSlice[:1]))
+				Expect(next).To(Equal("/coupons?brand=Vue&page%5Bafter%5D=c1c16d12-1c0a-11e9-a3a3-9fd4e9cc6238&page%5Bsize%5D=1"))
+			})
+
+			It("has no next link on the last page", func() {
+				request.URL.RawQuery = "page%5Bsize%5D=2"
+
+				couponHandler.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+
+				_, filters := fakeCouponService.GetCouponsArgsForCall(0)
+				Expect(filters.After).To(BeNil())
+				Expect(*filters.Limit).To(Equal(3))
+
+				coupons, next := fakeCouponSerializer.SerializeCouponPageArgsForCall(0)
+				Expect(coupons).To(Equal(couponsSlice))
+				Expect(next).To(BeEmpty())
+			})
+
+			It("pages by the default page size when only given where to start", func() {
+				request.URL.RawQuery = "page%5Bafter%5D=c1c16d12-1c0a-11e9-a3a3-9fd4e9cc6238"
+
+				couponHandler.ServeHTTP(recorder, request)
+
+				_, filters := fakeCouponService.GetCouponsArgsForCall(0)
+				Expect(*filters.Limit).To(Equal(handlers.DefaultPageSize + 1))
+			})
+
+			It("returns a 400 if the page size is out of range", func() {
+				for _, size := range []string{"0", "1001", "ten"} {
+					recorder = httptest.NewRecorder()
+					request.URL.RawQuery = "page%5Bsize%5D=" + size
+
+					couponHandler.ServeHTTP(recorder, request)
+					Expect(recorder.Code).To(Equal(http.StatusBadRequest), size)
+				}
+
+				Expect(fakeCouponService.GetCouponsCallCount()).To(Equal(0))
+			})
+
+			It("returns a 400 if the page doesn't start after a coupon id", func() {
+				request.URL.RawQuery = "page%5Bafter%5D=nope"
+
+				couponHandler.ServeHTTP(recorder, request)
+
+				Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(fakeCouponService.GetCouponsCallCount()).To(Equal(0))
+			})
+		})
 	})
 })
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/model/audit"
+	"net/http"
+)
+
+//go:generate counterfeiter . CouponAuditService
+type CouponAuditService interface {
+	GetCouponHistory(ctx context.Context, couponId string) ([]*audit.Entry, error)
+}
+
+//go:generate counterfeiter . AuditSerializer
+type AuditSerializer interface {
+	SerializeEntries(entries []*audit.Entry) ([]byte, error)
+}
+
+type CouponHistoryHandler struct {
+	AuditService CouponAuditService
+	Serializer   AuditSerializer
+}
+
+func (h CouponHistoryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		h.handleGet(w, req)
+	default:
+		handleError(w, req, errors.New("Method not allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h CouponHistoryHandler) handleGet(w http.ResponseWriter, req *http.Request) {
+	if !requireScope(w, req, auth.ScopeCouponsRead) {
+		return
+	}
+
+	couponId, ok := mux.Vars(req)["couponId"]
+	if !ok {
+		handleError(w, req, errors.New("couponId URL variable not found"), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := h.AuditService.GetCouponHistory(req.Context(), couponId)
+	if err != nil {
+		handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	serializedEntries, err := h.Serializer.SerializeEntries(entries)
+	if err != nil {
+		handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(serializedEntries)
+}
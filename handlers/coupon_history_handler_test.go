@@ -0,0 +1,92 @@
+package handlers_test
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/model/audit"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("CouponHistoryHandler", func() {
+	var (
+		request             *http.Request
+		recorder            *httptest.ResponseRecorder
+		fakeAuditService    *handlersfakes.FakeCouponAuditService
+		fakeAuditSerializer *handlersfakes.FakeAuditSerializer
+		handler             handlers.CouponHistoryHandler
+		entries             []*audit.Entry
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		request, err = http.NewRequest(http.MethodGet, "/coupon/123/history", nil)
+		Expect(err).ToNot(HaveOccurred())
+		request = mux.SetURLVars(authenticated(request, auth.ScopeCouponsRead), map[string]string{"couponId": "123"})
+
+		recorder = httptest.NewRecorder()
+
+		fakeAuditService = &handlersfakes.FakeCouponAuditService{}
+		fakeAuditSerializer = &handlersfakes.FakeAuditSerializer{}
+
+		entries = []*audit.Entry{{ID: "1", CouponID: "123", Action: audit.ActionCreate}}
+		fakeAuditService.GetCouponHistoryReturns(entries, nil)
+		fakeAuditSerializer.SerializeEntriesReturns([]byte("a long time ago 📜"), nil)
+
+		handler = handlers.CouponHistoryHandler{
+			AuditService: fakeAuditService,
+			Serializer:   fakeAuditSerializer,
+		}
+	})
+
+	It("successfully retrieves a coupon's history", func() {
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(recorder.Body.String()).To(Equal("a long time ago 📜"))
+
+		_, couponId := fakeAuditService.GetCouponHistoryArgsForCall(0)
+		Expect(couponId).To(Equal("123"))
+		Expect(fakeAuditSerializer.SerializeEntriesArgsForCall(0)).To(Equal(entries))
+	})
+
+	It("returns a 400 if the couponId URL variable is missing", func() {
+		request = mux.SetURLVars(request, map[string]string{})
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("propagates the error if the audit service fails", func() {
+		fakeAuditService.GetCouponHistoryReturns(nil, errors.New("🔥"))
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(fakeAuditSerializer.SerializeEntriesCallCount()).To(Equal(0))
+	})
+
+	It("propagates the error if serialization fails", func() {
+		fakeAuditSerializer.SerializeEntriesReturns(nil, errors.New("🙃"))
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("errors if the method is unsupported", func() {
+		request.Method = http.MethodDelete
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/auth"
+	"net/http"
+	"strconv"
+)
+
+type CouponVersionHandler struct {
+	CouponService CouponService
+	Serializer    CouponSerializer
+}
+
+func (h CouponVersionHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		h.handleGet(w, req)
+	default:
+		handleError(w, req, errors.New("Method not allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h CouponVersionHandler) handleGet(w http.ResponseWriter, req *http.Request) {
+	if !requireScope(w, req, auth.ScopeCouponsRead) {
+		return
+	}
+
+	vars := mux.Vars(req)
+
+	couponId, ok := vars["couponId"]
+	if !ok {
+		handleError(w, req, errors.New("couponId URL variable not found"), http.StatusBadRequest)
+		return
+	}
+
+	version, err := strconv.Atoi(vars["version"])
+	if err != nil {
+		handleError(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	couponInstance, err := h.CouponService.GetCouponVersion(req.Context(), couponId, version)
+	if err != nil {
+		handleError(w, req, err, statusForLookupError(err))
+		return
+	}
+
+	serializedCoupon, err := h.Serializer.SerializeCoupon(couponInstance)
+	if err != nil {
+		handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(serializedCoupon)
+}
@@ -0,0 +1,92 @@
+package handlers_test
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("CouponVersionHandler", func() {
+	var (
+		request              *http.Request
+		recorder             *httptest.ResponseRecorder
+		fakeCouponService    handlersfakes.FakeCouponService
+		fakeCouponSerializer handlersfakes.FakeCouponSerializer
+		handler              handlers.CouponVersionHandler
+		sampleCoupon         *coupon.Coupon
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		request, err = http.NewRequest(http.MethodGet, "/coupon/123/versions/2", nil)
+		Expect(err).NotTo(HaveOccurred())
+		request = mux.SetURLVars(authenticated(request, auth.ScopeCouponsRead), map[string]string{"couponId": "123", "version": "2"})
+
+		recorder = httptest.NewRecorder()
+
+		fakeCouponService = handlersfakes.FakeCouponService{}
+		fakeCouponSerializer = handlersfakes.FakeCouponSerializer{}
+
+		sampleCoupon = &coupon.Coupon{ID: "123"}
+		fakeCouponService.GetCouponVersionReturns(sampleCoupon, nil)
+		fakeCouponSerializer.SerializeCouponReturns([]byte("version two 📼"), nil)
+
+		handler = handlers.CouponVersionHandler{
+			CouponService: &fakeCouponService,
+			Serializer:    &fakeCouponSerializer,
+		}
+	})
+
+	It("retrieves the requested version of the coupon", func() {
+		handler.ServeHTTP(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+
+		Expect(fakeCouponService.GetCouponVersionCallCount()).To(Equal(1))
+		_, couponId, version := fakeCouponService.GetCouponVersionArgsForCall(0)
+		Expect(couponId).To(Equal("123"))
+		Expect(version).To(Equal(2))
+
+		Expect(fakeCouponSerializer.SerializeCouponArgsForCall(0)).To(Equal(sampleCoupon))
+		Expect(recorder.Body.String()).To(Equal("version two 📼"))
+	})
+
+	It("errors if the version is not a number", func() {
+		request = mux.SetURLVars(request, map[string]string{"couponId": "123", "version": "latest"})
+
+		handler.ServeHTTP(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(fakeCouponService.GetCouponVersionCallCount()).To(Equal(0))
+	})
+
+	It("returns a 404 if the version does not exist", func() {
+		fakeCouponService.GetCouponVersionReturns(nil, sql.ErrNoRows)
+
+		handler.ServeHTTP(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("propagates the error if the db service fails", func() {
+		fakeCouponService.GetCouponVersionReturns(nil, errors.New("🎺"))
+
+		handler.ServeHTTP(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(recorder.Body.String()).To(ContainSubstring("🎺"))
+	})
+
+	It("errors if the http method is not supported", func() {
+		request.Method = http.MethodDelete
+
+		handler.ServeHTTP(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
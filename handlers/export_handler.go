@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/exporters"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"net/http"
+)
+
+// exportFlushRows is how many coupons are exported between flushes, so clients get rows as they're read rather than
+// whenever a buffer fills
+const exportFlushRows = 100
+
+type ExportHandler struct {
+	CouponService CouponService
+}
+
+// writeTracker records whether any of the response body has gone out, after which the status can no longer change
+type writeTracker struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *writeTracker) Write(p []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(p)
+}
+
+func (h ExportHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		h.handleGet(w, req)
+	default:
+		handleError(w, req, errors.New("Method not allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h ExportHandler) handleGet(w http.ResponseWriter, req *http.Request) {
+	if !requireScope(w, req, auth.ScopeCouponsRead) {
+		return
+	}
+
+	format := req.URL.Query().Get("format")
+	if format == "" {
+		format = "csv"
+	}
+
+	filters, err := parseFilters(req)
+	if err != nil {
+		handleError(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	tracker := &writeTracker{ResponseWriter: w}
+
+	exportWriter, err := exporters.NewWriter(format, tracker)
+	if err != nil {
+		handleError(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", exportWriter.ContentType())
+	if format == "csv" {
+		w.Header().Set("Content-Disposition", `attachment; filename="coupons.csv"`)
+	}
+
+	rows := 0
+	err = h.CouponService.StreamCoupons(req.Context(), filters, func(couponInstance *coupon.Coupon) error {
+		err := exportWriter.Write(couponInstance)
+		if err != nil {
+			return err
+		}
+
+		rows++
+		if rows%exportFlushRows == 0 {
+			err = exportWriter.Flush()
+			if err != nil {
+				return err
+			}
+
+			// not every ResponseWriter can flush, and the rows will still go out when it's done
+			http.NewResponseController(w).Flush()
+		}
+
+		return nil
+	})
+	if err == nil {
+		err = exportWriter.Close()
+	}
+
+	if err != nil {
+		if !tracker.written {
+			w.Header().Del("Content-Disposition")
+			handleError(w, req, err, http.StatusInternalServerError)
+			return
+		}
+
+		// part of the export has already been sent with a 200, so cut the connection rather than let the
+		// client think a truncated file is complete
+		panic(http.ErrAbortHandler)
+	}
+}
@@ -0,0 +1,278 @@
+package handlers_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/idempotency"
+	"github.com/madeleinesmith/coupons/idempotency/idempotencyfakes"
+	"github.com/madeleinesmith/coupons/lockout"
+	"github.com/madeleinesmith/coupons/lockout/lockoutfakes"
+	"github.com/madeleinesmith/coupons/logging"
+	"github.com/madeleinesmith/coupons/metrics"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/ratelimit"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"github.com/madeleinesmith/coupons/tracing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ = Describe("ExportHandler", func() {
+	var (
+		recorder          *httptest.ResponseRecorder
+		request           *http.Request
+		handler           handlers.ExportHandler
+		fakeCouponService *handlersfakes.FakeCouponService
+		coupons           []*coupon.Coupon
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		fakeCouponService = &handlersfakes.FakeCouponService{}
+
+		name1, brand1, value1 := "Save £5 at Tesco", "Tesco", 5
+		name2, brand2, value2 := "Save £7 at Tesco", "Tesco", 7
+		coupons = []*coupon.Coupon{
+			{ID: "1", Name: &name1, Brand: &brand1, Value: &value1},
+			{ID: "2", Name: &name2, Brand: &brand2, Value: &value2},
+		}
+
+		fakeCouponService.StreamCouponsStub = func(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error {
+			for _, couponInstance := range coupons {
+				err := fn(couponInstance)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		handler = handlers.ExportHandler{
+			CouponService: fakeCouponService,
+		}
+
+		recorder = httptest.NewRecorder()
+
+		request, err = http.NewRequest(http.MethodGet, "/coupons/export?brand=Tesco&value=5", nil)
+		Expect(err).NotTo(HaveOccurred())
+		request = authenticated(request, auth.ScopeCouponsRead)
+	})
+
+	It("streams the filtered coupons as csv by default", func() {
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("text/csv"))
+		Expect(recorder.Header().Get("Content-Disposition")).To(Equal(`attachment; filename="coupons.csv"`))
+		Expect(recorder.Body.String()).To(Equal("id,name,brand,value,expiry\n1,Save £5 at Tesco,Tesco,5,\n2,Save £7 at Tesco,Tesco,7,\n"))
+
+		expectedBrand := "Tesco"
+		expectedValue := 5
+		Expect(fakeCouponService.StreamCouponsCallCount()).To(Equal(1))
+		_, filters, _ := fakeCouponService.StreamCouponsArgsForCall(0)
+		Expect(filters).To(Equal(handlers.Filters{Brand: &expectedBrand, Value: &expectedValue}))
+	})
+
+	It("streams the coupons as ndjson", func() {
+		request.URL.RawQuery = "format=ndjson"
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/x-ndjson"))
+		Expect(recorder.Body.String()).To(Equal(`{"id":"1","name":"Save £5 at Tesco","brand":"Tesco","value":5,"expiry":null}
+{"id":"2","name":"Save £7 at Tesco","brand":"Tesco","value":7,"expiry":null}
+`))
+	})
+
+	It("streams the coupons as a JSON:API document", func() {
+		request.URL.RawQuery = "format=jsonapi"
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(recorder.Body.String()).To(ContainSubstring(`{"data":[{"type":"coupons","id":"1"`))
+	})
+
+	It("returns a 400 if the format is unknown", func() {
+		request.URL.RawQuery = "format=xlsx"
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(fakeCouponService.StreamCouponsCallCount()).To(Equal(0))
+	})
+
+	It("returns a 400 if the filters are invalid", func() {
+		request.URL.RawQuery = "value=lots"
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(fakeCouponService.StreamCouponsCallCount()).To(Equal(0))
+	})
+
+	It("returns a 500 if the coupon service fails before anything is written", func() {
+		fakeCouponService.StreamCouponsStub = nil
+		fakeCouponService.StreamCouponsReturns(errors.New("cursor does not exist"))
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(recorder.Header().Get("Content-Disposition")).To(BeEmpty())
+	})
+
+	It("aborts the response if the coupon service fails part way through", func() {
+		fakeCouponService.StreamCouponsStub = func(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error {
+			Expect(fn(coupons[0])).To(Succeed())
+			return errors.New("connection reset")
+		}
+		request.URL.RawQuery = "format=ndjson"
+
+		defer func() {
+			Expect(recover()).To(Equal(http.ErrAbortHandler))
+		}()
+
+		handler.ServeHTTP(recorder, request)
+		Fail("expected the handler to abort")
+	})
+
+	It("flushes every hundred coupons", func() {
+		coupons = make([]*coupon.Coupon, 250)
+		for i := range coupons {
+			coupons[i] = &coupon.Coupon{ID: strconv.Itoa(i)}
+		}
+
+		flushes := 0
+		fakeCouponService.StreamCouponsStub = func(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error {
+			for _, couponInstance := range coupons {
+				Expect(fn(couponInstance)).To(Succeed())
+				if recorder.Flushed {
+					flushes++
+					recorder.Flushed = false
+				}
+			}
+			return nil
+		}
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(flushes).To(Equal(2))
+		Expect(strings.Count(recorder.Body.String(), "\n")).To(Equal(251))
+	})
+
+	Context("through the middleware", func() {
+		var (
+			server  *httptest.Server
+			carryOn chan struct{}
+		)
+
+		BeforeEach(func() {
+			carryOn = make(chan struct{})
+
+			fakeCouponService.StreamCouponsStub = func(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error {
+				for i := 0; i < 200; i++ {
+					if i == 100 {
+						select {
+						case <-carryOn:
+						case <-ctx.Done():
+							return ctx.Err()
+						}
+					}
+
+					err := fn(&coupon.Coupon{ID: strconv.Itoa(i)})
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			router := mux.NewRouter()
+			router.NewRoute().Name("coupons-export").Path("/coupons/export").Handler(handler)
+
+			// the same middleware as main.go, bar authentication
+			router.Use(requestcontext.Middleware)
+			router.Use(logging.AccessLog(slog.New(slog.NewJSONHandler(ioutil.Discard, nil))))
+			router.Use(tracing.Middleware)
+			router.Use(metrics.Middleware)
+			router.Use(requestcontext.Deadlines(time.Minute, nil))
+			router.Use(func(next http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+					next.ServeHTTP(w, authenticated(req, auth.ScopeCouponsRead))
+				})
+			})
+			router.Use(logging.Principal)
+			router.Use(ratelimit.Middleware(&ratelimit.MemoryLimiter{}, nil))
+			router.Use(lockout.Guard{Store: &lockoutfakes.FakeStore{}}.Middleware("coupons-export"))
+			router.Use(idempotency.Middleware(&idempotencyfakes.FakeStore{}, time.Hour, time.Minute))
+
+			server = httptest.NewServer(router)
+		})
+
+		AfterEach(func() {
+			// a failed spec may have left the export waiting
+			select {
+			case <-carryOn:
+			default:
+				close(carryOn)
+			}
+
+			server.Close()
+		})
+
+		It("sends rows while the export is still being read", func() {
+			// without flushing, not even the headers would arrive before the service carries on
+			client := &http.Client{Timeout: 5 * time.Second}
+			response, err := client.Get(server.URL + "/coupons/export")
+			Expect(err).NotTo(HaveOccurred())
+			defer response.Body.Close()
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+			lines := make(chan string, 202)
+			go func() {
+				defer GinkgoRecover()
+				defer close(lines)
+
+				scanner := bufio.NewScanner(response.Body)
+				for scanner.Scan() {
+					lines <- scanner.Text()
+				}
+			}()
+
+			// the header and first hundred rows arrive while the service waits to read the rest
+			Eventually(lines).Should(Receive(Equal("id,name,brand,value,expiry")))
+			for i := 0; i < 100; i++ {
+				Eventually(lines).Should(Receive(Equal(strconv.Itoa(i) + ",,,,")))
+			}
+
+			close(carryOn)
+			for i := 100; i < 200; i++ {
+				Eventually(lines).Should(Receive(Equal(strconv.Itoa(i) + ",,,,")))
+			}
+			Eventually(lines).Should(BeClosed())
+		})
+	})
+
+	It("errors if the method is unsupported", func() {
+		request.Method = http.MethodPost
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
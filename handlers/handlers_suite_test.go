@@ -1,6 +1,8 @@
 package handlers_test
 
 import (
+	"github.com/madeleinesmith/coupons/auth"
+	"net/http"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
@@ -11,3 +13,8 @@ func TestHandlers(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Handlers Suite")
 }
+
+// authenticated fakes what the API key middleware would have done for a key with the given scopes
+func authenticated(req *http.Request, scopes ...string) *http.Request {
+	return req.WithContext(auth.WithPrincipal(req.Context(), &auth.Principal{Name: "test", Scopes: scopes}))
+}
@@ -0,0 +1,121 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package handlersfakes
+
+import (
+	"sync"
+
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/audit"
+)
+
+type FakeAuditSerializer struct {
+	SerializeEntriesStub        func([]*audit.Entry) ([]byte, error)
+	serializeEntriesMutex       sync.RWMutex
+	serializeEntriesArgsForCall []struct {
+		arg1 []*audit.Entry
+	}
+	serializeEntriesReturns struct {
+		result1 []byte
+		result2 error
+	}
+	serializeEntriesReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeAuditSerializer) SerializeEntries(arg1 []*audit.Entry) ([]byte, error) {
+	var arg1Copy []*audit.Entry
+	if arg1 != nil {
+		arg1Copy = make([]*audit.Entry, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	fake.serializeEntriesMutex.Lock()
+	ret, specificReturn := fake.serializeEntriesReturnsOnCall[len(fake.serializeEntriesArgsForCall)]
+	fake.serializeEntriesArgsForCall = append(fake.serializeEntriesArgsForCall, struct {
+		arg1 []*audit.Entry
+	}{arg1Copy})
+	fake.recordInvocation("SerializeEntries", []interface{}{arg1Copy})
+	fake.serializeEntriesMutex.Unlock()
+	if fake.SerializeEntriesStub != nil {
+		return fake.SerializeEntriesStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.serializeEntriesReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeAuditSerializer) SerializeEntriesCallCount() int {
+	fake.serializeEntriesMutex.RLock()
+	defer fake.serializeEntriesMutex.RUnlock()
+	return len(fake.serializeEntriesArgsForCall)
+}
+
+func (fake *FakeAuditSerializer) SerializeEntriesCalls(stub func([]*audit.Entry) ([]byte, error)) {
+	fake.serializeEntriesMutex.Lock()
+	defer fake.serializeEntriesMutex.Unlock()
+	fake.SerializeEntriesStub = stub
+}
+
+func (fake *FakeAuditSerializer) SerializeEntriesArgsForCall(i int) []*audit.Entry {
+	fake.serializeEntriesMutex.RLock()
+	defer fake.serializeEntriesMutex.RUnlock()
+	argsForCall := fake.serializeEntriesArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeAuditSerializer) SerializeEntriesReturns(result1 []byte, result2 error) {
+	fake.serializeEntriesMutex.Lock()
+	defer fake.serializeEntriesMutex.Unlock()
+	fake.SerializeEntriesStub = nil
+	fake.serializeEntriesReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAuditSerializer) SerializeEntriesReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.serializeEntriesMutex.Lock()
+	defer fake.serializeEntriesMutex.Unlock()
+	fake.SerializeEntriesStub = nil
+	if fake.serializeEntriesReturnsOnCall == nil {
+		fake.serializeEntriesReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.serializeEntriesReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeAuditSerializer) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.serializeEntriesMutex.RLock()
+	defer fake.serializeEntriesMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeAuditSerializer) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ handlers.AuditSerializer = new(FakeAuditSerializer)
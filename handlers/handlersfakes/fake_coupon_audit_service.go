@@ -0,0 +1,119 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package handlersfakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/audit"
+)
+
+type FakeCouponAuditService struct {
+	GetCouponHistoryStub        func(context.Context, string) ([]*audit.Entry, error)
+	getCouponHistoryMutex       sync.RWMutex
+	getCouponHistoryArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	getCouponHistoryReturns struct {
+		result1 []*audit.Entry
+		result2 error
+	}
+	getCouponHistoryReturnsOnCall map[int]struct {
+		result1 []*audit.Entry
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeCouponAuditService) GetCouponHistory(arg1 context.Context, arg2 string) ([]*audit.Entry, error) {
+	fake.getCouponHistoryMutex.Lock()
+	ret, specificReturn := fake.getCouponHistoryReturnsOnCall[len(fake.getCouponHistoryArgsForCall)]
+	fake.getCouponHistoryArgsForCall = append(fake.getCouponHistoryArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("GetCouponHistory", []interface{}{arg1, arg2})
+	fake.getCouponHistoryMutex.Unlock()
+	if fake.GetCouponHistoryStub != nil {
+		return fake.GetCouponHistoryStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.getCouponHistoryReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeCouponAuditService) GetCouponHistoryCallCount() int {
+	fake.getCouponHistoryMutex.RLock()
+	defer fake.getCouponHistoryMutex.RUnlock()
+	return len(fake.getCouponHistoryArgsForCall)
+}
+
+func (fake *FakeCouponAuditService) GetCouponHistoryCalls(stub func(context.Context, string) ([]*audit.Entry, error)) {
+	fake.getCouponHistoryMutex.Lock()
+	defer fake.getCouponHistoryMutex.Unlock()
+	fake.GetCouponHistoryStub = stub
+}
+
+func (fake *FakeCouponAuditService) GetCouponHistoryArgsForCall(i int) (context.Context, string) {
+	fake.getCouponHistoryMutex.RLock()
+	defer fake.getCouponHistoryMutex.RUnlock()
+	argsForCall := fake.getCouponHistoryArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeCouponAuditService) GetCouponHistoryReturns(result1 []*audit.Entry, result2 error) {
+	fake.getCouponHistoryMutex.Lock()
+	defer fake.getCouponHistoryMutex.Unlock()
+	fake.GetCouponHistoryStub = nil
+	fake.getCouponHistoryReturns = struct {
+		result1 []*audit.Entry
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCouponAuditService) GetCouponHistoryReturnsOnCall(i int, result1 []*audit.Entry, result2 error) {
+	fake.getCouponHistoryMutex.Lock()
+	defer fake.getCouponHistoryMutex.Unlock()
+	fake.GetCouponHistoryStub = nil
+	if fake.getCouponHistoryReturnsOnCall == nil {
+		fake.getCouponHistoryReturnsOnCall = make(map[int]struct {
+			result1 []*audit.Entry
+			result2 error
+		})
+	}
+	fake.getCouponHistoryReturnsOnCall[i] = struct {
+		result1 []*audit.Entry
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCouponAuditService) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.getCouponHistoryMutex.RLock()
+	defer fake.getCouponHistoryMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeCouponAuditService) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ handlers.CouponAuditService = new(FakeCouponAuditService)
@@ -35,6 +35,20 @@ type FakeCouponSerializer struct {
 		result1 []byte
 		result2 error
 	}
+	SerializeCouponPageStub        func([]*coupon.Coupon, string) ([]byte, error)
+	serializeCouponPageMutex       sync.RWMutex
+	serializeCouponPageArgsForCall []struct {
+		arg1 []*coupon.Coupon
+		arg2 string
+	}
+	serializeCouponPageReturns struct {
+		result1 []byte
+		result2 error
+	}
+	serializeCouponPageReturnsOnCall map[int]struct {
+		result1 []byte
+		result2 error
+	}
 	SerializeCouponsStub        func([]*coupon.Coupon) ([]byte, error)
 	serializeCouponsMutex       sync.RWMutex
 	serializeCouponsArgsForCall []struct {
@@ -183,6 +197,75 @@ func (fake *FakeCouponSerializer) SerializeCouponReturnsOnCall(i int, result1 []
 	}{result1, result2}
 }
 
+func (fake *FakeCouponSerializer) SerializeCouponPage(arg1 []*coupon.Coupon, arg2 string) ([]byte, error) {
+	var arg1Copy []*coupon.Coupon
+	if arg1 != nil {
+		arg1Copy = make([]*coupon.Coupon, len(arg1))
+		copy(arg1Copy, arg1)
+	}
+	fake.serializeCouponPageMutex.Lock()
+	ret, specificReturn := fake.serializeCouponPageReturnsOnCall[len(fake.serializeCouponPageArgsForCall)]
+	fake.serializeCouponPageArgsForCall = append(fake.serializeCouponPageArgsForCall, struct {
+		arg1 []*coupon.Coupon
+		arg2 string
+	}{arg1Copy, arg2})
+	fake.recordInvocation("SerializeCouponPage", []interface{}{arg1Copy, arg2})
+	fake.serializeCouponPageMutex.Unlock()
+	if fake.SerializeCouponPageStub != nil {
+		return fake.SerializeCouponPageStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.serializeCouponPageReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeCouponSerializer) SerializeCouponPageCallCount() int {
+	fake.serializeCouponPageMutex.RLock()
+	defer fake.serializeCouponPageMutex.RUnlock()
+	return len(fake.serializeCouponPageArgsForCall)
+}
+
+func (fake *FakeCouponSerializer) SerializeCouponPageCalls(stub func([]*coupon.Coupon, string) ([]byte, error)) {
+	fake.serializeCouponPageMutex.Lock()
+	defer fake.serializeCouponPageMutex.Unlock()
+	fake.SerializeCouponPageStub = stub
+}
+
+func (fake *FakeCouponSerializer) SerializeCouponPageArgsForCall(i int) ([]*coupon.Coupon, string) {
+	fake.serializeCouponPageMutex.RLock()
+	defer fake.serializeCouponPageMutex.RUnlock()
+	argsForCall := fake.serializeCouponPageArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeCouponSerializer) SerializeCouponPageReturns(result1 []byte, result2 error) {
+	fake.serializeCouponPageMutex.Lock()
+	defer fake.serializeCouponPageMutex.Unlock()
+	fake.SerializeCouponPageStub = nil
+	fake.serializeCouponPageReturns = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCouponSerializer) SerializeCouponPageReturnsOnCall(i int, result1 []byte, result2 error) {
+	fake.serializeCouponPageMutex.Lock()
+	defer fake.serializeCouponPageMutex.Unlock()
+	fake.SerializeCouponPageStub = nil
+	if fake.serializeCouponPageReturnsOnCall == nil {
+		fake.serializeCouponPageReturnsOnCall = make(map[int]struct {
+			result1 []byte
+			result2 error
+		})
+	}
+	fake.serializeCouponPageReturnsOnCall[i] = struct {
+		result1 []byte
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeCouponSerializer) SerializeCoupons(arg1 []*coupon.Coupon) ([]byte, error) {
 	var arg1Copy []*coupon.Coupon
 	if arg1 != nil {
@@ -258,6 +341,8 @@ func (fake *FakeCouponSerializer) Invocations() map[string][][]interface{} {
 	defer fake.deserializeCouponMutex.RUnlock()
 	fake.serializeCouponMutex.RLock()
 	defer fake.serializeCouponMutex.RUnlock()
+	fake.serializeCouponPageMutex.RLock()
+	defer fake.serializeCouponPageMutex.RUnlock()
 	fake.serializeCouponsMutex.RLock()
 	defer fake.serializeCouponsMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
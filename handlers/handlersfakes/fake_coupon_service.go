@@ -2,17 +2,20 @@
 package handlersfakes
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"github.com/madeleinesmith/coupons/handlers"
 	"github.com/madeleinesmith/coupons/model/coupon"
 )
 
 type FakeCouponService struct {
-	CreateCouponStub        func(coupon.Coupon) (*coupon.Coupon, error)
+	CreateCouponStub        func(context.Context, coupon.Coupon) (*coupon.Coupon, error)
 	createCouponMutex       sync.RWMutex
 	createCouponArgsForCall []struct {
-		arg1 coupon.Coupon
+		arg1 context.Context
+		arg2 coupon.Coupon
 	}
 	createCouponReturns struct {
 		result1 *coupon.Coupon
@@ -22,10 +25,50 @@ type FakeCouponService struct {
 		result1 *coupon.Coupon
 		result2 error
 	}
-	GetCouponByIdStub        func(string) (*coupon.Coupon, error)
+	CreateCouponsStub        func(context.Context, []coupon.Coupon) error
+	createCouponsMutex       sync.RWMutex
+	createCouponsArgsForCall []struct {
+		arg1 context.Context
+		arg2 []coupon.Coupon
+	}
+	createCouponsReturns struct {
+		result1 error
+	}
+	createCouponsReturnsOnCall map[int]struct {
+		result1 error
+	}
+	DeleteCouponStub        func(context.Context, string) error
+	deleteCouponMutex       sync.RWMutex
+	deleteCouponArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	deleteCouponReturns struct {
+		result1 error
+	}
+	deleteCouponReturnsOnCall map[int]struct {
+		result1 error
+	}
+	GetCouponAsOfStub        func(context.Context, string, time.Time) (*coupon.Coupon, error)
+	getCouponAsOfMutex       sync.RWMutex
+	getCouponAsOfArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 time.Time
+	}
+	getCouponAsOfReturns struct {
+		result1 *coupon.Coupon
+		result2 error
+	}
+	getCouponAsOfReturnsOnCall map[int]struct {
+		result1 *coupon.Coupon
+		result2 error
+	}
+	GetCouponByIdStub        func(context.Context, string) (*coupon.Coupon, error)
 	getCouponByIdMutex       sync.RWMutex
 	getCouponByIdArgsForCall []struct {
-		arg1 string
+		arg1 context.Context
+		arg2 string
 	}
 	getCouponByIdReturns struct {
 		result1 *coupon.Coupon
@@ -35,10 +78,26 @@ type FakeCouponService struct {
 		result1 *coupon.Coupon
 		result2 error
 	}
-	GetCouponsStub        func(handlers.Filters) ([]*coupon.Coupon, error)
+	GetCouponVersionStub        func(context.Context, string, int) (*coupon.Coupon, error)
+	getCouponVersionMutex       sync.RWMutex
+	getCouponVersionArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 int
+	}
+	getCouponVersionReturns struct {
+		result1 *coupon.Coupon
+		result2 error
+	}
+	getCouponVersionReturnsOnCall map[int]struct {
+		result1 *coupon.Coupon
+		result2 error
+	}
+	GetCouponsStub        func(context.Context, handlers.Filters) ([]*coupon.Coupon, error)
 	getCouponsMutex       sync.RWMutex
 	getCouponsArgsForCall []struct {
-		arg1 handlers.Filters
+		arg1 context.Context
+		arg2 handlers.Filters
 	}
 	getCouponsReturns struct {
 		result1 []*coupon.Coupon
@@ -48,10 +107,39 @@ type FakeCouponService struct {
 		result1 []*coupon.Coupon
 		result2 error
 	}
-	UpdateCouponStub        func(coupon.Coupon) error
+	RevertCouponStub        func(context.Context, string, int) (*coupon.Coupon, error)
+	revertCouponMutex       sync.RWMutex
+	revertCouponArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 int
+	}
+	revertCouponReturns struct {
+		result1 *coupon.Coupon
+		result2 error
+	}
+	revertCouponReturnsOnCall map[int]struct {
+		result1 *coupon.Coupon
+		result2 error
+	}
+	StreamCouponsStub        func(context.Context, handlers.Filters, func(*coupon.Coupon) error) error
+	streamCouponsMutex       sync.RWMutex
+	streamCouponsArgsForCall []struct {
+		arg1 context.Context
+		arg2 handlers.Filters
+		arg3 func(*coupon.Coupon) error
+	}
+	streamCouponsReturns struct {
+		result1 error
+	}
+	streamCouponsReturnsOnCall map[int]struct {
+		result1 error
+	}
+	UpdateCouponStub        func(context.Context, coupon.Coupon) error
 	updateCouponMutex       sync.RWMutex
 	updateCouponArgsForCall []struct {
-		arg1 coupon.Coupon
+		arg1 context.Context
+		arg2 coupon.Coupon
 	}
 	updateCouponReturns struct {
 		result1 error
@@ -63,16 +151,17 @@ type FakeCouponService struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeCouponService) CreateCoupon(arg1 coupon.Coupon) (*coupon.Coupon, error) {
+func (fake *FakeCouponService) CreateCoupon(arg1 context.Context, arg2 coupon.Coupon) (*coupon.Coupon, error) {
 	fake.createCouponMutex.Lock()
 	ret, specificReturn := fake.createCouponReturnsOnCall[len(fake.createCouponArgsForCall)]
 	fake.createCouponArgsForCall = append(fake.createCouponArgsForCall, struct {
-		arg1 coupon.Coupon
-	}{arg1})
-	fake.recordInvocation("CreateCoupon", []interface{}{arg1})
+		arg1 context.Context
+		arg2 coupon.Coupon
+	}{arg1, arg2})
+	fake.recordInvocation("CreateCoupon", []interface{}{arg1, arg2})
 	fake.createCouponMutex.Unlock()
 	if fake.CreateCouponStub != nil {
-		return fake.CreateCouponStub(arg1)
+		return fake.CreateCouponStub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -87,17 +176,17 @@ func (fake *FakeCouponService) CreateCouponCallCount() int {
 	return len(fake.createCouponArgsForCall)
 }
 
-func (fake *FakeCouponService) CreateCouponCalls(stub func(coupon.Coupon) (*coupon.Coupon, error)) {
+func (fake *FakeCouponService) CreateCouponCalls(stub func(context.Context, coupon.Coupon) (*coupon.Coupon, error)) {
 	fake.createCouponMutex.Lock()
 	defer fake.createCouponMutex.Unlock()
 	fake.CreateCouponStub = stub
 }
 
-func (fake *FakeCouponService) CreateCouponArgsForCall(i int) coupon.Coupon {
+func (fake *FakeCouponService) CreateCouponArgsForCall(i int) (context.Context, coupon.Coupon) {
 	fake.createCouponMutex.RLock()
 	defer fake.createCouponMutex.RUnlock()
 	argsForCall := fake.createCouponArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *FakeCouponService) CreateCouponReturns(result1 *coupon.Coupon, result2 error) {
@@ -126,16 +215,209 @@ func (fake *FakeCouponService) CreateCouponReturnsOnCall(i int, result1 *coupon.
 	}{result1, result2}
 }
 
-func (fake *FakeCouponService) GetCouponById(arg1 string) (*coupon.Coupon, error) {
+func (fake *FakeCouponService) CreateCoupons(arg1 context.Context, arg2 []coupon.Coupon) error {
+	var arg2Copy []coupon.Coupon
+	if arg2 != nil {
+		arg2Copy = make([]coupon.Coupon, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.createCouponsMutex.Lock()
+	ret, specificReturn := fake.createCouponsReturnsOnCall[len(fake.createCouponsArgsForCall)]
+	fake.createCouponsArgsForCall = append(fake.createCouponsArgsForCall, struct {
+		arg1 context.Context
+		arg2 []coupon.Coupon
+	}{arg1, arg2Copy})
+	fake.recordInvocation("CreateCoupons", []interface{}{arg1, arg2Copy})
+	fake.createCouponsMutex.Unlock()
+	if fake.CreateCouponsStub != nil {
+		return fake.CreateCouponsStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.createCouponsReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeCouponService) CreateCouponsCallCount() int {
+	fake.createCouponsMutex.RLock()
+	defer fake.createCouponsMutex.RUnlock()
+	return len(fake.createCouponsArgsForCall)
+}
+
+func (fake *FakeCouponService) CreateCouponsCalls(stub func(context.Context, []coupon.Coupon) error) {
+	fake.createCouponsMutex.Lock()
+	defer fake.createCouponsMutex.Unlock()
+	fake.CreateCouponsStub = stub
+}
+
+func (fake *FakeCouponService) CreateCouponsArgsForCall(i int) (context.Context, []coupon.Coupon) {
+	fake.createCouponsMutex.RLock()
+	defer fake.createCouponsMutex.RUnlock()
+	argsForCall := fake.createCouponsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeCouponService) CreateCouponsReturns(result1 error) {
+	fake.createCouponsMutex.Lock()
+	defer fake.createCouponsMutex.Unlock()
+	fake.CreateCouponsStub = nil
+	fake.createCouponsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCouponService) CreateCouponsReturnsOnCall(i int, result1 error) {
+	fake.createCouponsMutex.Lock()
+	defer fake.createCouponsMutex.Unlock()
+	fake.CreateCouponsStub = nil
+	if fake.createCouponsReturnsOnCall == nil {
+		fake.createCouponsReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.createCouponsReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCouponService) DeleteCoupon(arg1 context.Context, arg2 string) error {
+	fake.deleteCouponMutex.Lock()
+	ret, specificReturn := fake.deleteCouponReturnsOnCall[len(fake.deleteCouponArgsForCall)]
+	fake.deleteCouponArgsForCall = append(fake.deleteCouponArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("DeleteCoupon", []interface{}{arg1, arg2})
+	fake.deleteCouponMutex.Unlock()
+	if fake.DeleteCouponStub != nil {
+		return fake.DeleteCouponStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.deleteCouponReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeCouponService) DeleteCouponCallCount() int {
+	fake.deleteCouponMutex.RLock()
+	defer fake.deleteCouponMutex.RUnlock()
+	return len(fake.deleteCouponArgsForCall)
+}
+
+func (fake *FakeCouponService) DeleteCouponCalls(stub func(context.Context, string) error) {
+	fake.deleteCouponMutex.Lock()
+	defer fake.deleteCouponMutex.Unlock()
+	fake.DeleteCouponStub = stub
+}
+
+func (fake *FakeCouponService) DeleteCouponArgsForCall(i int) (context.Context, string) {
+	fake.deleteCouponMutex.RLock()
+	defer fake.deleteCouponMutex.RUnlock()
+	argsForCall := fake.deleteCouponArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeCouponService) DeleteCouponReturns(result1 error) {
+	fake.deleteCouponMutex.Lock()
+	defer fake.deleteCouponMutex.Unlock()
+	fake.DeleteCouponStub = nil
+	fake.deleteCouponReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCouponService) DeleteCouponReturnsOnCall(i int, result1 error) {
+	fake.deleteCouponMutex.Lock()
+	defer fake.deleteCouponMutex.Unlock()
+	fake.DeleteCouponStub = nil
+	if fake.deleteCouponReturnsOnCall == nil {
+		fake.deleteCouponReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.deleteCouponReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCouponService) GetCouponAsOf(arg1 context.Context, arg2 string, arg3 time.Time) (*coupon.Coupon, error) {
+	fake.getCouponAsOfMutex.Lock()
+	ret, specificReturn := fake.getCouponAsOfReturnsOnCall[len(fake.getCouponAsOfArgsForCall)]
+	fake.getCouponAsOfArgsForCall = append(fake.getCouponAsOfArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 time.Time
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("GetCouponAsOf", []interface{}{arg1, arg2, arg3})
+	fake.getCouponAsOfMutex.Unlock()
+	if fake.GetCouponAsOfStub != nil {
+		return fake.GetCouponAsOfStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.getCouponAsOfReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeCouponService) GetCouponAsOfCallCount() int {
+	fake.getCouponAsOfMutex.RLock()
+	defer fake.getCouponAsOfMutex.RUnlock()
+	return len(fake.getCouponAsOfArgsForCall)
+}
+
+func (fake *FakeCouponService) GetCouponAsOfCalls(stub func(context.Context, string, time.Time) (*coupon.Coupon, error)) {
+	fake.getCouponAsOfMutex.Lock()
+	defer fake.getCouponAsOfMutex.Unlock()
+	fake.GetCouponAsOfStub = stub
+}
+
+func (fake *FakeCouponService) GetCouponAsOfArgsForCall(i int) (context.Context, string, time.Time) {
+	fake.getCouponAsOfMutex.RLock()
+	defer fake.getCouponAsOfMutex.RUnlock()
+	argsForCall := fake.getCouponAsOfArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeCouponService) GetCouponAsOfReturns(result1 *coupon.Coupon, result2 error) {
+	fake.getCouponAsOfMutex.Lock()
+	defer fake.getCouponAsOfMutex.Unlock()
+	fake.GetCouponAsOfStub = nil
+	fake.getCouponAsOfReturns = struct {
+		result1 *coupon.Coupon
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCouponService) GetCouponAsOfReturnsOnCall(i int, result1 *coupon.Coupon, result2 error) {
+	fake.getCouponAsOfMutex.Lock()
+	defer fake.getCouponAsOfMutex.Unlock()
+	fake.GetCouponAsOfStub = nil
+	if fake.getCouponAsOfReturnsOnCall == nil {
+		fake.getCouponAsOfReturnsOnCall = make(map[int]struct {
+			result1 *coupon.Coupon
+			result2 error
+		})
+	}
+	fake.getCouponAsOfReturnsOnCall[i] = struct {
+		result1 *coupon.Coupon
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCouponService) GetCouponById(arg1 context.Context, arg2 string) (*coupon.Coupon, error) {
 	fake.getCouponByIdMutex.Lock()
 	ret, specificReturn := fake.getCouponByIdReturnsOnCall[len(fake.getCouponByIdArgsForCall)]
 	fake.getCouponByIdArgsForCall = append(fake.getCouponByIdArgsForCall, struct {
-		arg1 string
-	}{arg1})
-	fake.recordInvocation("GetCouponById", []interface{}{arg1})
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("GetCouponById", []interface{}{arg1, arg2})
 	fake.getCouponByIdMutex.Unlock()
 	if fake.GetCouponByIdStub != nil {
-		return fake.GetCouponByIdStub(arg1)
+		return fake.GetCouponByIdStub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -150,17 +432,17 @@ func (fake *FakeCouponService) GetCouponByIdCallCount() int {
 	return len(fake.getCouponByIdArgsForCall)
 }
 
-func (fake *FakeCouponService) GetCouponByIdCalls(stub func(string) (*coupon.Coupon, error)) {
+func (fake *FakeCouponService) GetCouponByIdCalls(stub func(context.Context, string) (*coupon.Coupon, error)) {
 	fake.getCouponByIdMutex.Lock()
 	defer fake.getCouponByIdMutex.Unlock()
 	fake.GetCouponByIdStub = stub
 }
 
-func (fake *FakeCouponService) GetCouponByIdArgsForCall(i int) string {
+func (fake *FakeCouponService) GetCouponByIdArgsForCall(i int) (context.Context, string) {
 	fake.getCouponByIdMutex.RLock()
 	defer fake.getCouponByIdMutex.RUnlock()
 	argsForCall := fake.getCouponByIdArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *FakeCouponService) GetCouponByIdReturns(result1 *coupon.Coupon, result2 error) {
@@ -189,16 +471,82 @@ func (fake *FakeCouponService) GetCouponByIdReturnsOnCall(i int, result1 *coupon
 	}{result1, result2}
 }
 
-func (fake *FakeCouponService) GetCoupons(arg1 handlers.Filters) ([]*coupon.Coupon, error) {
+func (fake *FakeCouponService) GetCouponVersion(arg1 context.Context, arg2 string, arg3 int) (*coupon.Coupon, error) {
+	fake.getCouponVersionMutex.Lock()
+	ret, specificReturn := fake.getCouponVersionReturnsOnCall[len(fake.getCouponVersionArgsForCall)]
+	fake.getCouponVersionArgsForCall = append(fake.getCouponVersionArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 int
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("GetCouponVersion", []interface{}{arg1, arg2, arg3})
+	fake.getCouponVersionMutex.Unlock()
+	if fake.GetCouponVersionStub != nil {
+		return fake.GetCouponVersionStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.getCouponVersionReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeCouponService) GetCouponVersionCallCount() int {
+	fake.getCouponVersionMutex.RLock()
+	defer fake.getCouponVersionMutex.RUnlock()
+	return len(fake.getCouponVersionArgsForCall)
+}
+
+func (fake *FakeCouponService) GetCouponVersionCalls(stub func(context.Context, string, int) (*coupon.Coupon, error)) {
+	fake.getCouponVersionMutex.Lock()
+	defer fake.getCouponVersionMutex.Unlock()
+	fake.GetCouponVersionStub = stub
+}
+
+func (fake *FakeCouponService) GetCouponVersionArgsForCall(i int) (context.Context, string, int) {
+	fake.getCouponVersionMutex.RLock()
+	defer fake.getCouponVersionMutex.RUnlock()
+	argsForCall := fake.getCouponVersionArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeCouponService) GetCouponVersionReturns(result1 *coupon.Coupon, result2 error) {
+	fake.getCouponVersionMutex.Lock()
+	defer fake.getCouponVersionMutex.Unlock()
+	fake.GetCouponVersionStub = nil
+	fake.getCouponVersionReturns = struct {
+		result1 *coupon.Coupon
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCouponService) GetCouponVersionReturnsOnCall(i int, result1 *coupon.Coupon, result2 error) {
+	fake.getCouponVersionMutex.Lock()
+	defer fake.getCouponVersionMutex.Unlock()
+	fake.GetCouponVersionStub = nil
+	if fake.getCouponVersionReturnsOnCall == nil {
+		fake.getCouponVersionReturnsOnCall = make(map[int]struct {
+			result1 *coupon.Coupon
+			result2 error
+		})
+	}
+	fake.getCouponVersionReturnsOnCall[i] = struct {
+		result1 *coupon.Coupon
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCouponService) GetCoupons(arg1 context.Context, arg2 handlers.Filters) ([]*coupon.Coupon, error) {
 	fake.getCouponsMutex.Lock()
 	ret, specificReturn := fake.getCouponsReturnsOnCall[len(fake.getCouponsArgsForCall)]
 	fake.getCouponsArgsForCall = append(fake.getCouponsArgsForCall, struct {
-		arg1 handlers.Filters
-	}{arg1})
-	fake.recordInvocation("GetCoupons", []interface{}{arg1})
+		arg1 context.Context
+		arg2 handlers.Filters
+	}{arg1, arg2})
+	fake.recordInvocation("GetCoupons", []interface{}{arg1, arg2})
 	fake.getCouponsMutex.Unlock()
 	if fake.GetCouponsStub != nil {
-		return fake.GetCouponsStub(arg1)
+		return fake.GetCouponsStub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -213,17 +561,17 @@ func (fake *FakeCouponService) GetCouponsCallCount() int {
 	return len(fake.getCouponsArgsForCall)
 }
 
-func (fake *FakeCouponService) GetCouponsCalls(stub func(handlers.Filters) ([]*coupon.Coupon, error)) {
+func (fake *FakeCouponService) GetCouponsCalls(stub func(context.Context, handlers.Filters) ([]*coupon.Coupon, error)) {
 	fake.getCouponsMutex.Lock()
 	defer fake.getCouponsMutex.Unlock()
 	fake.GetCouponsStub = stub
 }
 
-func (fake *FakeCouponService) GetCouponsArgsForCall(i int) handlers.Filters {
+func (fake *FakeCouponService) GetCouponsArgsForCall(i int) (context.Context, handlers.Filters) {
 	fake.getCouponsMutex.RLock()
 	defer fake.getCouponsMutex.RUnlock()
 	argsForCall := fake.getCouponsArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *FakeCouponService) GetCouponsReturns(result1 []*coupon.Coupon, result2 error) {
@@ -252,16 +600,144 @@ func (fake *FakeCouponService) GetCouponsReturnsOnCall(i int, result1 []*coupon.
 	}{result1, result2}
 }
 
-func (fake *FakeCouponService) UpdateCoupon(arg1 coupon.Coupon) error {
+func (fake *FakeCouponService) RevertCoupon(arg1 context.Context, arg2 string, arg3 int) (*coupon.Coupon, error) {
+	fake.revertCouponMutex.Lock()
+	ret, specificReturn := fake.revertCouponReturnsOnCall[len(fake.revertCouponArgsForCall)]
+	fake.revertCouponArgsForCall = append(fake.revertCouponArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 int
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("RevertCoupon", []interface{}{arg1, arg2, arg3})
+	fake.revertCouponMutex.Unlock()
+	if fake.RevertCouponStub != nil {
+		return fake.RevertCouponStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.revertCouponReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeCouponService) RevertCouponCallCount() int {
+	fake.revertCouponMutex.RLock()
+	defer fake.revertCouponMutex.RUnlock()
+	return len(fake.revertCouponArgsForCall)
+}
+
+func (fake *FakeCouponService) RevertCouponCalls(stub func(context.Context, string, int) (*coupon.Coupon, error)) {
+	fake.revertCouponMutex.Lock()
+	defer fake.revertCouponMutex.Unlock()
+	fake.RevertCouponStub = stub
+}
+
+func (fake *FakeCouponService) RevertCouponArgsForCall(i int) (context.Context, string, int) {
+	fake.revertCouponMutex.RLock()
+	defer fake.revertCouponMutex.RUnlock()
+	argsForCall := fake.revertCouponArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeCouponService) RevertCouponReturns(result1 *coupon.Coupon, result2 error) {
+	fake.revertCouponMutex.Lock()
+	defer fake.revertCouponMutex.Unlock()
+	fake.RevertCouponStub = nil
+	fake.revertCouponReturns = struct {
+		result1 *coupon.Coupon
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCouponService) RevertCouponReturnsOnCall(i int, result1 *coupon.Coupon, result2 error) {
+	fake.revertCouponMutex.Lock()
+	defer fake.revertCouponMutex.Unlock()
+	fake.RevertCouponStub = nil
+	if fake.revertCouponReturnsOnCall == nil {
+		fake.revertCouponReturnsOnCall = make(map[int]struct {
+			result1 *coupon.Coupon
+			result2 error
+		})
+	}
+	fake.revertCouponReturnsOnCall[i] = struct {
+		result1 *coupon.Coupon
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCouponService) StreamCoupons(arg1 context.Context, arg2 handlers.Filters, arg3 func(*coupon.Coupon) error) error {
+	fake.streamCouponsMutex.Lock()
+	ret, specificReturn := fake.streamCouponsReturnsOnCall[len(fake.streamCouponsArgsForCall)]
+	fake.streamCouponsArgsForCall = append(fake.streamCouponsArgsForCall, struct {
+		arg1 context.Context
+		arg2 handlers.Filters
+		arg3 func(*coupon.Coupon) error
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("StreamCoupons", []interface{}{arg1, arg2, arg3})
+	fake.streamCouponsMutex.Unlock()
+	if fake.StreamCouponsStub != nil {
+		return fake.StreamCouponsStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.streamCouponsReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeCouponService) StreamCouponsCallCount() int {
+	fake.streamCouponsMutex.RLock()
+	defer fake.streamCouponsMutex.RUnlock()
+	return len(fake.streamCouponsArgsForCall)
+}
+
+func (fake *FakeCouponService) StreamCouponsCalls(stub func(context.Context, handlers.Filters, func(*coupon.Coupon) error) error) {
+	fake.streamCouponsMutex.Lock()
+	defer fake.streamCouponsMutex.Unlock()
+	fake.StreamCouponsStub = stub
+}
+
+func (fake *FakeCouponService) StreamCouponsArgsForCall(i int) (context.Context, handlers.Filters, func(*coupon.Coupon) error) {
+	fake.streamCouponsMutex.RLock()
+	defer fake.streamCouponsMutex.RUnlock()
+	argsForCall := fake.streamCouponsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeCouponService) StreamCouponsReturns(result1 error) {
+	fake.streamCouponsMutex.Lock()
+	defer fake.streamCouponsMutex.Unlock()
+	fake.StreamCouponsStub = nil
+	fake.streamCouponsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCouponService) StreamCouponsReturnsOnCall(i int, result1 error) {
+	fake.streamCouponsMutex.Lock()
+	defer fake.streamCouponsMutex.Unlock()
+	fake.StreamCouponsStub = nil
+	if fake.streamCouponsReturnsOnCall == nil {
+		fake.streamCouponsReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.streamCouponsReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCouponService) UpdateCoupon(arg1 context.Context, arg2 coupon.Coupon) error {
 	fake.updateCouponMutex.Lock()
 	ret, specificReturn := fake.updateCouponReturnsOnCall[len(fake.updateCouponArgsForCall)]
 	fake.updateCouponArgsForCall = append(fake.updateCouponArgsForCall, struct {
-		arg1 coupon.Coupon
-	}{arg1})
-	fake.recordInvocation("UpdateCoupon", []interface{}{arg1})
+		arg1 context.Context
+		arg2 coupon.Coupon
+	}{arg1, arg2})
+	fake.recordInvocation("UpdateCoupon", []interface{}{arg1, arg2})
 	fake.updateCouponMutex.Unlock()
 	if fake.UpdateCouponStub != nil {
-		return fake.UpdateCouponStub(arg1)
+		return fake.UpdateCouponStub(arg1, arg2)
 	}
 	if specificReturn {
 		return ret.result1
@@ -276,17 +752,17 @@ func (fake *FakeCouponService) UpdateCouponCallCount() int {
 	return len(fake.updateCouponArgsForCall)
 }
 
-func (fake *FakeCouponService) UpdateCouponCalls(stub func(coupon.Coupon) error) {
+func (fake *FakeCouponService) UpdateCouponCalls(stub func(context.Context, coupon.Coupon) error) {
 	fake.updateCouponMutex.Lock()
 	defer fake.updateCouponMutex.Unlock()
 	fake.UpdateCouponStub = stub
 }
 
-func (fake *FakeCouponService) UpdateCouponArgsForCall(i int) coupon.Coupon {
+func (fake *FakeCouponService) UpdateCouponArgsForCall(i int) (context.Context, coupon.Coupon) {
 	fake.updateCouponMutex.RLock()
 	defer fake.updateCouponMutex.RUnlock()
 	argsForCall := fake.updateCouponArgsForCall[i]
-	return argsForCall.arg1
+	return argsForCall.arg1, argsForCall.arg2
 }
 
 func (fake *FakeCouponService) UpdateCouponReturns(result1 error) {
@@ -317,10 +793,22 @@ func (fake *FakeCouponService) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.createCouponMutex.RLock()
 	defer fake.createCouponMutex.RUnlock()
+	fake.createCouponsMutex.RLock()
+	defer fake.createCouponsMutex.RUnlock()
+	fake.deleteCouponMutex.RLock()
+	defer fake.deleteCouponMutex.RUnlock()
+	fake.getCouponAsOfMutex.RLock()
+	defer fake.getCouponAsOfMutex.RUnlock()
 	fake.getCouponByIdMutex.RLock()
 	defer fake.getCouponByIdMutex.RUnlock()
+	fake.getCouponVersionMutex.RLock()
+	defer fake.getCouponVersionMutex.RUnlock()
 	fake.getCouponsMutex.RLock()
 	defer fake.getCouponsMutex.RUnlock()
+	fake.revertCouponMutex.RLock()
+	defer fake.revertCouponMutex.RUnlock()
+	fake.streamCouponsMutex.RLock()
+	defer fake.streamCouponsMutex.RUnlock()
 	fake.updateCouponMutex.RLock()
 	defer fake.updateCouponMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
@@ -0,0 +1,113 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package handlersfakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/madeleinesmith/coupons/handlers"
+)
+
+type FakeCouponTransactor struct {
+	WithinTransactionStub        func(context.Context, func(handlers.CouponService) error) error
+	withinTransactionMutex       sync.RWMutex
+	withinTransactionArgsForCall []struct {
+		arg1 context.Context
+		arg2 func(handlers.CouponService) error
+	}
+	withinTransactionReturns struct {
+		result1 error
+	}
+	withinTransactionReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeCouponTransactor) WithinTransaction(arg1 context.Context, arg2 func(handlers.CouponService) error) error {
+	fake.withinTransactionMutex.Lock()
+	ret, specificReturn := fake.withinTransactionReturnsOnCall[len(fake.withinTransactionArgsForCall)]
+	fake.withinTransactionArgsForCall = append(fake.withinTransactionArgsForCall, struct {
+		arg1 context.Context
+		arg2 func(handlers.CouponService) error
+	}{arg1, arg2})
+	fake.recordInvocation("WithinTransaction", []interface{}{arg1, arg2})
+	fake.withinTransactionMutex.Unlock()
+	if fake.WithinTransactionStub != nil {
+		return fake.WithinTransactionStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.withinTransactionReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeCouponTransactor) WithinTransactionCallCount() int {
+	fake.withinTransactionMutex.RLock()
+	defer fake.withinTransactionMutex.RUnlock()
+	return len(fake.withinTransactionArgsForCall)
+}
+
+func (fake *FakeCouponTransactor) WithinTransactionCalls(stub func(context.Context, func(handlers.CouponService) error) error) {
+	fake.withinTransactionMutex.Lock()
+	defer fake.withinTransactionMutex.Unlock()
+	fake.WithinTransactionStub = stub
+}
+
+func (fake *FakeCouponTransactor) WithinTransactionArgsForCall(i int) (context.Context, func(handlers.CouponService) error) {
+	fake.withinTransactionMutex.RLock()
+	defer fake.withinTransactionMutex.RUnlock()
+	argsForCall := fake.withinTransactionArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeCouponTransactor) WithinTransactionReturns(result1 error) {
+	fake.withinTransactionMutex.Lock()
+	defer fake.withinTransactionMutex.Unlock()
+	fake.WithinTransactionStub = nil
+	fake.withinTransactionReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCouponTransactor) WithinTransactionReturnsOnCall(i int, result1 error) {
+	fake.withinTransactionMutex.Lock()
+	defer fake.withinTransactionMutex.Unlock()
+	fake.WithinTransactionStub = nil
+	if fake.withinTransactionReturnsOnCall == nil {
+		fake.withinTransactionReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.withinTransactionReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCouponTransactor) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.withinTransactionMutex.RLock()
+	defer fake.withinTransactionMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeCouponTransactor) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ handlers.CouponTransactor = new(FakeCouponTransactor)
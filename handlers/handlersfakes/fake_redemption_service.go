@@ -0,0 +1,119 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package handlersfakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/coupon"
+)
+
+type FakeRedemptionService struct {
+	RedeemCouponStub        func(context.Context, string) (*coupon.Redemption, error)
+	redeemCouponMutex       sync.RWMutex
+	redeemCouponArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	redeemCouponReturns struct {
+		result1 *coupon.Redemption
+		result2 error
+	}
+	redeemCouponReturnsOnCall map[int]struct {
+		result1 *coupon.Redemption
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeRedemptionService) RedeemCoupon(arg1 context.Context, arg2 string) (*coupon.Redemption, error) {
+	fake.redeemCouponMutex.Lock()
+	ret, specificReturn := fake.redeemCouponReturnsOnCall[len(fake.redeemCouponArgsForCall)]
+	fake.redeemCouponArgsForCall = append(fake.redeemCouponArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("RedeemCoupon", []interface{}{arg1, arg2})
+	fake.redeemCouponMutex.Unlock()
+	if fake.RedeemCouponStub != nil {
+		return fake.RedeemCouponStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.redeemCouponReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRedemptionService) RedeemCouponCallCount() int {
+	fake.redeemCouponMutex.RLock()
+	defer fake.redeemCouponMutex.RUnlock()
+	return len(fake.redeemCouponArgsForCall)
+}
+
+func (fake *FakeRedemptionService) RedeemCouponCalls(stub func(context.Context, string) (*coupon.Redemption, error)) {
+	fake.redeemCouponMutex.Lock()
+	defer fake.redeemCouponMutex.Unlock()
+	fake.RedeemCouponStub = stub
+}
+
+func (fake *FakeRedemptionService) RedeemCouponArgsForCall(i int) (context.Context, string) {
+	fake.redeemCouponMutex.RLock()
+	defer fake.redeemCouponMutex.RUnlock()
+	argsForCall := fake.redeemCouponArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeRedemptionService) RedeemCouponReturns(result1 *coupon.Redemption, result2 error) {
+	fake.redeemCouponMutex.Lock()
+	defer fake.redeemCouponMutex.Unlock()
+	fake.RedeemCouponStub = nil
+	fake.redeemCouponReturns = struct {
+		result1 *coupon.Redemption
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRedemptionService) RedeemCouponReturnsOnCall(i int, result1 *coupon.Redemption, result2 error) {
+	fake.redeemCouponMutex.Lock()
+	defer fake.redeemCouponMutex.Unlock()
+	fake.RedeemCouponStub = nil
+	if fake.redeemCouponReturnsOnCall == nil {
+		fake.redeemCouponReturnsOnCall = make(map[int]struct {
+			result1 *coupon.Redemption
+			result2 error
+		})
+	}
+	fake.redeemCouponReturnsOnCall[i] = struct {
+		result1 *coupon.Redemption
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRedemptionService) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.redeemCouponMutex.RLock()
+	defer fake.redeemCouponMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeRedemptionService) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ handlers.RedemptionService = new(FakeRedemptionService)
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/importers"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+)
+
+const importBatchSize = 500
+
+// a file full of bad rows shouldn't produce a report as big as the file itself
+const maxReportedRejections = 1000
+
+type RejectedRow struct {
+	Row    int    `json:"row"`
+	Reason string `json:"reason"`
+}
+
+type ImportReport struct {
+	DryRun     bool          `json:"dryRun"`
+	Accepted   int           `json:"accepted"`
+	Rejected   int           `json:"rejected"`
+	Rejections []RejectedRow `json:"rejections"`
+}
+
+type ImportHandler struct {
+	CouponTransactor CouponTransactor
+	CouponValidator  CouponValidator
+}
+
+func (h ImportHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		h.handlePost(w, req)
+	default:
+		handleError(w, req, errors.New("Method not allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h ImportHandler) handlePost(w http.ResponseWriter, req *http.Request) {
+	if !requireScope(w, req, auth.ScopeCouponsWrite) {
+		return
+	}
+
+	queryParams := req.URL.Query()
+
+	format := importFormat(req)
+	if format == "" {
+		handleError(w, req, errors.New("import format must be csv or ndjson"), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	dryRun := false
+	if dryRunString := queryParams.Get("dry_run"); dryRunString != "" {
+		var err error
+		dryRun, err = strconv.ParseBool(dryRunString)
+		if err != nil {
+			handleError(w, req, err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	mapping, err := importers.ParseMapping(queryParams.Get("mapping"))
+	if err != nil {
+		handleError(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	rowReader, err := importers.NewRowReader(format, req.Body, mapping)
+	if err != nil {
+		handleError(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	report := ImportReport{
+		DryRun:     dryRun,
+		Rejections: []RejectedRow{},
+	}
+
+	if dryRun {
+		err = h.importRows(req.Context(), rowReader, &report, nil)
+	} else {
+		err = h.CouponTransactor.WithinTransaction(req.Context(), func(couponService CouponService) error {
+			return h.importRows(req.Context(), rowReader, &report, couponService)
+		})
+	}
+
+	if err != nil {
+		handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	responseBytes, err := json.Marshal(map[string]ImportReport{"meta": report})
+	if err != nil {
+		handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(responseBytes)
+}
+
+// importRows only validates when couponService is nil, which is how dry runs work
+func (h ImportHandler) importRows(ctx context.Context, rowReader importers.RowReader, report *ImportReport, couponService CouponService) error {
+	batch := make([]coupon.Coupon, 0, importBatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 || couponService == nil {
+			batch = batch[:0]
+			return nil
+		}
+
+		err := couponService.CreateCoupons(ctx, batch)
+		batch = batch[:0]
+
+		return err
+	}
+
+	for {
+		row, err := rowReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if row.Err == nil {
+			row.Err = h.CouponValidator.Validate(row.Coupon)
+		}
+
+		if row.Err != nil {
+			report.Rejected++
+			if len(report.Rejections) < maxReportedRejections {
+				report.Rejections = append(report.Rejections, RejectedRow{Row: row.Number, Reason: row.Err.Error()})
+			}
+			continue
+		}
+
+		report.Accepted++
+		batch = append(batch, row.Coupon)
+
+		if len(batch) == importBatchSize {
+			err = flush()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return flush()
+}
+
+func importFormat(req *http.Request) string {
+	switch format := req.URL.Query().Get("format"); format {
+	case "csv", "ndjson":
+		return format
+	case "":
+	default:
+		return ""
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/x-ndjson", "application/ndjson":
+		return "ndjson"
+	}
+
+	return ""
+}
@@ -0,0 +1,189 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/test_utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("ImportHandler", func() {
+	var (
+		recorder            *httptest.ResponseRecorder
+		request             *http.Request
+		handler             handlers.ImportHandler
+		fakeCouponService   *handlersfakes.FakeCouponService
+		fakeTransactor      *handlersfakes.FakeCouponTransactor
+		fakeCouponValidator *handlersfakes.FakeCouponValidator
+	)
+
+	newRequest := func(target string, contentType string, body string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", contentType)
+		return authenticated(req, auth.ScopeCouponsWrite)
+	}
+
+	BeforeEach(func() {
+		fakeCouponService = &handlersfakes.FakeCouponService{}
+		fakeTransactor = &handlersfakes.FakeCouponTransactor{}
+		fakeCouponValidator = &handlersfakes.FakeCouponValidator{}
+
+		fakeTransactor.WithinTransactionStub = func(ctx context.Context, fn func(handlers.CouponService) error) error {
+			return fn(fakeCouponService)
+		}
+
+		fakeCouponValidator.ValidateStub = func(couponInstance coupon.Coupon) error {
+			if couponInstance.Name == nil || *couponInstance.Name == "" {
+				return errors.New("name field is required")
+			}
+			return nil
+		}
+
+		handler = handlers.ImportHandler{
+			CouponTransactor: fakeTransactor,
+			CouponValidator:  fakeCouponValidator,
+		}
+
+		recorder = httptest.NewRecorder()
+
+		request = newRequest("/coupons/import?mapping=name:Title", "text/csv", "Title,brand,value\nA,Tesco,1\n,Tesco,2\nC,Tesco,three\nD,Tesco,4\n")
+	})
+
+	It("imports the valid rows and reports the rejected ones", func() {
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+  "meta": {
+    "dryRun": false,
+    "accepted": 2,
+    "rejected": 2,
+    "rejections": [
+      {"row": 2, "reason": "name field is required"},
+      {"row": 3, "reason": "value field must be an integer"}
+    ]
+  }
+}`))
+
+		Expect(fakeTransactor.WithinTransactionCallCount()).To(Equal(1))
+		Expect(fakeCouponService.CreateCouponsCallCount()).To(Equal(1))
+
+		_, importedCoupons := fakeCouponService.CreateCouponsArgsForCall(0)
+		Expect(importedCoupons).To(HaveLen(2))
+		Expect(*importedCoupons[0].Name).To(Equal("A"))
+		Expect(*importedCoupons[1].Name).To(Equal("D"))
+	})
+
+	It("validates every row but writes nothing on a dry run", func() {
+		request.URL.RawQuery += "&dry_run=true"
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"dryRun":true`))
+		Expect(recorder.Body.String()).To(ContainSubstring(`"accepted":2`))
+
+		Expect(fakeCouponValidator.ValidateCallCount()).To(Equal(3))
+		Expect(fakeTransactor.WithinTransactionCallCount()).To(Equal(0))
+		Expect(fakeCouponService.CreateCouponsCallCount()).To(Equal(0))
+	})
+
+	It("inserts large files in batches", func() {
+		body := strings.Builder{}
+		body.WriteString("name,brand,value\n")
+		for i := 0; i < 1201; i++ {
+			body.WriteString(fmt.Sprintf("Coupon %d,Tesco,%d\n", i, i))
+		}
+		request = newRequest("/coupons/import?format=csv", "", body.String())
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(fakeCouponService.CreateCouponsCallCount()).To(Equal(3))
+		for i, expectedLen := range []int{500, 500, 201} {
+			_, batch := fakeCouponService.CreateCouponsArgsForCall(i)
+			Expect(batch).To(HaveLen(expectedLen))
+		}
+	})
+
+	It("imports NDJSON", func() {
+		request = newRequest("/coupons/import", "application/x-ndjson", `{"name": "A", "brand": "Tesco", "value": 1}`)
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(fakeCouponService.CreateCouponsCallCount()).To(Equal(1))
+		_, batch := fakeCouponService.CreateCouponsArgsForCall(0)
+		Expect(batch).To(HaveLen(1))
+	})
+
+	It("returns a 415 if the format is unknown", func() {
+		request = newRequest("/coupons/import", "application/vnd.ms-excel", "")
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusUnsupportedMediaType))
+	})
+
+	It("returns a 400 if the mapping is invalid", func() {
+		request.URL.RawQuery = "mapping=expiry:Expires"
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(fakeTransactor.WithinTransactionCallCount()).To(Equal(0))
+	})
+
+	It("returns a 400 if the csv header doesn't contain the mapped columns", func() {
+		request.URL.RawQuery = ""
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("returns a 400 if dry_run isn't a boolean", func() {
+		request.URL.RawQuery += "&dry_run=perhaps"
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+	})
+
+	It("propagates the error if the coupon service fails", func() {
+		fakeCouponService.CreateCouponsReturns(errors.New("disk full"))
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("propagates the error if reading the request body fails", func() {
+		request = newRequest("/coupons/import", "application/x-ndjson", "")
+		request.Body = ioutil.NopCloser(test_utils.DummyReader{Message: "bad bad bad"})
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("errors if the method is unsupported", func() {
+		request.Method = http.MethodGet
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/madeleinesmith/coupons/auth"
+	"io/ioutil"
+	"net/http"
+)
+
+// https://jsonapi.org/ext/atomic/
+const AtomicContentType = `application/vnd.api+json; ext="https://jsonapi.org/ext/atomic"`
+
+type atomicRef struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type atomicOperation struct {
+	Op   string          `json:"op"`
+	Ref  *atomicRef      `json:"ref,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type atomicRequest struct {
+	Operations []atomicOperation `json:"atomic:operations"`
+}
+
+type atomicResponse struct {
+	Results []json.RawMessage `json:"atomic:results"`
+}
+
+type operationError struct {
+	index int
+	code  int
+	err   error
+}
+
+func (e operationError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.index, e.err.Error())
+}
+
+func (e operationError) Unwrap() error {
+	return e.err
+}
+
+type OperationsHandler struct {
+	Serializer       CouponSerializer
+	CouponTransactor CouponTransactor
+	CouponValidator  CouponValidator
+}
+
+func (h OperationsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		h.handlePost(w, req)
+	default:
+		handleError(w, req, errors.New("Method not allowed"), http.StatusMethodNotAllowed)
+	}
+}
+
+func (h OperationsHandler) handlePost(w http.ResponseWriter, req *http.Request) {
+	if !requireScope(w, req, auth.ScopeCouponsWrite) {
+		return
+	}
+
+	bodyBytes, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	var atomicReq atomicRequest
+	err = json.Unmarshal(bodyBytes, &atomicReq)
+	if err != nil {
+		handleError(w, req, err, http.StatusBadRequest)
+		return
+	}
+
+	if len(atomicReq.Operations) == 0 {
+		handleError(w, req, errors.New("atomic:operations must contain at least one operation"), http.StatusBadRequest)
+		return
+	}
+
+	results := make([]json.RawMessage, len(atomicReq.Operations))
+
+	err = h.CouponTransactor.WithinTransaction(req.Context(), func(couponService CouponService) error {
+		for i, operation := range atomicReq.Operations {
+			result, err := h.runOperation(req.Context(), couponService, operation)
+			if err != nil {
+				err.index = i
+				return err
+			}
+
+			results[i] = result
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		code := http.StatusInternalServerError
+		if opErr, ok := err.(*operationError); ok {
+			code = opErr.code
+		}
+
+		handleError(w, req, err, code)
+		return
+	}
+
+	responseBytes, err := json.Marshal(atomicResponse{Results: results})
+	if err != nil {
+		handleError(w, req, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", AtomicContentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(responseBytes)
+}
+
+func (h OperationsHandler) runOperation(ctx context.Context, couponService CouponService, operation atomicOperation) (json.RawMessage, *operationError) {
+	switch operation.Op {
+	case "add":
+		couponInstance, err := h.Serializer.DeserializeCoupon(wrapData(operation.Data))
+		if err != nil {
+			return nil, &operationError{code: http.StatusBadRequest, err: err}
+		}
+
+		err = h.CouponValidator.Validate(couponInstance)
+		if err != nil {
+			return nil, &operationError{code: http.StatusBadRequest, err: err}
+		}
+
+		createdCoupon, err := couponService.CreateCoupon(ctx, couponInstance)
+		if err != nil {
+			return nil, &operationError{code: http.StatusInternalServerError, err: err}
+		}
+
+		return h.serializeResult(ctx, createdCoupon.ID, couponService)
+
+	case "update":
+		couponInstance, err := h.Serializer.DeserializeCoupon(wrapData(operation.Data))
+		if err != nil {
+			return nil, &operationError{code: http.StatusBadRequest, err: err}
+		}
+
+		if operation.Ref != nil && operation.Ref.Type != "coupons" {
+			return nil, &operationError{code: http.StatusBadRequest, err: fmt.Errorf("update operations can't change %q, only coupons", operation.Ref.Type)}
+		}
+
+		if couponInstance.ID == "" && operation.Ref != nil {
+			couponInstance.ID = operation.Ref.ID
+		}
+
+		if couponInstance.ID == "" {
+			return nil, &operationError{code: http.StatusBadRequest, err: errors.New("update operations require a coupon id")}
+		}
+
+		err = couponService.UpdateCoupon(ctx, couponInstance)
+		if err != nil {
+			return nil, &operationError{code: statusForLookupError(err), err: err}
+		}
+
+		return h.serializeResult(ctx, couponInstance.ID, couponService)
+
+	case "remove":
+		if operation.Ref == nil || operation.Ref.Type != "coupons" || operation.Ref.ID == "" {
+			return nil, &operationError{code: http.StatusBadRequest, err: errors.New("remove operations require a coupons ref with an id")}
+		}
+
+		err := couponService.DeleteCoupon(ctx, operation.Ref.ID)
+		if err != nil {
+			return nil, &operationError{code: statusForLookupError(err), err: err}
+		}
+
+		return json.RawMessage(`{}`), nil
+
+	default:
+		return nil, &operationError{code: http.StatusBadRequest, err: fmt.Errorf("unsupported op %q", operation.Op)}
+	}
+}
+
+// the result of an add/update is the coupon as it now stands in the transaction
+func (h OperationsHandler) serializeResult(ctx context.Context, couponId string, couponService CouponService) (json.RawMessage, *operationError) {
+	couponInstance, err := couponService.GetCouponById(ctx, couponId)
+	if err != nil {
+		return nil, &operationError{code: statusForLookupError(err), err: err}
+	}
+
+	serializedCoupon, err := h.Serializer.SerializeCoupon(couponInstance)
+	if err != nil {
+		return nil, &operationError{code: http.StatusInternalServerError, err: err}
+	}
+
+	return json.RawMessage(serializedCoupon), nil
+}
+
+func wrapData(data json.RawMessage) []byte {
+	return []byte(fmt.Sprintf(`{"data": %s}`, data))
+}
+
+func statusForLookupError(err error) int {
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
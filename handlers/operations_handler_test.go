@@ -0,0 +1,231 @@
+package handlers_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+var _ = Describe("OperationsHandler", func() {
+	var (
+		recorder             *httptest.ResponseRecorder
+		request              *http.Request
+		handler              handlers.OperationsHandler
+		fakeCouponSerializer *handlersfakes.FakeCouponSerializer
+		fakeCouponService    *handlersfakes.FakeCouponService
+		fakeTransactor       *handlersfakes.FakeCouponTransactor
+		fakeCouponValidator  *handlersfakes.FakeCouponValidator
+		bodyJSON             string
+		newCoupon            coupon.Coupon
+		updatedCoupon        coupon.Coupon
+	)
+
+	BeforeEach(func() {
+		fakeCouponSerializer = &handlersfakes.FakeCouponSerializer{}
+		fakeCouponService = &handlersfakes.FakeCouponService{}
+		fakeTransactor = &handlersfakes.FakeCouponTransactor{}
+		fakeCouponValidator = &handlersfakes.FakeCouponValidator{}
+
+		fakeTransactor.WithinTransactionStub = func(ctx context.Context, fn func(handlers.CouponService) error) error {
+			return fn(fakeCouponService)
+		}
+
+		handler = handlers.OperationsHandler{
+			Serializer:       fakeCouponSerializer,
+			CouponTransactor: fakeTransactor,
+			CouponValidator:  fakeCouponValidator,
+		}
+
+		recorder = httptest.NewRecorder()
+
+		bodyJSON = `{
+  "atomic:operations": [{
+    "op": "add",
+    "data": {"type": "coupons", "attributes": {"name": "Save £5 at Boots", "brand": "Boots", "value": 5}}
+  }, {
+    "op": "update",
+    "data": {"type": "coupons", "id": "0faec7ea-239f-11e9-9e44-d770694a0159", "attributes": {"value": 15}}
+  }, {
+    "op": "remove",
+    "ref": {"type": "coupons", "id": "c614eeaa-1c9d-11e9-8c4f-3f7c43a05026"}
+  }]
+}`
+
+		var err error
+		request, err = http.NewRequest(http.MethodPost, "/operations", strings.NewReader(bodyJSON))
+		Expect(err).NotTo(HaveOccurred())
+		request = authenticated(request, auth.ScopeCouponsWrite)
+
+		name := "Save £5 at Boots"
+		brand := "Boots"
+		value := 5
+		newCoupon = coupon.Coupon{Name: &name, Brand: &brand, Value: &value}
+
+		updatedValue := 15
+		updatedCoupon = coupon.Coupon{ID: "0faec7ea-239f-11e9-9e44-d770694a0159", Value: &updatedValue}
+
+		fakeCouponSerializer.DeserializeCouponReturnsOnCall(0, newCoupon, nil)
+		fakeCouponSerializer.DeserializeCouponReturnsOnCall(1, updatedCoupon, nil)
+
+		createdCoupon := newCoupon
+		createdCoupon.ID = "9dfd6d90-1c0a-11e9-9567-73937c5f9289"
+		fakeCouponService.CreateCouponReturns(&createdCoupon, nil)
+		fakeCouponService.GetCouponByIdReturns(&createdCoupon, nil)
+
+		fakeCouponSerializer.SerializeCouponReturnsOnCall(0, []byte(`{"data": {"type": "coupons", "id": "1"}}`), nil)
+		fakeCouponSerializer.SerializeCouponReturnsOnCall(1, []byte(`{"data": {"type": "coupons", "id": "2"}}`), nil)
+	})
+
+	It("runs every operation inside a single transaction", func() {
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal(handlers.AtomicContentType))
+		Expect(recorder.Body.String()).To(MatchJSON(`{
+  "atomic:results": [
+    {"data": {"type": "coupons", "id": "1"}},
+    {"data": {"type": "coupons", "id": "2"}},
+    {}
+  ]
+}`))
+
+		Expect(fakeTransactor.WithinTransactionCallCount()).To(Equal(1))
+
+		Expect(fakeCouponSerializer.DeserializeCouponArgsForCall(0)).To(MatchJSON(`{
+  "data": {"type": "coupons", "attributes": {"name": "Save £5 at Boots", "brand": "Boots", "value": 5}}
+}`))
+
+		Expect(fakeCouponValidator.ValidateCallCount()).To(Equal(1))
+		Expect(fakeCouponValidator.ValidateArgsForCall(0)).To(Equal(newCoupon))
+
+		Expect(fakeCouponService.CreateCouponCallCount()).To(Equal(1))
+		_, couponToCreate := fakeCouponService.CreateCouponArgsForCall(0)
+		Expect(couponToCreate).To(Equal(newCoupon))
+
+		Expect(fakeCouponService.UpdateCouponCallCount()).To(Equal(1))
+		_, couponToUpdate := fakeCouponService.UpdateCouponArgsForCall(0)
+		Expect(couponToUpdate).To(Equal(updatedCoupon))
+
+		Expect(fakeCouponService.DeleteCouponCallCount()).To(Equal(1))
+		_, couponToDelete := fakeCouponService.DeleteCouponArgsForCall(0)
+		Expect(couponToDelete).To(Equal("c614eeaa-1c9d-11e9-8c4f-3f7c43a05026"))
+	})
+
+	It("returns a 400 if the body is not valid JSON", func() {
+		request.Body = http.NoBody
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(fakeTransactor.WithinTransactionCallCount()).To(Equal(0))
+	})
+
+	It("returns a 400 if there are no operations", func() {
+		request, _ = http.NewRequest(http.MethodPost, "/operations", strings.NewReader(`{"atomic:operations": []}`))
+		request = authenticated(request, auth.ScopeCouponsWrite)
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(fakeTransactor.WithinTransactionCallCount()).To(Equal(0))
+	})
+
+	It("returns a 400 if an op is unsupported", func() {
+		request, _ = http.NewRequest(http.MethodPost, "/operations", strings.NewReader(`{"atomic:operations": [{"op": "upsert"}]}`))
+		request = authenticated(request, auth.ScopeCouponsWrite)
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Body.String()).To(ContainSubstring(`operation 0: unsupported op "upsert"`))
+	})
+
+	It("aborts the transaction with a 400 if validation of an added coupon fails", func() {
+		fakeCouponValidator.ValidateReturns(errors.New("name field is required"))
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Body.String()).To(ContainSubstring("operation 0: name field is required"))
+		Expect(fakeCouponService.CreateCouponCallCount()).To(Equal(0))
+		Expect(fakeCouponService.UpdateCouponCallCount()).To(Equal(0))
+	})
+
+	It("returns a 404 naming the operation if a removed coupon does not exist", func() {
+		fakeCouponService.DeleteCouponReturns(sql.ErrNoRows)
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		Expect(recorder.Body.String()).To(ContainSubstring("operation 2:"))
+	})
+
+	It("returns a 404 naming the operation if an updated coupon does not exist", func() {
+		fakeCouponService.UpdateCouponReturns(sql.ErrNoRows)
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		Expect(recorder.Body.String()).To(ContainSubstring("operation 1:"))
+		Expect(fakeCouponService.DeleteCouponCallCount()).To(Equal(0))
+	})
+
+	It("returns a 400 if an update refers to something other than a coupon", func() {
+		request, _ = http.NewRequest(http.MethodPost, "/operations", strings.NewReader(`{"atomic:operations": [{
+  "op": "update",
+  "ref": {"type": "redemptions", "id": "0faec7ea-239f-11e9-9e44-d770694a0159"},
+  "data": {"type": "coupons", "attributes": {"value": 15}}
+}]}`))
+		request = authenticated(request, auth.ScopeCouponsWrite)
+		fakeCouponSerializer.DeserializeCouponReturnsOnCall(0, coupon.Coupon{}, nil)
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(recorder.Body.String()).To(ContainSubstring(`operation 0: update operations can't change "redemptions", only coupons`))
+		Expect(fakeCouponService.UpdateCouponCallCount()).To(Equal(0))
+	})
+
+	It("returns a 403 naming the operation if the policy denies it", func() {
+		fakeCouponService.DeleteCouponReturns(auth.PermissionDeniedError{Permission: "delete-coupons"})
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusForbidden))
+		Expect(recorder.Body.String()).To(ContainSubstring("operation 2: permission denied: delete-coupons"))
+	})
+
+	It("propagates the error if the coupon service fails", func() {
+		fakeCouponService.UpdateCouponReturns(errors.New("deadlock detected"))
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(fakeCouponService.DeleteCouponCallCount()).To(Equal(0))
+	})
+
+	It("propagates the error if the transaction cannot be committed", func() {
+		fakeTransactor.WithinTransactionStub = nil
+		fakeTransactor.WithinTransactionReturns(errors.New("could not serialize access"))
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("errors if the method is unsupported", func() {
+		request.Method = http.MethodGet
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
@@ -0,0 +1,107 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+//go:generate counterfeiter . Checker
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc lets a plain function be used as a Checker
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Check fails if it errors, or takes longer than its Budget when it has one
+type Check struct {
+	Name    string
+	Checker Checker
+	Budget  time.Duration
+}
+
+type CheckResult struct {
+	Status     string  `json:"status"`
+	DurationMS float64 `json:"durationMs"`
+	Error      string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// Liveness only shows the process is up and serving, so it never touches the database
+func Liveness(w http.ResponseWriter, req *http.Request) {
+	writeReport(w, Report{Status: StatusOK})
+}
+
+// Readiness runs every check on each request, responding 503 if any fails so traffic is routed elsewhere
+type Readiness struct {
+	Checks  []Check
+	Timeout time.Duration
+}
+
+func (r Readiness) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	report := Report{Status: StatusOK, Checks: map[string]CheckResult{}}
+
+	for _, check := range r.Checks {
+		result := run(ctx, check)
+		if result.Status != StatusOK {
+			report.Status = StatusFail
+		}
+
+		report.Checks[check.Name] = result
+	}
+
+	writeReport(w, report)
+}
+
+func run(ctx context.Context, check Check) CheckResult {
+	start := time.Now()
+	err := check.Checker.Check(ctx)
+	duration := time.Since(start)
+
+	result := CheckResult{Status: StatusOK, DurationMS: float64(duration.Microseconds()) / 1000}
+
+	if err == nil && check.Budget > 0 && duration > check.Budget {
+		err = fmt.Errorf("took %s, over its budget of %s", duration.Round(time.Millisecond), check.Budget)
+	}
+
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if report.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,113 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/madeleinesmith/coupons/health"
+	"github.com/madeleinesmith/coupons/health/healthfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("Health", func() {
+	var (
+		recorder *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	decodeReport := func() health.Report {
+		var report health.Report
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &report)).To(Succeed())
+
+		return report
+	}
+
+	BeforeEach(func() {
+		var err error
+
+		recorder = httptest.NewRecorder()
+		request, err = http.NewRequest(http.MethodGet, "/readyz", nil)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("Liveness", func() {
+		It("always responds ok", func() {
+			health.Liveness(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(decodeReport()).To(Equal(health.Report{Status: health.StatusOK}))
+		})
+	})
+
+	Describe("Readiness", func() {
+		var (
+			databaseChecker   *healthfakes.FakeChecker
+			migrationsChecker *healthfakes.FakeChecker
+			readiness         health.Readiness
+		)
+
+		BeforeEach(func() {
+			databaseChecker = &healthfakes.FakeChecker{}
+			migrationsChecker = &healthfakes.FakeChecker{}
+
+			readiness = health.Readiness{
+				Checks: []health.Check{
+					{Name: "database", Checker: databaseChecker},
+					{Name: "migrations", Checker: migrationsChecker},
+				},
+				Timeout: time.Second,
+			}
+		})
+
+		It("responds ok when every check passes", func() {
+			readiness.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			report := decodeReport()
+			Expect(report.Status).To(Equal(health.StatusOK))
+			Expect(report.Checks).To(HaveLen(2))
+			Expect(report.Checks["database"].Status).To(Equal(health.StatusOK))
+			Expect(report.Checks["migrations"].Status).To(Equal(health.StatusOK))
+
+			ctx := databaseChecker.CheckArgsForCall(0)
+			_, hasDeadline := ctx.Deadline()
+			Expect(hasDeadline).To(BeTrue())
+		})
+
+		It("responds 503 with the failing check's error", func() {
+			migrationsChecker.CheckReturns(errors.New("database schema is at version 9"))
+
+			readiness.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+
+			report := decodeReport()
+			Expect(report.Status).To(Equal(health.StatusFail))
+			Expect(report.Checks["database"].Status).To(Equal(health.StatusOK))
+			Expect(report.Checks["migrations"].Status).To(Equal(health.StatusFail))
+			Expect(report.Checks["migrations"].Error).To(Equal("database schema is at version 9"))
+		})
+
+		It("fails checks that go over their latency budget", func() {
+			readiness.Checks = []health.Check{{
+				Name: "sample-query",
+				Checker: health.CheckerFunc(func(ctx context.Context) error {
+					time.Sleep(5 * time.Millisecond)
+					return nil
+				}),
+				Budget: time.Millisecond,
+			}}
+
+			readiness.ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(decodeReport().Checks["sample-query"].Error).To(ContainSubstring("over its budget of 1ms"))
+		})
+	})
+})
@@ -0,0 +1,210 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"github.com/madeleinesmith/coupons/response"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	Header         = "Idempotency-Key"
+	ReplayedHeader = "Idempotent-Replayed"
+	DefaultTTL     = 24 * time.Hour
+	// DefaultLease is how long a key can be in progress before a retry takes it over, in case the request's server
+	// died before finishing with it. It's the default server.writeTimeout, which no request can outlast.
+	DefaultLease = 5 * time.Minute
+	MaxKeyLength = 255
+
+	// MaxStoredResponseSize caps the response kept for replays. Anything bigger isn't stored, and the key is
+	// released as it would be for a server error.
+	MaxStoredResponseSize = 8 << 20
+)
+
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Entry is a stored key. Its Fingerprint and Response are nil while the first request with the key is still being
+// handled, as the body is only fingerprinted as the handler reads it.
+type Entry struct {
+	Fingerprint []byte
+	Response    *Response
+}
+
+//go:generate counterfeiter . Store
+type Store interface {
+	// Reserve returns false if the key is already in use and hasn't expired, unless it has been in progress for longer
+	// than lease
+	Reserve(ctx context.Context, key string, ttl time.Duration, lease time.Duration) (bool, error)
+	Find(ctx context.Context, key string) (*Entry, error)
+	Complete(ctx context.Context, key string, fingerprint []byte, response Response) error
+	Release(ctx context.Context, key string) error
+}
+
+// Fingerprint identifies a request, so a key can't be reused for a different one
+func Fingerprint(req *http.Request, body []byte) []byte {
+	fingerprint := newFingerprint(req)
+	fingerprint.Write(body)
+
+	return fingerprint.Sum(nil)
+}
+
+// newFingerprint is Fingerprint before the body has been written to it
+func newFingerprint(req *http.Request) hash.Hash {
+	fingerprint := sha256.New()
+	fmt.Fprintf(fingerprint, "%s %s\n", req.Method, req.URL.RequestURI())
+
+	return fingerprint
+}
+
+// Middleware replays the stored response to any POST retried with the same Idempotency-Key and body. Server errors
+// aren't stored, so those requests can be retried for real, and nor are panics. A key whose request never finished,
+// say as the server died, can be retried once it has been in progress for longer than lease.
+func Middleware(store Store, ttl time.Duration, lease time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			key := req.Header.Get(Header)
+			if req.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			if len(key) > MaxKeyLength {
+				http.Error(w, fmt.Sprintf("%s must be at most %d characters", Header, MaxKeyLength), http.StatusBadRequest)
+				return
+			}
+
+			reserved, err := store.Reserve(req.Context(), key, ttl, lease)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if !reserved {
+				replay(w, req, store, key)
+				return
+			}
+
+			// the body is fingerprinted as the handler reads it, so imports are still never held in memory
+			fingerprint := newFingerprint(req)
+			body := req.Body
+			req.Body = readCloser{Reader: io.TeeReader(body, fingerprint), Closer: body}
+
+			defer func() {
+				recovered := recover()
+				if recovered == nil {
+					return
+				}
+
+				releaseErr := store.Release(context.WithoutCancel(req.Context()), key)
+				if releaseErr != nil {
+					log.Printf("releasing %s %q after a panic: %v", Header, key, releaseErr)
+				}
+
+				panic(recovered)
+			}()
+
+			recorder := &responseRecorder{Recorder: response.NewRecorder(w)}
+			next.ServeHTTP(recorder, req)
+
+			// anything the handler didn't read is still part of the request
+			_, drainErr := io.Copy(fingerprint, body)
+
+			// the response has gone, so finish up even if the client has too
+			ctx := context.WithoutCancel(req.Context())
+
+			switch {
+			case recorder.StatusCode >= http.StatusInternalServerError:
+				err = store.Release(ctx, key)
+			case drainErr != nil:
+				log.Printf("not storing the response for %s %q, as the request body couldn't be read: %v", Header, key, drainErr)
+				err = store.Release(ctx, key)
+			case recorder.tooLarge:
+				log.Printf("not storing the response for %s %q, as it's over %d bytes", Header, key, MaxStoredResponseSize)
+				err = store.Release(ctx, key)
+			default:
+				err = store.Complete(ctx, key, fingerprint.Sum(nil), Response{
+					StatusCode: recorder.StatusCode,
+					Header:     w.Header().Clone(),
+					Body:       recorder.body.Bytes(),
+				})
+			}
+
+			if err != nil {
+				log.Printf("storing the response for %s %q: %v", Header, key, err)
+			}
+		})
+	}
+}
+
+func replay(w http.ResponseWriter, req *http.Request, store Store, key string) {
+	entry, err := store.Find(req.Context(), key)
+	if err == sql.ErrNoRows {
+		// it expired, or its request failed, since we tried to reserve it
+		http.Error(w, fmt.Sprintf("the request with this %s has not finished, try again", Header), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if entry.Response == nil {
+		http.Error(w, fmt.Sprintf("the request with this %s has not finished, try again", Header), http.StatusConflict)
+		return
+	}
+
+	fingerprint := newFingerprint(req)
+	_, err = io.Copy(fingerprint, req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if !bytes.Equal(entry.Fingerprint, fingerprint.Sum(nil)) {
+		http.Error(w, fmt.Sprintf("%s has already been used for a different request", Header), http.StatusUnprocessableEntity)
+		return
+	}
+
+	for name, values := range entry.Response.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(entry.Response.StatusCode)
+	w.Write(entry.Response.Body)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// responseRecorder keeps a copy of everything written, up to MaxStoredResponseSize, so it can be stored once the
+// handler is done
+type responseRecorder struct {
+	*response.Recorder
+	body     bytes.Buffer
+	tooLarge bool
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if !r.tooLarge && r.body.Len()+len(data) > MaxStoredResponseSize {
+		r.tooLarge = true
+		r.body = bytes.Buffer{}
+	}
+	if !r.tooLarge {
+		r.body.Write(data)
+	}
+
+	return r.Recorder.Write(data)
+}
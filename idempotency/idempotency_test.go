@@ -0,0 +1,255 @@
+package idempotency_test
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/idempotency"
+	"github.com/madeleinesmith/coupons/idempotency/idempotencyfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+var _ = Describe("Middleware", func() {
+	var (
+		fakeStore          *idempotencyfakes.FakeStore
+		handler            http.Handler
+		recorder           *httptest.ResponseRecorder
+		nextHandlerInvoked bool
+		nextHandlerStatus  int
+		capturedBody       string
+	)
+
+	newRequest := func(body string) *http.Request {
+		request, err := http.NewRequest(http.MethodPost, "/coupons", strings.NewReader(body))
+		Expect(err).NotTo(HaveOccurred())
+		request.Header.Set(idempotency.Header, "checkout-order-123")
+
+		return request
+	}
+
+	BeforeEach(func() {
+		fakeStore = &idempotencyfakes.FakeStore{}
+		fakeStore.ReserveReturns(true, nil)
+
+		nextHandlerInvoked = false
+		nextHandlerStatus = http.StatusCreated
+		handler = idempotency.Middleware(fakeStore, time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			nextHandlerInvoked = true
+
+			body, err := ioutil.ReadAll(req.Body)
+			Expect(err).NotTo(HaveOccurred())
+			capturedBody = string(body)
+
+			w.Header().Set("Content-Type", "application/vnd.api+json")
+			w.WriteHeader(nextHandlerStatus)
+			w.Write([]byte(`{"data":{"id":"1"}}`))
+		}))
+
+		recorder = httptest.NewRecorder()
+	})
+
+	It("stores the response to the first request with a key", func() {
+		handler.ServeHTTP(recorder, newRequest(`{"name":"Save £5"}`))
+
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		Expect(capturedBody).To(Equal(`{"name":"Save £5"}`))
+
+		Expect(fakeStore.ReserveCallCount()).To(Equal(1))
+		_, key, ttl, lease := fakeStore.ReserveArgsForCall(0)
+		Expect(key).To(Equal("checkout-order-123"))
+		Expect(ttl).To(Equal(time.Hour))
+		Expect(lease).To(Equal(time.Minute))
+
+		Expect(fakeStore.CompleteCallCount()).To(Equal(1))
+		_, key, fingerprint, response := fakeStore.CompleteArgsForCall(0)
+		Expect(key).To(Equal("checkout-order-123"))
+		Expect(fingerprint).To(Equal(idempotency.Fingerprint(newRequest(""), []byte(`{"name":"Save £5"}`))))
+		Expect(response.StatusCode).To(Equal(http.StatusCreated))
+		Expect(response.Header.Get("Content-Type")).To(Equal("application/vnd.api+json"))
+		Expect(string(response.Body)).To(Equal(`{"data":{"id":"1"}}`))
+	})
+
+	It("replays the stored response to retries with the same body", func() {
+		request := newRequest(`{"name":"Save £5"}`)
+
+		fakeStore.ReserveReturns(false, nil)
+		fakeStore.FindReturns(&idempotency.Entry{
+			Fingerprint: idempotency.Fingerprint(request, []byte(`{"name":"Save £5"}`)),
+			Response: &idempotency.Response{
+				StatusCode: http.StatusCreated,
+				Header:     http.Header{"Content-Type": {"application/vnd.api+json"}},
+				Body:       []byte(`{"data":{"id":"1"}}`),
+			},
+		}, nil)
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(nextHandlerInvoked).To(BeFalse())
+		Expect(recorder.Code).To(Equal(http.StatusCreated))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/vnd.api+json"))
+		Expect(recorder.Header().Get(idempotency.ReplayedHeader)).To(Equal("true"))
+		Expect(recorder.Body.String()).To(Equal(`{"data":{"id":"1"}}`))
+	})
+
+	It("returns 422 if the key was used with a different body", func() {
+		fakeStore.ReserveReturns(false, nil)
+		fakeStore.FindReturns(&idempotency.Entry{
+			Fingerprint: idempotency.Fingerprint(newRequest(""), []byte(`{"name":"Save £5"}`)),
+			Response:    &idempotency.Response{StatusCode: http.StatusCreated},
+		}, nil)
+
+		handler.ServeHTTP(recorder, newRequest(`{"name":"Save £500"}`))
+
+		Expect(nextHandlerInvoked).To(BeFalse())
+		Expect(recorder.Code).To(Equal(http.StatusUnprocessableEntity))
+	})
+
+	It("returns 409 while the first request is still being handled", func() {
+		fakeStore.ReserveReturns(false, nil)
+		fakeStore.FindReturns(&idempotency.Entry{}, nil)
+
+		handler.ServeHTTP(recorder, newRequest(`{"name":"Save £5"}`))
+
+		Expect(nextHandlerInvoked).To(BeFalse())
+		Expect(recorder.Code).To(Equal(http.StatusConflict))
+	})
+
+	It("returns 409 if the key is released before it can be found", func() {
+		fakeStore.ReserveReturns(false, nil)
+		fakeStore.FindReturns(nil, sql.ErrNoRows)
+
+		handler.ServeHTTP(recorder, newRequest(`{}`))
+		Expect(recorder.Code).To(Equal(http.StatusConflict))
+	})
+
+	It("releases the key rather than storing server errors, so they can be retried", func() {
+		nextHandlerStatus = http.StatusInternalServerError
+
+		handler.ServeHTTP(recorder, newRequest(`{}`))
+
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		Expect(fakeStore.CompleteCallCount()).To(Equal(0))
+		Expect(fakeStore.ReleaseCallCount()).To(Equal(1))
+		_, key := fakeStore.ReleaseArgsForCall(0)
+		Expect(key).To(Equal("checkout-order-123"))
+	})
+
+	It("fingerprints the body as the handler streams it, rather than reading it all up front", func() {
+		body, bodyWriter := io.Pipe()
+		request, err := http.NewRequest(http.MethodPost, "/coupons/import", body)
+		Expect(err).NotTo(HaveOccurred())
+		request.Header.Set(idempotency.Header, "nightly-import-2026-10-19")
+
+		firstLineRead := make(chan string)
+		handler = idempotency.Middleware(fakeStore, time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			reader := bufio.NewReader(req.Body)
+
+			line, err := reader.ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			firstLineRead <- line
+
+			_, err = io.Copy(ioutil.Discard, reader)
+			Expect(err).NotTo(HaveOccurred())
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		served := make(chan struct{})
+		go func() {
+			defer GinkgoRecover()
+			defer close(served)
+			handler.ServeHTTP(recorder, request)
+		}()
+
+		// the rest of the body isn't sent until the handler has the first line, so this would block forever if the
+		// middleware read the whole body before calling it
+		_, err = bodyWriter.Write([]byte("name,brand,value\n"))
+		Expect(err).NotTo(HaveOccurred())
+		Eventually(firstLineRead).Should(Receive(Equal("name,brand,value\n")))
+
+		_, err = bodyWriter.Write([]byte("Save £5,Boots,5\n"))
+		Expect(err).NotTo(HaveOccurred())
+		bodyWriter.Close()
+		Eventually(served).Should(BeClosed())
+
+		Expect(fakeStore.CompleteCallCount()).To(Equal(1))
+		_, _, fingerprint, _ := fakeStore.CompleteArgsForCall(0)
+		Expect(fingerprint).To(Equal(idempotency.Fingerprint(request, []byte("name,brand,value\nSave £5,Boots,5\n"))))
+	})
+
+	It("fingerprints any of the body the handler didn't read", func() {
+		handler = idempotency.Middleware(fakeStore, time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+
+		handler.ServeHTTP(recorder, newRequest(`{"name":"Save £5"}`))
+
+		_, _, fingerprint, response := fakeStore.CompleteArgsForCall(0)
+		Expect(fingerprint).To(Equal(idempotency.Fingerprint(newRequest(""), []byte(`{"name":"Save £5"}`))))
+		Expect(response.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+
+	It("releases the key rather than storing responses too large to keep", func() {
+		handler = idempotency.Middleware(fakeStore, time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Write(bytes.Repeat([]byte("a"), idempotency.MaxStoredResponseSize))
+			w.Write([]byte("a"))
+		}))
+
+		handler.ServeHTTP(recorder, newRequest(`{}`))
+
+		Expect(recorder.Body.Len()).To(Equal(idempotency.MaxStoredResponseSize + 1))
+		Expect(fakeStore.CompleteCallCount()).To(Equal(0))
+		Expect(fakeStore.ReleaseCallCount()).To(Equal(1))
+	})
+
+	It("releases the key if the handler panics, and passes the panic on", func() {
+		handler = idempotency.Middleware(fakeStore, time.Hour, time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			panic("nil map")
+		}))
+
+		Expect(func() { handler.ServeHTTP(recorder, newRequest(`{}`)) }).To(Panic())
+
+		Expect(fakeStore.CompleteCallCount()).To(Equal(0))
+		Expect(fakeStore.ReleaseCallCount()).To(Equal(1))
+		_, key := fakeStore.ReleaseArgsForCall(0)
+		Expect(key).To(Equal("checkout-order-123"))
+	})
+
+	It("returns 500 if the key can't be reserved", func() {
+		fakeStore.ReserveReturns(false, errors.New("connection refused"))
+
+		handler.ServeHTTP(recorder, newRequest(`{}`))
+
+		Expect(nextHandlerInvoked).To(BeFalse())
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+	})
+
+	It("rejects overly long keys", func() {
+		request := newRequest(`{}`)
+		request.Header.Set(idempotency.Header, strings.Repeat("k", 256))
+
+		handler.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusBadRequest))
+		Expect(fakeStore.ReserveCallCount()).To(Equal(0))
+	})
+
+	It("ignores requests without a key, and anything other than a POST", func() {
+		request := newRequest(`{}`)
+		request.Header.Del(idempotency.Header)
+		handler.ServeHTTP(recorder, request)
+
+		request = newRequest(`{}`)
+		request.Method = http.MethodPatch
+		handler.ServeHTTP(httptest.NewRecorder(), request)
+
+		Expect(fakeStore.ReserveCallCount()).To(Equal(0))
+	})
+})
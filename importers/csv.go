@@ -0,0 +1,79 @@
+package importers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type CSVReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+	rowNum  int
+}
+
+// NewCSVReader reads the header line straight away so a bad mapping is reported before any rows are imported
+func NewCSVReader(r io.Reader, mapping Mapping) (*CSVReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("csv file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	headerIndexes := map[string]int{}
+	for i, column := range header {
+		headerIndexes[strings.TrimSpace(column)] = i
+	}
+
+	columns := map[string]int{}
+	for field, column := range mapping {
+		index, ok := headerIndexes[column]
+		if !ok {
+			return nil, fmt.Errorf("csv header has no %q column for coupon field %q", column, field)
+		}
+
+		columns[field] = index
+	}
+
+	return &CSVReader{
+		reader:  reader,
+		columns: columns,
+	}, nil
+}
+
+func (r *CSVReader) Next() (Row, error) {
+	record, err := r.reader.Read()
+	if err == io.EOF {
+		return Row{}, io.EOF
+	}
+
+	r.rowNum++
+	row := Row{Number: r.rowNum}
+
+	if parseErr, ok := err.(*csv.ParseError); ok {
+		row.Err = parseErr.Err
+		return row, nil
+	}
+	if err != nil {
+		return Row{}, err
+	}
+
+	fields := map[string]string{}
+	for field, index := range r.columns {
+		if index < len(record) {
+			fields[field] = record[index]
+		}
+	}
+
+	row.Coupon, row.Err = couponFromFields(fields)
+
+	return row, nil
+}
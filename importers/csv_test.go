@@ -0,0 +1,82 @@
+package importers_test
+
+import (
+	"github.com/madeleinesmith/coupons/importers"
+	"github.com/madeleinesmith/coupons/test_utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io"
+	"strings"
+)
+
+var _ = Describe("CSVReader", func() {
+	It("reads coupons using the mapped columns", func() {
+		csvFile := `Store,Title,Amount,Notes
+Tesco,Save £5 at Tesco,5,first
+Boots, Save £10 at Boots ,10,
+`
+		mapping, err := importers.ParseMapping("name:Title,brand:Store,value:Amount")
+		Expect(err).NotTo(HaveOccurred())
+
+		reader, err := importers.NewCSVReader(strings.NewReader(csvFile), mapping)
+		Expect(err).NotTo(HaveOccurred())
+
+		row, err := reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.Number).To(Equal(1))
+		Expect(row.Err).NotTo(HaveOccurred())
+		Expect(*row.Coupon.Name).To(Equal("Save £5 at Tesco"))
+		Expect(*row.Coupon.Brand).To(Equal("Tesco"))
+		Expect(*row.Coupon.Value).To(Equal(5))
+
+		row, err = reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.Number).To(Equal(2))
+		Expect(*row.Coupon.Value).To(Equal(10))
+
+		_, err = reader.Next()
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("rejects rows with a value that is not an integer without stopping", func() {
+		csvFile := "name,brand,value\nA,B,lots\nC,D,3\n"
+
+		reader, err := importers.NewCSVReader(strings.NewReader(csvFile), importers.DefaultMapping())
+		Expect(err).NotTo(HaveOccurred())
+
+		row, err := reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.Err).To(MatchError("value field must be an integer"))
+
+		row, err = reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.Err).NotTo(HaveOccurred())
+		Expect(*row.Coupon.Value).To(Equal(3))
+	})
+
+	It("leaves fields missing from short rows unset", func() {
+		reader, err := importers.NewCSVReader(strings.NewReader("name,brand,value\nA\n"), importers.DefaultMapping())
+		Expect(err).NotTo(HaveOccurred())
+
+		row, err := reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(*row.Coupon.Name).To(Equal("A"))
+		Expect(row.Coupon.Brand).To(BeNil())
+		Expect(row.Coupon.Value).To(BeNil())
+	})
+
+	It("errors if a mapped column is missing from the header", func() {
+		_, err := importers.NewCSVReader(strings.NewReader("name,brand\n"), importers.DefaultMapping())
+		Expect(err).To(MatchError(`csv header has no "value" column for coupon field "value"`))
+	})
+
+	It("errors if the file is empty", func() {
+		_, err := importers.NewCSVReader(strings.NewReader(""), importers.DefaultMapping())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("propagates the error if reading fails", func() {
+		_, err := importers.NewCSVReader(test_utils.DummyReader{Message: "disk on fire 🔥"}, importers.DefaultMapping())
+		Expect(err).To(MatchError("disk on fire 🔥"))
+	})
+})
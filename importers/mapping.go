@@ -0,0 +1,50 @@
+package importers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mapping maps a coupon field (name, brand, value) to the column or key it is read from
+type Mapping map[string]string
+
+var couponFields = []string{"name", "brand", "value"}
+
+func DefaultMapping() Mapping {
+	return Mapping{
+		"name":  "name",
+		"brand": "brand",
+		"value": "value",
+	}
+}
+
+// ParseMapping parses "name:Title,brand:Store" into a Mapping; fields that are left out keep their default column
+func ParseMapping(mappingString string) (Mapping, error) {
+	mapping := DefaultMapping()
+
+	if strings.TrimSpace(mappingString) == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(mappingString, ",") {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid mapping %q, expected field:column", pair)
+		}
+
+		field := strings.TrimSpace(parts[0])
+		column := strings.TrimSpace(parts[1])
+
+		if _, ok := mapping[field]; !ok {
+			return nil, fmt.Errorf("unknown coupon field %q in mapping", field)
+		}
+
+		if column == "" {
+			return nil, fmt.Errorf("missing column for coupon field %q in mapping", field)
+		}
+
+		mapping[field] = column
+	}
+
+	return mapping, nil
+}
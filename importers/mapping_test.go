@@ -0,0 +1,35 @@
+package importers_test
+
+import (
+	"github.com/madeleinesmith/coupons/importers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mapping", func() {
+	It("defaults every field to a column of the same name", func() {
+		mapping, err := importers.ParseMapping("")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mapping).To(Equal(importers.DefaultMapping()))
+	})
+
+	It("overrides the columns that are given", func() {
+		mapping, err := importers.ParseMapping("name: Coupon Title ,value:Amount")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mapping).To(Equal(importers.Mapping{
+			"name":  "Coupon Title",
+			"brand": "brand",
+			"value": "Amount",
+		}))
+	})
+
+	It("errors on an unknown field", func() {
+		_, err := importers.ParseMapping("colour:Colour")
+		Expect(err).To(MatchError(`unknown coupon field "colour" in mapping`))
+	})
+
+	It("errors on a malformed pair", func() {
+		_, err := importers.ParseMapping("name")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,112 @@
+package importers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const maxNDJSONLineBytes = 1024 * 1024
+
+type NDJSONReader struct {
+	reader  *bufio.Reader
+	mapping Mapping
+	rowNum  int
+}
+
+func NewNDJSONReader(r io.Reader, mapping Mapping) *NDJSONReader {
+	return &NDJSONReader{
+		reader:  bufio.NewReaderSize(r, 64*1024),
+		mapping: mapping,
+	}
+}
+
+func (r *NDJSONReader) Next() (Row, error) {
+	for {
+		line, tooLong, err := r.readLine()
+		if err != nil && err != io.EOF {
+			return Row{}, err
+		}
+
+		if tooLong {
+			r.rowNum++
+			return Row{Number: r.rowNum, Err: fmt.Errorf("line is over %d bytes", maxNDJSONLineBytes)}, nil
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			if err == io.EOF {
+				return Row{}, io.EOF
+			}
+
+			continue
+		}
+
+		r.rowNum++
+		return r.parse(line), nil
+	}
+}
+
+// readLine reads up to the next newline. Lines over maxNDJSONLineBytes are skipped past rather than held in memory,
+// and reported as tooLong, so the rest of the file can still be read.
+func (r *NDJSONReader) readLine() ([]byte, bool, error) {
+	var line []byte
+	tooLong := false
+
+	for {
+		chunk, err := r.reader.ReadSlice('\n')
+		if !tooLong && len(line)+len(chunk) > maxNDJSONLineBytes {
+			tooLong, line = true, nil
+		}
+		if !tooLong {
+			line = append(line, chunk...)
+		}
+
+		if err != bufio.ErrBufferFull {
+			return line, tooLong, err
+		}
+	}
+}
+
+func (r *NDJSONReader) parse(line []byte) Row {
+	row := Row{Number: r.rowNum}
+
+	// numbers are kept as they were written, so large whole numbers aren't turned into floats like 1e+06
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+
+	var object map[string]interface{}
+	err := decoder.Decode(&object)
+	if err == nil && decoder.More() {
+		err = errors.New("unexpected data after the object")
+	}
+	if err != nil {
+		row.Err = fmt.Errorf("invalid JSON: %s", err.Error())
+		return row
+	}
+
+	fields := map[string]string{}
+	for field, key := range r.mapping {
+		value, ok := object[key]
+		if !ok || value == nil {
+			continue
+		}
+
+		switch typedValue := value.(type) {
+		case string:
+			fields[field] = typedValue
+		case json.Number:
+			fields[field] = typedValue.String()
+		default:
+			row.Err = fmt.Errorf("%s field must be a string or a number", field)
+			return row
+		}
+	}
+
+	row.Coupon, row.Err = couponFromFields(fields)
+
+	return row
+}
@@ -0,0 +1,101 @@
+package importers_test
+
+import (
+	"github.com/madeleinesmith/coupons/importers"
+	"github.com/madeleinesmith/coupons/test_utils"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"io"
+	"strings"
+)
+
+var _ = Describe("NDJSONReader", func() {
+	It("reads one coupon per line, skipping blank lines", func() {
+		ndjsonFile := `{"title": "Save £5 at Tesco", "brand": "Tesco", "value": 5}
+
+{"title": "Save £10 at Boots", "brand": "Boots", "value": "10"}
+`
+		mapping, err := importers.ParseMapping("name:title")
+		Expect(err).NotTo(HaveOccurred())
+
+		reader := importers.NewNDJSONReader(strings.NewReader(ndjsonFile), mapping)
+
+		row, err := reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.Number).To(Equal(1))
+		Expect(*row.Coupon.Name).To(Equal("Save £5 at Tesco"))
+		Expect(*row.Coupon.Value).To(Equal(5))
+
+		row, err = reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.Number).To(Equal(2))
+		Expect(*row.Coupon.Brand).To(Equal("Boots"))
+		Expect(*row.Coupon.Value).To(Equal(10))
+
+		_, err = reader.Next()
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("rejects lines that are not valid JSON without stopping", func() {
+		reader := importers.NewNDJSONReader(strings.NewReader("{oops\n{\"name\": \"A\"}\n"), importers.DefaultMapping())
+
+		row, err := reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.Err).To(MatchError(ContainSubstring("invalid JSON")))
+
+		row, err = reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.Err).NotTo(HaveOccurred())
+		Expect(*row.Coupon.Name).To(Equal("A"))
+	})
+
+	It("rejects values of the wrong type", func() {
+		reader := importers.NewNDJSONReader(strings.NewReader(`{"name": ["A"]}`), importers.DefaultMapping())
+
+		row, err := reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.Err).To(MatchError("name field must be a string or a number"))
+	})
+
+	It("rejects fractional values", func() {
+		reader := importers.NewNDJSONReader(strings.NewReader(`{"value": 2.5}`), importers.DefaultMapping())
+
+		row, err := reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.Err).To(MatchError("value field must be an integer"))
+	})
+
+	It("keeps large whole numbers as they were written", func() {
+		reader := importers.NewNDJSONReader(strings.NewReader(`{"value": 1000000}`), importers.DefaultMapping())
+
+		row, err := reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.Err).NotTo(HaveOccurred())
+		Expect(*row.Coupon.Value).To(Equal(1000000))
+	})
+
+	It("rejects lines that are too long without stopping", func() {
+		ndjsonFile := `{"name": "` + strings.Repeat("a", 2*1024*1024) + `"}` + "\n" + `{"name": "A"}`
+		reader := importers.NewNDJSONReader(strings.NewReader(ndjsonFile), importers.DefaultMapping())
+
+		row, err := reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.Number).To(Equal(1))
+		Expect(row.Err).To(MatchError(ContainSubstring("line is over")))
+
+		row, err = reader.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(row.Number).To(Equal(2))
+		Expect(*row.Coupon.Name).To(Equal("A"))
+
+		_, err = reader.Next()
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("propagates the error if reading fails", func() {
+		reader := importers.NewNDJSONReader(test_utils.DummyReader{Message: "connection reset"}, importers.DefaultMapping())
+
+		_, err := reader.Next()
+		Expect(err).To(MatchError("connection reset"))
+	})
+})
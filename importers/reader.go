@@ -0,0 +1,56 @@
+package importers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type Row struct {
+	Number int
+	Coupon coupon.Coupon
+	// Err is set when the row itself could not be parsed; the rest of the file can still be read
+	Err error
+}
+
+// RowReader returns io.EOF once there are no more rows. Any other error means the file can't be read any further.
+type RowReader interface {
+	Next() (Row, error)
+}
+
+func NewRowReader(format string, r io.Reader, mapping Mapping) (RowReader, error) {
+	switch format {
+	case "csv":
+		return NewCSVReader(r, mapping)
+	case "ndjson":
+		return NewNDJSONReader(r, mapping), nil
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", format)
+	}
+}
+
+func couponFromFields(fields map[string]string) (coupon.Coupon, error) {
+	var couponInstance coupon.Coupon
+
+	if name, ok := fields["name"]; ok {
+		couponInstance.Name = &name
+	}
+
+	if brand, ok := fields["brand"]; ok {
+		couponInstance.Brand = &brand
+	}
+
+	if stringValue, ok := fields["value"]; ok && strings.TrimSpace(stringValue) != "" {
+		value, err := strconv.Atoi(strings.TrimSpace(stringValue))
+		if err != nil {
+			return coupon.Coupon{}, errors.New("value field must be an integer")
+		}
+
+		couponInstance.Value = &value
+	}
+
+	return couponInstance, nil
+}
@@ -0,0 +1,244 @@
+package lockout
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"github.com/madeleinesmith/coupons/response"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	ReasonTooManyFailures = "too-many-failures"
+	ReasonSequentialGuess = "sequential-guesses"
+
+	// lockouts within this long of each other count towards making the next one longer
+	EscalationPeriod = 24 * time.Hour
+)
+
+// Policy decides when an actor looking up coupons that don't exist is guessing, and how long to lock them out for
+type Policy struct {
+	Window                time.Duration
+	MaxFailures           int
+	MaxSequentialFailures int
+	LockoutDuration       time.Duration
+	MaxLockoutDuration    time.Duration
+}
+
+var DefaultPolicy = Policy{
+	Window:                10 * time.Minute,
+	MaxFailures:           20,
+	MaxSequentialFailures: 5,
+	LockoutDuration:       time.Minute,
+	MaxLockoutDuration:    24 * time.Hour,
+}
+
+// Event is raised whenever an actor is locked out
+type Event struct {
+	Actor       string    `json:"actor"`
+	Reason      string    `json:"reason"`
+	Failures    int       `json:"failures"`
+	Lockouts    int       `json:"lockouts"`
+	LockedUntil time.Time `json:"lockedUntil"`
+}
+
+//go:generate counterfeiter . Store
+type Store interface {
+	RecordFailure(ctx context.Context, actor string, identifier string) error
+	// FailuresSince returns the identifiers actor failed to look up, oldest first
+	FailuresSince(ctx context.Context, actor string, since time.Time) ([]string, error)
+	Lock(ctx context.Context, actor string, until time.Time) error
+	// LockedUntil returns when actor's latest lockout ends, or the zero time if they've never been locked out
+	LockedUntil(ctx context.Context, actor string) (time.Time, error)
+	CountLockouts(ctx context.Context, actor string, since time.Time) (int, error)
+}
+
+//go:generate counterfeiter . Alerter
+type Alerter interface {
+	Alert(ctx context.Context, event Event) error
+}
+
+// LogAlerter writes events to the log as JSON, for log based alerting to pick up
+type LogAlerter struct{}
+
+func (LogAlerter) Alert(ctx context.Context, event Event) error {
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("lockout alert: %s", eventJSON)
+
+	return nil
+}
+
+type Guard struct {
+	Store   Store
+	Alerter Alerter
+	Policy  Policy
+	Now     func() time.Time
+}
+
+func (g Guard) now() time.Time {
+	if g.Now != nil {
+		return g.Now()
+	}
+
+	return time.Now()
+}
+
+// Middleware guards the named routes, counting every 404 as a failed lookup of the route's {couponId}. Actors are
+// told how long they're locked out for with a 429 and Retry-After.
+func (g Guard) Middleware(routeNames ...string) func(http.Handler) http.Handler {
+	guarded := map[string]bool{}
+	for _, routeName := range routeNames {
+		guarded[routeName] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			route := mux.CurrentRoute(req)
+			if route == nil || !guarded[route.GetName()] {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			actor := ActorKey(req.Context(), requestcontext.ClientIP(req))
+
+			lockedUntil, err := g.Store.LockedUntil(req.Context(), actor)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			if remaining := g.Remaining(lockedUntil); remaining > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(remaining.Seconds()))))
+				http.Error(w, "too many failed lookups, try again later", http.StatusTooManyRequests)
+				return
+			}
+
+			recorder := response.NewRecorder(w)
+			next.ServeHTTP(recorder, req)
+
+			if recorder.StatusCode != http.StatusNotFound {
+				return
+			}
+
+			err = g.RecordFailure(context.WithoutCancel(req.Context()), actor, mux.Vars(req)["couponId"], lockedUntil)
+			if err != nil {
+				log.Printf("recording a failed lookup by %s: %v", actor, err)
+			}
+		})
+	}
+}
+
+// Remaining is how much longer a lockout ending at lockedUntil, as the Store gave it, has to run
+func (g Guard) Remaining(lockedUntil time.Time) time.Duration {
+	return lockedUntil.Sub(g.now())
+}
+
+// RecordFailure locks the actor out if this failure takes them over the policy's limits, only counting failures
+// since their last lockout ended at lastLockedUntil. Each lockout within a day of the last one is twice as long, up
+// to the policy's maximum.
+func (g Guard) RecordFailure(ctx context.Context, actor string, identifier string, lastLockedUntil time.Time) error {
+	err := g.Store.RecordFailure(ctx, actor, identifier)
+	if err != nil {
+		return err
+	}
+
+	now := g.now()
+
+	since := now.Add(-g.Policy.Window)
+	if lastLockedUntil.After(since) {
+		since = lastLockedUntil
+	}
+
+	failures, err := g.Store.FailuresSince(ctx, actor, since)
+	if err != nil {
+		return err
+	}
+
+	reason := ""
+	if len(failures) >= g.Policy.MaxFailures {
+		reason = ReasonTooManyFailures
+	} else if sequentialFailures(failures) >= g.Policy.MaxSequentialFailures {
+		reason = ReasonSequentialGuess
+	}
+
+	if reason == "" {
+		return nil
+	}
+
+	previousLockouts, err := g.Store.CountLockouts(ctx, actor, now.Add(-EscalationPeriod))
+	if err != nil {
+		return err
+	}
+
+	duration := g.Policy.LockoutDuration * time.Duration(math.Pow(2, float64(previousLockouts)))
+	if duration > g.Policy.MaxLockoutDuration || duration <= 0 {
+		duration = g.Policy.MaxLockoutDuration
+	}
+
+	lockedUntil := now.Add(duration)
+
+	err = g.Store.Lock(ctx, actor, lockedUntil)
+	if err != nil {
+		return err
+	}
+
+	return g.Alerter.Alert(ctx, Event{
+		Actor:       actor,
+		Reason:      reason,
+		Failures:    len(failures),
+		Lockouts:    previousLockouts + 1,
+		LockedUntil: lockedUntil,
+	})
+}
+
+// sequentialFailures counts the longest run of failures where each identifier only differs from the one before in
+// its last few characters, as happens when someone steps through codes rather than mistyping one
+func sequentialFailures(identifiers []string) int {
+	longestRun := 0
+	run := 0
+
+	for i, identifier := range identifiers {
+		if i > 0 && adjacent(identifiers[i-1], identifier) {
+			run++
+		} else {
+			run = 1
+		}
+
+		if run > longestRun {
+			longestRun = run
+		}
+	}
+
+	return longestRun
+}
+
+func adjacent(previous string, identifier string) bool {
+	const suffixLength = 4
+
+	if len(previous) != len(identifier) || len(identifier) <= suffixLength || previous == identifier {
+		return false
+	}
+
+	prefixLength := len(identifier) - suffixLength
+
+	return previous[:prefixLength] == identifier[:prefixLength]
+}
+
+// ActorKey is who's looking coupons up: their API key or token if they have one, otherwise their IP
+func ActorKey(ctx context.Context, clientIP string) string {
+	if principal, ok := auth.PrincipalFrom(ctx); ok {
+		return "principal:" + principal.ID
+	}
+
+	return "ip:" + clientIP
+}
@@ -0,0 +1,238 @@
+package lockout_test
+
+import (
+	"context"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/dbservices"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/lockout"
+	"github.com/madeleinesmith/coupons/lockout/lockoutfakes"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("Guard", func() {
+	var (
+		fakeStore          *lockoutfakes.FakeStore
+		fakeAlerter        *lockoutfakes.FakeAlerter
+		now                time.Time
+		router             *mux.Router
+		recorder           *httptest.ResponseRecorder
+		request            *http.Request
+		nextHandlerInvoked bool
+		nextHandlerStatus  int
+	)
+
+	BeforeEach(func() {
+		var err error
+
+		fakeStore = &lockoutfakes.FakeStore{}
+		fakeAlerter = &lockoutfakes.FakeAlerter{}
+		now = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+		guard := lockout.Guard{
+			Store:   fakeStore,
+			Alerter: fakeAlerter,
+			Policy: lockout.Policy{
+				Window:                10 * time.Minute,
+				MaxFailures:           3,
+				MaxSequentialFailures: 2,
+				LockoutDuration:       time.Minute,
+				MaxLockoutDuration:    5 * time.Minute,
+			},
+			Now: func() time.Time { return now },
+		}
+
+		nextHandlerInvoked = false
+		nextHandlerStatus = http.StatusNotFound
+		nextHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			nextHandlerInvoked = true
+			w.WriteHeader(nextHandlerStatus)
+		})
+
+		router = mux.NewRouter()
+		router.NewRoute().Name("coupon").Path("/coupon/{couponId}").Handler(nextHandler)
+		router.NewRoute().Name("coupons").Path("/coupons").Handler(nextHandler)
+		router.Use(guard.Middleware("coupon"))
+
+		recorder = httptest.NewRecorder()
+		request, err = http.NewRequest(http.MethodGet, "/coupon/SAVE0001", nil)
+		Expect(err).NotTo(HaveOccurred())
+		request = request.WithContext(auth.WithPrincipal(request.Context(), &auth.Principal{ID: "key-1"}))
+	})
+
+	It("records failed lookups by the actor", func() {
+		fakeStore.FailuresSinceReturns([]string{"SAVE0001"}, nil)
+
+		router.ServeHTTP(recorder, request)
+
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		Expect(fakeStore.RecordFailureCallCount()).To(Equal(1))
+		_, actor, identifier := fakeStore.RecordFailureArgsForCall(0)
+		Expect(actor).To(Equal("principal:key-1"))
+		Expect(identifier).To(Equal("SAVE0001"))
+
+		_, _, since := fakeStore.FailuresSinceArgsForCall(0)
+		Expect(since).To(Equal(now.Add(-10 * time.Minute)))
+
+		Expect(fakeStore.LockCallCount()).To(Equal(0))
+	})
+
+	It("only counts failures since the actor's last lockout ended", func() {
+		fakeStore.LockedUntilReturns(now.Add(-time.Minute), nil)
+
+		router.ServeHTTP(recorder, request)
+
+		_, _, since := fakeStore.FailuresSinceArgsForCall(0)
+		Expect(since).To(Equal(now.Add(-time.Minute)))
+	})
+
+	It("doesn't count successful lookups, or unguarded routes", func() {
+		nextHandlerStatus = http.StatusOK
+		router.ServeHTTP(recorder, request)
+
+		nextHandlerStatus = http.StatusNotFound
+		request.URL.Path = "/coupons"
+		router.ServeHTTP(httptest.NewRecorder(), request)
+
+		Expect(fakeStore.RecordFailureCallCount()).To(Equal(0))
+	})
+
+	It("locks out actors with too many failures in the window, and raises an alert", func() {
+		fakeStore.FailuresSinceReturns([]string{"a-coupon", "SAVE0001", "another-one"}, nil)
+
+		router.ServeHTTP(recorder, request)
+
+		Expect(fakeStore.LockCallCount()).To(Equal(1))
+		_, actor, until := fakeStore.LockArgsForCall(0)
+		Expect(actor).To(Equal("principal:key-1"))
+		Expect(until).To(Equal(now.Add(time.Minute)))
+
+		Expect(fakeAlerter.AlertCallCount()).To(Equal(1))
+		_, event := fakeAlerter.AlertArgsForCall(0)
+		Expect(event).To(Equal(lockout.Event{
+			Actor:       "principal:key-1",
+			Reason:      lockout.ReasonTooManyFailures,
+			Failures:    3,
+			Lockouts:    1,
+			LockedUntil: now.Add(time.Minute),
+		}))
+	})
+
+	It("locks out actors stepping through codes sooner", func() {
+		fakeStore.FailuresSinceReturns([]string{"SAVE0001", "SAVE0002"}, nil)
+
+		router.ServeHTTP(recorder, request)
+
+		Expect(fakeStore.LockCallCount()).To(Equal(1))
+		_, event := fakeAlerter.AlertArgsForCall(0)
+		Expect(event.Reason).To(Equal(lockout.ReasonSequentialGuess))
+	})
+
+	It("locks out actors guessing codes that can't be coupon ids, as the service treats them as missing", func() {
+		var failures []string
+		fakeStore.RecordFailureStub = func(ctx context.Context, actor string, identifier string) error {
+			failures = append(failures, identifier)
+			return nil
+		}
+		fakeStore.FailuresSinceStub = func(ctx context.Context, actor string, since time.Time) ([]string, error) {
+			return failures, nil
+		}
+		fakeStore.LockedUntilStub = func(ctx context.Context, actor string) (time.Time, error) {
+			if fakeStore.LockCallCount() == 0 {
+				return time.Time{}, nil
+			}
+
+			_, _, until := fakeStore.LockArgsForCall(fakeStore.LockCallCount() - 1)
+			return until, nil
+		}
+
+		db, dbMock, err := sqlmock.New()
+		Expect(err).NotTo(HaveOccurred())
+
+		router.Get("coupon").Handler(handlers.CouponDetailsHandler{
+			CouponService: dbservices.CouponService{DB: db},
+			Serializer:    coupon.Serializer{},
+		})
+
+		codes := []string{"SAVE0001", "SAVE0002", "SAVE0003"}
+		statuses := make([]int, len(codes))
+		for i, code := range codes {
+			guess, err := http.NewRequest(http.MethodGet, "/coupon/"+code, nil)
+			Expect(err).NotTo(HaveOccurred())
+			guess = guess.WithContext(auth.WithPrincipal(guess.Context(), &auth.Principal{ID: "key-1", Scopes: []string{auth.ScopeCouponsRead}}))
+
+			recorder = httptest.NewRecorder()
+			router.ServeHTTP(recorder, guess)
+			statuses[i] = recorder.Code
+		}
+
+		Expect(statuses).To(Equal([]int{http.StatusNotFound, http.StatusNotFound, http.StatusTooManyRequests}))
+		Expect(failures).To(Equal([]string{"SAVE0001", "SAVE0002"}))
+		Expect(dbMock.ExpectationsWereMet()).To(Succeed())
+	})
+
+	It("doesn't mistake unrelated codes for a sequence", func() {
+		fakeStore.FailuresSinceReturns([]string{"SAVE0001", "WINTER01"}, nil)
+
+		router.ServeHTTP(recorder, request)
+
+		Expect(fakeStore.LockCallCount()).To(Equal(0))
+	})
+
+	It("doubles the lockout each time, up to the maximum", func() {
+		fakeStore.FailuresSinceReturns([]string{"a", "b", "c"}, nil)
+
+		fakeStore.CountLockoutsReturns(2, nil)
+		router.ServeHTTP(recorder, request)
+		_, _, until := fakeStore.LockArgsForCall(0)
+		Expect(until).To(Equal(now.Add(4 * time.Minute)))
+
+		_, _, since := fakeStore.CountLockoutsArgsForCall(0)
+		Expect(since).To(Equal(now.Add(-lockout.EscalationPeriod)))
+
+		fakeStore.CountLockoutsReturns(3, nil)
+		router.ServeHTTP(httptest.NewRecorder(), request)
+		_, _, until = fakeStore.LockArgsForCall(1)
+		Expect(until).To(Equal(now.Add(5 * time.Minute)))
+	})
+
+	It("refuses locked out actors with a 429", func() {
+		fakeStore.LockedUntilReturns(now.Add(90*time.Second), nil)
+
+		router.ServeHTTP(recorder, request)
+
+		Expect(nextHandlerInvoked).To(BeFalse())
+		Expect(recorder.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(recorder.Header().Get("Retry-After")).To(Equal("90"))
+		_, actor := fakeStore.LockedUntilArgsForCall(0)
+		Expect(actor).To(Equal("principal:key-1"))
+	})
+
+	It("tells unauthenticated actors apart by IP", func() {
+		unauthenticatedRequest, err := http.NewRequest(http.MethodGet, "/coupon/SAVE0001", nil)
+		Expect(err).NotTo(HaveOccurred())
+		unauthenticatedRequest.RemoteAddr = "203.0.113.7:51234"
+
+		router.ServeHTTP(recorder, unauthenticatedRequest)
+
+		_, actor := fakeStore.LockedUntilArgsForCall(0)
+		Expect(actor).To(Equal("ip:203.0.113.7"))
+	})
+
+	It("returns 500 if it can't tell whether the actor is locked out", func() {
+		fakeStore.LockedUntilReturns(time.Time{}, errors.New("connection refused"))
+
+		router.ServeHTTP(recorder, request)
+
+		Expect(nextHandlerInvoked).To(BeFalse())
+		Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+	})
+})
@@ -0,0 +1,113 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package lockoutfakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/madeleinesmith/coupons/lockout"
+)
+
+type FakeAlerter struct {
+	AlertStub        func(context.Context, lockout.Event) error
+	alertMutex       sync.RWMutex
+	alertArgsForCall []struct {
+		arg1 context.Context
+		arg2 lockout.Event
+	}
+	alertReturns struct {
+		result1 error
+	}
+	alertReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeAlerter) Alert(arg1 context.Context, arg2 lockout.Event) error {
+	fake.alertMutex.Lock()
+	ret, specificReturn := fake.alertReturnsOnCall[len(fake.alertArgsForCall)]
+	fake.alertArgsForCall = append(fake.alertArgsForCall, struct {
+		arg1 context.Context
+		arg2 lockout.Event
+	}{arg1, arg2})
+	fake.recordInvocation("Alert", []interface{}{arg1, arg2})
+	fake.alertMutex.Unlock()
+	if fake.AlertStub != nil {
+		return fake.AlertStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.alertReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeAlerter) AlertCallCount() int {
+	fake.alertMutex.RLock()
+	defer fake.alertMutex.RUnlock()
+	return len(fake.alertArgsForCall)
+}
+
+func (fake *FakeAlerter) AlertCalls(stub func(context.Context, lockout.Event) error) {
+	fake.alertMutex.Lock()
+	defer fake.alertMutex.Unlock()
+	fake.AlertStub = stub
+}
+
+func (fake *FakeAlerter) AlertArgsForCall(i int) (context.Context, lockout.Event) {
+	fake.alertMutex.RLock()
+	defer fake.alertMutex.RUnlock()
+	argsForCall := fake.alertArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeAlerter) AlertReturns(result1 error) {
+	fake.alertMutex.Lock()
+	defer fake.alertMutex.Unlock()
+	fake.AlertStub = nil
+	fake.alertReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAlerter) AlertReturnsOnCall(i int, result1 error) {
+	fake.alertMutex.Lock()
+	defer fake.alertMutex.Unlock()
+	fake.AlertStub = nil
+	if fake.alertReturnsOnCall == nil {
+		fake.alertReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.alertReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeAlerter) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.alertMutex.RLock()
+	defer fake.alertMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeAlerter) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ lockout.Alerter = new(FakeAlerter)
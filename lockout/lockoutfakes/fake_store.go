@@ -0,0 +1,437 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package lockoutfakes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/madeleinesmith/coupons/lockout"
+)
+
+type FakeStore struct {
+	CountLockoutsStub        func(context.Context, string, time.Time) (int, error)
+	countLockoutsMutex       sync.RWMutex
+	countLockoutsArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 time.Time
+	}
+	countLockoutsReturns struct {
+		result1 int
+		result2 error
+	}
+	countLockoutsReturnsOnCall map[int]struct {
+		result1 int
+		result2 error
+	}
+	FailuresSinceStub        func(context.Context, string, time.Time) ([]string, error)
+	failuresSinceMutex       sync.RWMutex
+	failuresSinceArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 time.Time
+	}
+	failuresSinceReturns struct {
+		result1 []string
+		result2 error
+	}
+	failuresSinceReturnsOnCall map[int]struct {
+		result1 []string
+		result2 error
+	}
+	LockStub        func(context.Context, string, time.Time) error
+	lockMutex       sync.RWMutex
+	lockArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 time.Time
+	}
+	lockReturns struct {
+		result1 error
+	}
+	lockReturnsOnCall map[int]struct {
+		result1 error
+	}
+	LockedUntilStub        func(context.Context, string) (time.Time, error)
+	lockedUntilMutex       sync.RWMutex
+	lockedUntilArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+	}
+	lockedUntilReturns struct {
+		result1 time.Time
+		result2 error
+	}
+	lockedUntilReturnsOnCall map[int]struct {
+		result1 time.Time
+		result2 error
+	}
+	RecordFailureStub        func(context.Context, string, string) error
+	recordFailureMutex       sync.RWMutex
+	recordFailureArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+	}
+	recordFailureReturns struct {
+		result1 error
+	}
+	recordFailureReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeStore) CountLockouts(arg1 context.Context, arg2 string, arg3 time.Time) (int, error) {
+	fake.countLockoutsMutex.Lock()
+	ret, specificReturn := fake.countLockoutsReturnsOnCall[len(fake.countLockoutsArgsForCall)]
+	fake.countLockoutsArgsForCall = append(fake.countLockoutsArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 time.Time
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("CountLockouts", []interface{}{arg1, arg2, arg3})
+	fake.countLockoutsMutex.Unlock()
+	if fake.CountLockoutsStub != nil {
+		return fake.CountLockoutsStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.countLockoutsReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeStore) CountLockoutsCallCount() int {
+	fake.countLockoutsMutex.RLock()
+	defer fake.countLockoutsMutex.RUnlock()
+	return len(fake.countLockoutsArgsForCall)
+}
+
+func (fake *FakeStore) CountLockoutsCalls(stub func(context.Context, string, time.Time) (int, error)) {
+	fake.countLockoutsMutex.Lock()
+	defer fake.countLockoutsMutex.Unlock()
+	fake.CountLockoutsStub = stub
+}
+
+func (fake *FakeStore) CountLockoutsArgsForCall(i int) (context.Context, string, time.Time) {
+	fake.countLockoutsMutex.RLock()
+	defer fake.countLockoutsMutex.RUnlock()
+	argsForCall := fake.countLockoutsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeStore) CountLockoutsReturns(result1 int, result2 error) {
+	fake.countLockoutsMutex.Lock()
+	defer fake.countLockoutsMutex.Unlock()
+	fake.CountLockoutsStub = nil
+	fake.countLockoutsReturns = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStore) CountLockoutsReturnsOnCall(i int, result1 int, result2 error) {
+	fake.countLockoutsMutex.Lock()
+	defer fake.countLockoutsMutex.Unlock()
+	fake.CountLockoutsStub = nil
+	if fake.countLockoutsReturnsOnCall == nil {
+		fake.countLockoutsReturnsOnCall = make(map[int]struct {
+			result1 int
+			result2 error
+		})
+	}
+	fake.countLockoutsReturnsOnCall[i] = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStore) FailuresSince(arg1 context.Context, arg2 string, arg3 time.Time) ([]string, error) {
+	fake.failuresSinceMutex.Lock()
+	ret, specificReturn := fake.failuresSinceReturnsOnCall[len(fake.failuresSinceArgsForCall)]
+	fake.failuresSinceArgsForCall = append(fake.failuresSinceArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 time.Time
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("FailuresSince", []interface{}{arg1, arg2, arg3})
+	fake.failuresSinceMutex.Unlock()
+	if fake.FailuresSinceStub != nil {
+		return fake.FailuresSinceStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.failuresSinceReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeStore) FailuresSinceCallCount() int {
+	fake.failuresSinceMutex.RLock()
+	defer fake.failuresSinceMutex.RUnlock()
+	return len(fake.failuresSinceArgsForCall)
+}
+
+func (fake *FakeStore) FailuresSinceCalls(stub func(context.Context, string, time.Time) ([]string, error)) {
+	fake.failuresSinceMutex.Lock()
+	defer fake.failuresSinceMutex.Unlock()
+	fake.FailuresSinceStub = stub
+}
+
+func (fake *FakeStore) FailuresSinceArgsForCall(i int) (context.Context, string, time.Time) {
+	fake.failuresSinceMutex.RLock()
+	defer fake.failuresSinceMutex.RUnlock()
+	argsForCall := fake.failuresSinceArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeStore) FailuresSinceReturns(result1 []string, result2 error) {
+	fake.failuresSinceMutex.Lock()
+	defer fake.failuresSinceMutex.Unlock()
+	fake.FailuresSinceStub = nil
+	fake.failuresSinceReturns = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStore) FailuresSinceReturnsOnCall(i int, result1 []string, result2 error) {
+	fake.failuresSinceMutex.Lock()
+	defer fake.failuresSinceMutex.Unlock()
+	fake.FailuresSinceStub = nil
+	if fake.failuresSinceReturnsOnCall == nil {
+		fake.failuresSinceReturnsOnCall = make(map[int]struct {
+			result1 []string
+			result2 error
+		})
+	}
+	fake.failuresSinceReturnsOnCall[i] = struct {
+		result1 []string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStore) Lock(arg1 context.Context, arg2 string, arg3 time.Time) error {
+	fake.lockMutex.Lock()
+	ret, specificReturn := fake.lockReturnsOnCall[len(fake.lockArgsForCall)]
+	fake.lockArgsForCall = append(fake.lockArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 time.Time
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("Lock", []interface{}{arg1, arg2, arg3})
+	fake.lockMutex.Unlock()
+	if fake.LockStub != nil {
+		return fake.LockStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.lockReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeStore) LockCallCount() int {
+	fake.lockMutex.RLock()
+	defer fake.lockMutex.RUnlock()
+	return len(fake.lockArgsForCall)
+}
+
+func (fake *FakeStore) LockCalls(stub func(context.Context, string, time.Time) error) {
+	fake.lockMutex.Lock()
+	defer fake.lockMutex.Unlock()
+	fake.LockStub = stub
+}
+
+func (fake *FakeStore) LockArgsForCall(i int) (context.Context, string, time.Time) {
+	fake.lockMutex.RLock()
+	defer fake.lockMutex.RUnlock()
+	argsForCall := fake.lockArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeStore) LockReturns(result1 error) {
+	fake.lockMutex.Lock()
+	defer fake.lockMutex.Unlock()
+	fake.LockStub = nil
+	fake.lockReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStore) LockReturnsOnCall(i int, result1 error) {
+	fake.lockMutex.Lock()
+	defer fake.lockMutex.Unlock()
+	fake.LockStub = nil
+	if fake.lockReturnsOnCall == nil {
+		fake.lockReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.lockReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStore) LockedUntil(arg1 context.Context, arg2 string) (time.Time, error) {
+	fake.lockedUntilMutex.Lock()
+	ret, specificReturn := fake.lockedUntilReturnsOnCall[len(fake.lockedUntilArgsForCall)]
+	fake.lockedUntilArgsForCall = append(fake.lockedUntilArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("LockedUntil", []interface{}{arg1, arg2})
+	fake.lockedUntilMutex.Unlock()
+	if fake.LockedUntilStub != nil {
+		return fake.LockedUntilStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.lockedUntilReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeStore) LockedUntilCallCount() int {
+	fake.lockedUntilMutex.RLock()
+	defer fake.lockedUntilMutex.RUnlock()
+	return len(fake.lockedUntilArgsForCall)
+}
+
+func (fake *FakeStore) LockedUntilCalls(stub func(context.Context, string) (time.Time, error)) {
+	fake.lockedUntilMutex.Lock()
+	defer fake.lockedUntilMutex.Unlock()
+	fake.LockedUntilStub = stub
+}
+
+func (fake *FakeStore) LockedUntilArgsForCall(i int) (context.Context, string) {
+	fake.lockedUntilMutex.RLock()
+	defer fake.lockedUntilMutex.RUnlock()
+	argsForCall := fake.lockedUntilArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeStore) LockedUntilReturns(result1 time.Time, result2 error) {
+	fake.lockedUntilMutex.Lock()
+	defer fake.lockedUntilMutex.Unlock()
+	fake.LockedUntilStub = nil
+	fake.lockedUntilReturns = struct {
+		result1 time.Time
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStore) LockedUntilReturnsOnCall(i int, result1 time.Time, result2 error) {
+	fake.lockedUntilMutex.Lock()
+	defer fake.lockedUntilMutex.Unlock()
+	fake.LockedUntilStub = nil
+	if fake.lockedUntilReturnsOnCall == nil {
+		fake.lockedUntilReturnsOnCall = make(map[int]struct {
+			result1 time.Time
+			result2 error
+		})
+	}
+	fake.lockedUntilReturnsOnCall[i] = struct {
+		result1 time.Time
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeStore) RecordFailure(arg1 context.Context, arg2 string, arg3 string) error {
+	fake.recordFailureMutex.Lock()
+	ret, specificReturn := fake.recordFailureReturnsOnCall[len(fake.recordFailureArgsForCall)]
+	fake.recordFailureArgsForCall = append(fake.recordFailureArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("RecordFailure", []interface{}{arg1, arg2, arg3})
+	fake.recordFailureMutex.Unlock()
+	if fake.RecordFailureStub != nil {
+		return fake.RecordFailureStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.recordFailureReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeStore) RecordFailureCallCount() int {
+	fake.recordFailureMutex.RLock()
+	defer fake.recordFailureMutex.RUnlock()
+	return len(fake.recordFailureArgsForCall)
+}
+
+func (fake *FakeStore) RecordFailureCalls(stub func(context.Context, string, string) error) {
+	fake.recordFailureMutex.Lock()
+	defer fake.recordFailureMutex.Unlock()
+	fake.RecordFailureStub = stub
+}
+
+func (fake *FakeStore) RecordFailureArgsForCall(i int) (context.Context, string, string) {
+	fake.recordFailureMutex.RLock()
+	defer fake.recordFailureMutex.RUnlock()
+	argsForCall := fake.recordFailureArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeStore) RecordFailureReturns(result1 error) {
+	fake.recordFailureMutex.Lock()
+	defer fake.recordFailureMutex.Unlock()
+	fake.RecordFailureStub = nil
+	fake.recordFailureReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStore) RecordFailureReturnsOnCall(i int, result1 error) {
+	fake.recordFailureMutex.Lock()
+	defer fake.recordFailureMutex.Unlock()
+	fake.RecordFailureStub = nil
+	if fake.recordFailureReturnsOnCall == nil {
+		fake.recordFailureReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.recordFailureReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeStore) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.countLockoutsMutex.RLock()
+	defer fake.countLockoutsMutex.RUnlock()
+	fake.failuresSinceMutex.RLock()
+	defer fake.failuresSinceMutex.RUnlock()
+	fake.lockMutex.RLock()
+	defer fake.lockMutex.RUnlock()
+	fake.lockedUntilMutex.RLock()
+	defer fake.lockedUntilMutex.RUnlock()
+	fake.recordFailureMutex.RLock()
+	defer fake.recordFailureMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeStore) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ lockout.Store = new(FakeStore)
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"github.com/madeleinesmith/coupons/response"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type accessLogEntry struct {
+	principal string
+	tenant    string
+}
+
+// AccessLog logs a line for every request once it's been handled. It goes after requestcontext.Middleware so the
+// request ID is known, and ahead of everything else so requests turned away by authentication or rate limits are
+// logged too.
+func AccessLog(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx, done := StartAccess(req.Context(), logger)
+
+			recorder := response.NewRecorder(w)
+			next.ServeHTTP(recorder, req.WithContext(ctx))
+
+			route := ""
+			if currentRoute := mux.CurrentRoute(req); currentRoute != nil {
+				route, _ = currentRoute.GetPathTemplate()
+			}
+
+			done(
+				slog.String("method", req.Method),
+				slog.String("route", route),
+				slog.Int("status", recorder.StatusCode),
+				slog.Int64("bytes", recorder.BytesWritten),
+			)
+		})
+	}
+}
+
+// StartAccess is AccessLog for calls that don't come through the router, such as gRPC ones. done logs the line once
+// the call has been handled with ctx, with attrs describing it.
+func StartAccess(ctx context.Context, logger *slog.Logger) (context.Context, func(attrs ...slog.Attr)) {
+	start := time.Now()
+
+	requestLogger := logger.With(slog.String("request_id", requestcontext.RequestID(ctx)))
+	entry := &accessLogEntry{principal: requestcontext.AnonymousActor}
+	ctx = context.WithValue(WithLogger(ctx, requestLogger), accessLogKey, entry)
+
+	done := func(attrs ...slog.Attr) {
+		attrs = append(attrs,
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("principal", entry.principal),
+			slog.String("tenant", entry.tenant),
+		)
+
+		requestLogger.LogAttrs(ctx, slog.LevelInfo, "request", attrs...)
+	}
+
+	return ctx, done
+}
+
+// Principal notes who authentication decided the caller was, for the access log and anything the handlers log. It
+// goes after the authentication middleware, as that puts the caller on a context AccessLog never sees.
+func Principal(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(w, req.WithContext(WithPrincipal(req.Context())))
+	})
+}
+
+// WithPrincipal is Principal for calls that don't come through the router
+func WithPrincipal(ctx context.Context) context.Context {
+	if entry, ok := ctx.Value(accessLogKey).(*accessLogEntry); ok {
+		entry.principal = requestcontext.Actor(ctx)
+		entry.tenant = requestcontext.Tenant(ctx)
+	}
+
+	logger := FromContext(ctx).With(slog.String("principal", requestcontext.Actor(ctx)))
+	return WithLogger(ctx, logger)
+}
@@ -0,0 +1,97 @@
+package logging_test
+
+import (
+	"bytes"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/logging"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Access log", func() {
+	var (
+		output *bytes.Buffer
+		router *mux.Router
+		actor  string
+	)
+
+	// authenticate stands in for the auth middleware, which puts the caller on a new request context
+	authenticate := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if actor == "" {
+				http.Error(w, "X-API-Key is required", http.StatusUnauthorized)
+				return
+			}
+
+			ctx := requestcontext.WithActor(req.Context(), actor)
+			next.ServeHTTP(w, req.WithContext(requestcontext.WithTenant(ctx, "boots")))
+		})
+	}
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+		actor = "api-key:checkout"
+
+		router = mux.NewRouter()
+		router.NewRoute().Name("coupon").Path("/coupon/{couponId}").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			logging.FromContext(req.Context()).Warn("from the handler")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":123}`))
+		})
+		router.Use(requestcontext.Middleware)
+		router.Use(logging.AccessLog(logging.New(output, slog.LevelInfo)))
+		router.Use(authenticate)
+		router.Use(logging.Principal)
+	})
+
+	serve := func() {
+		request, err := http.NewRequest(http.MethodGet, "/coupon/123", nil)
+		Expect(err).NotTo(HaveOccurred())
+		request.Header.Set("X-Request-ID", "req-123")
+
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	It("logs each request's method, route template, status, latency, bytes, request ID and principal", func() {
+		serve()
+
+		lines := logLines(output)
+		Expect(lines).To(HaveLen(2))
+
+		accessLine := lines[1]
+		Expect(accessLine).To(HaveKeyWithValue("msg", "request"))
+		Expect(accessLine).To(HaveKeyWithValue("method", "GET"))
+		Expect(accessLine).To(HaveKeyWithValue("route", "/coupon/{couponId}"))
+		Expect(accessLine).To(HaveKeyWithValue("status", BeEquivalentTo(201)))
+		Expect(accessLine).To(HaveKeyWithValue("latency_ms", BeNumerically(">=", 0)))
+		Expect(accessLine).To(HaveKeyWithValue("bytes", BeEquivalentTo(10)))
+		Expect(accessLine).To(HaveKeyWithValue("request_id", "req-123"))
+		Expect(accessLine).To(HaveKeyWithValue("principal", "api-key:checkout"))
+		Expect(accessLine).To(HaveKeyWithValue("tenant", "boots"))
+	})
+
+	It("gives handlers a logger with the request ID and principal", func() {
+		serve()
+
+		handlerLine := logLines(output)[0]
+		Expect(handlerLine).To(HaveKeyWithValue("msg", "from the handler"))
+		Expect(handlerLine).To(HaveKeyWithValue("request_id", "req-123"))
+		Expect(handlerLine).To(HaveKeyWithValue("principal", "api-key:checkout"))
+	})
+
+	It("logs requests turned away before authentication as anonymous", func() {
+		actor = ""
+
+		serve()
+
+		lines := logLines(output)
+		Expect(lines).To(HaveLen(1))
+		Expect(lines[0]).To(HaveKeyWithValue("status", BeEquivalentTo(401)))
+		Expect(lines[0]).To(HaveKeyWithValue("principal", "anonymous"))
+		Expect(lines[0]).To(HaveKeyWithValue("tenant", ""))
+	})
+})
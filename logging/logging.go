@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// Levels are the values accepted for logging.level
+var Levels = []string{"debug", "info", "warn", "error"}
+
+// sensitiveKeys are matched against attribute keys with their case, dashes and underscores ignored, so db_password,
+// X-API-Key and authToken are all caught
+var sensitiveKeys = []string{"password", "secret", "token", "authorization", "cookie", "apikey", "email"}
+
+var sensitiveValues = []*regexp.Regexp{
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	regexp.MustCompile(`(?i)bearer\s+[A-Za-z0-9._~+/-]+=*`),
+}
+
+func ParseLevel(level string) (slog.Level, error) {
+	var parsed slog.Level
+	err := parsed.UnmarshalText([]byte(level))
+	if err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q", level)
+	}
+
+	return parsed, nil
+}
+
+// New writes JSON lines to w, one per record at level or above. Attributes that look like credentials or personal
+// details are redacted before they're written, wherever they're logged from.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level, ReplaceAttr: redact}))
+}
+
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(attr.Key))
+	for _, sensitiveKey := range sensitiveKeys {
+		if strings.Contains(key, sensitiveKey) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+
+	switch value := attr.Value.Any().(type) {
+	case string:
+		return slog.String(attr.Key, redactString(value))
+	case error:
+		return slog.String(attr.Key, redactString(value.Error()))
+	case []string:
+		redactedValues := make([]string, len(value))
+		for i, v := range value {
+			redactedValues[i] = redactString(v)
+		}
+		return slog.Any(attr.Key, redactedValues)
+	}
+
+	return attr
+}
+
+func redactString(value string) string {
+	for _, pattern := range sensitiveValues {
+		value = pattern.ReplaceAllString(value, redacted)
+	}
+
+	return value
+}
+
+type key int
+
+const (
+	loggerKey key = iota
+	accessLogKey
+)
+
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext is the request's logger, which adds its request ID and caller to everything logged, or the default
+// logger outside a request
+func FromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerKey).(*slog.Logger)
+	if !ok {
+		return slog.Default()
+	}
+
+	return logger
+}
+
+// Error logs an error a handler is responding with, along with the stack it was reported from. Server errors are
+// logged at error level; the caller's mistakes only at info, as there's nothing for us to fix.
+func Error(ctx context.Context, err error, statusCode int) {
+	level := slog.LevelInfo
+	if statusCode >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+
+	FromContext(ctx).LogAttrs(ctx, level, "request failed",
+		slog.Any("error", err),
+		slog.Int("status", statusCode),
+		slog.Any("stack", stack(3)),
+	)
+}
+
+// stack stops at the first frame in net/http, below which it's just the middleware chain and the server
+func stack(skip int) []string {
+	programCounters := make([]uintptr, 32)
+	count := runtime.Callers(skip, programCounters)
+	frames := runtime.CallersFrames(programCounters[:count])
+
+	var lines []string
+	for {
+		frame, more := frames.Next()
+		if strings.HasPrefix(frame.Function, "net/http.") {
+			break
+		}
+
+		lines = append(lines, fmt.Sprintf("%s (%s:%d)", frame.Function, filepath.Base(frame.File), frame.Line))
+		if !more {
+			break
+		}
+	}
+
+	return lines
+}
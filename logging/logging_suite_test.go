@@ -0,0 +1,29 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestLogging(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Logging Suite")
+}
+
+// logLines decodes every JSON line written to output
+func logLines(output *bytes.Buffer) []map[string]interface{} {
+	var lines []map[string]interface{}
+
+	decoder := json.NewDecoder(output)
+	for decoder.More() {
+		var line map[string]interface{}
+		Expect(decoder.Decode(&line)).To(Succeed())
+		lines = append(lines, line)
+	}
+
+	return lines
+}
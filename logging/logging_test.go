@@ -0,0 +1,106 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/madeleinesmith/coupons/logging"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"log/slog"
+	"net/http"
+)
+
+var _ = Describe("Logging", func() {
+	var output *bytes.Buffer
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+	})
+
+	Describe("ParseLevel", func() {
+		It("parses each level, whatever its case", func() {
+			for level, expected := range map[string]slog.Level{
+				"debug": slog.LevelDebug, "info": slog.LevelInfo, "WARN": slog.LevelWarn, "error": slog.LevelError,
+			} {
+				parsed, err := logging.ParseLevel(level)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(parsed).To(Equal(expected))
+			}
+		})
+
+		It("rejects unknown levels", func() {
+			_, err := logging.ParseLevel("verbose")
+			Expect(err).To(MatchError(`unknown log level "verbose"`))
+		})
+	})
+
+	Describe("New", func() {
+		It("writes JSON lines at the level and above", func() {
+			logger := logging.New(output, slog.LevelWarn)
+
+			logger.Info("ignored")
+			logger.Warn("written", "coupon_id", 123)
+
+			lines := logLines(output)
+			Expect(lines).To(HaveLen(1))
+			Expect(lines[0]).To(HaveKeyWithValue("level", "WARN"))
+			Expect(lines[0]).To(HaveKeyWithValue("msg", "written"))
+			Expect(lines[0]).To(HaveKeyWithValue("coupon_id", BeEquivalentTo(123)))
+		})
+
+		It("redacts attributes named like credentials or personal details", func() {
+			logger := logging.New(output, slog.LevelInfo)
+
+			logger.Info("connecting", "db_password", "hunter2", "X-API-Key", "abc123", "authToken", "xyz",
+				"customerEmail", "someone@example.com", "host", "localhost")
+
+			line := logLines(output)[0]
+			Expect(line).To(HaveKeyWithValue("db_password", "[REDACTED]"))
+			Expect(line).To(HaveKeyWithValue("X-API-Key", "[REDACTED]"))
+			Expect(line).To(HaveKeyWithValue("authToken", "[REDACTED]"))
+			Expect(line).To(HaveKeyWithValue("customerEmail", "[REDACTED]"))
+			Expect(line).To(HaveKeyWithValue("host", "localhost"))
+		})
+
+		It("redacts email addresses and bearer tokens anywhere in a line", func() {
+			logger := logging.New(output, slog.LevelInfo)
+
+			logger.Info("sent to someone@example.com", "error", errors.New("rejected Bearer eyJhbGciOi.eyJzdWIi.sig"))
+
+			line := logLines(output)[0]
+			Expect(line).To(HaveKeyWithValue("msg", "sent to [REDACTED]"))
+			Expect(line).To(HaveKeyWithValue("error", "rejected [REDACTED]"))
+		})
+	})
+
+	Describe("Error", func() {
+		BeforeEach(func() {
+			logger := logging.New(output, slog.LevelDebug).With("request_id", "req-123")
+			ctx := logging.WithLogger(context.Background(), logger)
+
+			logging.Error(ctx, errors.New("connection refused"), http.StatusInternalServerError)
+			logging.Error(ctx, errors.New("couponId must be a number"), http.StatusBadRequest)
+		})
+
+		It("logs with the request's logger and the stack the error was reported from", func() {
+			lines := logLines(output)
+			Expect(lines).To(HaveLen(2))
+
+			Expect(lines[0]).To(HaveKeyWithValue("request_id", "req-123"))
+			Expect(lines[0]).To(HaveKeyWithValue("error", "connection refused"))
+			Expect(lines[0]).To(HaveKeyWithValue("status", BeEquivalentTo(500)))
+			Expect(lines[0]["stack"]).To(ContainElement(ContainSubstring("logging_test.go")))
+		})
+
+		It("logs server errors at error level, and the caller's mistakes at info", func() {
+			lines := logLines(output)
+			Expect(lines[0]).To(HaveKeyWithValue("level", "ERROR"))
+			Expect(lines[1]).To(HaveKeyWithValue("level", "INFO"))
+		})
+	})
+
+	It("falls back to the default logger outside a request", func() {
+		Expect(logging.FromContext(context.Background())).To(Equal(slog.Default()))
+	})
+})
@@ -1,28 +1,136 @@
 package main // import "github.com/madeleinesmith/coupons"
 
 import (
+	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/go-jose/go-jose/v4/jwt"
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/config"
 	"github.com/madeleinesmith/coupons/dbservices"
+	"github.com/madeleinesmith/coupons/grpcapi"
 	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/health"
+	"github.com/madeleinesmith/coupons/idempotency"
+	"github.com/madeleinesmith/coupons/lockout"
+	"github.com/madeleinesmith/coupons/logging"
+	"github.com/madeleinesmith/coupons/metrics"
 	"github.com/madeleinesmith/coupons/model"
+	"github.com/madeleinesmith/coupons/model/audit"
 	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/openapi"
+	"github.com/madeleinesmith/coupons/policy"
+	"github.com/madeleinesmith/coupons/ratelimit"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"github.com/madeleinesmith/coupons/tracing"
 	"github.com/madeleinesmith/coupons/validators"
 	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
 func main() {
-	router := mux.NewRouter().StrictSlash(true)
+	// the coupon commands load the config themselves, only if they need the database rather than the API
+	if len(os.Args) > 1 && os.Args[1] == "coupon" {
+		err := runCouponCommand(os.Args[2:])
+		if err != nil && err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	applicationConfiguration, args, err := config.Load(os.Args[1:], os.Getenv)
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// the config has been validated, so the level is known; the log package writes through this logger too
+	logLevel, _ := logging.ParseLevel(applicationConfiguration.Logging.Level)
+	logger := logging.New(os.Stderr, logLevel)
+	slog.SetDefault(logger)
+
+	db, err := initializeDb(applicationConfiguration)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// migrating is how the schema gets up to date, so it only waits for the database rather than checking the schema
+	if len(args) > 0 && args[0] == "migrate" {
+		err := waitForDatabase(db, applicationConfiguration)
+		if err == nil {
+			err = runMigrateCommand(db, args[1:])
+		}
+
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	err = checkDatabaseReady(db, applicationConfiguration)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) > 0 && args[0] == "apikeys" {
+		err := runAPIKeysCommand(db, args[1:])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	serverConfiguration := applicationConfiguration.Server
+	rootRouter := mux.NewRouter().StrictSlash(true)
+	servers := []managedServer{newServer(serverConfiguration.Port, rootRouter, serverConfiguration)}
+
+	err = metrics.RegisterDB(db, applicationConfiguration.Database.DBName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// health checks and metrics are registered ahead of the API so they skip its authentication and rate limits
+	if serverConfiguration.AdminPort == 0 {
+		registerAdminRoutes(rootRouter, db, applicationConfiguration)
+	} else {
+		adminRouter := mux.NewRouter()
+		registerAdminRoutes(adminRouter, db, applicationConfiguration)
+		servers = append(servers, newServer(serverConfiguration.AdminPort, adminRouter, serverConfiguration))
+	}
+
+	// the docs are public too, so they're also registered ahead of the API
+	rootRouter.NewRoute().Name("openapi").Path("/openapi.json").Methods(http.MethodGet).HandlerFunc(openapi.SpecHandler)
+	rootRouter.NewRoute().Name("docs").PathPrefix("/docs").Methods(http.MethodGet).Handler(openapi.DocsHandler("/docs", "/openapi.json"))
 
-	db := initializeDb()
+	router := rootRouter.PathPrefix("/").Subrouter()
 
-	couponService := dbservices.CouponService{
-		DB: db,
+	// every handler goes through the policy, so what a caller can do depends on their roles (or API key scopes)
+	instrumentedCouponService := metrics.CouponService{
+		Next:       dbservices.CouponService{DB: db},
+		Transactor: dbservices.CouponService{DB: db},
+	}
+	couponService := policy.CouponService{
+		Next:       instrumentedCouponService,
+		Transactor: instrumentedCouponService,
+		Policy:     policy.DefaultPolicy,
+		Denials:    dbservices.CouponAuditService{DB: db},
 	}
 	couponSerializer := coupon.Serializer{}
 	couponValidator := validators.CouponValidator{}
@@ -37,33 +145,395 @@ func main() {
 		Serializer:    couponSerializer,
 	}
 
-	router.NewRoute().Path("/coupons").Handler(couponHandler)
-	router.NewRoute().Path("/coupon/{couponId}").Handler(couponDetailsHandler)
+	operationsHandler := handlers.OperationsHandler{
+		Serializer:       couponSerializer,
+		CouponTransactor: couponService,
+		CouponValidator:  couponValidator,
+	}
+
+	importHandler := handlers.ImportHandler{
+		CouponTransactor: couponService,
+		CouponValidator:  couponValidator,
+	}
+
+	// routes are named so rate limits can be configured for them
+	router.NewRoute().Name("coupons").Path("/coupons").Handler(couponHandler)
+	router.NewRoute().Name("coupons-import").Path("/coupons/import").Handler(importHandler)
+	router.NewRoute().Name("coupons-export").Path("/coupons/export").Handler(handlers.ExportHandler{CouponService: couponService})
+	router.NewRoute().Name("coupon").Path("/coupon/{couponId}").Handler(couponDetailsHandler)
+	router.NewRoute().Name("coupon-version").Path("/coupon/{couponId}/versions/{version}").Handler(handlers.CouponVersionHandler{
+		CouponService: couponService,
+		Serializer:    couponSerializer,
+	})
+	router.NewRoute().Name("coupon-history").Path("/coupon/{couponId}/history").Handler(handlers.CouponHistoryHandler{
+		AuditService: policy.CouponAuditService{
+			Next:    dbservices.CouponAuditService{DB: db},
+			Policy:  policy.DefaultPolicy,
+			Denials: dbservices.CouponAuditService{DB: db},
+		},
+		Serializer: audit.Serializer{},
+	})
+	router.NewRoute().Name("operations").Path("/operations").Handler(operationsHandler)
+
+	// the config has been validated, so this can't fail
+	trustedProxies, _ := requestcontext.ParseTrustedProxies(serverConfiguration.TrustedProxies)
+
+	router.Use(requestcontext.Middleware)
+	router.Use(trustedProxies.Middleware)
+	router.Use(logging.AccessLog(logger))
+	router.Use(tracing.Middleware)
+	router.Use(metrics.Middleware)
+
+	queryTimeout, routeQueryTimeouts := queryTimeouts(applicationConfiguration)
+	router.Use(requestcontext.Deadlines(queryTimeout, routeQueryTimeouts))
+
+	grpcAuthenticator := grpcapi.Authenticator{APIKeyStore: dbservices.APIKeyService{DB: db}}
+	if applicationConfiguration.JWT.JWKSFile != "" || applicationConfiguration.JWT.JWKSURL != "" {
+		jwtValidator, err := newJWTValidator(applicationConfiguration)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		router.Use(auth.JWTMiddleware(jwtValidator))
+		grpcAuthenticator.JWTValidator = &jwtValidator
+	}
+
+	router.Use(auth.APIKeyMiddleware(dbservices.APIKeyService{DB: db}))
+	router.Use(logging.Principal)
 
-	log.Fatal(http.ListenAndServe(":6584", router))
+	// the limiter and lockout guard are shared with the gRPC API, so a caller can't get around either by switching API
+	rateLimiter, rateLimitRules, err := newRateLimiter(applicationConfiguration, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	router.Use(ratelimit.Middleware(rateLimiter, rateLimitRules))
+
+	lockoutGuard, lockoutRoutes, err := newLockoutGuard(applicationConfiguration, db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	router.Use(lockoutGuard.Middleware(lockoutRoutes...))
+
+	idempotencyTTL, err := parseDuration("idempotency.ttl", applicationConfiguration.Idempotency.TTL, idempotency.DefaultTTL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	idempotencyLease, err := parseDuration("idempotency.lease", applicationConfiguration.Idempotency.Lease, idempotency.DefaultLease)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	idempotencyKeyService := dbservices.IdempotencyKeyService{DB: db}
+	router.Use(idempotency.Middleware(idempotencyKeyService, idempotencyTTL, idempotencyLease))
+	go deleteExpiredIdempotencyKeys(idempotencyKeyService)
+
+	// the gRPC API is served from the same policy-wrapped service as the REST handlers, with the same limits
+	if serverConfiguration.GRPCPort != 0 {
+		grpcServer, grpcHealthServer := grpcapi.NewServer(&grpcapi.CouponServer{
+			CouponService:    couponService,
+			CouponTransactor: couponService,
+			CouponValidator:  couponValidator,
+			RedemptionService: metrics.RedemptionService{
+				Next: policy.RedemptionService{
+					Next:    dbservices.CouponService{DB: db},
+					Policy:  policy.DefaultPolicy,
+					Denials: dbservices.CouponAuditService{DB: db},
+				},
+			},
+		}, grpcapi.Instrumentation{
+			Logger:             logger,
+			QueryTimeout:       queryTimeout,
+			RouteQueryTimeouts: routeQueryTimeouts,
+		}, grpcAuthenticator, grpcapi.Limits{
+			Limiter:       rateLimiter,
+			Rules:         rateLimitRules,
+			Guard:         lockoutGuard,
+			GuardedRoutes: lockoutRoutes,
+		}, grpcapi.Idempotency{
+			Store: idempotencyKeyService,
+			TTL:   idempotencyTTL,
+			Lease: idempotencyLease,
+		})
+		servers = append(servers, newGRPCServer(serverConfiguration.GRPCPort, grpcServer, grpcHealthServer))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stop()
+
+	tracingConfiguration := applicationConfiguration.Tracing
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    tracingConfiguration.Exporter,
+		File:        tracingConfiguration.File,
+		Endpoint:    tracingConfiguration.Endpoint,
+		ServiceName: tracingConfiguration.ServiceName,
+		SampleRatio: tracingConfiguration.SampleRatio,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	shutdownTimeout, _ := parseDuration("server.shutdownTimeout", serverConfiguration.ShutdownTimeout, 30*time.Second)
+	err = serve(ctx, servers, shutdownTimeout)
+
+	// only closed once the servers have shut down, so no handler is left without a connection mid-transaction
+	db.Close()
+
+	tracingErr := shutdownTracing(context.Background())
+	if tracingErr != nil {
+		log.Printf("flushing traces: %v", tracingErr)
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func registerAdminRoutes(router *mux.Router, db *sql.DB, applicationConfiguration model.Config) {
+	healthConfiguration := applicationConfiguration.Health
+
+	// the config has been validated, so these can't fail
+	timeout, _ := parseDuration("health.timeout", healthConfiguration.Timeout, 2*time.Second)
+	latencyBudget, _ := parseDuration("health.latencyBudget", healthConfiguration.LatencyBudget, 0)
+
+	checks := []health.Check{
+		{Name: "database", Checker: health.CheckerFunc(db.PingContext)},
+		{Name: "migrations", Checker: health.CheckerFunc(func(ctx context.Context) error {
+			return dbservices.CheckSchema(ctx, db)
+		})},
+	}
+
+	if healthConfiguration.SampleQuery {
+		checks = append(checks, health.Check{
+			Name: "sample-query",
+			Checker: health.CheckerFunc(func(ctx context.Context) error {
+				return dbservices.SampleQuery(ctx, db)
+			}),
+			Budget: latencyBudget,
+		})
+	}
+
+	router.NewRoute().Name("healthz").Path("/healthz").Methods(http.MethodGet).HandlerFunc(health.Liveness)
+	router.NewRoute().Name("readyz").Path("/readyz").Methods(http.MethodGet).Handler(health.Readiness{Checks: checks, Timeout: timeout})
+	router.NewRoute().Name("metrics").Path("/metrics").Methods(http.MethodGet).Handler(metrics.Handler())
 }
 
-func initializeDb() *sql.DB {
-	applicationConfiguration := loadConfiguration()
+func initializeDb(applicationConfiguration model.Config) (*sql.DB, error) {
+	database := applicationConfiguration.Database
 
-	connectionString := fmt.Sprintf("user=%s password=%s dbname=%s sslmode=disable",
-		applicationConfiguration.Database.User,
-		applicationConfiguration.Database.Password,
-		applicationConfiguration.Database.DBName)
+	// the config has been validated, so these can't fail
+	connectTimeout, _ := parseDuration("database.connectTimeout", database.ConnectTimeout, 0)
+	connMaxLifetime, _ := parseDuration("database.connMaxLifetime", database.ConnMaxLifetime, 0)
+
+	connectionString := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s connect_timeout=%d",
+		quoteConnectionValue(database.Host),
+		database.Port,
+		quoteConnectionValue(database.User),
+		quoteConnectionValue(database.Password),
+		quoteConnectionValue(database.DBName),
+		database.SSLMode,
+		int(math.Ceil(connectTimeout.Seconds())))
+
+	db, err := sql.Open("postgres", connectionString)
+	if err != nil {
+		return nil, err
+	}
 
-	db, _ := sql.Open("postgres", connectionString)
+	db.SetMaxOpenConns(database.MaxOpenConns)
+	db.SetMaxIdleConns(database.MaxIdleConns)
+	db.SetConnMaxLifetime(connMaxLifetime)
 
-	return db
+	return db, nil
 }
 
-func loadConfiguration() model.Config {
-	var config model.Config
-	configFile, err := os.Open("./config.json")
-	defer configFile.Close()
+// checkDatabaseReady fails on a wrong password or missing migration now, rather than on the first request
+func checkDatabaseReady(db *sql.DB, applicationConfiguration model.Config) error {
+	err := waitForDatabase(db, applicationConfiguration)
 	if err != nil {
-		fmt.Println(err.Error())
+		return err
+	}
+
+	return dbservices.CheckSchema(context.Background(), db)
+}
+
+func waitForDatabase(db *sql.DB, applicationConfiguration model.Config) error {
+	startupTimeout, _ := parseDuration("database.startupTimeout", applicationConfiguration.Database.StartupTimeout, 30*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), startupTimeout)
+	defer cancel()
+
+	return dbservices.WaitForDatabase(ctx, db, 500*time.Millisecond, 5*time.Second)
+}
+
+func queryTimeouts(applicationConfiguration model.Config) (time.Duration, map[string]time.Duration) {
+	database := applicationConfiguration.Database
+
+	// the config has been validated, so these can't fail
+	queryTimeout, _ := parseDuration("database.queryTimeout", database.QueryTimeout, 0)
+
+	routeQueryTimeouts := map[string]time.Duration{}
+	for routeName, timeout := range database.RouteQueryTimeouts {
+		routeQueryTimeouts[routeName], _ = parseDuration("database.routeQueryTimeouts."+routeName, timeout, 0)
+	}
+
+	return queryTimeout, routeQueryTimeouts
+}
+
+// quoteConnectionValue lets values such as passwords contain spaces and quotes
+func quoteConnectionValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+func newJWTValidator(applicationConfiguration model.Config) (auth.JWTValidator, error) {
+	jwtConfiguration := applicationConfiguration.JWT
+
+	if jwtConfiguration.Issuer == "" || jwtConfiguration.Audience == "" {
+		return auth.JWTValidator{}, errors.New("jwt.issuer and jwt.audience must be set to accept bearer tokens")
+	}
+
+	validator := auth.JWTValidator{
+		Issuer:      jwtConfiguration.Issuer,
+		Audience:    jwtConfiguration.Audience,
+		RolesClaim:  jwtConfiguration.RolesClaim,
+		TenantClaim: jwtConfiguration.TenantClaim,
+		Leeway:      jwt.DefaultLeeway,
+	}
+
+	if jwtConfiguration.JWKSFile != "" {
+		keySet, err := auth.LoadKeySetFile(jwtConfiguration.JWKSFile)
+		if err != nil {
+			return auth.JWTValidator{}, err
+		}
+
+		validator.Keys = keySet
+	} else {
+		validator.Keys = &auth.RemoteKeySet{URL: jwtConfiguration.JWKSURL, Client: &http.Client{Timeout: 10 * time.Second}}
+	}
+
+	return validator, nil
+}
+
+func newRateLimiter(applicationConfiguration model.Config, db *sql.DB) (ratelimit.Limiter, map[string]ratelimit.Rule, error) {
+	rateLimiting := applicationConfiguration.RateLimiting
+
+	rules := map[string]ratelimit.Rule{}
+	for routeName, rateLimit := range rateLimiting.Routes {
+		rule, err := ratelimit.NewRule(rateLimit.RequestsPerMinute, rateLimit.Burst, rateLimit.Key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("rateLimiting.routes.%s: %v", routeName, err)
+		}
+
+		rules[routeName] = rule
+	}
+
+	switch rateLimiting.Backend {
+	case "", "memory":
+		return &ratelimit.MemoryLimiter{}, rules, nil
+	case "postgres":
+		rateLimitService := dbservices.RateLimitService{DB: db}
+		go deleteIdleRateLimitBuckets(rateLimitService)
+
+		return rateLimitService, rules, nil
+	default:
+		return nil, nil, fmt.Errorf("rateLimiting.backend must be memory or postgres, got %q", rateLimiting.Backend)
+	}
+}
+
+// deleteIdleRateLimitBuckets forgets buckets unused for a day; no limit takes anywhere near that long to refill
+func deleteIdleRateLimitBuckets(rateLimitService dbservices.RateLimitService) {
+	for range time.Tick(time.Hour) {
+		_, err := rateLimitService.DeleteIdleBuckets(context.Background(), 24*time.Hour)
+		if err != nil {
+			log.Printf("deleting idle rate limit buckets: %v", err)
+		}
+	}
+}
+
+// parseDuration returns defaultValue if value is empty
+func parseDuration(name string, value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", name, err)
+	}
+
+	if duration <= 0 {
+		return 0, fmt.Errorf("%s must be positive", name)
+	}
+
+	return duration, nil
+}
+
+// newLockoutGuard guards coupon lookups and redemptions by default, as that's where codes would be guessed
+func newLockoutGuard(applicationConfiguration model.Config, db *sql.DB) (lockout.Guard, []string, error) {
+	lockoutConfiguration := applicationConfiguration.Lockout
+	policy := lockout.DefaultPolicy
+
+	var err error
+
+	policy.Window, err = parseDuration("lockout.window", lockoutConfiguration.Window, policy.Window)
+	if err != nil {
+		return lockout.Guard{}, nil, err
+	}
+
+	policy.LockoutDuration, err = parseDuration("lockout.lockoutDuration", lockoutConfiguration.LockoutDuration, policy.LockoutDuration)
+	if err != nil {
+		return lockout.Guard{}, nil, err
+	}
+
+	policy.MaxLockoutDuration, err = parseDuration("lockout.maxLockoutDuration", lockoutConfiguration.MaxLockoutDuration, policy.MaxLockoutDuration)
+	if err != nil {
+		return lockout.Guard{}, nil, err
+	}
+
+	if lockoutConfiguration.MaxFailures != 0 {
+		policy.MaxFailures = lockoutConfiguration.MaxFailures
+	}
+
+	if lockoutConfiguration.MaxSequentialFailures != 0 {
+		policy.MaxSequentialFailures = lockoutConfiguration.MaxSequentialFailures
+	}
+
+	if policy.MaxFailures < 0 || policy.MaxSequentialFailures < 0 {
+		return lockout.Guard{}, nil, errors.New("lockout.maxFailures and lockout.maxSequentialFailures must be positive")
+	}
+
+	routes := lockoutConfiguration.Routes
+	if routes == nil {
+		routes = []string{"coupon", "coupon-redeem"}
+	}
+
+	lockoutService := dbservices.LockoutService{DB: db}
+	go deleteOldLockouts(lockoutService, policy)
+
+	alerter := metrics.LockoutAlerter{Next: lockout.LogAlerter{}}
+
+	return lockout.Guard{Store: lockoutService, Alerter: alerter, Policy: policy}, routes, nil
+}
+
+func deleteOldLockouts(lockoutService dbservices.LockoutService, policy lockout.Policy) {
+	keepFor := lockout.EscalationPeriod
+	if policy.Window > keepFor {
+		keepFor = policy.Window
+	}
+
+	for range time.Tick(time.Hour) {
+		err := lockoutService.DeleteBefore(context.Background(), time.Now().Add(-keepFor))
+		if err != nil {
+			log.Printf("deleting old lockouts: %v", err)
+		}
+	}
+}
+
+// deleteExpiredIdempotencyKeys only keeps the table small; expired keys are already ignored and can be reused
+func deleteExpiredIdempotencyKeys(idempotencyKeyService dbservices.IdempotencyKeyService) {
+	for range time.Tick(time.Hour) {
+		_, err := idempotencyKeyService.DeleteExpired(context.Background())
+		if err != nil {
+			log.Printf("deleting expired idempotency keys: %v", err)
+		}
 	}
-	jsonParser := json.NewDecoder(configFile)
-	jsonParser.Decode(&config)
-	return config
 }
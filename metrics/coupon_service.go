@@ -0,0 +1,155 @@
+package metrics
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/audit"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"time"
+)
+
+const actionRevert = "revert"
+
+// CouponService times every call to Next, and counts the coupons it changes. Changes made within a transaction are
+// only counted once it commits.
+type CouponService struct {
+	Next       handlers.CouponService
+	Transactor handlers.CouponTransactor
+	changes    map[string]int
+}
+
+func observe(method string, start time.Time, err error) {
+	outcome := "success"
+	if err != nil {
+		outcome = "error"
+	}
+
+	dbQueryDuration.WithLabelValues(method, outcome).Observe(time.Since(start).Seconds())
+}
+
+func (s CouponService) count(action string, changed int) {
+	if s.changes != nil {
+		s.changes[action] += changed
+		return
+	}
+
+	couponChanges.WithLabelValues(action).Add(float64(changed))
+}
+
+func (s CouponService) WithinTransaction(ctx context.Context, fn func(handlers.CouponService) error) error {
+	start := time.Now()
+	changes := map[string]int{}
+
+	err := s.Transactor.WithinTransaction(ctx, func(txService handlers.CouponService) error {
+		return fn(CouponService{Next: txService, changes: changes})
+	})
+	observe("WithinTransaction", start, err)
+
+	if err == nil {
+		for action, changed := range changes {
+			s.count(action, changed)
+		}
+	}
+
+	return err
+}
+
+func (s CouponService) CreateCoupon(ctx context.Context, couponInstance coupon.Coupon) (*coupon.Coupon, error) {
+	start := time.Now()
+	createdCoupon, err := s.Next.CreateCoupon(ctx, couponInstance)
+	observe("CreateCoupon", start, err)
+
+	if err == nil {
+		s.count(audit.ActionCreate, 1)
+	}
+
+	return createdCoupon, err
+}
+
+func (s CouponService) CreateCoupons(ctx context.Context, coupons []coupon.Coupon) error {
+	start := time.Now()
+	err := s.Next.CreateCoupons(ctx, coupons)
+	observe("CreateCoupons", start, err)
+
+	if err == nil {
+		s.count(audit.ActionCreate, len(coupons))
+	}
+
+	return err
+}
+
+func (s CouponService) UpdateCoupon(ctx context.Context, couponInstance coupon.Coupon) error {
+	start := time.Now()
+	err := s.Next.UpdateCoupon(ctx, couponInstance)
+	observe("UpdateCoupon", start, err)
+
+	if err == nil {
+		s.count(audit.ActionUpdate, 1)
+	}
+
+	return err
+}
+
+func (s CouponService) GetCoupons(ctx context.Context, filters handlers.Filters) ([]*coupon.Coupon, error) {
+	start := time.Now()
+	coupons, err := s.Next.GetCoupons(ctx, filters)
+	observe("GetCoupons", start, err)
+
+	return coupons, err
+}
+
+func (s CouponService) StreamCoupons(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error {
+	start := time.Now()
+	err := s.Next.StreamCoupons(ctx, filters, fn)
+	observe("StreamCoupons", start, err)
+
+	return err
+}
+
+func (s CouponService) GetCouponById(ctx context.Context, couponId string) (*coupon.Coupon, error) {
+	start := time.Now()
+	couponInstance, err := s.Next.GetCouponById(ctx, couponId)
+	observe("GetCouponById", start, err)
+
+	return couponInstance, err
+}
+
+func (s CouponService) DeleteCoupon(ctx context.Context, couponId string) error {
+	start := time.Now()
+	err := s.Next.DeleteCoupon(ctx, couponId)
+	observe("DeleteCoupon", start, err)
+
+	if err == nil {
+		s.count(audit.ActionDelete, 1)
+	}
+
+	return err
+}
+
+func (s CouponService) GetCouponAsOf(ctx context.Context, couponId string, asOf time.Time) (*coupon.Coupon, error) {
+	start := time.Now()
+	couponInstance, err := s.Next.GetCouponAsOf(ctx, couponId, asOf)
+	observe("GetCouponAsOf", start, err)
+
+	return couponInstance, err
+}
+
+func (s CouponService) GetCouponVersion(ctx context.Context, couponId string, version int) (*coupon.Coupon, error) {
+	start := time.Now()
+	couponInstance, err := s.Next.GetCouponVersion(ctx, couponId, version)
+	observe("GetCouponVersion", start, err)
+
+	return couponInstance, err
+}
+
+func (s CouponService) RevertCoupon(ctx context.Context, couponId string, version int) (*coupon.Coupon, error) {
+	start := time.Now()
+	couponInstance, err := s.Next.RevertCoupon(ctx, couponId, version)
+	observe("RevertCoupon", start, err)
+
+	if err == nil {
+		s.count(actionRevert, 1)
+	}
+
+	return couponInstance, err
+}
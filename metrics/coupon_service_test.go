@@ -0,0 +1,84 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/metrics"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CouponService", func() {
+	var (
+		fakeCouponService    *handlersfakes.FakeCouponService
+		fakeCouponTransactor *handlersfakes.FakeCouponTransactor
+		service              metrics.CouponService
+		ctx                  context.Context
+	)
+
+	created := map[string]string{"action": "create"}
+
+	BeforeEach(func() {
+		fakeCouponService = &handlersfakes.FakeCouponService{}
+		fakeCouponTransactor = &handlersfakes.FakeCouponTransactor{}
+		fakeCouponTransactor.WithinTransactionStub = func(ctx context.Context, fn func(handlers.CouponService) error) error {
+			return fn(fakeCouponService)
+		}
+
+		service = metrics.CouponService{Next: fakeCouponService, Transactor: fakeCouponTransactor}
+		ctx = context.Background()
+	})
+
+	It("times each method by outcome", func() {
+		succeeded := map[string]string{"method": "GetCouponById", "outcome": "success"}
+		failed := map[string]string{"method": "GetCouponById", "outcome": "error"}
+		succeededBefore := sampleValue("coupons_db_query_duration_seconds", succeeded)
+		failedBefore := sampleValue("coupons_db_query_duration_seconds", failed)
+
+		_, err := service.GetCouponById(ctx, "123")
+		Expect(err).NotTo(HaveOccurred())
+
+		fakeCouponService.GetCouponByIdReturns(nil, errors.New("connection refused"))
+		_, err = service.GetCouponById(ctx, "123")
+		Expect(err).To(MatchError("connection refused"))
+
+		Expect(sampleValue("coupons_db_query_duration_seconds", succeeded)).To(Equal(succeededBefore + 1))
+		Expect(sampleValue("coupons_db_query_duration_seconds", failed)).To(Equal(failedBefore + 1))
+		Expect(fakeCouponService.GetCouponByIdCallCount()).To(Equal(2))
+	})
+
+	It("counts the coupons created", func() {
+		createdBefore := sampleValue("coupons_coupon_changes_total", created)
+
+		Expect(service.CreateCoupons(ctx, make([]coupon.Coupon, 3))).To(Succeed())
+
+		fakeCouponService.CreateCouponsReturns(errors.New("duplicate key"))
+		Expect(service.CreateCoupons(ctx, make([]coupon.Coupon, 5))).NotTo(Succeed())
+
+		Expect(sampleValue("coupons_coupon_changes_total", created)).To(Equal(createdBefore + 3))
+	})
+
+	It("only counts changes made in a transaction once it commits", func() {
+		createdBefore := sampleValue("coupons_coupon_changes_total", created)
+
+		err := service.WithinTransaction(ctx, func(txService handlers.CouponService) error {
+			_, err := txService.CreateCoupon(ctx, coupon.Coupon{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sampleValue("coupons_coupon_changes_total", created)).To(Equal(createdBefore))
+
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sampleValue("coupons_coupon_changes_total", created)).To(Equal(createdBefore + 1))
+
+		err = service.WithinTransaction(ctx, func(txService handlers.CouponService) error {
+			txService.CreateCoupon(ctx, coupon.Coupon{})
+			return errors.New("rolled back")
+		})
+		Expect(err).To(MatchError("rolled back"))
+		Expect(sampleValue("coupons_coupon_changes_total", created)).To(Equal(createdBefore + 1))
+	})
+})
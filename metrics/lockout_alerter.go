@@ -0,0 +1,17 @@
+package metrics
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/lockout"
+)
+
+// LockoutAlerter counts lockouts by reason before passing them on to Next
+type LockoutAlerter struct {
+	Next lockout.Alerter
+}
+
+func (a LockoutAlerter) Alert(ctx context.Context, event lockout.Event) error {
+	lockouts.WithLabelValues(event.Reason).Inc()
+
+	return a.Next.Alert(ctx, event)
+}
@@ -0,0 +1,27 @@
+package metrics_test
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/lockout"
+	"github.com/madeleinesmith/coupons/lockout/lockoutfakes"
+	"github.com/madeleinesmith/coupons/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("LockoutAlerter", func() {
+	It("counts lockouts by reason and passes them on", func() {
+		fakeAlerter := &lockoutfakes.FakeAlerter{}
+		alerter := metrics.LockoutAlerter{Next: fakeAlerter}
+		labels := map[string]string{"reason": lockout.ReasonSequentialGuess}
+		before := sampleValue("coupons_lockouts_total", labels)
+
+		event := lockout.Event{Actor: "ip:203.0.113.7", Reason: lockout.ReasonSequentialGuess}
+		Expect(alerter.Alert(context.Background(), event)).To(Succeed())
+
+		Expect(sampleValue("coupons_lockouts_total", labels)).To(Equal(before + 1))
+		Expect(fakeAlerter.AlertCallCount()).To(Equal(1))
+		_, passedOn := fakeAlerter.AlertArgsForCall(0)
+		Expect(passedOn).To(Equal(event))
+	})
+})
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"database/sql"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/response"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "coupons"
+
+// Registry holds every metric the service exports, along with the Go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests handled, by route name, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "How long HTTP requests took to handle, by route name, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	grpcCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "grpc_calls_total",
+		Help:      "gRPC calls handled, by method and status code.",
+	}, []string{"method", "code"})
+
+	grpcCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "grpc_call_duration_seconds",
+		Help:      "How long gRPC calls took to handle, by method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "code"})
+
+	dbQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "How long each CouponService method took against the database, by method and whether it failed.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "outcome"})
+
+	couponChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "coupon_changes_total",
+		Help:      "Coupons created, updated, reverted or deleted, counted once their transaction commits.",
+	}, []string{"action"})
+
+	redemptions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "redemptions_total",
+		Help:      "Coupon redemptions attempted, by outcome (success, refused or error), and why they were refused.",
+	}, []string{"outcome", "reason"})
+
+	lockouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lockouts_total",
+		Help:      "Actors locked out for guessing coupon ids, by reason.",
+	}, []string{"reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpRequestDuration,
+		grpcCalls,
+		grpcCallDuration,
+		dbQueryDuration,
+		couponChanges,
+		redemptions,
+		lockouts,
+	)
+}
+
+// Handler serves the Registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB exports the connection pool's sql.DBStats, labelled with the database's name
+func RegisterDB(db *sql.DB, dbName string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// Middleware counts and times requests by route name rather than path, so coupon ids don't each get their own series
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		routeName := ""
+		if route := mux.CurrentRoute(req); route != nil {
+			routeName = route.GetName()
+		}
+
+		start := time.Now()
+		recorder := response.NewRecorder(w)
+
+		next.ServeHTTP(recorder, req)
+
+		labels := prometheus.Labels{"route": routeName, "method": req.Method, "status": strconv.Itoa(recorder.StatusCode)}
+		httpRequests.With(labels).Inc()
+		httpRequestDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+// ObserveGRPCCall counts and times a gRPC call, by its method's name rather than its full path
+func ObserveGRPCCall(method string, code string, duration time.Duration) {
+	labels := prometheus.Labels{"method": method, "code": code}
+	grpcCalls.With(labels).Inc()
+	grpcCallDuration.With(labels).Observe(duration.Seconds())
+}
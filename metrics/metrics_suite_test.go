@@ -0,0 +1,44 @@
+package metrics_test
+
+import (
+	"testing"
+
+	"github.com/madeleinesmith/coupons/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestMetrics(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Metrics Suite")
+}
+
+// sampleValue reads a counter, or a histogram's sample count, from the registry. Metrics are shared by every spec,
+// so specs compare values before and after rather than expecting exact ones.
+func sampleValue(name string, labels map[string]string) float64 {
+	families, err := metrics.Registry.Gather()
+	Expect(err).NotTo(HaveOccurred())
+
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, label := range metric.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue metrics
+				}
+			}
+
+			if metric.GetHistogram() != nil {
+				return float64(metric.GetHistogram().GetSampleCount())
+			}
+
+			return metric.GetCounter().GetValue()
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,74 @@
+package metrics_test
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+var _ = Describe("Metrics", func() {
+	Describe("Middleware", func() {
+		var router *mux.Router
+
+		BeforeEach(func() {
+			router = mux.NewRouter()
+			router.NewRoute().Name("coupon").Path("/coupon/{couponId}").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				http.Error(w, "not found", http.StatusNotFound)
+			})
+			router.Use(metrics.Middleware)
+		})
+
+		It("counts and times requests by route name and status", func() {
+			labels := map[string]string{"route": "coupon", "method": "GET", "status": "404"}
+			requestsBefore := sampleValue("coupons_http_requests_total", labels)
+			observationsBefore := sampleValue("coupons_http_request_duration_seconds", labels)
+
+			for _, path := range []string{"/coupon/SAVE0001", "/coupon/SAVE0002"} {
+				request, err := http.NewRequest(http.MethodGet, path, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				router.ServeHTTP(httptest.NewRecorder(), request)
+			}
+
+			Expect(sampleValue("coupons_http_requests_total", labels)).To(Equal(requestsBefore + 2))
+			Expect(sampleValue("coupons_http_request_duration_seconds", labels)).To(Equal(observationsBefore + 2))
+		})
+	})
+
+	Describe("ObserveGRPCCall", func() {
+		It("counts and times calls by method and code", func() {
+			labels := map[string]string{"method": "GetCoupon", "code": "NotFound"}
+			callsBefore := sampleValue("coupons_grpc_calls_total", labels)
+			observationsBefore := sampleValue("coupons_grpc_call_duration_seconds", labels)
+
+			metrics.ObserveGRPCCall("GetCoupon", "NotFound", time.Millisecond)
+
+			Expect(sampleValue("coupons_grpc_calls_total", labels)).To(Equal(callsBefore + 1))
+			Expect(sampleValue("coupons_grpc_call_duration_seconds", labels)).To(Equal(observationsBefore + 1))
+		})
+	})
+
+	Describe("Handler", func() {
+		It("serves the Prometheus text format, including the pool stats", func() {
+			db, _, err := sqlmock.New()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metrics.RegisterDB(db, "coupons_test")).To(Succeed())
+
+			request, err := http.NewRequest(http.MethodGet, "/metrics", nil)
+			Expect(err).NotTo(HaveOccurred())
+			recorder := httptest.NewRecorder()
+
+			metrics.Handler().ServeHTTP(recorder, request)
+
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+			Expect(recorder.Header().Get("Content-Type")).To(ContainSubstring("text/plain"))
+			Expect(recorder.Body.String()).To(ContainSubstring(`go_sql_max_open_connections{db_name="coupons_test"} 0`))
+			Expect(recorder.Body.String()).To(ContainSubstring("go_goroutines"))
+		})
+	})
+})
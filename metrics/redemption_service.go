@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"time"
+)
+
+// The reasons a redemption is refused, for redemptions_total. Redemptions that fail for any other reason are counted
+// as errors.
+const (
+	ReasonExpired     = "expired"
+	ReasonNotFound    = "not_found"
+	ReasonDenied      = "denied"
+	ReasonRateLimited = "rate_limited"
+	ReasonLockedOut   = "locked_out"
+)
+
+// RedemptionService counts every redemption by outcome, and times those that got as far as the database alongside
+// the CouponService methods. It goes ahead of the policy, so denied redemptions are counted too.
+type RedemptionService struct {
+	Next handlers.RedemptionService
+}
+
+func (s RedemptionService) RedeemCoupon(ctx context.Context, couponId string) (*coupon.Redemption, error) {
+	start := time.Now()
+	redemption, err := s.Next.RedeemCoupon(ctx, couponId)
+
+	var permissionDenied auth.PermissionDeniedError
+
+	switch {
+	case err == nil:
+		redemptions.WithLabelValues("success", "").Inc()
+	case errors.As(err, &permissionDenied):
+		CountRefusedRedemption(ReasonDenied)
+		return redemption, err
+	case errors.Is(err, coupon.ErrExpired):
+		CountRefusedRedemption(ReasonExpired)
+	case errors.Is(err, sql.ErrNoRows):
+		CountRefusedRedemption(ReasonNotFound)
+	default:
+		redemptions.WithLabelValues("error", "").Inc()
+	}
+
+	observe("RedeemCoupon", start, err)
+
+	return redemption, err
+}
+
+// CountRefusedRedemption counts a redemption refused for reason, for those refused before reaching RedemptionService,
+// such as by rate limits.
+func CountRefusedRedemption(reason string) {
+	redemptions.WithLabelValues("refused", reason).Inc()
+}
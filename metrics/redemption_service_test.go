@@ -0,0 +1,109 @@
+package metrics_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/metrics"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RedemptionService", func() {
+	It("times each redemption by outcome", func() {
+		fakeRedemptionService := &handlersfakes.FakeRedemptionService{}
+		service := metrics.RedemptionService{Next: fakeRedemptionService}
+
+		succeeded := map[string]string{"method": "RedeemCoupon", "outcome": "success"}
+		failed := map[string]string{"method": "RedeemCoupon", "outcome": "error"}
+		succeededBefore := sampleValue("coupons_db_query_duration_seconds", succeeded)
+		failedBefore := sampleValue("coupons_db_query_duration_seconds", failed)
+
+		_, err := service.RedeemCoupon(context.Background(), "123")
+		Expect(err).NotTo(HaveOccurred())
+
+		fakeRedemptionService.RedeemCouponReturns(nil, errors.New("connection refused"))
+		_, err = service.RedeemCoupon(context.Background(), "123")
+		Expect(err).To(MatchError("connection refused"))
+
+		Expect(sampleValue("coupons_db_query_duration_seconds", succeeded)).To(Equal(succeededBefore + 1))
+		Expect(sampleValue("coupons_db_query_duration_seconds", failed)).To(Equal(failedBefore + 1))
+	})
+
+	Describe("counting redemptions", func() {
+		var (
+			fakeRedemptionService *handlersfakes.FakeRedemptionService
+			service               metrics.RedemptionService
+		)
+
+		refused := func(reason string) map[string]string {
+			return map[string]string{"outcome": "refused", "reason": reason}
+		}
+
+		BeforeEach(func() {
+			fakeRedemptionService = &handlersfakes.FakeRedemptionService{}
+			service = metrics.RedemptionService{Next: fakeRedemptionService}
+		})
+
+		It("counts successes", func() {
+			succeeded := map[string]string{"outcome": "success", "reason": ""}
+			before := sampleValue("coupons_redemptions_total", succeeded)
+
+			_, err := service.RedeemCoupon(context.Background(), "123")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(sampleValue("coupons_redemptions_total", succeeded)).To(Equal(before + 1))
+		})
+
+		It("counts refusals by reason", func() {
+			expiredBefore := sampleValue("coupons_redemptions_total", refused(metrics.ReasonExpired))
+			notFoundBefore := sampleValue("coupons_redemptions_total", refused(metrics.ReasonNotFound))
+
+			fakeRedemptionService.RedeemCouponReturns(nil, coupon.ErrExpired)
+			_, err := service.RedeemCoupon(context.Background(), "123")
+			Expect(err).To(Equal(coupon.ErrExpired))
+
+			fakeRedemptionService.RedeemCouponReturns(nil, sql.ErrNoRows)
+			_, err = service.RedeemCoupon(context.Background(), "123")
+			Expect(err).To(Equal(sql.ErrNoRows))
+
+			Expect(sampleValue("coupons_redemptions_total", refused(metrics.ReasonExpired))).To(Equal(expiredBefore + 1))
+			Expect(sampleValue("coupons_redemptions_total", refused(metrics.ReasonNotFound))).To(Equal(notFoundBefore + 1))
+		})
+
+		It("counts denials without timing them, as they never reach the database", func() {
+			denied := refused(metrics.ReasonDenied)
+			timed := map[string]string{"method": "RedeemCoupon", "outcome": "error"}
+			deniedBefore := sampleValue("coupons_redemptions_total", denied)
+			timedBefore := sampleValue("coupons_db_query_duration_seconds", timed)
+
+			fakeRedemptionService.RedeemCouponReturns(nil, auth.PermissionDeniedError{Permission: "redeem-coupons"})
+			_, err := service.RedeemCoupon(context.Background(), "123")
+			Expect(err).To(HaveOccurred())
+
+			Expect(sampleValue("coupons_redemptions_total", denied)).To(Equal(deniedBefore + 1))
+			Expect(sampleValue("coupons_db_query_duration_seconds", timed)).To(Equal(timedBefore))
+		})
+
+		It("counts other failures as errors", func() {
+			failed := map[string]string{"outcome": "error", "reason": ""}
+			before := sampleValue("coupons_redemptions_total", failed)
+
+			fakeRedemptionService.RedeemCouponReturns(nil, errors.New("connection refused"))
+			_, _ = service.RedeemCoupon(context.Background(), "123")
+
+			Expect(sampleValue("coupons_redemptions_total", failed)).To(Equal(before + 1))
+		})
+
+		It("counts redemptions refused before reaching the service", func() {
+			before := sampleValue("coupons_redemptions_total", refused(metrics.ReasonRateLimited))
+
+			metrics.CountRefusedRedemption(metrics.ReasonRateLimited)
+
+			Expect(sampleValue("coupons_redemptions_total", refused(metrics.ReasonRateLimited))).To(Equal(before + 1))
+		})
+	})
+})
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/madeleinesmith/coupons/db"
+	"github.com/madeleinesmith/coupons/dbservices"
+	"io/fs"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = `usage:
+  coupons migrate up
+  coupons migrate down [-all]
+  coupons migrate status
+  coupons migrate goto <version>
+  coupons migrate force <version>`
+
+// runMigrateCommand applies and reverts the migrations built into the binary, e.g. `coupons migrate up`
+func runMigrateCommand(database *sql.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	migrationFiles, err := fs.Sub(db.Migrations, "migrations")
+	if err != nil {
+		return err
+	}
+
+	migrations, err := dbservices.LoadMigrations(migrationFiles)
+	if err != nil {
+		return err
+	}
+
+	migrator := dbservices.Migrator{DB: database, Migrations: migrations}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ContinueOnError)
+		all := flags.Bool("all", false, "revert every migration, rather than just the latest")
+
+		err := flags.Parse(args[1:])
+		if err != nil {
+			return err
+		}
+
+		steps := 1
+		if *all {
+			steps = 0
+		}
+
+		return migrator.Down(ctx, steps)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, status := range statuses {
+			switch {
+			case status.Dirty:
+				fmt.Fprintf(writer, "%s\tdirty\n", status.Migration)
+			case status.Applied:
+				fmt.Fprintf(writer, "%s\tapplied %s\n", status.Migration, status.AppliedAt.Format("2006-01-02 15:04:05 MST"))
+			default:
+				fmt.Fprintf(writer, "%s\tpending\n", status.Migration)
+			}
+		}
+
+		return writer.Flush()
+	case "goto", "force":
+		if len(args) != 2 {
+			return errors.New(migrateUsage)
+		}
+
+		version, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("version must be a number, got %q", args[1])
+		}
+
+		if args[0] == "goto" {
+			return migrator.Goto(ctx, version)
+		}
+
+		return migrator.Force(ctx, version)
+	default:
+		return errors.New(migrateUsage)
+	}
+}
@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"time"
+)
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	ActionDenied = "denied"
+	ActionRedeem = "redeem"
+)
+
+type Change struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+type Entry struct {
+	ID         string            `jsonapi:"primary,coupon-audits"`
+	CouponID   string            `jsonapi:"attr,couponId"`
+	Action     string            `jsonapi:"attr,action"`
+	Actor      string            `jsonapi:"attr,actor"`
+	RequestID  string            `jsonapi:"attr,requestId,omitempty"`
+	Permission string            `jsonapi:"attr,permission,omitempty"`
+	Changes    map[string]Change `jsonapi:"attr,changes"`
+	CreatedAt  time.Time         `jsonapi:"attr,createdAt,iso8601"`
+}
+
+// Diff lists the coupon fields that differ between before and after. Either side can be nil for creates and deletes.
+func Diff(before *coupon.Coupon, after *coupon.Coupon) map[string]Change {
+	if before == nil {
+		before = &coupon.Coupon{}
+	}
+
+	if after == nil {
+		after = &coupon.Coupon{}
+	}
+
+	changes := map[string]Change{}
+
+	addChange := func(field string, beforeValue interface{}, afterValue interface{}, changed bool) {
+		if changed {
+			changes[field] = Change{Before: beforeValue, After: afterValue}
+		}
+	}
+
+	addChange("name", stringOrNil(before.Name), stringOrNil(after.Name), !equalStrings(before.Name, after.Name))
+	addChange("brand", stringOrNil(before.Brand), stringOrNil(after.Brand), !equalStrings(before.Brand, after.Brand))
+	addChange("value", intOrNil(before.Value), intOrNil(after.Value), !equalInts(before.Value, after.Value))
+	addChange("expiry", timeOrNil(before.Expiry), timeOrNil(after.Expiry), !equalTimes(before.Expiry, after.Expiry))
+
+	return changes
+}
+
+func stringOrNil(s *string) interface{} {
+	if s == nil {
+		return nil
+	}
+	return *s
+}
+
+func intOrNil(i *int) interface{} {
+	if i == nil {
+		return nil
+	}
+	return *i
+}
+
+func timeOrNil(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func equalStrings(a *string, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalInts(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func equalTimes(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
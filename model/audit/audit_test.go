@@ -0,0 +1,58 @@
+package audit_test
+
+import (
+	"github.com/madeleinesmith/coupons/model/audit"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("Diff", func() {
+	var (
+		name  string
+		brand string
+		value int
+	)
+
+	BeforeEach(func() {
+		name = "Save £5 at Boots"
+		brand = "Boots"
+		value = 5
+	})
+
+	It("lists every field as new when a coupon is created", func() {
+		Expect(audit.Diff(nil, &coupon.Coupon{Name: &name, Brand: &brand, Value: &value})).To(Equal(map[string]audit.Change{
+			"name":  {Before: nil, After: "Save £5 at Boots"},
+			"brand": {Before: nil, After: "Boots"},
+			"value": {Before: nil, After: 5},
+		}))
+	})
+
+	It("only lists the fields that changed", func() {
+		newValue := 10
+		Expect(audit.Diff(
+			&coupon.Coupon{Name: &name, Brand: &brand, Value: &value},
+			&coupon.Coupon{Name: &name, Brand: &brand, Value: &newValue},
+		)).To(Equal(map[string]audit.Change{
+			"value": {Before: 5, After: 10},
+		}))
+	})
+
+	It("lists every field as removed when a coupon is deleted", func() {
+		Expect(audit.Diff(&coupon.Coupon{Name: &name, Brand: &brand, Value: &value}, nil)).To(HaveLen(3))
+	})
+
+	It("lists a coupon's expiry changing, in UTC", func() {
+		expiry := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+		expired := time.Date(2026, 10, 19, 10, 30, 0, 0, time.FixedZone("BST", 3600))
+		Expect(audit.Diff(&coupon.Coupon{Expiry: &expiry}, &coupon.Coupon{Expiry: &expired})).To(Equal(map[string]audit.Change{
+			"expiry": {Before: "2027-01-01T00:00:00Z", After: "2026-10-19T09:30:00Z"},
+		}))
+	})
+
+	It("is empty when nothing changed", func() {
+		sameName := name
+		Expect(audit.Diff(&coupon.Coupon{Name: &name}, &coupon.Coupon{Name: &sameName})).To(BeEmpty())
+	})
+})
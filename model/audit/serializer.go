@@ -0,0 +1,23 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/google/jsonapi"
+)
+
+type Serializer struct{}
+
+func (s Serializer) SerializeEntries(entries []*Entry) ([]byte, error) {
+	buffer := bytes.Buffer{}
+	writer := bufio.NewWriter(&buffer)
+
+	err := jsonapi.MarshalPayloadWithoutIncluded(writer, entries)
+	if err != nil {
+		return nil, err
+	}
+
+	writer.Flush()
+
+	return buffer.Bytes(), nil
+}
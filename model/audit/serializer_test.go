@@ -0,0 +1,42 @@
+package audit_test
+
+import (
+	"github.com/madeleinesmith/coupons/model/audit"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("Audit Serializer", func() {
+	Context("SerializeEntries", func() {
+		It("serializes audit entries", func() {
+			entries := []*audit.Entry{{
+				ID:        "42",
+				CouponID:  "658a191a-28b5-11e9-9968-87c211c8c951",
+				Action:    "update",
+				Actor:     "madeleine",
+				RequestID: "req-123",
+				Changes:   map[string]audit.Change{"value": {Before: 10, After: 20}},
+				CreatedAt: time.Date(2026, 1, 1, 9, 30, 0, 0, time.UTC),
+			}}
+
+			byteSlice, err := audit.Serializer{}.SerializeEntries(entries)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(byteSlice)).To(MatchJSON(`{
+  "data": [{
+    "type": "coupon-audits",
+    "id": "42",
+    "attributes": {
+      "couponId": "658a191a-28b5-11e9-9968-87c211c8c951",
+      "action": "update",
+      "actor": "madeleine",
+      "requestId": "req-123",
+      "changes": {"value": {"before": 10, "after": 20}},
+      "createdAt": "2026-01-01T09:30:00Z"
+    }
+  }]
+}`))
+		})
+	})
+})
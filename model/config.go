@@ -1,9 +1,99 @@
 package model
 
 type Config struct {
-	Database struct {
-		User     string `json:"user"`
-		Password string `json:"password"`
-		DBName string `json:"dbName"`
-	} `json:"database"`
-}
\ No newline at end of file
+	Server       ServerConfig       `json:"server" yaml:"server"`
+	Database     DatabaseConfig     `json:"database" yaml:"database"`
+	JWT          JWTConfig          `json:"jwt" yaml:"jwt"`
+	Idempotency  IdempotencyConfig  `json:"idempotency" yaml:"idempotency"`
+	RateLimiting RateLimitingConfig `json:"rateLimiting" yaml:"rateLimiting"`
+	Lockout      LockoutConfig      `json:"lockout" yaml:"lockout"`
+	Health       HealthConfig       `json:"health" yaml:"health"`
+	Tracing      TracingConfig      `json:"tracing" yaml:"tracing"`
+	Logging      LoggingConfig      `json:"logging" yaml:"logging"`
+}
+
+// ServerConfig's AdminPort serves the health checks on their own port when set, rather than next to the API, and
+// GRPCPort serves the gRPC API when set. TrustedProxies are CIDRs whose X-Forwarded-For is believed.
+type ServerConfig struct {
+	Port              int      `json:"port" yaml:"port"`
+	AdminPort         int      `json:"adminPort" yaml:"adminPort"`
+	GRPCPort          int      `json:"grpcPort" yaml:"grpcPort"`
+	ReadHeaderTimeout string   `json:"readHeaderTimeout" yaml:"readHeaderTimeout"`
+	ReadTimeout       string   `json:"readTimeout" yaml:"readTimeout"`
+	WriteTimeout      string   `json:"writeTimeout" yaml:"writeTimeout"`
+	IdleTimeout       string   `json:"idleTimeout" yaml:"idleTimeout"`
+	ShutdownTimeout   string   `json:"shutdownTimeout" yaml:"shutdownTimeout"`
+	TrustedProxies    []string `json:"trustedProxies" yaml:"trustedProxies"`
+}
+
+// DatabaseConfig durations are strings such as "30s", like the rest of the config
+type DatabaseConfig struct {
+	Host            string `json:"host" yaml:"host"`
+	Port            int    `json:"port" yaml:"port"`
+	User            string `json:"user" yaml:"user"`
+	Password        string `json:"password" yaml:"password"`
+	DBName          string `json:"dbName" yaml:"dbName"`
+	SSLMode         string `json:"sslMode" yaml:"sslMode"`
+	ConnectTimeout  string `json:"connectTimeout" yaml:"connectTimeout"`
+	MaxOpenConns    int    `json:"maxOpenConns" yaml:"maxOpenConns"`
+	MaxIdleConns    int    `json:"maxIdleConns" yaml:"maxIdleConns"`
+	ConnMaxLifetime string `json:"connMaxLifetime" yaml:"connMaxLifetime"`
+	StartupTimeout  string `json:"startupTimeout" yaml:"startupTimeout"`
+	// QueryTimeout bounds each request's queries, unless its route has its own in RouteQueryTimeouts
+	QueryTimeout       string            `json:"queryTimeout" yaml:"queryTimeout"`
+	RouteQueryTimeouts map[string]string `json:"routeQueryTimeouts" yaml:"routeQueryTimeouts"`
+}
+
+type JWTConfig struct {
+	JWKSFile    string `json:"jwksFile" yaml:"jwksFile"`
+	JWKSURL     string `json:"jwksUrl" yaml:"jwksUrl"`
+	Issuer      string `json:"issuer" yaml:"issuer"`
+	Audience    string `json:"audience" yaml:"audience"`
+	RolesClaim  string `json:"rolesClaim" yaml:"rolesClaim"`
+	TenantClaim string `json:"tenantClaim" yaml:"tenantClaim"`
+}
+
+type IdempotencyConfig struct {
+	TTL   string `json:"ttl" yaml:"ttl"`
+	Lease string `json:"lease" yaml:"lease"`
+}
+
+type RateLimitingConfig struct {
+	Backend string               `json:"backend" yaml:"backend"`
+	Routes  map[string]RateLimit `json:"routes" yaml:"routes"`
+}
+
+type LockoutConfig struct {
+	Routes                []string `json:"routes" yaml:"routes"`
+	Window                string   `json:"window" yaml:"window"`
+	MaxFailures           int      `json:"maxFailures" yaml:"maxFailures"`
+	MaxSequentialFailures int      `json:"maxSequentialFailures" yaml:"maxSequentialFailures"`
+	LockoutDuration       string   `json:"lockoutDuration" yaml:"lockoutDuration"`
+	MaxLockoutDuration    string   `json:"maxLockoutDuration" yaml:"maxLockoutDuration"`
+}
+
+type HealthConfig struct {
+	Timeout       string `json:"timeout" yaml:"timeout"`
+	SampleQuery   bool   `json:"sampleQuery" yaml:"sampleQuery"`
+	LatencyBudget string `json:"latencyBudget" yaml:"latencyBudget"`
+}
+
+// TracingConfig's File is only used by the file exporter, and Endpoint by the otlp one
+type TracingConfig struct {
+	Exporter    string  `json:"exporter" yaml:"exporter"`
+	File        string  `json:"file" yaml:"file"`
+	Endpoint    string  `json:"endpoint" yaml:"endpoint"`
+	ServiceName string  `json:"serviceName" yaml:"serviceName"`
+	SampleRatio float64 `json:"sampleRatio" yaml:"sampleRatio"`
+}
+
+type LoggingConfig struct {
+	Level string `json:"level" yaml:"level"`
+}
+
+// RateLimit is keyed by the name of the route it applies to
+type RateLimit struct {
+	RequestsPerMinute int    `json:"requestsPerMinute" yaml:"requestsPerMinute"`
+	Burst             int    `json:"burst" yaml:"burst"`
+	Key               string `json:"key" yaml:"key"`
+}
@@ -1,8 +1,13 @@
 package coupon
 
+import "time"
+
 type Coupon struct {
 	ID string `jsonapi:"primary,coupons"`
 	Name *string `jsonapi:"attr,name,omitempty"`
 	Brand *string `jsonapi:"attr,brand,omitempty"`
 	Value *int `jsonapi:"attr,value,omitempty"`
+	Version *int `jsonapi:"attr,version,omitempty"`
+	// Expiry is when the coupon stops being valid
+	Expiry *time.Time `jsonapi:"attr,expiry,iso8601,omitempty"`
 }
\ No newline at end of file
@@ -0,0 +1,15 @@
+package coupon
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrExpired is returned for coupons redeemed once their expiry has passed
+var ErrExpired = errors.New("coupon has expired")
+
+type Redemption struct {
+	ID         string
+	CouponID   string
+	RedeemedAt time.Time
+}
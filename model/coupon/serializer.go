@@ -3,6 +3,8 @@ package coupon
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"errors"
 	"github.com/google/jsonapi"
 )
 
@@ -44,4 +46,24 @@ func (s Serializer) SerializeCoupons(coupons []*Coupon) ([]byte, error) {
 	writer.Flush()
 
 	return buffer.Bytes(), nil
-}
\ No newline at end of file
+}
+
+// SerializeCouponPage is SerializeCoupons with a link to the next page, unless next is empty as this is the last
+func (s Serializer) SerializeCouponPage(coupons []*Coupon, next string) ([]byte, error) {
+	payload, err := jsonapi.Marshal(coupons)
+	if err != nil {
+		return nil, err
+	}
+
+	manyPayload, ok := payload.(*jsonapi.ManyPayload)
+	if !ok {
+		return nil, errors.New("expected a payload of many coupons")
+	}
+
+	manyPayload.Included = nil
+	if next != "" {
+		manyPayload.Links = &jsonapi.Links{"next": next}
+	}
+
+	return json.Marshal(manyPayload)
+}
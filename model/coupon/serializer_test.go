@@ -137,4 +137,40 @@ var _ = Describe("Coupon Serializer", func() {
 }`))
 		})
 	})
-})
\ No newline at end of file
+
+	Context("SerializeCouponPage", func() {
+		var coupons []*coupon.Coupon
+
+		BeforeEach(func() {
+			name := "Save £10 at Madeleine's Supermercado"
+			coupons = []*coupon.Coupon{{ID: "354403f0-1c0e-11e9-9142-134e17ba9a5f", Name: &name}}
+		})
+
+		It("links to the next page", func() {
+			byteSlice, err := s.SerializeCouponPage(coupons, "/coupons?page%5Bafter%5D=354403f0-1c0e-11e9-9142-134e17ba9a5f")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(byteSlice)).To(MatchJSON(`{
+  "data":[
+    {
+      "type": "coupons",
+      "id": "354403f0-1c0e-11e9-9142-134e17ba9a5f",
+      "attributes": {
+        "name": "Save £10 at Madeleine's Supermercado"
+      }
+    }
+  ],
+  "links": {
+    "next": "/coupons?page%5Bafter%5D=354403f0-1c0e-11e9-9142-134e17ba9a5f"
+  }
+}`))
+		})
+
+		It("has no links on the last page", func() {
+			byteSlice, err := s.SerializeCouponPage(coupons, "")
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(string(byteSlice)).NotTo(ContainSubstring("links"))
+		})
+	})
+})
@@ -0,0 +1,54 @@
+package openapi
+
+import (
+	_ "embed"
+	"fmt"
+	swaggerFiles "github.com/swaggo/files/v2"
+	"net/http"
+	"strings"
+)
+
+// Spec is the OpenAPI document for the API. It's written by hand, and the tests check it against the handlers'
+// responses so the two can't drift apart.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// SpecHandler serves Spec
+func SpecHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(Spec)
+}
+
+// swaggerInitializer replaces the one that comes with Swagger UI, which shows the petstore example
+const swaggerInitializer = `window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: %q,
+    dom_id: "#swagger-ui",
+    deepLinking: true,
+    presets: [SwaggerUIBundle.presets.apis, SwaggerUIStandalonePreset],
+    plugins: [SwaggerUIBundle.plugins.DownloadUrl],
+    layout: "StandaloneLayout"
+  });
+};
+`
+
+// DocsHandler serves Swagger UI under prefix, showing the document at specURL. Swagger UI is bundled into the
+// binary, so the docs work without reaching a CDN.
+func DocsHandler(prefix string, specURL string) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	initializer := fmt.Sprintf(swaggerInitializer, specURL)
+	files := http.StripPrefix(prefix, http.FileServer(http.FS(swaggerFiles.FS)))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case strings.TrimSuffix(prefix, "/"):
+			http.Redirect(w, req, prefix, http.StatusMovedPermanently)
+		case prefix + "swagger-initializer.js":
+			w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+			w.Write([]byte(initializer))
+		default:
+			files.ServeHTTP(w, req)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package openapi_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/madeleinesmith/coupons/openapi"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"mime"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestOpenapi(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Openapi Suite")
+}
+
+const specURL = "https://coupons.example.com/openapi.json"
+
+var (
+	spec     map[string]interface{}
+	compiler *jsonschema.Compiler
+)
+
+var _ = BeforeSuite(func() {
+	Expect(json.Unmarshal(openapi.Spec, &spec)).To(Succeed())
+
+	document, err := jsonschema.UnmarshalJSON(bytes.NewReader(openapi.Spec))
+	Expect(err).NotTo(HaveOccurred())
+
+	// OpenAPI 3.1 schemas are JSON Schema 2020-12, so the spec's own schemas can check the responses
+	compiler = jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	compiler.AssertFormat()
+	Expect(compiler.AddResource(specURL, document)).To(Succeed())
+})
+
+// pointerTo escapes path as a JSON pointer into the spec
+func pointerTo(path ...string) string {
+	escaped := make([]string, len(path))
+	for i, segment := range path {
+		escaped[i] = strings.NewReplacer("~", "~0", "/", "~1").Replace(segment)
+	}
+
+	return specURL + "#/" + strings.Join(escaped, "/")
+}
+
+func lookup(path ...string) (interface{}, bool) {
+	var node interface{} = spec
+	for _, segment := range path {
+		object, ok := node.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		node, ok = object[segment]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return node, true
+}
+
+// documentedResponse finds the response the spec gives for the route's status, following a $ref to a shared one
+func documentedResponse(route string, method string, statusCode int) (map[string]interface{}, []string) {
+	path := []string{"paths", route, strings.ToLower(method), "responses", strconv.Itoa(statusCode)}
+
+	response, ok := lookup(path...)
+	ExpectWithOffset(2, ok).To(BeTrue(), "the spec has no %d response for %s %s", statusCode, method, route)
+
+	if ref, ok := response.(map[string]interface{})["$ref"].(string); ok {
+		path = strings.Split(strings.TrimPrefix(ref, "#/"), "/")
+		response, ok = lookup(path...)
+		ExpectWithOffset(2, ok).To(BeTrue(), "the spec has no %s", ref)
+	}
+
+	return response.(map[string]interface{}), path
+}
+
+// expectDocumented checks the spec documents the response's status, content type and body for the route
+func expectDocumented(route string, method string, recorder *httptest.ResponseRecorder) {
+	response, path := documentedResponse(route, method, recorder.Code)
+
+	content, hasContent := response["content"].(map[string]interface{})
+	if !hasContent {
+		ExpectWithOffset(1, recorder.Body.Len()).To(BeZero(), "%s %s %d has no content in the spec", method, route, recorder.Code)
+		return
+	}
+
+	mediaType, _, err := mime.ParseMediaType(recorder.Header().Get("Content-Type"))
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+
+	var contentType string
+	for documentedType := range content {
+		documentedMediaType, _, _ := mime.ParseMediaType(documentedType)
+		if documentedMediaType == mediaType {
+			contentType = documentedType
+		}
+	}
+	ExpectWithOffset(1, contentType).NotTo(BeEmpty(), "%s %s %d isn't documented as %s", method, route, recorder.Code, mediaType)
+
+	schema, err := compiler.Compile(pointerTo(append(path, "content", contentType, "schema")...))
+	ExpectWithOffset(1, err).NotTo(HaveOccurred())
+
+	var body interface{} = recorder.Body.String()
+	if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		body, err = jsonschema.UnmarshalJSON(bytes.NewReader(recorder.Body.Bytes()))
+		ExpectWithOffset(1, err).NotTo(HaveOccurred())
+	}
+
+	err = schema.Validate(body)
+	ExpectWithOffset(1, err).NotTo(HaveOccurred(), "%s %s %d: %s", method, route, recorder.Code, recorder.Body.String())
+}
@@ -0,0 +1,63 @@
+package openapi_test
+
+import (
+	"github.com/madeleinesmith/coupons/openapi"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("SpecHandler", func() {
+	It("serves the spec", func() {
+		recorder := httptest.NewRecorder()
+		openapi.SpecHandler(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+		Expect(recorder.Body.Bytes()).To(Equal(openapi.Spec))
+		Expect(spec["openapi"]).To(Equal("3.1.0"))
+	})
+})
+
+var _ = Describe("DocsHandler", func() {
+	var handler http.Handler
+
+	BeforeEach(func() {
+		handler = openapi.DocsHandler("/docs", "/openapi.json")
+	})
+
+	serve := func(target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+
+		return recorder
+	}
+
+	It("serves Swagger UI", func() {
+		recorder := serve("/docs/")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(ContainSubstring(`<div id="swagger-ui">`))
+
+		recorder = serve("/docs/swagger-ui-bundle.js")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.Len()).To(BeNumerically(">", 0))
+	})
+
+	It("points Swagger UI at the spec", func() {
+		recorder := serve("/docs/swagger-initializer.js")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(recorder.Body.String()).To(ContainSubstring(`url: "/openapi.json"`))
+		Expect(recorder.Body.String()).NotTo(ContainSubstring("petstore"))
+	})
+
+	It("redirects to the trailing slash, so Swagger UI's relative links work", func() {
+		recorder := serve("/docs")
+		Expect(recorder.Code).To(Equal(http.StatusMovedPermanently))
+		Expect(recorder.Header().Get("Location")).To(Equal("/docs/"))
+	})
+
+	It("404s for files Swagger UI doesn't have", func() {
+		Expect(serve("/docs/nope.js").Code).To(Equal(http.StatusNotFound))
+	})
+})
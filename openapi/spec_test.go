@@ -0,0 +1,256 @@
+package openapi_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/health"
+	"github.com/madeleinesmith/coupons/model/audit"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/openapi"
+	"github.com/madeleinesmith/coupons/validators"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var _ = Describe("The spec", func() {
+	var (
+		couponService *handlersfakes.FakeCouponService
+		auditService  *handlersfakes.FakeCouponAuditService
+		router        *mux.Router
+		scopes        []string
+		boots         *coupon.Coupon
+	)
+
+	BeforeEach(func() {
+		couponService = new(handlersfakes.FakeCouponService)
+		auditService = new(handlersfakes.FakeCouponAuditService)
+		transactor := new(handlersfakes.FakeCouponTransactor)
+		transactor.WithinTransactionStub = func(ctx context.Context, fn func(handlers.CouponService) error) error {
+			return fn(couponService)
+		}
+
+		serializer := coupon.Serializer{}
+		validator := validators.CouponValidator{}
+
+		// the routes as main registers them
+		router = mux.NewRouter()
+		router.Path("/coupons").Handler(handlers.CouponHandler{CouponService: couponService, Serializer: serializer, CouponValidator: validator})
+		router.Path("/coupons/import").Handler(handlers.ImportHandler{CouponTransactor: transactor, CouponValidator: validator})
+		router.Path("/coupons/export").Handler(handlers.ExportHandler{CouponService: couponService})
+		router.Path("/coupon/{couponId}").Handler(handlers.CouponDetailsHandler{CouponService: couponService, Serializer: serializer})
+		router.Path("/coupon/{couponId}/versions/{version}").Handler(handlers.CouponVersionHandler{CouponService: couponService, Serializer: serializer})
+		router.Path("/coupon/{couponId}/history").Handler(handlers.CouponHistoryHandler{AuditService: auditService, Serializer: audit.Serializer{}})
+		router.Path("/operations").Handler(handlers.OperationsHandler{CouponTransactor: transactor, Serializer: serializer, CouponValidator: validator})
+		router.Path("/healthz").HandlerFunc(health.Liveness)
+		router.Path("/readyz").Handler(health.Readiness{Checks: []health.Check{{Name: "database", Checker: health.CheckerFunc(func(ctx context.Context) error {
+			return errors.New("connection refused")
+		})}}})
+		router.Path("/openapi.json").HandlerFunc(openapi.SpecHandler)
+
+		scopes = []string{auth.ScopeCouponsRead, auth.ScopeCouponsWrite}
+
+		name, brand, value, version := "10% off", "boots", 10, 2
+		boots = &coupon.Coupon{ID: "123", Name: &name, Brand: &brand, Value: &value, Version: &version}
+		couponService.CreateCouponReturns(boots, nil)
+		couponService.GetCouponsReturns([]*coupon.Coupon{boots}, nil)
+		couponService.GetCouponByIdReturns(boots, nil)
+		couponService.GetCouponAsOfReturns(boots, nil)
+		couponService.GetCouponVersionReturns(boots, nil)
+		couponService.RevertCouponReturns(boots, nil)
+		couponService.StreamCouponsStub = func(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error {
+			return fn(boots)
+		}
+		auditService.GetCouponHistoryReturns([]*audit.Entry{{
+			ID:        "1",
+			CouponID:  "123",
+			Action:    audit.ActionUpdate,
+			Actor:     "checkout",
+			RequestID: "abc",
+			Changes:   map[string]audit.Change{"value": {Before: 5, After: 10}},
+			CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		}}, nil)
+	})
+
+	serve := func(method string, target string, contentType string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+
+		principal := &auth.Principal{Name: "checkout", Scopes: scopes}
+		req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	const newCoupon = `{"data":{"type":"coupons","attributes":{"name":"10% off","brand":"boots","value":10}}}`
+
+	Describe("CouponHandler", func() {
+		DescribeTable("documents its responses",
+			func(method string, target string, body string, statusCode int, setup func()) {
+				if setup != nil {
+					setup()
+				}
+
+				recorder := serve(method, target, "application/json", body)
+				Expect(recorder.Code).To(Equal(statusCode), recorder.Body.String())
+				expectDocumented("/coupons", method, recorder)
+			},
+			Entry("finding coupons", http.MethodGet, "/coupons?brand=boots&value=10", "", http.StatusOK, nil),
+			Entry("finding no coupons", http.MethodGet, "/coupons", "", http.StatusNotFound, func() {
+				couponService.GetCouponsReturns(nil, errors.New("sql: no rows in result set"))
+			}),
+			Entry("finding a page of coupons", http.MethodGet, "/coupons?page%5Bsize%5D=1", "", http.StatusOK, func() {
+				couponService.GetCouponsReturns([]*coupon.Coupon{boots, boots}, nil)
+			}),
+			Entry("finding a page of coupons after one that isn't a coupon id", http.MethodGet, "/coupons?page%5Bafter%5D=123", "", http.StatusBadRequest, nil),
+			Entry("filtering on a value that isn't a number", http.MethodGet, "/coupons?value=ten", "", http.StatusBadRequest, nil),
+			Entry("finding coupons without the scope", http.MethodGet, "/coupons", "", http.StatusForbidden, func() {
+				scopes = nil
+			}),
+			Entry("failing to find coupons", http.MethodGet, "/coupons", "", http.StatusInternalServerError, func() {
+				couponService.GetCouponsReturns(nil, errors.New("connection reset"))
+			}),
+			Entry("timing out", http.MethodGet, "/coupons", "", http.StatusServiceUnavailable, func() {
+				couponService.GetCouponsReturns(nil, context.DeadlineExceeded)
+			}),
+			Entry("creating a coupon", http.MethodPost, "/coupons", newCoupon, http.StatusCreated, nil),
+			Entry("creating an invalid coupon", http.MethodPost, "/coupons", `{"data":{"type":"coupons","attributes":{"name":"10% off"}}}`, http.StatusBadRequest, nil),
+			Entry("updating a coupon", http.MethodPatch, "/coupons", `{"data":{"type":"coupons","id":"123","attributes":{"value":20}}}`, http.StatusNoContent, nil),
+			Entry("updating a missing coupon", http.MethodPatch, "/coupons", `{"data":{"type":"coupons","id":"123","attributes":{"value":20}}}`, http.StatusNotFound, func() {
+				couponService.UpdateCouponReturns(sql.ErrNoRows)
+			}),
+		)
+
+		It("is described by schemas the responses match, and not by ones they don't", func() {
+			recorder := serve(http.MethodGet, "/coupons", "", "")
+			Expect(recorder.Code).To(Equal(http.StatusOK))
+
+			schema, err := compiler.Compile(pointerTo("components", "schemas", "CouponDocument"))
+			Expect(err).NotTo(HaveOccurred())
+
+			var body interface{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(Succeed())
+			Expect(schema.Validate(body)).To(HaveOccurred())
+		})
+	})
+
+	DescribeTable("the other handlers document their responses",
+		func(route string, method string, target string, contentType string, body string, statusCode int, setup func()) {
+			if setup != nil {
+				setup()
+			}
+
+			recorder := serve(method, target, contentType, body)
+			Expect(recorder.Code).To(Equal(statusCode), recorder.Body.String())
+			expectDocumented(route, method, recorder)
+		},
+		Entry("getting a coupon", "/coupon/{couponId}", http.MethodGet, "/coupon/123", "", "", http.StatusOK, nil),
+		Entry("getting a coupon as it was", "/coupon/{couponId}", http.MethodGet, "/coupon/123?as_of=2026-03-01T12:00:00Z", "", "", http.StatusOK, nil),
+		Entry("getting a coupon at a bad time", "/coupon/{couponId}", http.MethodGet, "/coupon/123?as_of=yesterday", "", "", http.StatusBadRequest, nil),
+		Entry("getting a missing coupon", "/coupon/{couponId}", http.MethodGet, "/coupon/123", "", "", http.StatusNotFound, func() {
+			couponService.GetCouponByIdReturns(nil, sql.ErrNoRows)
+		}),
+		Entry("reverting a coupon", "/coupon/{couponId}", http.MethodPatch, "/coupon/123", "application/json", `{"meta":{"revertToVersion":1}}`, http.StatusOK, nil),
+		Entry("reverting without a version", "/coupon/{couponId}", http.MethodPatch, "/coupon/123", "application/json", `{"meta":{}}`, http.StatusBadRequest, nil),
+		Entry("getting a version", "/coupon/{couponId}/versions/{version}", http.MethodGet, "/coupon/123/versions/1", "", "", http.StatusOK, nil),
+		Entry("getting a version that isn't a number", "/coupon/{couponId}/versions/{version}", http.MethodGet, "/coupon/123/versions/one", "", "", http.StatusBadRequest, nil),
+		Entry("getting a coupon's history", "/coupon/{couponId}/history", http.MethodGet, "/coupon/123/history", "", "", http.StatusOK, nil),
+		Entry("running operations", "/operations", http.MethodPost, "/operations", handlers.AtomicContentType,
+			`{"atomic:operations":[{"op":"add","data":{"type":"coupons","attributes":{"name":"10% off","brand":"boots","value":10}}},{"op":"remove","ref":{"type":"coupons","id":"456"}}]}`,
+			http.StatusOK, nil),
+		Entry("running no operations", "/operations", http.MethodPost, "/operations", handlers.AtomicContentType, `{"atomic:operations":[]}`, http.StatusBadRequest, nil),
+		Entry("importing coupons", "/coupons/import", http.MethodPost, "/coupons/import", "text/csv", "name,brand,value\n10% off,boots,10\n,boots,5\n", http.StatusOK, nil),
+		Entry("importing an unknown format", "/coupons/import", http.MethodPost, "/coupons/import", "application/pdf", "", http.StatusUnsupportedMediaType, nil),
+		Entry("exporting coupons as CSV", "/coupons/export", http.MethodGet, "/coupons/export", "", "", http.StatusOK, nil),
+		Entry("exporting coupons as JSON:API", "/coupons/export", http.MethodGet, "/coupons/export?format=jsonapi", "", "", http.StatusOK, nil),
+		Entry("exporting coupons as an unknown format", "/coupons/export", http.MethodGet, "/coupons/export?format=xml", "", "", http.StatusBadRequest, nil),
+		Entry("checking liveness", "/healthz", http.MethodGet, "/healthz", "", "", http.StatusOK, nil),
+		Entry("checking readiness", "/readyz", http.MethodGet, "/readyz", "", "", http.StatusServiceUnavailable, nil),
+		Entry("getting the spec", "/openapi.json", http.MethodGet, "/openapi.json", "", "", http.StatusOK, nil),
+	)
+
+	It("documents the lines of an NDJSON export", func() {
+		recorder := serve(http.MethodGet, "/coupons/export?format=ndjson", "", "")
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		expectDocumented("/coupons/export", http.MethodGet, recorder)
+
+		schema, err := compiler.Compile(pointerTo("components", "schemas", "ExportedCoupon"))
+		Expect(err).NotTo(HaveOccurred())
+
+		for _, line := range strings.Split(strings.TrimSpace(recorder.Body.String()), "\n") {
+			var exported interface{}
+			Expect(json.Unmarshal([]byte(line), &exported)).To(Succeed())
+			Expect(schema.Validate(exported)).To(Succeed())
+		}
+	})
+
+	It("only has schemas that compile", func() {
+		var walk func(node interface{}, path []string)
+		walk = func(node interface{}, path []string) {
+			switch value := node.(type) {
+			case map[string]interface{}:
+				for key, child := range value {
+					childPath := append(append([]string(nil), path...), key)
+					if key == "schema" {
+						_, err := compiler.Compile(pointerTo(childPath...))
+						Expect(err).NotTo(HaveOccurred(), strings.Join(childPath, "/"))
+						continue
+					}
+
+					walk(child, childPath)
+				}
+			case []interface{}:
+				for i, child := range value {
+					walk(child, append(append([]string(nil), path...), strconv.Itoa(i)))
+				}
+			}
+		}
+
+		walk(spec["paths"], []string{"paths"})
+		walk(spec["components"], []string{"components"})
+
+		for name := range spec["components"].(map[string]interface{})["schemas"].(map[string]interface{}) {
+			_, err := compiler.Compile(pointerTo("components", "schemas", name))
+			Expect(err).NotTo(HaveOccurred(), name)
+		}
+	})
+
+	It("resolves every $ref", func() {
+		var walk func(node interface{})
+		walk = func(node interface{}) {
+			switch value := node.(type) {
+			case map[string]interface{}:
+				if ref, ok := value["$ref"].(string); ok {
+					_, found := lookup(strings.Split(strings.TrimPrefix(ref, "#/"), "/")...)
+					Expect(found).To(BeTrue(), ref)
+				}
+
+				for _, child := range value {
+					walk(child)
+				}
+			case []interface{}:
+				for _, child := range value {
+					walk(child)
+				}
+			}
+		}
+
+		walk(spec)
+	})
+})
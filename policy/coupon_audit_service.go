@@ -0,0 +1,42 @@
+package policy
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/audit"
+)
+
+// CouponAuditService checks reads of a coupon's history against the Policy before passing them on to Next, recording
+// denials as CouponService does. Redemptions are left out of the history for anyone not allowed to view them.
+type CouponAuditService struct {
+	Next    handlers.CouponAuditService
+	Policy  Policy
+	Denials DenialRecorder
+}
+
+func (s CouponAuditService) GetCouponHistory(ctx context.Context, couponId string) ([]*audit.Entry, error) {
+	err := CouponService{Policy: s.Policy, Denials: s.Denials}.authorize(ctx, PermissionViewCoupons, couponId)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := s.Next.GetCouponHistory(ctx, couponId)
+	if err != nil {
+		return nil, err
+	}
+
+	principal, _ := auth.PrincipalFrom(ctx)
+	if s.Policy.Allows(principal, PermissionViewRedemptions) {
+		return entries, nil
+	}
+
+	visibleEntries := []*audit.Entry{}
+	for _, entry := range entries {
+		if entry.Action != audit.ActionRedeem {
+			visibleEntries = append(visibleEntries, entry)
+		}
+	}
+
+	return visibleEntries, nil
+}
@@ -0,0 +1,75 @@
+package policy_test
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/model/audit"
+	"github.com/madeleinesmith/coupons/policy"
+	"github.com/madeleinesmith/coupons/policy/policyfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CouponAuditService", func() {
+	var (
+		fakeAuditService   *handlersfakes.FakeCouponAuditService
+		fakeDenialRecorder *policyfakes.FakeDenialRecorder
+		auditService       policy.CouponAuditService
+		update             *audit.Entry
+		redemption         *audit.Entry
+	)
+
+	asRole := func(role string) context.Context {
+		return auth.WithPrincipal(context.Background(), &auth.Principal{Name: "madeleine", Roles: []string{role}})
+	}
+
+	BeforeEach(func() {
+		fakeAuditService = &handlersfakes.FakeCouponAuditService{}
+		fakeDenialRecorder = &policyfakes.FakeDenialRecorder{}
+
+		update = &audit.Entry{ID: "1", Action: audit.ActionUpdate}
+		redemption = &audit.Entry{ID: "2", Action: audit.ActionRedeem}
+		fakeAuditService.GetCouponHistoryReturns([]*audit.Entry{update, redemption}, nil)
+
+		auditService = policy.CouponAuditService{
+			Next:    fakeAuditService,
+			Policy:  policy.DefaultPolicy,
+			Denials: fakeDenialRecorder,
+		}
+	})
+
+	It("includes redemptions for those allowed to view them", func() {
+		entries, err := auditService.GetCouponHistory(asRole(auth.RoleFinance), "123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal([]*audit.Entry{update, redemption}))
+	})
+
+	It("leaves redemptions out for everyone else", func() {
+		entries, err := auditService.GetCouponHistory(asRole(auth.RoleViewer), "123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(Equal([]*audit.Entry{update}))
+	})
+
+	It("goes by scope for API keys", func() {
+		apiKey := auth.WithPrincipal(context.Background(), &auth.Principal{
+			Name:   "reporting",
+			Scopes: []string{auth.ScopeCouponsRead, auth.ScopeRedemptionsRead},
+		})
+
+		entries, err := auditService.GetCouponHistory(apiKey, "123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+	})
+
+	It("refuses those who can't view coupons and records the denial", func() {
+		_, err := auditService.GetCouponHistory(context.Background(), "123")
+		Expect(err).To(Equal(auth.PermissionDeniedError{Permission: policy.PermissionViewCoupons}))
+
+		Expect(fakeAuditService.GetCouponHistoryCallCount()).To(Equal(0))
+		Expect(fakeDenialRecorder.RecordDenialCallCount()).To(Equal(1))
+		_, permission, couponId := fakeDenialRecorder.RecordDenialArgsForCall(0)
+		Expect(permission).To(Equal(policy.PermissionViewCoupons))
+		Expect(couponId).To(Equal("123"))
+	})
+})
@@ -0,0 +1,145 @@
+package policy
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"time"
+)
+
+//go:generate counterfeiter . DenialRecorder
+type DenialRecorder interface {
+	RecordDenial(ctx context.Context, permission string, couponId string) error
+}
+
+// CouponService checks every call against the Policy before passing it on to Next
+type CouponService struct {
+	Next       handlers.CouponService
+	Transactor handlers.CouponTransactor
+	Policy     Policy
+	Denials    DenialRecorder
+}
+
+func (s CouponService) authorize(ctx context.Context, permission string, couponId string) error {
+	principal, _ := auth.PrincipalFrom(ctx)
+	if s.Policy.Allows(principal, permission) {
+		return nil
+	}
+
+	err := s.Denials.RecordDenial(ctx, permission, couponId)
+	if err != nil {
+		return err
+	}
+
+	return auth.PermissionDeniedError{Permission: permission}
+}
+
+// WithinTransaction keeps enforcing the policy on the service handed to fn
+func (s CouponService) WithinTransaction(ctx context.Context, fn func(handlers.CouponService) error) error {
+	return s.Transactor.WithinTransaction(ctx, func(txService handlers.CouponService) error {
+		return fn(CouponService{Next: txService, Policy: s.Policy, Denials: s.Denials})
+	})
+}
+
+func (s CouponService) CreateCoupon(ctx context.Context, couponInstance coupon.Coupon) (*coupon.Coupon, error) {
+	err := s.authorize(ctx, PermissionCreateCoupons, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Next.CreateCoupon(ctx, couponInstance)
+}
+
+func (s CouponService) CreateCoupons(ctx context.Context, coupons []coupon.Coupon) error {
+	err := s.authorize(ctx, PermissionCreateCoupons, "")
+	if err != nil {
+		return err
+	}
+
+	return s.Next.CreateCoupons(ctx, coupons)
+}
+
+func (s CouponService) UpdateCoupon(ctx context.Context, couponInstance coupon.Coupon) error {
+	if couponInstance.Name != nil || couponInstance.Brand != nil || couponInstance.Expiry != nil {
+		err := s.authorize(ctx, PermissionEditCoupons, couponInstance.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if couponInstance.Value != nil {
+		err := s.authorize(ctx, PermissionChangeValue, couponInstance.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.Next.UpdateCoupon(ctx, couponInstance)
+}
+
+func (s CouponService) GetCoupons(ctx context.Context, filters handlers.Filters) ([]*coupon.Coupon, error) {
+	err := s.authorize(ctx, PermissionViewCoupons, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Next.GetCoupons(ctx, filters)
+}
+
+func (s CouponService) StreamCoupons(ctx context.Context, filters handlers.Filters, fn func(*coupon.Coupon) error) error {
+	err := s.authorize(ctx, PermissionViewCoupons, "")
+	if err != nil {
+		return err
+	}
+
+	return s.Next.StreamCoupons(ctx, filters, fn)
+}
+
+func (s CouponService) GetCouponById(ctx context.Context, couponId string) (*coupon.Coupon, error) {
+	err := s.authorize(ctx, PermissionViewCoupons, couponId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Next.GetCouponById(ctx, couponId)
+}
+
+func (s CouponService) DeleteCoupon(ctx context.Context, couponId string) error {
+	err := s.authorize(ctx, PermissionDeleteCoupons, couponId)
+	if err != nil {
+		return err
+	}
+
+	return s.Next.DeleteCoupon(ctx, couponId)
+}
+
+func (s CouponService) GetCouponAsOf(ctx context.Context, couponId string, asOf time.Time) (*coupon.Coupon, error) {
+	err := s.authorize(ctx, PermissionViewCoupons, couponId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Next.GetCouponAsOf(ctx, couponId, asOf)
+}
+
+func (s CouponService) GetCouponVersion(ctx context.Context, couponId string, version int) (*coupon.Coupon, error) {
+	err := s.authorize(ctx, PermissionViewCoupons, couponId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Next.GetCouponVersion(ctx, couponId, version)
+}
+
+// RevertCoupon can change any field, so it needs both edit and change-value
+func (s CouponService) RevertCoupon(ctx context.Context, couponId string, version int) (*coupon.Coupon, error) {
+	for _, permission := range []string{PermissionEditCoupons, PermissionChangeValue} {
+		err := s.authorize(ctx, permission, couponId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.Next.RevertCoupon(ctx, couponId, version)
+}
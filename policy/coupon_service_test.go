@@ -0,0 +1,131 @@
+package policy_test
+
+import (
+	"context"
+	"errors"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/policy"
+	"github.com/madeleinesmith/coupons/policy/policyfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("CouponService", func() {
+	var (
+		fakeCouponService    *handlersfakes.FakeCouponService
+		fakeCouponTransactor *handlersfakes.FakeCouponTransactor
+		fakeDenialRecorder   *policyfakes.FakeDenialRecorder
+		couponService        policy.CouponService
+	)
+
+	asRole := func(role string) context.Context {
+		return auth.WithPrincipal(context.Background(), &auth.Principal{Name: "madeleine", Roles: []string{role}})
+	}
+
+	BeforeEach(func() {
+		fakeCouponService = &handlersfakes.FakeCouponService{}
+		fakeCouponTransactor = &handlersfakes.FakeCouponTransactor{}
+		fakeDenialRecorder = &policyfakes.FakeDenialRecorder{}
+
+		couponService = policy.CouponService{
+			Next:       fakeCouponService,
+			Transactor: fakeCouponTransactor,
+			Policy:     policy.DefaultPolicy,
+			Denials:    fakeDenialRecorder,
+		}
+	})
+
+	It("passes allowed calls through", func() {
+		name := "Save £5 at Boots"
+		fakeCouponService.CreateCouponReturns(&coupon.Coupon{ID: "123"}, nil)
+
+		createdCoupon, err := couponService.CreateCoupon(asRole(auth.RoleMarketer), coupon.Coupon{Name: &name})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(createdCoupon.ID).To(Equal("123"))
+
+		Expect(fakeCouponService.CreateCouponCallCount()).To(Equal(1))
+		Expect(fakeDenialRecorder.RecordDenialCallCount()).To(Equal(0))
+	})
+
+	It("refuses denied calls and records the denial", func() {
+		ctx := asRole(auth.RoleMarketer)
+
+		err := couponService.DeleteCoupon(ctx, "123")
+		Expect(err).To(Equal(auth.PermissionDeniedError{Permission: policy.PermissionDeleteCoupons}))
+
+		Expect(fakeCouponService.DeleteCouponCallCount()).To(Equal(0))
+		Expect(fakeDenialRecorder.RecordDenialCallCount()).To(Equal(1))
+		recordedCtx, permission, couponId := fakeDenialRecorder.RecordDenialArgsForCall(0)
+		Expect(recordedCtx).To(Equal(ctx))
+		Expect(permission).To(Equal(policy.PermissionDeleteCoupons))
+		Expect(couponId).To(Equal("123"))
+	})
+
+	It("propagates the error if the denial can't be recorded", func() {
+		fakeDenialRecorder.RecordDenialReturns(errors.New("disk full"))
+
+		_, err := couponService.GetCoupons(context.Background(), handlers.Filters{})
+		Expect(err).To(MatchError("disk full"))
+		Expect(fakeCouponService.GetCouponsCallCount()).To(Equal(0))
+	})
+
+	Describe("UpdateCoupon", func() {
+		var name string
+		var value int
+
+		BeforeEach(func() {
+			name = "Save £50 at Boots"
+			value = 50
+		})
+
+		It("lets marketers edit but not change the value", func() {
+			Expect(couponService.UpdateCoupon(asRole(auth.RoleMarketer), coupon.Coupon{ID: "123", Name: &name})).To(Succeed())
+
+			err := couponService.UpdateCoupon(asRole(auth.RoleMarketer), coupon.Coupon{ID: "123", Name: &name, Value: &value})
+			Expect(err).To(Equal(auth.PermissionDeniedError{Permission: policy.PermissionChangeValue}))
+
+			Expect(fakeCouponService.UpdateCouponCallCount()).To(Equal(1))
+		})
+
+		It("lets finance change the value but not edit", func() {
+			Expect(couponService.UpdateCoupon(asRole(auth.RoleFinance), coupon.Coupon{ID: "123", Value: &value})).To(Succeed())
+
+			err := couponService.UpdateCoupon(asRole(auth.RoleFinance), coupon.Coupon{ID: "123", Name: &name})
+			Expect(err).To(Equal(auth.PermissionDeniedError{Permission: policy.PermissionEditCoupons}))
+		})
+
+		It("treats expiring a coupon as editing it", func() {
+			expiry := time.Now()
+			Expect(couponService.UpdateCoupon(asRole(auth.RoleMarketer), coupon.Coupon{ID: "123", Expiry: &expiry})).To(Succeed())
+
+			err := couponService.UpdateCoupon(asRole(auth.RoleFinance), coupon.Coupon{ID: "123", Expiry: &expiry})
+			Expect(err).To(Equal(auth.PermissionDeniedError{Permission: policy.PermissionEditCoupons}))
+		})
+	})
+
+	It("needs both edit and change-value to revert", func() {
+		_, err := couponService.RevertCoupon(asRole(auth.RoleMarketer), "123", 1)
+		Expect(err).To(Equal(auth.PermissionDeniedError{Permission: policy.PermissionChangeValue}))
+
+		_, err = couponService.RevertCoupon(asRole(auth.RoleAdmin), "123", 1)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeCouponService.RevertCouponCallCount()).To(Equal(1))
+	})
+
+	It("keeps enforcing the policy inside transactions", func() {
+		txCouponService := &handlersfakes.FakeCouponService{}
+		fakeCouponTransactor.WithinTransactionStub = func(ctx context.Context, fn func(handlers.CouponService) error) error {
+			return fn(txCouponService)
+		}
+
+		err := couponService.WithinTransaction(asRole(auth.RoleViewer), func(couponService handlers.CouponService) error {
+			return couponService.CreateCoupons(asRole(auth.RoleViewer), []coupon.Coupon{{}})
+		})
+		Expect(err).To(Equal(auth.PermissionDeniedError{Permission: policy.PermissionCreateCoupons}))
+		Expect(txCouponService.CreateCouponsCallCount()).To(Equal(0))
+	})
+})
@@ -0,0 +1,77 @@
+package policy
+
+import (
+	"github.com/madeleinesmith/coupons/auth"
+)
+
+const (
+	PermissionViewCoupons     = "view-coupons"
+	PermissionCreateCoupons   = "create-coupons"
+	PermissionEditCoupons     = "edit-coupons"
+	PermissionChangeValue     = "change-value"
+	PermissionDeleteCoupons   = "delete-coupons"
+	PermissionRedeemCoupons   = "redeem-coupons"
+	PermissionViewRedemptions = "view-redemptions"
+)
+
+// Rule says which roles hold a permission. API keys are granted scopes rather than roles, so they're checked
+// against Scope instead
+type Rule struct {
+	Roles []string
+	Scope string
+}
+
+type Policy map[string]Rule
+
+var DefaultPolicy = Policy{
+	PermissionViewCoupons: {
+		Roles: []string{auth.RoleViewer, auth.RoleMarketer, auth.RoleFinance, auth.RoleAdmin},
+		Scope: auth.ScopeCouponsRead,
+	},
+	PermissionCreateCoupons: {
+		Roles: []string{auth.RoleMarketer, auth.RoleAdmin},
+		Scope: auth.ScopeCouponsWrite,
+	},
+	PermissionEditCoupons: {
+		Roles: []string{auth.RoleMarketer, auth.RoleAdmin},
+		Scope: auth.ScopeCouponsWrite,
+	},
+	PermissionChangeValue: {
+		Roles: []string{auth.RoleFinance, auth.RoleAdmin},
+		Scope: auth.ScopeCouponsWrite,
+	},
+	PermissionDeleteCoupons: {
+		Roles: []string{auth.RoleAdmin},
+		Scope: auth.ScopeCouponsWrite,
+	},
+	PermissionRedeemCoupons: {
+		Roles: []string{auth.RoleFinance, auth.RoleAdmin},
+		Scope: auth.ScopeRedemptionsWrite,
+	},
+	PermissionViewRedemptions: {
+		Roles: []string{auth.RoleFinance, auth.RoleAdmin},
+		Scope: auth.ScopeRedemptionsRead,
+	},
+}
+
+// Allows denies anything it has no rule for, and anyone who isn't authenticated
+func (p Policy) Allows(principal *auth.Principal, permission string) bool {
+	rule, ok := p[permission]
+	if !ok || principal == nil {
+		return false
+	}
+
+	if len(principal.Roles) == 0 {
+		return rule.Scope != "" && principal.HasScope(rule.Scope)
+	}
+
+	for _, role := range principal.Roles {
+		for _, allowedRole := range rule.Roles {
+			if role == allowedRole {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,46 @@
+package policy_test
+
+import (
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/policy"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DefaultPolicy", func() {
+	withRole := func(role string) *auth.Principal {
+		return &auth.Principal{Roles: []string{role}}
+	}
+
+	DescribeTable("decides who may do what",
+		func(role string, permission string, allowed bool) {
+			Expect(policy.DefaultPolicy.Allows(withRole(role), permission)).To(Equal(allowed))
+		},
+		Entry("viewers can view coupons", auth.RoleViewer, policy.PermissionViewCoupons, true),
+		Entry("viewers can't create coupons", auth.RoleViewer, policy.PermissionCreateCoupons, false),
+		Entry("marketers can create coupons", auth.RoleMarketer, policy.PermissionCreateCoupons, true),
+		Entry("marketers can edit coupons", auth.RoleMarketer, policy.PermissionEditCoupons, true),
+		Entry("marketers can't change values", auth.RoleMarketer, policy.PermissionChangeValue, false),
+		Entry("marketers can't delete coupons", auth.RoleMarketer, policy.PermissionDeleteCoupons, false),
+		Entry("finance can change values", auth.RoleFinance, policy.PermissionChangeValue, true),
+		Entry("finance can't edit coupons", auth.RoleFinance, policy.PermissionEditCoupons, false),
+		Entry("finance can't create coupons", auth.RoleFinance, policy.PermissionCreateCoupons, false),
+		Entry("admins can delete coupons", auth.RoleAdmin, policy.PermissionDeleteCoupons, true),
+		Entry("finance can redeem coupons", auth.RoleFinance, policy.PermissionRedeemCoupons, true),
+		Entry("marketers can't redeem coupons", auth.RoleMarketer, policy.PermissionRedeemCoupons, false),
+		Entry("unknown roles can't do anything", "intern", policy.PermissionViewCoupons, false),
+	)
+
+	It("checks API keys, which have no roles, against the permission's scope", func() {
+		apiKey := &auth.Principal{Scopes: []string{auth.ScopeCouponsRead}}
+
+		Expect(policy.DefaultPolicy.Allows(apiKey, policy.PermissionViewCoupons)).To(BeTrue())
+		Expect(policy.DefaultPolicy.Allows(apiKey, policy.PermissionCreateCoupons)).To(BeFalse())
+	})
+
+	It("denies anyone unauthenticated, and anything it has no rule for", func() {
+		Expect(policy.DefaultPolicy.Allows(nil, policy.PermissionViewCoupons)).To(BeFalse())
+		Expect(policy.DefaultPolicy.Allows(withRole(auth.RoleAdmin), "launch-rockets")).To(BeFalse())
+	})
+})
@@ -0,0 +1,115 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package policyfakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/madeleinesmith/coupons/policy"
+)
+
+type FakeDenialRecorder struct {
+	RecordDenialStub        func(context.Context, string, string) error
+	recordDenialMutex       sync.RWMutex
+	recordDenialArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+	}
+	recordDenialReturns struct {
+		result1 error
+	}
+	recordDenialReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeDenialRecorder) RecordDenial(arg1 context.Context, arg2 string, arg3 string) error {
+	fake.recordDenialMutex.Lock()
+	ret, specificReturn := fake.recordDenialReturnsOnCall[len(fake.recordDenialArgsForCall)]
+	fake.recordDenialArgsForCall = append(fake.recordDenialArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 string
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("RecordDenial", []interface{}{arg1, arg2, arg3})
+	fake.recordDenialMutex.Unlock()
+	if fake.RecordDenialStub != nil {
+		return fake.RecordDenialStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.recordDenialReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeDenialRecorder) RecordDenialCallCount() int {
+	fake.recordDenialMutex.RLock()
+	defer fake.recordDenialMutex.RUnlock()
+	return len(fake.recordDenialArgsForCall)
+}
+
+func (fake *FakeDenialRecorder) RecordDenialCalls(stub func(context.Context, string, string) error) {
+	fake.recordDenialMutex.Lock()
+	defer fake.recordDenialMutex.Unlock()
+	fake.RecordDenialStub = stub
+}
+
+func (fake *FakeDenialRecorder) RecordDenialArgsForCall(i int) (context.Context, string, string) {
+	fake.recordDenialMutex.RLock()
+	defer fake.recordDenialMutex.RUnlock()
+	argsForCall := fake.recordDenialArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeDenialRecorder) RecordDenialReturns(result1 error) {
+	fake.recordDenialMutex.Lock()
+	defer fake.recordDenialMutex.Unlock()
+	fake.RecordDenialStub = nil
+	fake.recordDenialReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeDenialRecorder) RecordDenialReturnsOnCall(i int, result1 error) {
+	fake.recordDenialMutex.Lock()
+	defer fake.recordDenialMutex.Unlock()
+	fake.RecordDenialStub = nil
+	if fake.recordDenialReturnsOnCall == nil {
+		fake.recordDenialReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.recordDenialReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeDenialRecorder) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.recordDenialMutex.RLock()
+	defer fake.recordDenialMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeDenialRecorder) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ policy.DenialRecorder = new(FakeDenialRecorder)
@@ -0,0 +1,24 @@
+package policy
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/handlers"
+	"github.com/madeleinesmith/coupons/model/coupon"
+)
+
+// RedemptionService checks redemptions against the Policy before passing them on to Next, recording denials as
+// CouponService does
+type RedemptionService struct {
+	Next    handlers.RedemptionService
+	Policy  Policy
+	Denials DenialRecorder
+}
+
+func (s RedemptionService) RedeemCoupon(ctx context.Context, couponId string) (*coupon.Redemption, error) {
+	err := CouponService{Policy: s.Policy, Denials: s.Denials}.authorize(ctx, PermissionRedeemCoupons, couponId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Next.RedeemCoupon(ctx, couponId)
+}
@@ -0,0 +1,57 @@
+package policy_test
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/handlers/handlersfakes"
+	"github.com/madeleinesmith/coupons/model/coupon"
+	"github.com/madeleinesmith/coupons/policy"
+	"github.com/madeleinesmith/coupons/policy/policyfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RedemptionService", func() {
+	var (
+		fakeRedemptionService *handlersfakes.FakeRedemptionService
+		fakeDenialRecorder    *policyfakes.FakeDenialRecorder
+		redemptionService     policy.RedemptionService
+	)
+
+	asRole := func(role string) context.Context {
+		return auth.WithPrincipal(context.Background(), &auth.Principal{Name: "madeleine", Roles: []string{role}})
+	}
+
+	BeforeEach(func() {
+		fakeRedemptionService = &handlersfakes.FakeRedemptionService{}
+		fakeDenialRecorder = &policyfakes.FakeDenialRecorder{}
+
+		redemptionService = policy.RedemptionService{
+			Next:    fakeRedemptionService,
+			Policy:  policy.DefaultPolicy,
+			Denials: fakeDenialRecorder,
+		}
+	})
+
+	It("passes allowed redemptions through", func() {
+		fakeRedemptionService.RedeemCouponReturns(&coupon.Redemption{ID: "456", CouponID: "123"}, nil)
+
+		redemption, err := redemptionService.RedeemCoupon(asRole(auth.RoleFinance), "123")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(redemption.ID).To(Equal("456"))
+
+		Expect(fakeRedemptionService.RedeemCouponCallCount()).To(Equal(1))
+		Expect(fakeDenialRecorder.RecordDenialCallCount()).To(Equal(0))
+	})
+
+	It("refuses denied redemptions and records the denial", func() {
+		_, err := redemptionService.RedeemCoupon(asRole(auth.RoleMarketer), "123")
+		Expect(err).To(Equal(auth.PermissionDeniedError{Permission: policy.PermissionRedeemCoupons}))
+
+		Expect(fakeRedemptionService.RedeemCouponCallCount()).To(Equal(0))
+		Expect(fakeDenialRecorder.RecordDenialCallCount()).To(Equal(1))
+		_, permission, couponId := fakeDenialRecorder.RecordDenialArgsForCall(0)
+		Expect(permission).To(Equal(policy.PermissionRedeemCoupons))
+		Expect(couponId).To(Equal("123"))
+	})
+})
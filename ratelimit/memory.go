@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+// MemoryLimiter keeps its buckets in this process, so with several instances each one allows the full limit.
+// The zero value is ready to use.
+type MemoryLimiter struct {
+	Now func() time.Time
+
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	limit   Limit
+	tokens  float64
+	updated time.Time
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+	b.updated = now
+}
+
+func (l *MemoryLimiter) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	now := time.Now()
+	if l.Now != nil {
+		now = l.Now()
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.buckets == nil {
+		l.buckets = map[string]*bucket{}
+	}
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		l.buckets[key] = b
+	}
+
+	b.limit = limit
+	b.refill(now)
+
+	if b.tokens < 1 {
+		return Result{Allowed: false, Tokens: b.tokens}, nil
+	}
+
+	b.tokens--
+
+	return Result{Allowed: true, Tokens: b.tokens}, nil
+}
+
+// sweep forgets full buckets, as they're no different from ones that were never used
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.lastSweep = now
+}
@@ -0,0 +1,63 @@
+package ratelimit_test
+
+import (
+	"context"
+	"github.com/madeleinesmith/coupons/ratelimit"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"time"
+)
+
+var _ = Describe("MemoryLimiter", func() {
+	var (
+		now     time.Time
+		limiter *ratelimit.MemoryLimiter
+		limit   ratelimit.Limit
+		ctx     context.Context
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		limiter = &ratelimit.MemoryLimiter{Now: func() time.Time { return now }}
+		limit = ratelimit.Limit{Rate: 1, Burst: 3}
+		ctx = context.Background()
+	})
+
+	It("allows a burst, then refills at the rate", func() {
+		for i := 2; i >= 0; i-- {
+			result, err := limiter.Take(ctx, "checkout", limit)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(ratelimit.Result{Allowed: true, Tokens: float64(i)}))
+		}
+
+		result, err := limiter.Take(ctx, "checkout", limit)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Allowed).To(BeFalse())
+
+		now = now.Add(500 * time.Millisecond)
+		result, _ = limiter.Take(ctx, "checkout", limit)
+		Expect(result).To(Equal(ratelimit.Result{Allowed: false, Tokens: 0.5}))
+
+		now = now.Add(500 * time.Millisecond)
+		result, _ = limiter.Take(ctx, "checkout", limit)
+		Expect(result).To(Equal(ratelimit.Result{Allowed: true, Tokens: 0}))
+	})
+
+	It("never refills past the burst", func() {
+		_, err := limiter.Take(ctx, "checkout", limit)
+		Expect(err).NotTo(HaveOccurred())
+
+		now = now.Add(time.Hour)
+		result, _ := limiter.Take(ctx, "checkout", limit)
+		Expect(result.Tokens).To(Equal(float64(2)))
+	})
+
+	It("keeps a bucket per key", func() {
+		for i := 0; i < 3; i++ {
+			limiter.Take(ctx, "checkout", limit)
+		}
+
+		result, _ := limiter.Take(ctx, "loyalty", limit)
+		Expect(result.Allowed).To(BeTrue())
+	})
+})
@@ -0,0 +1,134 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const (
+	KeyByAPIKey = "api-key"
+	KeyByIP     = "ip"
+	KeyByCoupon = "coupon"
+)
+
+// Limit is a token bucket holding up to Burst tokens, refilled at Rate tokens a second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is whether a token was taken, and how many are left afterwards
+type Result struct {
+	Allowed bool
+	Tokens  float64
+}
+
+//go:generate counterfeiter . Limiter
+type Limiter interface {
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// Rule limits a route separately for each API key, client IP or coupon
+type Rule struct {
+	Limit Limit
+	KeyBy string
+}
+
+// NewRule defaults the burst to a minute's worth of requests
+func NewRule(requestsPerMinute int, burst int, keyBy string) (Rule, error) {
+	if requestsPerMinute <= 0 {
+		return Rule{}, fmt.Errorf("requestsPerMinute must be positive, got %d", requestsPerMinute)
+	}
+
+	if burst < 0 {
+		return Rule{}, fmt.Errorf("burst must not be negative, got %d", burst)
+	}
+
+	if burst == 0 {
+		burst = requestsPerMinute
+	}
+
+	switch keyBy {
+	case KeyByAPIKey, KeyByIP, KeyByCoupon:
+	default:
+		return Rule{}, fmt.Errorf("unknown key %q, expected %s, %s or %s", keyBy, KeyByAPIKey, KeyByIP, KeyByCoupon)
+	}
+
+	return Rule{Limit: Limit{Rate: float64(requestsPerMinute) / 60, Burst: burst}, KeyBy: keyBy}, nil
+}
+
+// Middleware applies the rule for the matched route's name, if there is one. It has to come after authentication
+// for requests to be limited per API key; unauthenticated ones fall back to being limited per IP.
+func Middleware(limiter Limiter, rules map[string]Rule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			route := mux.CurrentRoute(req)
+			if route == nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			routeName := route.GetName()
+			rule, ok := rules[routeName]
+			if !ok {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			key := Key(req.Context(), rule.KeyBy, mux.Vars(req)["couponId"], requestcontext.ClientIP(req))
+			result, err := limiter.Take(req.Context(), routeName+":"+key, rule.Limit)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			setHeaders(w, rule.Limit, result)
+
+			if !result.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(RetryAfter(rule.Limit, result)))
+				http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+// Key is the bucket a request goes in for a rule keyed by keyBy, out of the route it's for. Requests without a
+// principal or coupon id to key by fall back to their client's IP.
+func Key(ctx context.Context, keyBy string, couponId string, clientIP string) string {
+	switch keyBy {
+	case KeyByAPIKey:
+		if principal, ok := auth.PrincipalFrom(ctx); ok {
+			return "principal:" + principal.ID
+		}
+	case KeyByCoupon:
+		if couponId != "" {
+			return "coupon:" + couponId
+		}
+	}
+
+	return "ip:" + clientIP
+}
+
+// RetryAfter is how many seconds until a request refused with result would be allowed
+func RetryAfter(limit Limit, result Result) int {
+	return int(math.Ceil((1 - result.Tokens) / limit.Rate))
+}
+
+// setHeaders sets the RateLimit-* fields from the IETF draft, with the reset being when the bucket will be full again
+func setHeaders(w http.ResponseWriter, limit Limit, result Result) {
+	remaining := int(math.Max(0, math.Floor(result.Tokens)))
+	reset := math.Ceil((float64(limit.Burst) - result.Tokens) / limit.Rate)
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Burst))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(int(math.Max(0, reset))))
+}
@@ -0,0 +1,139 @@
+package ratelimit_test
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/auth"
+	"github.com/madeleinesmith/coupons/ratelimit"
+	"github.com/madeleinesmith/coupons/ratelimit/ratelimitfakes"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Rate limiting", func() {
+	Describe("NewRule", func() {
+		It("converts requests per minute into a rate, with a minute's burst by default", func() {
+			rule, err := ratelimit.NewRule(120, 0, ratelimit.KeyByIP)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rule).To(Equal(ratelimit.Rule{Limit: ratelimit.Limit{Rate: 2, Burst: 120}, KeyBy: ratelimit.KeyByIP}))
+		})
+
+		table.DescribeTable("rejects invalid rules",
+			func(requestsPerMinute int, burst int, keyBy string) {
+				_, err := ratelimit.NewRule(requestsPerMinute, burst, keyBy)
+				Expect(err).To(HaveOccurred())
+			},
+			table.Entry("no rate", 0, 10, ratelimit.KeyByIP),
+			table.Entry("a negative burst", 60, -1, ratelimit.KeyByIP),
+			table.Entry("an unknown key", 60, 10, "user-agent"),
+		)
+	})
+
+	Describe("Middleware", func() {
+		var (
+			fakeLimiter        *ratelimitfakes.FakeLimiter
+			router             *mux.Router
+			recorder           *httptest.ResponseRecorder
+			request            *http.Request
+			nextHandlerInvoked bool
+		)
+
+		BeforeEach(func() {
+			var err error
+
+			fakeLimiter = &ratelimitfakes.FakeLimiter{}
+			fakeLimiter.TakeReturns(ratelimit.Result{Allowed: true, Tokens: 4}, nil)
+
+			nextHandlerInvoked = false
+			nextHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				nextHandlerInvoked = true
+			})
+
+			router = mux.NewRouter()
+			router.NewRoute().Name("coupon").Path("/coupon/{couponId}").Handler(nextHandler)
+			router.NewRoute().Name("coupons").Path("/coupons").Handler(nextHandler)
+			router.Use(ratelimit.Middleware(fakeLimiter, map[string]ratelimit.Rule{
+				"coupon": {Limit: ratelimit.Limit{Rate: 0.5, Burst: 10}, KeyBy: ratelimit.KeyByCoupon},
+			}))
+
+			recorder = httptest.NewRecorder()
+			request, err = http.NewRequest(http.MethodGet, "/coupon/123", nil)
+			Expect(err).NotTo(HaveOccurred())
+			request.RemoteAddr = "203.0.113.7:51234"
+		})
+
+		It("takes a token from the route's bucket and reports what's left", func() {
+			router.ServeHTTP(recorder, request)
+
+			Expect(nextHandlerInvoked).To(BeTrue())
+			Expect(fakeLimiter.TakeCallCount()).To(Equal(1))
+			_, key, limit := fakeLimiter.TakeArgsForCall(0)
+			Expect(key).To(Equal("coupon:coupon:123"))
+			Expect(limit).To(Equal(ratelimit.Limit{Rate: 0.5, Burst: 10}))
+
+			Expect(recorder.Header().Get("RateLimit-Limit")).To(Equal("10"))
+			Expect(recorder.Header().Get("RateLimit-Remaining")).To(Equal("4"))
+			Expect(recorder.Header().Get("RateLimit-Reset")).To(Equal("12"))
+		})
+
+		It("returns 429 once the bucket is empty", func() {
+			fakeLimiter.TakeReturns(ratelimit.Result{Allowed: false, Tokens: 0.25}, nil)
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(nextHandlerInvoked).To(BeFalse())
+			Expect(recorder.Code).To(Equal(http.StatusTooManyRequests))
+			Expect(recorder.Header().Get("RateLimit-Remaining")).To(Equal("0"))
+			Expect(recorder.Header().Get("Retry-After")).To(Equal("2"))
+		})
+
+		It("leaves routes without a rule alone", func() {
+			request.URL.Path = "/coupons"
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(nextHandlerInvoked).To(BeTrue())
+			Expect(fakeLimiter.TakeCallCount()).To(Equal(0))
+			Expect(recorder.Header().Get("RateLimit-Limit")).To(BeEmpty())
+		})
+
+		It("returns 500 if the limiter fails", func() {
+			fakeLimiter.TakeReturns(ratelimit.Result{}, errors.New("connection refused"))
+
+			router.ServeHTTP(recorder, request)
+
+			Expect(nextHandlerInvoked).To(BeFalse())
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		})
+
+		Describe("keys", func() {
+			keyFor := func(keyBy string, req *http.Request) string {
+				router = mux.NewRouter()
+				router.NewRoute().Name("coupon").Path("/coupon/{couponId}").Handler(http.NotFoundHandler())
+				router.Use(ratelimit.Middleware(fakeLimiter, map[string]ratelimit.Rule{
+					"coupon": {Limit: ratelimit.Limit{Rate: 1, Burst: 1}, KeyBy: keyBy},
+				}))
+
+				router.ServeHTTP(httptest.NewRecorder(), req)
+				_, key, _ := fakeLimiter.TakeArgsForCall(fakeLimiter.TakeCallCount() - 1)
+
+				return key
+			}
+
+			It("limits per API key, falling back to the client's IP", func() {
+				Expect(keyFor(ratelimit.KeyByAPIKey, request)).To(Equal("coupon:ip:203.0.113.7"))
+
+				authenticatedRequest := request.WithContext(auth.WithPrincipal(request.Context(), &auth.Principal{ID: "key-1"}))
+				Expect(keyFor(ratelimit.KeyByAPIKey, authenticatedRequest)).To(Equal("coupon:principal:key-1"))
+			})
+
+			It("limits per IP, ignoring X-Forwarded-For", func() {
+				request.Header.Set("X-Forwarded-For", "198.51.100.1")
+				Expect(keyFor(ratelimit.KeyByIP, request)).To(Equal("coupon:ip:203.0.113.7"))
+			})
+		})
+	})
+})
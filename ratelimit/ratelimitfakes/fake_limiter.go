@@ -0,0 +1,120 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package ratelimitfakes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/madeleinesmith/coupons/ratelimit"
+)
+
+type FakeLimiter struct {
+	TakeStub        func(context.Context, string, ratelimit.Limit) (ratelimit.Result, error)
+	takeMutex       sync.RWMutex
+	takeArgsForCall []struct {
+		arg1 context.Context
+		arg2 string
+		arg3 ratelimit.Limit
+	}
+	takeReturns struct {
+		result1 ratelimit.Result
+		result2 error
+	}
+	takeReturnsOnCall map[int]struct {
+		result1 ratelimit.Result
+		result2 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeLimiter) Take(arg1 context.Context, arg2 string, arg3 ratelimit.Limit) (ratelimit.Result, error) {
+	fake.takeMutex.Lock()
+	ret, specificReturn := fake.takeReturnsOnCall[len(fake.takeArgsForCall)]
+	fake.takeArgsForCall = append(fake.takeArgsForCall, struct {
+		arg1 context.Context
+		arg2 string
+		arg3 ratelimit.Limit
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("Take", []interface{}{arg1, arg2, arg3})
+	fake.takeMutex.Unlock()
+	if fake.TakeStub != nil {
+		return fake.TakeStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.takeReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeLimiter) TakeCallCount() int {
+	fake.takeMutex.RLock()
+	defer fake.takeMutex.RUnlock()
+	return len(fake.takeArgsForCall)
+}
+
+func (fake *FakeLimiter) TakeCalls(stub func(context.Context, string, ratelimit.Limit) (ratelimit.Result, error)) {
+	fake.takeMutex.Lock()
+	defer fake.takeMutex.Unlock()
+	fake.TakeStub = stub
+}
+
+func (fake *FakeLimiter) TakeArgsForCall(i int) (context.Context, string, ratelimit.Limit) {
+	fake.takeMutex.RLock()
+	defer fake.takeMutex.RUnlock()
+	argsForCall := fake.takeArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeLimiter) TakeReturns(result1 ratelimit.Result, result2 error) {
+	fake.takeMutex.Lock()
+	defer fake.takeMutex.Unlock()
+	fake.TakeStub = nil
+	fake.takeReturns = struct {
+		result1 ratelimit.Result
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeLimiter) TakeReturnsOnCall(i int, result1 ratelimit.Result, result2 error) {
+	fake.takeMutex.Lock()
+	defer fake.takeMutex.Unlock()
+	fake.TakeStub = nil
+	if fake.takeReturnsOnCall == nil {
+		fake.takeReturnsOnCall = make(map[int]struct {
+			result1 ratelimit.Result
+			result2 error
+		})
+	}
+	fake.takeReturnsOnCall[i] = struct {
+		result1 ratelimit.Result
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeLimiter) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.takeMutex.RLock()
+	defer fake.takeMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeLimiter) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ ratelimit.Limiter = new(FakeLimiter)
@@ -0,0 +1,215 @@
+package requestcontext
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type key int
+
+const (
+	actorKey key = iota
+	requestIDKey
+	tenantKey
+	clientIPKey
+)
+
+const (
+	RequestIDHeader    = "X-Request-ID"
+	ForwardedForHeader = "X-Forwarded-For"
+	AnonymousActor     = "anonymous"
+)
+
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+func Actor(ctx context.Context) string {
+	actor, ok := ctx.Value(actorKey).(string)
+	if !ok || actor == "" {
+		return AnonymousActor
+	}
+
+	return actor
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithTenant is set by authentication, from the API key or token the request was made with
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenant)
+}
+
+// Tenant has no default, unlike Actor, so that nothing can be read or written without one
+func Tenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey).(string)
+	return tenant
+}
+
+// ClientIP is the address the request came from. That's the address it was sent from, unless TrustedProxies found
+// the client behind them.
+func ClientIP(req *http.Request) string {
+	if clientIP, ok := req.Context().Value(clientIPKey).(string); ok {
+		return clientIP
+	}
+
+	return remoteIP(req)
+}
+
+func remoteIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+
+	return host
+}
+
+// TrustedProxies are the load balancers and proxies in front of the service, whose X-Forwarded-For can be believed
+type TrustedProxies []*net.IPNet
+
+func ParseTrustedProxies(cidrs []string) (TrustedProxies, error) {
+	proxies := make(TrustedProxies, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a CIDR such as 10.0.0.0/8", cidr)
+		}
+
+		proxies[i] = network
+	}
+
+	return proxies, nil
+}
+
+// Middleware works out ClientIP for the rest of the request. X-Forwarded-For is ignored unless the request came
+// from a trusted proxy, as any client can set it, and is only followed back through trusted proxies: the first
+// address that isn't one is the client, as anything further back could have been made up by it.
+func (p TrustedProxies) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		clientIP := remoteIP(req)
+
+		var forwardedFor []string
+		for _, header := range req.Header.Values(ForwardedForHeader) {
+			forwardedFor = append(forwardedFor, strings.Split(header, ",")...)
+		}
+
+		for i := len(forwardedFor) - 1; i >= 0 && p.trusts(clientIP); i-- {
+			forwardedIP := strings.TrimSpace(forwardedFor[i])
+			if net.ParseIP(forwardedIP) == nil {
+				break
+			}
+
+			clientIP = forwardedIP
+		}
+
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), clientIPKey, clientIP)))
+	})
+}
+
+func (p TrustedProxies) trusts(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Middleware puts the caller's X-Request-ID on the request context so it ends up in the audit trail and logs. A new
+// one is generated when the caller didn't send one, or sent one we wouldn't want to log, and either way it's echoed
+// back so the caller can quote it.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requestID := AcceptRequestID(req.Header.Get(RequestIDHeader))
+
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, req.WithContext(WithRequestID(req.Context(), requestID)))
+	})
+}
+
+// AcceptRequestID returns the request ID the caller sent, or a new one if they didn't send one or sent one we wouldn't
+// want to log
+func AcceptRequestID(requestID string) string {
+	if !validRequestID(requestID) {
+		return newRequestID()
+	}
+
+	return requestID
+}
+
+const maxRequestIDLength = 128
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, character := range requestID {
+		if character <= ' ' || character > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	// crypto/rand never fails on the platforms we run on
+	rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
+
+// Deadlines gives each request a deadline from routeTimeouts, by route name, or defaultTimeout. Every database query
+// runs with the request's context, so they're cancelled once it passes, as they are when the client disconnects.
+func Deadlines(defaultTimeout time.Duration, routeTimeouts map[string]time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			routeName := ""
+			if route := mux.CurrentRoute(req); route != nil {
+				routeName = route.GetName()
+			}
+
+			ctx, cancel := WithRouteDeadline(req.Context(), routeName, defaultTimeout, routeTimeouts)
+			defer cancel()
+
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
+
+// WithRouteDeadline gives ctx the deadline Deadlines would for the named route, if it has one
+func WithRouteDeadline(ctx context.Context, routeName string, defaultTimeout time.Duration, routeTimeouts map[string]time.Duration) (context.Context, context.CancelFunc) {
+	timeout := defaultTimeout
+	if routeTimeout, ok := routeTimeouts[routeName]; ok {
+		timeout = routeTimeout
+	}
+
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
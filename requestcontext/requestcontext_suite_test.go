@@ -0,0 +1,13 @@
+package requestcontext_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestRequestcontext(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Requestcontext Suite")
+}
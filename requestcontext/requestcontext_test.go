@@ -0,0 +1,207 @@
+package requestcontext_test
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/requestcontext"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+)
+
+var _ = Describe("Request context", func() {
+	It("defaults to an anonymous actor", func() {
+		Expect(requestcontext.Actor(context.Background())).To(Equal(requestcontext.AnonymousActor))
+	})
+
+	It("stores the actor", func() {
+		ctx := requestcontext.WithActor(context.Background(), "madeleine")
+		Expect(requestcontext.Actor(ctx)).To(Equal("madeleine"))
+	})
+
+	It("stores the request ID", func() {
+		Expect(requestcontext.RequestID(context.Background())).To(BeEmpty())
+
+		ctx := requestcontext.WithRequestID(context.Background(), "req-123")
+		Expect(requestcontext.RequestID(ctx)).To(Equal("req-123"))
+	})
+
+	It("stores the tenant, without defaulting it", func() {
+		Expect(requestcontext.Tenant(context.Background())).To(BeEmpty())
+
+		ctx := requestcontext.WithTenant(context.Background(), "tesco")
+		Expect(requestcontext.Tenant(ctx)).To(Equal("tesco"))
+	})
+
+	It("takes the client's IP from the connection rather than X-Forwarded-For", func() {
+		request, err := http.NewRequest(http.MethodGet, "/coupons", nil)
+		Expect(err).NotTo(HaveOccurred())
+		request.RemoteAddr = "203.0.113.7:51234"
+		request.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+		Expect(requestcontext.ClientIP(request)).To(Equal("203.0.113.7"))
+	})
+
+	Describe("TrustedProxies", func() {
+		var (
+			proxies  requestcontext.TrustedProxies
+			request  *http.Request
+			clientIP string
+		)
+
+		BeforeEach(func() {
+			var err error
+			proxies, err = requestcontext.ParseTrustedProxies([]string{"10.0.0.0/8"})
+			Expect(err).NotTo(HaveOccurred())
+
+			request, err = http.NewRequest(http.MethodGet, "/coupons", nil)
+			Expect(err).NotTo(HaveOccurred())
+			request.RemoteAddr = "10.1.2.3:51234"
+		})
+
+		JustBeforeEach(func() {
+			proxies.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				clientIP = requestcontext.ClientIP(req)
+			})).ServeHTTP(httptest.NewRecorder(), request)
+		})
+
+		Context("from a trusted proxy", func() {
+			BeforeEach(func() {
+				request.Header.Set("X-Forwarded-For", "198.51.100.1")
+			})
+
+			It("takes the client's IP from X-Forwarded-For", func() {
+				Expect(clientIP).To(Equal("198.51.100.1"))
+			})
+		})
+
+		Context("through several proxies", func() {
+			BeforeEach(func() {
+				request.Header.Set("X-Forwarded-For", "192.0.2.9, 198.51.100.1, 10.4.5.6")
+			})
+
+			It("stops at the first address that isn't a trusted proxy, as the client could have made up the rest", func() {
+				Expect(clientIP).To(Equal("198.51.100.1"))
+			})
+		})
+
+		Context("from anywhere else", func() {
+			BeforeEach(func() {
+				request.RemoteAddr = "203.0.113.7:51234"
+				request.Header.Set("X-Forwarded-For", "198.51.100.1")
+			})
+
+			It("ignores X-Forwarded-For", func() {
+				Expect(clientIP).To(Equal("203.0.113.7"))
+			})
+		})
+
+		Context("with a forwarded address that isn't an IP", func() {
+			BeforeEach(func() {
+				request.Header.Set("X-Forwarded-For", "unknown")
+			})
+
+			It("keeps the proxy's address", func() {
+				Expect(clientIP).To(Equal("10.1.2.3"))
+			})
+		})
+
+		It("rejects proxies that aren't CIDRs", func() {
+			_, err := requestcontext.ParseTrustedProxies([]string{"10.0.0.1"})
+			Expect(err).To(MatchError(`"10.0.0.1" is not a CIDR such as 10.0.0.0/8`))
+		})
+	})
+
+	Describe("Middleware", func() {
+		var (
+			handler           http.Handler
+			request           *http.Request
+			capturedRequestID string
+		)
+
+		BeforeEach(func() {
+			handler = requestcontext.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				capturedRequestID = requestcontext.RequestID(req.Context())
+			}))
+
+			var err error
+			request, err = http.NewRequest(http.MethodGet, "/coupons", nil)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("puts the X-Request-ID header on the request context, and echoes it back", func() {
+			request.Header.Set("X-Request-ID", "req-123")
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			Expect(capturedRequestID).To(Equal("req-123"))
+			Expect(recorder.Header().Get("X-Request-ID")).To(Equal("req-123"))
+		})
+
+		It("generates a request ID when the caller didn't send one", func() {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			Expect(capturedRequestID).To(MatchRegexp("^[0-9a-f]{32}$"))
+			Expect(recorder.Header().Get("X-Request-ID")).To(Equal(capturedRequestID))
+
+			firstRequestID := capturedRequestID
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+			Expect(capturedRequestID).NotTo(Equal(firstRequestID))
+		})
+
+		It("replaces request IDs that are too long or contain control characters", func() {
+			request.Header.Set("X-Request-ID", strings.Repeat("a", 129))
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+			Expect(capturedRequestID).To(MatchRegexp("^[0-9a-f]{32}$"))
+
+			request.Header.Set("X-Request-ID", "req-123\nfake log line")
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+			Expect(capturedRequestID).To(MatchRegexp("^[0-9a-f]{32}$"))
+		})
+	})
+
+	Describe("Deadlines", func() {
+		var (
+			router           *mux.Router
+			capturedDeadline time.Time
+			hasDeadline      bool
+		)
+
+		BeforeEach(func() {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				capturedDeadline, hasDeadline = req.Context().Deadline()
+			})
+
+			router = mux.NewRouter()
+			router.NewRoute().Name("coupons-export").Path("/coupons/export").Handler(handler)
+			router.NewRoute().Name("coupons").Path("/coupons").Handler(handler)
+			router.Use(requestcontext.Deadlines(time.Second, map[string]time.Duration{"coupons-export": time.Hour}))
+		})
+
+		serve := func(path string) {
+			request, err := http.NewRequest(http.MethodGet, path, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			router.ServeHTTP(httptest.NewRecorder(), request)
+		}
+
+		It("uses the route's timeout", func() {
+			serve("/coupons/export")
+
+			Expect(hasDeadline).To(BeTrue())
+			Expect(capturedDeadline).To(BeTemporally("~", time.Now().Add(time.Hour), time.Second))
+		})
+
+		It("falls back to the default timeout", func() {
+			serve("/coupons")
+
+			Expect(hasDeadline).To(BeTrue())
+			Expect(capturedDeadline).To(BeTemporally("~", time.Now().Add(time.Second), 500*time.Millisecond))
+		})
+	})
+})
@@ -0,0 +1,48 @@
+package response
+
+import (
+	"net/http"
+)
+
+// Recorder notes the status and size of a response as a handler writes it, for middleware to act on once the handler
+// is done. It passes flushes on, and unwraps for http.ResponseController, so wrapping a handler doesn't stop it
+// streaming.
+type Recorder struct {
+	http.ResponseWriter
+	StatusCode   int
+	BytesWritten int64
+	wroteHeader  bool
+}
+
+func NewRecorder(w http.ResponseWriter) *Recorder {
+	return &Recorder{ResponseWriter: w, StatusCode: http.StatusOK}
+}
+
+func (r *Recorder) WriteHeader(statusCode int) {
+	if !r.wroteHeader {
+		r.StatusCode = statusCode
+		r.wroteHeader = true
+	}
+
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *Recorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+
+	written, err := r.ResponseWriter.Write(data)
+	r.BytesWritten += int64(written)
+
+	return written, err
+}
+
+// Flush sends whatever has been written so far, if the writer underneath can
+func (r *Recorder) Flush() {
+	r.wroteHeader = true
+
+	http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *Recorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
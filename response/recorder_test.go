@@ -0,0 +1,51 @@
+package response_test
+
+import (
+	"github.com/madeleinesmith/coupons/response"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+)
+
+var _ = Describe("Recorder", func() {
+	var (
+		underlying *httptest.ResponseRecorder
+		recorder   *response.Recorder
+	)
+
+	BeforeEach(func() {
+		underlying = httptest.NewRecorder()
+		recorder = response.NewRecorder(underlying)
+	})
+
+	It("records the first status written and how much of the body went out", func() {
+		recorder.WriteHeader(http.StatusNotFound)
+		recorder.WriteHeader(http.StatusInternalServerError)
+		recorder.Write([]byte("not found"))
+
+		Expect(recorder.StatusCode).To(Equal(http.StatusNotFound))
+		Expect(recorder.BytesWritten).To(Equal(int64(9)))
+		Expect(underlying.Code).To(Equal(http.StatusNotFound))
+		Expect(underlying.Body.String()).To(Equal("not found"))
+	})
+
+	It("assumes a 200 if the handler writes without a status", func() {
+		recorder.Write([]byte("ok"))
+		recorder.WriteHeader(http.StatusInternalServerError)
+
+		Expect(recorder.StatusCode).To(Equal(http.StatusOK))
+	})
+
+	It("passes flushes through any number of recorders", func() {
+		outer := response.NewRecorder(recorder)
+		outer.Write([]byte("first row\n"))
+
+		Expect(http.NewResponseController(outer).Flush()).To(Succeed())
+		Expect(underlying.Flushed).To(BeTrue())
+	})
+
+	It("unwraps to the writer it records", func() {
+		Expect(recorder.Unwrap()).To(BeIdenticalTo(underlying))
+	})
+})
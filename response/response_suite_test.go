@@ -0,0 +1,13 @@
+package response_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func TestResponse(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Response Suite")
+}
@@ -1,15 +1,10 @@
 #!/bin/bash
 
-# `testing` user must be a superuser role
-
 DB_NAME=${1:-coupons_test}
 DB_PASSWORD=${2:-testingtesting123}
 DB_USER=${3:-testing}
 
 ROOT_DIR_PATH=$(cd $(dirname $0)/.. && pwd)
 
-for FILE in `ls -1 ${ROOT_DIR_PATH}/db/migrations/*up.sql`
-do
-  echo "Executing: `basename ${FILE}`"
-  psql "dbname='${DB_NAME}' password='${DB_PASSWORD}' user='${DB_USER}'" -f ${FILE} 2>${ROOT_DIR_PATH}/allfiles.err.log
-done
\ No newline at end of file
+cd ${ROOT_DIR_PATH} && COUPONS_DB_NAME=${DB_NAME} COUPONS_DB_PASSWORD=${DB_PASSWORD} COUPONS_DB_USER=${DB_USER} \
+  go run . migrate up
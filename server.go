@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/madeleinesmith/coupons/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// managedServer is anything serve can run and shut down gracefully
+type managedServer struct {
+	addr           string
+	listenAndServe func() error
+	shutdown       func(ctx context.Context) error
+	close          func() error
+}
+
+func newServer(port int, handler http.Handler, serverConfiguration model.ServerConfig) managedServer {
+	// the config has been validated, so these can't fail
+	readHeaderTimeout, _ := parseDuration("server.readHeaderTimeout", serverConfiguration.ReadHeaderTimeout, 0)
+	readTimeout, _ := parseDuration("server.readTimeout", serverConfiguration.ReadTimeout, 0)
+	writeTimeout, _ := parseDuration("server.writeTimeout", serverConfiguration.WriteTimeout, 0)
+	idleTimeout, _ := parseDuration("server.idleTimeout", serverConfiguration.IdleTimeout, 0)
+
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+
+	return managedServer{
+		addr:           httpServer.Addr,
+		listenAndServe: httpServer.ListenAndServe,
+		shutdown:       httpServer.Shutdown,
+		close:          httpServer.Close,
+	}
+}
+
+// newGRPCServer tells health checks the server isn't serving as soon as it starts shutting down. The HTTP timeouts
+// don't apply, as callers set their own deadlines and ListCoupons streams for as long as it takes.
+func newGRPCServer(port int, grpcServer *grpc.Server, healthServer *health.Server) managedServer {
+	addr := fmt.Sprintf(":%d", port)
+
+	return managedServer{
+		addr: addr,
+		listenAndServe: func() error {
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				return err
+			}
+
+			return grpcServer.Serve(listener)
+		},
+		shutdown: func(ctx context.Context) error {
+			healthServer.Shutdown()
+
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+
+			select {
+			case <-stopped:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		},
+		close: func() error {
+			grpcServer.Stop()
+			return nil
+		},
+	}
+}
+
+// serve runs the servers until one fails or ctx is done, then stops them all taking new connections and waits up to
+// shutdownTimeout for requests in flight to finish. Anything still running after that has its connection closed.
+// The servers shut down side by side, so each gets the whole timeout, and serve only returns once they all have.
+func serve(ctx context.Context, servers []managedServer, shutdownTimeout time.Duration) error {
+	serverErrors := make(chan error, len(servers))
+
+	for _, server := range servers {
+		go func(server managedServer) {
+			log.Printf("listening on %s", server.addr)
+
+			// a gRPC server returns nil once stopped, where an HTTP one returns ErrServerClosed
+			err := server.listenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				serverErrors <- err
+			}
+		}(server)
+	}
+
+	var err error
+
+	select {
+	case err = <-serverErrors:
+	case <-ctx.Done():
+		log.Printf("shutting down, waiting up to %s for requests in flight", shutdownTimeout)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	var shutdowns sync.WaitGroup
+
+	for _, server := range servers {
+		shutdowns.Add(1)
+
+		go func(server managedServer) {
+			defer shutdowns.Done()
+
+			shutdownErr := server.shutdown(shutdownCtx)
+			if shutdownErr != nil {
+				log.Printf("requests to %s still running after %s, closing their connections", server.addr, shutdownTimeout)
+				server.close()
+			}
+		}(server)
+	}
+
+	shutdowns.Wait()
+
+	return err
+}
@@ -0,0 +1,150 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/response"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net/http"
+	"os"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+
+	instrumentationName = "github.com/madeleinesmith/coupons/tracing"
+)
+
+// Exporters are the values accepted for tracing.exporter
+var Exporters = []string{ExporterNone, ExporterStdout, ExporterFile, ExporterOTLP}
+
+type Config struct {
+	Exporter    string
+	File        string
+	Endpoint    string
+	ServiceName string
+	SampleRatio float64
+}
+
+// Setup installs the W3C traceparent propagator, and a tracer provider sending spans to the configured exporter.
+// With no exporter spans are never recorded, but trace context is still passed on. The returned function flushes
+// any spans not yet exported, and should be called on shutdown.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch config.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		exporter, err = newFileExporter(config.File)
+	case ExporterOTLP:
+		// without an endpoint the exporter falls back to OTEL_EXPORTER_OTLP_ENDPOINT, then localhost:4318
+		var options []otlptracehttp.Option
+		if config.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpointURL(config.Endpoint))
+		}
+
+		exporter, err = otlptracehttp.New(ctx, options...)
+	default:
+		err = fmt.Errorf("unknown tracing exporter %q", config.Exporter)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(config.ServiceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// newFileExporter appends spans to path as JSON, one per line
+func newFileExporter(path string) (sdktrace.SpanExporter, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return closingExporter{SpanExporter: exporter, closer: file}, nil
+}
+
+type closingExporter struct {
+	sdktrace.SpanExporter
+	closer io.Closer
+}
+
+func (e closingExporter) Shutdown(ctx context.Context) error {
+	err := e.SpanExporter.Shutdown(ctx)
+	if closeErr := e.closer.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Middleware starts a server span for each request, named by its route, continuing any trace the caller sent in a
+// traceparent header. Queries made while handling the request are recorded as its children.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		spanName := req.Method
+		attributes := []attribute.KeyValue{semconv.HTTPRequestMethodKey.String(req.Method), semconv.URLPath(req.URL.Path)}
+
+		if route := mux.CurrentRoute(req); route != nil {
+			spanName += " " + route.GetName()
+
+			if template, err := route.GetPathTemplate(); err == nil {
+				attributes = append(attributes, semconv.HTTPRoute(template))
+			}
+		}
+
+		ctx, span := StartServerSpan(req.Context(), propagation.HeaderCarrier(req.Header), spanName, attributes...)
+		defer span.End()
+
+		recorder := response.NewRecorder(w)
+		next.ServeHTTP(recorder, req.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(recorder.StatusCode))
+		if recorder.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(recorder.StatusCode))
+		}
+	})
+}
+
+// StartServerSpan starts the span for a call the service is handling, continuing any trace the caller sent in the
+// carrier's traceparent. Middleware starts one for each request; gRPC calls read theirs from the call's metadata.
+func StartServerSpan(ctx context.Context, carrier propagation.TextMapCarrier, spanName string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+
+	return otel.Tracer(instrumentationName).Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attributes...))
+}
@@ -0,0 +1,112 @@
+package tracing_test
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/madeleinesmith/coupons/tracing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+)
+
+var _ = Describe("Tracing", func() {
+	AfterEach(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	})
+
+	Describe("Middleware", func() {
+		var (
+			spanRecorder   *tracetest.SpanRecorder
+			router         *mux.Router
+			handlerSpan    trace.SpanContext
+			responseStatus int
+		)
+
+		BeforeEach(func() {
+			_, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterNone})
+			Expect(err).NotTo(HaveOccurred())
+
+			spanRecorder = tracetest.NewSpanRecorder()
+			otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+
+			responseStatus = http.StatusOK
+			router = mux.NewRouter()
+			router.NewRoute().Name("coupon").Path("/coupon/{couponId}").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				handlerSpan = trace.SpanContextFromContext(req.Context())
+				w.WriteHeader(responseStatus)
+			})
+			router.Use(tracing.Middleware)
+		})
+
+		It("continues the caller's trace in a span named after the route", func() {
+			request, err := http.NewRequest(http.MethodGet, "/coupon/123", nil)
+			Expect(err).NotTo(HaveOccurred())
+			request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+			router.ServeHTTP(httptest.NewRecorder(), request)
+
+			spans := spanRecorder.Ended()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Name()).To(Equal("GET coupon"))
+			Expect(spans[0].SpanKind()).To(Equal(trace.SpanKindServer))
+			Expect(spans[0].SpanContext().TraceID().String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+			Expect(spans[0].Parent().SpanID().String()).To(Equal("00f067aa0ba902b7"))
+			Expect(spans[0].Attributes()).To(ContainElement(attribute.String("http.route", "/coupon/{couponId}")))
+			Expect(spans[0].Attributes()).To(ContainElement(attribute.Int("http.response.status_code", http.StatusOK)))
+
+			Expect(handlerSpan.SpanID()).To(Equal(spans[0].SpanContext().SpanID()))
+		})
+
+		It("marks server errors as failed", func() {
+			responseStatus = http.StatusInternalServerError
+
+			request, err := http.NewRequest(http.MethodGet, "/coupon/123", nil)
+			Expect(err).NotTo(HaveOccurred())
+			router.ServeHTTP(httptest.NewRecorder(), request)
+
+			Expect(spanRecorder.Ended()[0].Status().Code).To(Equal(codes.Error))
+		})
+	})
+
+	Describe("Setup", func() {
+		It("writes spans to a file, so tracing works without a collector", func() {
+			dir, err := ioutil.TempDir("", "tracing")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			path := filepath.Join(dir, "spans.json")
+
+			shutdown, err := tracing.Setup(context.Background(), tracing.Config{
+				Exporter:    tracing.ExporterFile,
+				File:        path,
+				ServiceName: "coupons",
+				SampleRatio: 1,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, span := otel.Tracer("test").Start(context.Background(), "GET coupon")
+			span.End()
+			Expect(shutdown(context.Background())).To(Succeed())
+
+			spans, err := ioutil.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(spans)).To(ContainSubstring(`"Name":"GET coupon"`))
+		})
+
+		It("rejects exporters it doesn't know", func() {
+			_, err := tracing.Setup(context.Background(), tracing.Config{Exporter: "zipkin"})
+			Expect(err).To(MatchError(`unknown tracing exporter "zipkin"`))
+		})
+	})
+})